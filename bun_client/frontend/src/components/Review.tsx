@@ -28,6 +28,13 @@ interface Comment {
     in_reply_to: number;
     created_at: string;
     outdated: boolean;
+    line?: number;
+    side?: string;
+    start_line?: number;
+    start_side?: string;
+    thread_id?: string;
+    resolved?: boolean;
+    resolved_by?: string;
 }
 
 interface PluginResult {
@@ -165,6 +172,8 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
     const [showPlugins, setShowPlugins] = useState(false);
     const [collapsedFiles, setCollapsedFiles] = useState<Set<string>>(new Set());
     const [activeOutdatedFile, setActiveOutdatedFile] = useState<string | null>(null);
+    // Resolved threads are collapsed unless expanded here (by root comment ID)
+    const [expandedThreads, setExpandedThreads] = useState<Set<string>>(new Set());
 
     const [submitting, setSubmitting] = useState(false);
     const [isSubmittingReview, setIsSubmittingReview] = useState(false);
@@ -173,6 +182,8 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
     // Comment form data
     const [filename, setFilename] = useState('');
     const [position, setPosition] = useState('');
+    // First position of a multi-line comment, set by shift-clicking a second line
+    const [startPosition, setStartPosition] = useState<number | null>(null);
     const [commentBody, setCommentBody] = useState('');
     const [replyToId, setReplyToId] = useState<number | null>(null);
 
@@ -341,6 +352,7 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
     const resetCommentForm = () => {
         setFilename('');
         setPosition('');
+        setStartPosition(null);
         setCommentBody('');
         setReplyToId(null);
         setShowCommentModal(false);
@@ -362,6 +374,8 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
             };
             if (replyToId !== null) {
                 params.ReplyToID = replyToId;
+            } else if (startPosition !== null) {
+                params.StartPosition = startPosition;
             }
             const res = await rpcCall<PRResponse>('RPCHandler.AddComment', [params]);
             setContent(res.content || '');
@@ -377,6 +391,37 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
         }
     };
 
+    const toggleThreadExpanded = (id: string) => {
+        setExpandedThreads(prev => {
+            const next = new Set(prev);
+            if (next.has(id)) {
+                next.delete(id);
+            } else {
+                next.add(id);
+            }
+            return next;
+        });
+    };
+
+    const handleToggleResolved = async (rc: Comment) => {
+        if (!rc.thread_id) return;
+        try {
+            const res = await rpcCall<PRResponse>(rc.resolved ? 'RPCHandler.UnresolveThread' : 'RPCHandler.ResolveThread', [{
+                Owner: owner,
+                Repo: repo,
+                Number: number,
+                ThreadID: rc.thread_id
+            }]);
+            setContent(res.content || '');
+            setDiff(res.diff || '');
+            setComments(res.comments || []);
+            setOutdatedComments(res.outdated_comments || []);
+        } catch (e) {
+            console.error(e);
+            alert('Error updating thread');
+        }
+    };
+
     const handleSubmitReview = async () => {
         setIsSubmittingReview(true);
         try {
@@ -603,7 +648,16 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
         return parsedLines;
     };
 
-    const handleCommentClick = (idx: number, file: string, pos: number) => {
+    const handleCommentClick = (idx: number, file: string, pos: number, extendRange = false) => {
+        if (extendRange && activeLineIndex !== null && activeLineIndex !== idx && filename === file && replyToId === null) {
+            // Shift-click turns the active line into a multi-line comment ending at the later line
+            const current = parseInt(position, 10);
+            setStartPosition(Math.min(current, pos));
+            setPosition(Math.max(current, pos).toString());
+            setActiveLineIndex(pos > current ? idx : activeLineIndex);
+            return;
+        }
+        setStartPosition(null);
         if (activeLineIndex === idx) {
             setActiveLineIndex(null);
             setFilename('');
@@ -973,6 +1027,7 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                         {rootComments.map(rc => {
                             const thread = [rc, ...lineComments.filter(c => c.in_reply_to === parseInt(rc.id, 10))];
                             const isReplyingToThread = replyToId !== null && thread.some(c => parseInt(c.id, 10) === replyToId);
+                            const collapsed = !!rc.resolved && !expandedThreads.has(rc.id);
                             return (
                                 <div
                                     key={rc.id}
@@ -995,8 +1050,24 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                                     title="Click to reply to this thread"
                                 >
                                     <div style={{ background: 'var(--bg-secondary)', padding: '5px 10px', fontSize: '11px', borderBottom: '1px solid var(--border)', color: 'var(--text-secondary)', display: 'flex', justifyContent: 'space-between', alignItems: 'center' }}>
-                                        <span>{rc.author} commented</span>
+                                        <span>{rc.author} commented{rc.resolved && ` · resolved by ${rc.resolved_by || 'someone'}`}</span>
                                         <div style={{ display: 'flex', alignItems: 'center', gap: '8px' }}>
+                                            {rc.resolved && (
+                                                <button
+                                                    onClick={(e) => { e.stopPropagation(); toggleThreadExpanded(rc.id); }}
+                                                    style={{ fontSize: '10px', padding: '2px 6px', cursor: 'pointer' }}
+                                                >
+                                                    {collapsed ? 'Show' : 'Hide'}
+                                                </button>
+                                            )}
+                                            {rc.thread_id && (
+                                                <button
+                                                    onClick={(e) => { e.stopPropagation(); handleToggleResolved(rc); }}
+                                                    style={{ fontSize: '10px', padding: '2px 6px', cursor: 'pointer' }}
+                                                >
+                                                    {rc.resolved ? 'Unresolve' : 'Resolve'}
+                                                </button>
+                                            )}
                                             <span style={{
                                                 fontSize: '10px',
                                                 color: colors.accent,
@@ -1010,7 +1081,7 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                                             <span>ID: {rc.id}</span>
                                         </div>
                                     </div>
-                                    {thread.map(c => (
+                                    {!collapsed && thread.map(c => (
                                         <div key={c.id} style={{ padding: '10px', borderBottom: c.id !== thread[thread.length - 1].id ? '1px solid var(--border)' : 'none' }}>
                                             {c !== rc && <div style={{ fontSize: '11px', color: 'var(--accent)', marginBottom: '5px' }}>Reply by {c.author}:</div>}
                                             <div style={{ whiteSpace: 'pre-wrap' }}>{c.body}</div>
@@ -1182,10 +1253,10 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                                 onClick={(e) => {
                                     if (item.clickable && item.file && item.pos !== null) {
                                         e.stopPropagation();
-                                        handleCommentClick(idx, item.file, item.pos);
+                                        handleCommentClick(idx, item.file, item.pos, e.shiftKey);
                                     }
                                 }}
-                                title={item.clickable ? `Add comment to ${item.file}:${item.pos}` : undefined}
+                                title={item.clickable ? `Add comment to ${item.file}:${item.pos} (shift-click another line for a range)` : undefined}
                             >
                                 {isAddition ? '+' : isDeletion ? '-' : ''}
                             </span>
@@ -1242,6 +1313,7 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                     {rootComments.map(rc => {
                         const thread = [rc, ...lineComments.filter(c => c.in_reply_to === parseInt(rc.id, 10))];
                         const isReplyingToThread = replyToId !== null && thread.some(c => parseInt(c.id, 10) === replyToId);
+                        const collapsed = !!rc.resolved && !expandedThreads.has(rc.id);
                         return (
                             <div
                                 key={rc.id}
@@ -1264,8 +1336,24 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                                 title="Click to reply to this thread"
                             >
                                 <div style={{ background: 'var(--bg-secondary)', padding: '5px 10px', fontSize: '11px', borderBottom: '1px solid var(--border)', color: 'var(--text-secondary)', display: 'flex', justifyContent: 'space-between', alignItems: 'center' }}>
-                                    <span>{rc.author} commented</span>
+                                    <span>{rc.author} commented{rc.resolved && ` · resolved by ${rc.resolved_by || 'someone'}`}</span>
                                     <div style={{ display: 'flex', alignItems: 'center', gap: '8px' }}>
+                                        {rc.resolved && (
+                                            <button
+                                                onClick={(e) => { e.stopPropagation(); toggleThreadExpanded(rc.id); }}
+                                                style={{ fontSize: '10px', padding: '2px 6px', cursor: 'pointer' }}
+                                            >
+                                                {collapsed ? 'Show' : 'Hide'}
+                                            </button>
+                                        )}
+                                        {rc.thread_id && (
+                                            <button
+                                                onClick={(e) => { e.stopPropagation(); handleToggleResolved(rc); }}
+                                                style={{ fontSize: '10px', padding: '2px 6px', cursor: 'pointer' }}
+                                            >
+                                                {rc.resolved ? 'Unresolve' : 'Resolve'}
+                                            </button>
+                                        )}
                                         <span style={{
                                             fontSize: '10px',
                                             color: colors.accent,
@@ -1279,7 +1367,7 @@ export default function Review({ owner, repo, number, theme, onThemeChange }: Re
                                         <span>ID: {rc.id}</span>
                                     </div>
                                 </div>
-                                {thread.map(c => (
+                                {!collapsed && thread.map(c => (
                                     <div key={c.id} style={{ padding: '10px', borderBottom: c.id !== thread[thread.length - 1].id ? '1px solid var(--border)' : 'none' }}>
                                         {c !== rc && <div style={{ fontSize: '11px', color: 'var(--accent)', marginBottom: '5px' }}>Reply by {c.author}:</div>}
                                         <div style={{ whiteSpace: 'pre-wrap' }}>{c.body}</div>
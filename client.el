@@ -35,6 +35,14 @@
 (defvar crs-plugins nil
   "List of plugins configured on the server.")
 
+(defvar crs-notification-functions '(crs--refresh-reviews-on-change)
+  "Functions called with (METHOD PARAMS) for each server-initiated notification.
+The server sends `reviews/changed', `plugin/finished', `pr/updated' and `pr/changed'.")
+
+(defvar crs-show-resolved-threads nil
+  "When non-nil, resolved review threads are rendered in full instead of collapsed.
+Toggle with `crs-toggle-resolved-threads'.")
+
 (defvar crs--section-header-regexp
   "^\\(?:[^[:space:]].*?[[:space:]]\\)?\\(?:\\(?:\\.\\.\\.\\)?\\(?:modified\\|deleted\\|new file\\)[[:space:]:]+.*\\|Commits .*\\|Description\\|Conversation\\|Your Review Feedback\\|Files changed .*\\)$"
   "Regexp to match section headers in the code review buffer.")
@@ -174,6 +182,10 @@ Useful after recompiling the Go server binary."
   (message "DEBUG crs response: %s" response-line)
   (condition-case err
       (let ((response (json-read-from-string response-line)))
+        (if (and (assq 'method response) (null (cdr (assq 'id response))))
+            (run-hook-with-args 'crs-notification-functions
+                                (cdr (assq 'method response))
+                                (cdr (assq 'params response)))
         (let ((id (cdr (assq 'id response)))
               (result (cdr (assq 'result response)))
               (error (cdr (assq 'error response))))
@@ -186,10 +198,16 @@ Useful after recompiling the Go server binary."
             (let ((callback (gethash id crs--pending-requests)))
               (when callback
                 (remhash id crs--pending-requests)
-                (funcall callback result))))))
+                (funcall callback result)))))))
     (error
      (message "Error parsing JSON-RPC response: %s" err))))
 
+(defun crs--refresh-reviews-on-change (method _params)
+  "Reload the reviews buffer when the server reports a `reviews/changed' METHOD."
+  (when (and (equal method "reviews/changed")
+             (get-buffer "* Reviews *"))
+    (crs-get-reviews)))
+
 (defun crs--process-sentinel (process event)
   "Sentinel function for the crs process."
   (when (memq (process-status process) '(exit signal))
@@ -240,6 +258,29 @@ CALLBACK is a function to call with the result."
        (setq crs-plugins (mapcar (lambda (p) (cdr (assq 'Name p))) plugins-list))
        (message "Plugins updated: %d plugins found" (length crs-plugins))))))
 
+(defun crs-show-rate-limit ()
+  "Show the remaining GitHub API budget in the minibuffer."
+  (interactive)
+  (crs--send-request
+   "RPCHandler.GetRateLimit"
+   (vector)
+   (lambda (result)
+     (let ((resources (append (cdr (assq 'resources result)) nil))
+           (backoff (or (cdr (assq 'backoff_seconds result)) 0)))
+       (message "%s%s"
+                (mapconcat (lambda (r)
+                             (format "%s: %s/%s"
+                                     (let ((host (cdr (assq 'host r))))
+                                       (if (and host (not (string= host "github.com")))
+                                           (format "%s %s" host (cdr (assq 'resource r)))
+                                         (cdr (assq 'resource r))))
+                                     (cdr (assq 'remaining r))
+                                     (cdr (assq 'limit r))))
+                           resources ", ")
+                (if (> backoff 0)
+                    (format " (sync paused for %ds)" backoff)
+                  ""))))))
+
 ;;;###autoload
 (defun crs-get-reviews ()
   "Call the GetAllReviews RPC method and display the result in '* Reviews *' buffer."
@@ -261,7 +302,8 @@ CALLBACK is a function to call with the result."
          (insert (or content ""))
          (crs--process-html-placeholders)
          (goto-char (point-min))
-         (org-mode))
+         (org-mode)
+         (add-hook 'org-after-todo-state-change-hook #'crs--todo-state-changed nil t))
        (display-buffer buffer)
        (message "Reviews loaded into '* Reviews *' buffer")))))
 
@@ -336,6 +378,15 @@ CALLBACK is a function to call with the result."
       (when (overlay-get ov 'codereview-hide)
         (delete-overlay ov)))))
 
+(defun crs-toggle-resolved-threads ()
+  "Toggle whether resolved review threads are shown in full."
+  (interactive)
+  (setq crs-show-resolved-threads (not crs-show-resolved-threads))
+  (when crs--buffer-diff
+    (let ((current-line (line-number-at-pos)))
+      (crs--render-and-update (current-buffer) nil current-line)))
+  (message "Resolved threads %s" (if crs-show-resolved-threads "shown" "collapsed")))
+
 (defun crs-toggle-comments ()
   "Toggle visibility of all comments in the current review buffer.
 Re-renders the buffer with or without comments based on the toggle state."
@@ -365,6 +416,14 @@ Re-renders the buffer with or without comments based on the toggle state."
   "RET" #'crs-visit-file
   "<return>" #'crs-visit-file
   "O" #'crs-show-outdated-comments
+  "M" #'crs-move-comment
+  "S" #'crs-add-suggestion
+  "A" #'crs-apply-suggestion
+  "R" #'crs-toggle-resolved-threads
+  "X" #'crs-toggle-thread-resolution
+  "C-c n" #'crs-add-note
+  "C-c N" #'crs-delete-note
+  "C-c x" #'crs-toggle-checklist-item
   "q" #'quit-window
   )
 
@@ -431,6 +490,11 @@ Re-renders the buffer with or without comments based on the toggle state."
     "f" #'crs-set-review-feedback
     "RET" #'crs-visit-file
     "O" #'crs-show-outdated-comments
+    "M" #'crs-move-comment
+    "S" #'crs-add-suggestion
+    "A" #'crs-apply-suggestion
+    "R" #'crs-toggle-resolved-threads
+    "X" #'crs-toggle-thread-resolution
     "q" #'quit-window)
   ;; Define keys for visual state
   (evil-define-key 'visual my-code-review-mode-map
@@ -451,6 +515,11 @@ Re-renders the buffer with or without comments based on the toggle state."
     "f" #'crs-set-review-feedback
     "RET" #'crs-visit-file
     "O" #'crs-show-outdated-comments
+    "M" #'crs-move-comment
+    "S" #'crs-add-suggestion
+    "A" #'crs-apply-suggestion
+    "R" #'crs-toggle-resolved-threads
+    "X" #'crs-toggle-thread-resolution
     "q" #'quit-window)
   ;; Define keys for insert state
   (evil-define-key 'insert my-code-review-mode-map
@@ -482,28 +551,39 @@ Re-renders the buffer with or without comments based on the toggle state."
            (id (cdr (assq 'id root)))
            (created (cdr (assq 'created_at root)))
            (author (cdr (assq 'author root)))
-           (header (cond (outdated "    ┌─ REVIEW COMMENT [OUTDATED] ──────")
+           (resolved (eq (cdr (assq 'resolved root)) t))
+           (collapsed (and resolved (not crs-show-resolved-threads)))
+           (header (cond ((eq (cdr (assq 'private root)) t) "    ┌─ PRIVATE NOTE ───────────────────")
+                         (outdated "    ┌─ REVIEW COMMENT [OUTDATED] ──────")
+                         (resolved "    ┌─ REVIEW COMMENT [RESOLVED] ──────")
                          ((not (cdr (assq 'position root))) "    ┌─ FILE COMMENT ───────────────────")
                          (t "    ┌─ REVIEW COMMENT ─────────────────")))
            (lines (list "    │"
                         (format "    │ %s : %s" (or created "") (or id "")) ;; Simplification: not merging authors
                         (format "    │ File: %s" file)
                         header)))
-      ;; Render root comment
-      (push (format "    │ [%s]:" (or author "local")) lines)
-      (push (crs--make-html-placeholder (cdr (assq 'body root)) "    │   ") lines)
-
-      ;; Render replies
-      (dolist (reply replies)
-        (push "    │" lines)
-        (let ((r-author (cdr (assq 'author reply)))
-              (r-id (cdr (assq 'id reply))))
-          (push (format "    │ Reply by [%s]:[%s]" (or r-author "local") (or r-id "")) lines)
-          (push (crs--make-html-placeholder (cdr (assq 'body reply)) "    │   ") lines)))
-
-      (push "    └──────────────────────────────────" lines)
-      (push "" lines)
-      (mapconcat #'identity (nreverse lines) "\n"))))
+      (if (and collapsed (not (equal (cdr (assq 'in_reply_to root)) 0)))
+          ;; Replies in a collapsed thread are summarised by its root.
+          ""
+        (if collapsed
+            (push (format "    │ Resolved by %s (R to show resolved threads)"
+                          (or (cdr (assq 'resolved_by root)) "someone"))
+                  lines)
+          ;; Render root comment
+          (push (format "    │ [%s]:" (or author "local")) lines)
+          (push (crs--make-html-placeholder (cdr (assq 'body root)) "    │   ") lines)
+
+          ;; Render replies
+          (dolist (reply replies)
+            (push "    │" lines)
+            (let ((r-author (cdr (assq 'author reply)))
+                  (r-id (cdr (assq 'id reply))))
+              (push (format "    │ Reply by [%s]:[%s]" (or r-author "local") (or r-id "")) lines)
+              (push (crs--make-html-placeholder (cdr (assq 'body reply)) "    │   ") lines))))
+
+        (push "    └──────────────────────────────────" lines)
+        (push "" lines)
+        (mapconcat #'identity (nreverse lines) "\n")))))
 
 (defun crs--format-compact-comment-indicator (comments)
   "Format a compact comment indicator for COMMENTS.
@@ -781,8 +861,9 @@ SHOW-FULL-COMMENTS determines whether to show full content or indicators."
           ;; Normal insert
           (insert content))))))
 
-(defun crs--render-header-from-metadata (metadata)
-  "Render the PR header from METADATA alist."
+(defun crs--render-header-from-metadata (metadata &optional notes checklists)
+  "Render the PR header from METADATA alist.
+Private NOTES and review CHECKLISTS, if any, are rendered in drawers."
   (if (null metadata)
       ""
     (let ((number (cdr (assq 'number metadata)))
@@ -846,6 +927,37 @@ SHOW-FULL-COMMENTS determines whether to show full content or indicators."
                 (setq sb (concat sb (format "  - %s\n" fail))))))
         (setq sb (concat sb "CI Status: \tUnknown\n")))
 
+      (when notes
+        (setq sb (concat sb ":NOTES:\n"))
+        (dolist (note notes)
+          (let ((path (cdr (assq 'path note)))
+                (line (cdr (assq 'line note))))
+            (setq sb (concat sb "- "
+                             (cond ((and path line) (format "%s:%s: " path line))
+                                   (path (format "%s: " path))
+                                   (t ""))
+                             (replace-regexp-in-string
+                              "\n" "\n  " (string-trim-right (or (cdr (assq 'body note)) "")))
+                             "\n"))))
+        (setq sb (concat sb ":END:\n")))
+
+      (when checklists
+        (setq sb (concat sb ":CHECKLISTS:\n"))
+        (dolist (checklist checklists)
+          (let ((items (append (cdr (assq 'items checklist)) nil)))
+            (setq sb (concat sb (format "%s [%d/%d]\n"
+                                        (cdr (assq 'name checklist))
+                                        (seq-count (lambda (item) (eq (cdr (assq 'checked item)) t)) items)
+                                        (length items))))
+            (dolist (item items)
+              (setq sb (concat sb
+                               (format "- [%s] %s" (if (eq (cdr (assq 'checked item)) t) "X" " ")
+                                       (cdr (assq 'text item)))
+                               (if (eq (cdr (assq 'required item)) t) " (required)" "")
+                               (if (eq (cdr (assq 'stale item)) t) " (checked at an earlier commit)" "")
+                               "\n")))))
+        (setq sb (concat sb ":END:\n")))
+
       ;; Description/Body (rendered as HTML)
       (setq sb (concat sb "\nDescription\n"))
       (setq sb (concat sb (crs--make-html-placeholder body) "\n"))
@@ -927,6 +1039,8 @@ If CONTENT is nil, re-renders from stored data (useful for toggle operations)."
           (new-metadata nil)
           (new-reviews nil)
           (new-preamble nil)
+          (new-notes nil)
+          (new-checklists nil)
           (new-show-comments (if (local-variable-p 'crs--buffer-show-comments)
                                  crs--buffer-show-comments
                                t))
@@ -937,6 +1051,8 @@ If CONTENT is nil, re-renders from stored data (useful for toggle operations)."
           (existing-metadata crs--buffer-metadata)
           (existing-reviews crs--buffer-reviews)
           (existing-preamble crs--buffer-preamble)
+          (existing-notes crs--buffer-notes)
+          (existing-checklists crs--buffer-checklists)
           (existing-review-feedback crs--buffer-review-feedback))
 
       ;; If content is a JSON result (alist), extract the components
@@ -964,7 +1080,14 @@ If CONTENT is nil, re-renders from stored data (useful for toggle operations)."
           (setq new-outdated-comments outdated-comments)
           (setq new-metadata metadata)
           (setq new-reviews reviews)
-          (setq new-preamble preamble)))
+          (setq new-preamble preamble)
+          ;; Only some replies carry notes; keep the stored ones otherwise
+          (when (assq 'notes content)
+            (setq new-notes (append (cdr (assq 'notes content)) nil)
+                  existing-notes nil))
+          (when (assq 'checklists content)
+            (setq new-checklists (append (cdr (assq 'checklists content)) nil)
+                  existing-checklists nil))))
 
       ;; Temporarily set for rendering (before mode change wipes them)
       (setq crs--buffer-diff (or new-diff existing-diff))
@@ -973,6 +1096,8 @@ If CONTENT is nil, re-renders from stored data (useful for toggle operations)."
       (setq crs--buffer-metadata (or new-metadata existing-metadata))
       (setq crs--buffer-reviews (or new-reviews existing-reviews))
       (setq crs--buffer-preamble (or new-preamble existing-preamble))
+      (setq crs--buffer-notes (or new-notes existing-notes))
+      (setq crs--buffer-checklists (or new-checklists existing-checklists))
       (setq crs--buffer-show-comments new-show-comments)
       (setq crs--buffer-review-feedback existing-review-feedback)
 
@@ -1001,7 +1126,8 @@ If CONTENT is nil, re-renders from stored data (useful for toggle operations)."
         (crs--insert-comments-into-buffer crs--buffer-comments crs--buffer-show-comments)
 
         ;; 5. Insert Preamble & Feedback at TOP
-        (let* ((header (crs--render-header-from-metadata crs--buffer-metadata))
+        (let* ((header (crs--render-header-from-metadata crs--buffer-metadata crs--buffer-notes
+                                                         crs--buffer-checklists))
                (conversation (crs--render-conversation-from-data
                               crs--buffer-comments
                               crs--buffer-reviews
@@ -1035,6 +1161,8 @@ If CONTENT is nil, re-renders from stored data (useful for toggle operations)."
       (setq crs--buffer-metadata (or new-metadata existing-metadata))
       (setq crs--buffer-reviews (or new-reviews existing-reviews))
       (setq crs--buffer-preamble (or new-preamble existing-preamble))
+      (setq crs--buffer-notes (or new-notes existing-notes))
+      (setq crs--buffer-checklists (or new-checklists existing-checklists))
       (setq crs--buffer-show-comments new-show-comments)
       (setq crs--buffer-review-feedback existing-review-feedback)
 
@@ -1102,6 +1230,198 @@ The line should contain a URL in the format https://github.com/OWNER/REPO/pull/N
           (crs-get-review owner repo number))
       (error "Could not find GitHub PR URL on current line"))))
 
+(defun crs-mark-notification-read ()
+  "Mark the GitHub notification of the review item at point as read."
+  (interactive)
+  (let ((notification-id
+         (save-excursion
+           (org-back-to-heading t)
+           (let ((end (save-excursion (outline-next-heading) (point))))
+             (when (re-search-forward "^\\s-*Notification: \\([0-9]+\\)" end t)
+               (match-string-no-properties 1))))))
+    (if (not notification-id)
+        (message "No notification at point")
+      (crs--send-request
+       "RPCHandler.MarkNotificationRead"
+       (vector (list (cons 'NotificationID notification-id)))
+       (lambda (result)
+         (let ((err (cdr (assq 'error result))))
+           (if err
+               (message "Error marking notification read: %s" (if (stringp err) err (cdr (assq 'message err))))
+             (message "Marked notification read")
+             (crs-get-reviews))))))))
+
+(defun crs--review-item-at-point ()
+  "Return (OWNER REPO NUMBER) of the review item at point in the reviews buffer."
+  (save-excursion
+    (org-back-to-heading t)
+    (let ((end (save-excursion (outline-next-heading) (point)))
+          number)
+      (forward-line 1)
+      (when (looking-at "^\\s-*\\([0-9]+\\)\\s-*$")
+        (setq number (string-to-number (match-string-no-properties 1))))
+      (when (and number (re-search-forward "^\\s-*Repo: \\(.+\\)/\\([^/\n]+\\)$" end t))
+        (list (match-string-no-properties 1) (match-string-no-properties 2) number)))))
+
+(defun crs--hide-request (method params success)
+  "Call METHOD with PARAMS, then report SUCCESS and reload the reviews."
+  (crs--send-request
+   method
+   (vector params)
+   (lambda (result)
+     (let ((err (cdr (assq 'error result))))
+       (if err
+           (message "Error: %s" (if (stringp err) err (cdr (assq 'message err))))
+         (message "%s" success)
+         (crs-get-reviews))))))
+
+(defun crs-snooze-item (until until-activity)
+  "Snooze the review item at point until UNTIL or, with UNTIL-ACTIVITY, new activity.
+UNTIL is a date like 2024-05-13, a duration like 4h or 3d, or empty."
+  (interactive
+   (list (read-string "Snooze until (date, 4h, 3d or empty): ")
+         (y-or-n-p "Wake on new activity? ")))
+  (let ((item (crs--review-item-at-point)))
+    (if (not item)
+        (message "No review item at point")
+      (crs--hide-request
+       "RPCHandler.SnoozeItem"
+       (list (cons 'Owner (nth 0 item))
+             (cons 'Repo (nth 1 item))
+             (cons 'Number (nth 2 item))
+             (cons 'Until until)
+             (cons 'UntilActivity (if until-activity t :json-false)))
+       (format "Snoozed %s/%s #%d" (nth 0 item) (nth 1 item) (nth 2 item))))))
+
+(defun crs-unsnooze-item ()
+  "Pick a snoozed PR and bring it back."
+  (interactive)
+  (crs--send-request
+   "RPCHandler.ListMutes"
+   (vector (list))
+   (lambda (result)
+     (let ((identifiers (mapcar (lambda (snooze) (cdr (assq 'identifier snooze)))
+                                (append (cdr (assq 'snoozes result)) nil))))
+       (if (not identifiers)
+           (message "No snoozed items")
+         (let ((identifier (completing-read "Unsnooze: " identifiers nil t)))
+           (when (string-match "\\`\\(.+\\)/\\([^/]+\\)-\\([0-9]+\\)\\'" identifier)
+             (crs--hide-request
+              "RPCHandler.UnsnoozeItem"
+              (list (cons 'Owner (match-string 1 identifier))
+                    (cons 'Repo (match-string 2 identifier))
+                    (cons 'Number (string-to-number (match-string 3 identifier))))
+              (format "Unsnoozed %s" identifier)))))))))
+
+(defun crs-mute-item ()
+  "Mute the review item at point until its mute rule is removed."
+  (interactive)
+  (let ((item (crs--review-item-at-point)))
+    (if (not item)
+        (message "No review item at point")
+      (crs--hide-request
+       "RPCHandler.MuteItem"
+       (list (cons 'Owner (nth 0 item))
+             (cons 'Repo (nth 1 item))
+             (cons 'Number (nth 2 item)))
+       (format "Muted %s/%s #%d" (nth 0 item) (nth 1 item) (nth 2 item))))))
+
+(defun crs-add-mute-rule (kind value)
+  "Mute every PR whose KIND (author, label or repo) is VALUE."
+  (interactive
+   (list (completing-read "Mute by: " '("author" "label" "repo") nil t)
+         (read-string "Value: ")))
+  (crs--hide-request
+   "RPCHandler.AddMuteRule"
+   (list (cons 'Kind kind) (cons 'Value value))
+   (format "Muted PRs with %s %s" kind value)))
+
+(defun crs-remove-mute-rule ()
+  "Pick a mute rule and remove it."
+  (interactive)
+  (crs--send-request
+   "RPCHandler.ListMutes"
+   (vector (list))
+   (lambda (result)
+     (let* ((rules (append (cdr (assq 'rules result)) nil))
+            (choices (mapcar (lambda (rule)
+                               (cons (format "%s: %s" (cdr (assq 'kind rule)) (cdr (assq 'value rule)))
+                                     (cdr (assq 'id rule))))
+                             rules)))
+       (if (not choices)
+           (message "No mute rules")
+         (let ((id (cdr (assoc (completing-read "Remove mute rule: " choices nil t) choices))))
+           (crs--hide-request
+            "RPCHandler.RemoveMuteRule"
+            (list (cons 'ID id))
+            "Removed mute rule")))))))
+
+(defun crs-set-item-status (status)
+  "Give the review item at point STATUS, kept across syncs; empty to use the synced status."
+  (interactive
+   (list (completing-read "Status (empty for synced): " '("TODO" "WAITING" "DONE" "CANCELLED"))))
+  (let ((item (crs--review-item-at-point)))
+    (if (not item)
+        (message "No review item at point")
+      (crs--hide-request
+       "RPCHandler.SetItemStatus"
+       (list (cons 'Owner (nth 0 item))
+             (cons 'Repo (nth 1 item))
+             (cons 'Number (nth 2 item))
+             (cons 'Status status))
+       (if (string-empty-p status)
+           (format "%s/%s #%d follows the synced status" (nth 0 item) (nth 1 item) (nth 2 item))
+         (format "%s/%s #%d is %s" (nth 0 item) (nth 1 item) (nth 2 item) status))))))
+
+(defun crs--todo-state-changed ()
+  "Store a TODO keyword changed in the reviews buffer as the item's status."
+  (let ((item (crs--review-item-at-point)))
+    (when item
+      (crs--send-request
+       "RPCHandler.SetItemStatus"
+       (vector (list (cons 'Owner (nth 0 item))
+                     (cons 'Repo (nth 1 item))
+                     (cons 'Number (nth 2 item))
+                     (cons 'Status (or org-state ""))))
+       (lambda (result)
+         (let ((err (cdr (assq 'error result))))
+           (when err
+             (message "Error setting status: %s" (if (stringp err) err (cdr (assq 'message err)))))))))))
+
+(defun crs-reload-config ()
+  "Reload codereviewserver.toml in the running server."
+  (interactive)
+  (crs--send-request
+   "RPCHandler.ReloadConfig"
+   (vector (list))
+   (lambda (result)
+     (let ((err (cdr (assq 'error result)))
+           (errors (cdr (assq 'errors result))))
+       (cond
+        (err (message "Error reloading config: %s" (if (stringp err) err (cdr (assq 'message err)))))
+        ((> (length errors) 0)
+         (message "Config not reloaded: %s" (mapconcat #'identity errors "; ")))
+        (t (message "Config reloaded")
+           (crs-get-reviews)))))))
+
+(defun crs-doctor ()
+  "Check the config, credentials, repos, plugins and database and show the report."
+  (interactive)
+  (crs--send-request
+   "RPCHandler.Doctor"
+   (vector (list))
+   (lambda (result)
+     (let ((err (cdr (assq 'error result))))
+       (if err
+           (message "Error running doctor: %s" (if (stringp err) err (cdr (assq 'message err))))
+         (with-current-buffer (get-buffer-create "* CRS Doctor *")
+           (let ((inhibit-read-only t))
+             (erase-buffer)
+             (insert (cdr (assq 'report result))))
+           (special-mode)
+           (goto-char (point-min))
+           (display-buffer (current-buffer))))))))
+
 (defun crs-visit-file ()
   "Visit the file at point in the code review buffer."
   (interactive)
@@ -1150,6 +1470,9 @@ The line should contain a URL in the format https://github.com/OWNER/REPO/pull/N
                           (path (or (cdr (assq 'path c)) ""))
                           (body (or (cdr (assq 'body c)) "(No body)")))
                       (insert (format "[%s] %s on %s:\n" author time path))
+                      (when (eq (cdr (assq 'orphaned c)) t)
+                        (insert (format "  (orphaned by a push: move with `crs-move-comment' (M), id %s)\n"
+                                        (cdr (assq 'id c)))))
                       (crs--insert-html body "  ")
                       (insert "\n\n──────────────────────────────────\n\n")))
                   comments))
@@ -1191,11 +1514,15 @@ Returns a list (owner repo number) or signals an error if not in a review buffer
 (defvar-local crs--comment-number nil)
 (defvar-local crs--comment-filename nil)
 (defvar-local crs--comment-position nil)
+(defvar-local crs--comment-start-position nil
+  "First diff position of a multi-line comment, or nil for a single line.")
 (defvar-local crs--comment-reply-to-id nil)
 (defvar-local crs--comment-editing-id nil
   "When non-nil, we're editing an existing local comment with this ID.")
 (defvar-local crs--comment-original-line nil
   "The line number in the review buffer where the comment was started.")
+(defvar-local crs--comment-suggestion nil
+  "When non-nil, the buffer holds replacement lines for a suggested change.")
 
 ;; Buffer-local variables for storing PR data separately
 (defvar-local crs--buffer-diff nil
@@ -1214,6 +1541,10 @@ Returns a list (owner repo number) or signals an error if not in a review buffer
   "Whether to show comments in the buffer. Toggle with `crs-toggle-comments'.")
 (defvar-local crs--buffer-review-feedback nil
   "The review feedback for the current PR.")
+(defvar-local crs--buffer-notes nil
+  "The private notes on the current PR.")
+(defvar-local crs--buffer-checklists nil
+  "The review checklists attached to the current PR.")
 
 (defun crs-submit-comment ()
   "Submit the comment in the current buffer."
@@ -1228,9 +1559,34 @@ Returns a list (owner repo number) or signals an error if not in a review buffer
         (original-line crs--comment-original-line)
         (position (if crs--comment-reply-to-id
                       nil
-                    crs--comment-position)))
-    (if (string-match-p "\\`[[:space:]\n]*\\'" body)
-        (message "Comment is empty, not submitting.")
+                    crs--comment-position))
+        (start-position (unless crs--comment-reply-to-id
+                          crs--comment-start-position)))
+    (cond
+     (crs--comment-suggestion
+      ;; An empty buffer is a valid suggestion: it deletes the lines.
+      (crs--send-request
+       "RPCHandler.AddSuggestion"
+       (vector (list (cons 'Owner owner)
+                     (cons 'Repo repo)
+                     (cons 'Number number)
+                     (cons 'Filename filename)
+                     (cons 'Position position)
+                     (cons 'StartPosition (or start-position 0))
+                     (cons 'Replacement body)
+                     (cons 'Body (read-string "Explanation (optional): "))))
+       (lambda (result)
+         (let ((err (cdr (assq 'error result))))
+           (if err
+               (message "Error adding suggestion: %s" (if (stringp err) err (cdr (assq 'message err))))
+             (let ((review-buffer (get-buffer (format "* Review %s/%s #%d *" owner repo number))))
+               (when review-buffer
+                 (crs--render-and-update review-buffer result original-line))
+               (message "Suggestion added successfully")
+               (kill-buffer-and-window)))))))
+     ((string-match-p "\\`[[:space:]\n]*\\'" body)
+      (message "Comment is empty, not submitting."))
+     (t
       (if editing-id
           ;; Editing an existing local comment
           (crs--send-request
@@ -1257,6 +1613,7 @@ Returns a list (owner repo number) or signals an error if not in a review buffer
                        (cons 'Number number)
                        (cons 'Filename filename)
                        (cons 'Position position)
+                       (cons 'StartPosition (or start-position 0))
                        (cons 'ReplyToID reply-to-id)
                        (cons 'Body body)))
          (lambda (result)
@@ -1267,7 +1624,7 @@ Returns a list (owner repo number) or signals an error if not in a review buffer
                  (when review-buffer
                    (crs--render-and-update review-buffer result original-line))
                  (message "Comment added successfully")
-                 (kill-buffer-and-window))))))))))
+                 (kill-buffer-and-window)))))))))))
 
 (defun crs-abort-comment ()
   "Abort the comment in the current buffer."
@@ -1505,20 +1862,41 @@ If on a local comment, local-comment-id and local-comment-body will be set."
       (message (concat "Position:" (prin1-to-string position)))
       ctx)))
 
-(defun crs-add-or-edit-comment (owner repo number filename position &optional reply-to-id local-comment-id local-comment-body line)
+(defun crs--region-start-position (filename)
+  "Return the diff position at the start of the active region in FILENAME.
+Returns nil when there is no region or it starts in a different file."
+  (when (use-region-p)
+    (let ((ctx (save-excursion
+                 (goto-char (region-beginning))
+                 (crs--get-comment-context))))
+      (when (equal (nth 3 ctx) filename)
+        (nth 4 ctx)))))
+
+(defun crs-add-or-edit-comment (owner repo number filename position &optional reply-to-id local-comment-id local-comment-body line start-position)
   "Open a buffer to add or edit a comment on a review.
 If on a local comment, opens it for editing with the existing body pre-filled.
+With an active region, the comment spans every diff line in the region.
 If called interactively, attempts to guess parameters from context."
   (interactive
-   (let ((ctx (crs--get-comment-context)))
+   (let ((ctx (save-excursion
+                ;; Anchor a region comment on its last line, like GitHub does.
+                (when (use-region-p)
+                  (goto-char (region-end))
+                  (when (and (bolp) (> (point) (region-beginning)))
+                    (backward-char)))
+                (crs--get-comment-context))))
      ;; For editing, we need local-comment-id; for adding, we need position or reply-to-id
      (unless (and (nth 0 ctx) (nth 3 ctx) (or (nth 4 ctx) (nth 5 ctx) (nth 6 ctx)))
        (error "Could not determine context (Owner: %S, Repo: %S, Num: %S, File: %S, Pos: %S, ReplyID: %S, LocalID: %S). Buffer: %S"
               (nth 0 ctx) (nth 1 ctx) (nth 2 ctx) (nth 3 ctx) (nth 4 ctx) (nth 5 ctx) (nth 6 ctx) (buffer-name)))
-     ctx))
+     (append ctx (list (crs--region-start-position (nth 3 ctx))))))
   (let ((buffer (get-buffer-create (format "*Comment Edit %s/%s #%d*" owner repo number)))
         (editing (not (null local-comment-id)))
         (original-line (line-number-at-pos)))
+    ;; The rendered text of a suggestion is a mini diff, so edit the raw body instead.
+    (when editing
+      (setq local-comment-body (or (crs--local-comment-raw-body local-comment-id)
+                                   local-comment-body)))
     (with-current-buffer buffer
       (comment-edit-mode)
       (erase-buffer)
@@ -1527,6 +1905,7 @@ If called interactively, attempts to guess parameters from context."
       (setq crs--comment-number number)
       (setq crs--comment-filename filename)
       (setq crs--comment-position position)
+      (setq crs--comment-start-position (unless (equal start-position position) start-position))
       (setq crs--comment-reply-to-id reply-to-id)
       (setq crs--comment-editing-id local-comment-id)
       (setq crs--comment-original-line original-line)
@@ -1542,6 +1921,176 @@ If called interactively, attempts to guess parameters from context."
 ;; Alias for backwards compatibility
 (defalias 'crs-add-comment 'crs-add-or-edit-comment)
 
+(defun crs--local-comment-raw-body (id)
+  "Return the stored body of local comment ID in the current review, if known."
+  (let ((comment (seq-find (lambda (c) (equal (cdr (assq 'id c)) (format "%d" id)))
+                           crs--buffer-comments)))
+    (when (and comment (equal (cdr (assq 'author comment)) "local"))
+      (cdr (assq 'body comment)))))
+
+(defun crs--region-new-lines ()
+  "Return the new-file lines of the diff in the active region, without their diff prefix.
+Removed lines, hunk headers and comment blocks are skipped."
+  (let ((lines nil))
+    (save-excursion
+      (goto-char (region-beginning))
+      (beginning-of-line)
+      (while (and (< (point) (region-end)) (not (eobp)))
+        (let ((line (buffer-substring-no-properties (line-beginning-position) (line-end-position))))
+          (unless (or (string-match-p "^[[:cntrl:][:space:]]*[│┌└]" line)
+                      (string-prefix-p "@@" line)
+                      (string-prefix-p "-" line)
+                      (string-empty-p line))
+            (push (substring line 1) lines)))
+        (forward-line 1)))
+    (nreverse lines)))
+
+(defun crs-add-suggestion ()
+  "Suggest a replacement for the diff lines in the region, or the line at point.
+The edit buffer starts with the current lines; edit them into the suggested
+text and press C-c C-c. Only lines of the new version can be replaced."
+  (interactive)
+  (let* ((ctx (save-excursion
+                (when (use-region-p)
+                  (goto-char (region-end))
+                  (when (and (bolp) (> (point) (region-beginning)))
+                    (backward-char)))
+                (crs--get-comment-context)))
+         (owner (nth 0 ctx))
+         (repo (nth 1 ctx))
+         (number (nth 2 ctx))
+         (filename (nth 3 ctx))
+         (position (nth 4 ctx))
+         (start-position (crs--region-start-position filename))
+         (original (if (use-region-p)
+                       (crs--region-new-lines)
+                     (let ((line (buffer-substring-no-properties (line-beginning-position) (line-end-position))))
+                       (unless (string-prefix-p "-" line)
+                         (list (substring line (min 1 (length line))))))))
+         (original-line (line-number-at-pos)))
+    (unless (and owner filename position (> position 0))
+      (user-error "Point is not on a diff line"))
+    (unless original
+      (user-error "Suggestions can only replace added or unchanged lines"))
+    (let ((buffer (get-buffer-create (format "*Suggestion %s/%s #%d*" owner repo number))))
+      (with-current-buffer buffer
+        (comment-edit-mode)
+        (erase-buffer)
+        (setq crs--comment-owner owner)
+        (setq crs--comment-repo repo)
+        (setq crs--comment-number number)
+        (setq crs--comment-filename filename)
+        (setq crs--comment-position position)
+        (setq crs--comment-start-position (unless (equal start-position position) start-position))
+        (setq crs--comment-reply-to-id nil)
+        (setq crs--comment-editing-id nil)
+        (setq crs--comment-original-line original-line)
+        (setq crs--comment-suggestion t)
+        (insert (string-join original "\n")))
+      (switch-to-buffer-other-window buffer)
+      (message "Edit the lines into the suggested change, then C-c C-c"))))
+
+(defun crs-apply-suggestion ()
+  "Apply the local suggestion at point to the PR's worktree."
+  (interactive)
+  (let ((comment-info (crs--get-local-comment-at-point)))
+    (if (not comment-info)
+        (message "Not on a local comment")
+      (crs--send-request
+       "RPCHandler.ApplySuggestion"
+       (vector (list (cons 'Owner (plist-get comment-info :owner))
+                     (cons 'Repo (plist-get comment-info :repo))
+                     (cons 'Number (plist-get comment-info :number))
+                     (cons 'ID (plist-get comment-info :id))))
+       (lambda (result)
+         (let ((err (cdr (assq 'error result))))
+           (if err
+               (message "Error applying suggestion: %s" (if (stringp err) err (cdr (assq 'message err))))
+             (message "Applied suggestion to %s" (cdr (assq 'path result))))))))))
+
+(defun crs--orphaned-comments ()
+  "Return the local comments of the current review that were orphaned by a push."
+  (seq-filter (lambda (c) (eq (cdr (assq 'orphaned c)) t))
+              crs--buffer-outdated-comments))
+
+(defun crs-move-comment ()
+  "Move an orphaned local comment to the diff line at point.
+With an active region, the comment spans every diff line in the region."
+  (interactive)
+  (let ((orphans (crs--orphaned-comments)))
+    (when (seq-empty-p orphans)
+      (user-error "No orphaned comments to move"))
+    (let* ((choices (mapcar (lambda (c)
+                              (cons (format "%s: %s" (cdr (assq 'id c))
+                                            (truncate-string-to-width
+                                             (replace-regexp-in-string "\n" " " (or (cdr (assq 'body c)) ""))
+                                             60 nil nil "..."))
+                                    c))
+                            orphans))
+           (comment (cdr (assoc (completing-read "Move comment: " choices nil t) choices)))
+           (ctx (save-excursion
+                  (when (use-region-p)
+                    (goto-char (region-end))
+                    (when (and (bolp) (> (point) (region-beginning)))
+                      (backward-char)))
+                  (crs--get-comment-context)))
+           (owner (nth 0 ctx))
+           (repo (nth 1 ctx))
+           (number (nth 2 ctx))
+           (filename (nth 3 ctx))
+           (position (nth 4 ctx))
+           (start-position (crs--region-start-position filename))
+           (original-line (line-number-at-pos)))
+      (unless (and filename position (> position 0))
+        (user-error "Point is not on a diff line"))
+      (crs--send-request
+       "RPCHandler.MoveComment"
+       (vector (list (cons 'Owner owner)
+                     (cons 'Repo repo)
+                     (cons 'Number number)
+                     (cons 'ID (string-to-number (cdr (assq 'id comment))))
+                     (cons 'Filename filename)
+                     (cons 'Position position)
+                     (cons 'StartPosition (if (equal start-position position) 0 (or start-position 0)))))
+       (lambda (result)
+         (let ((err (cdr (assq 'error result))))
+           (if err
+               (message "Error moving comment: %s" (if (stringp err) err (cdr (assq 'message err))))
+             (let ((review-buffer (get-buffer (format "* Review %s/%s #%d *" owner repo number))))
+               (when review-buffer
+                 (crs--render-and-update review-buffer result original-line))
+               (message "Comment moved")))))))))
+
+(defun crs-toggle-thread-resolution ()
+  "Resolve the GitHub review thread at point, or unresolve it if it is resolved."
+  (interactive)
+  (let* ((ctx (crs--get-comment-context))
+         (owner (nth 0 ctx))
+         (repo (nth 1 ctx))
+         (number (nth 2 ctx))
+         (comment-id (nth 5 ctx))
+         (comment (and comment-id
+                       (seq-find (lambda (c) (equal (cdr (assq 'id c)) (format "%d" comment-id)))
+                                 (append crs--buffer-comments crs--buffer-outdated-comments nil))))
+         (resolved (and comment (eq (cdr (assq 'resolved comment)) t)))
+         (original-line (line-number-at-pos)))
+    (unless (and comment (cdr (assq 'thread_id comment)))
+      (user-error "Not on a GitHub review thread"))
+    (crs--send-request
+     (if resolved "RPCHandler.UnresolveThread" "RPCHandler.ResolveThread")
+     (vector (list (cons 'Owner owner)
+                   (cons 'Repo repo)
+                   (cons 'Number number)
+                   (cons 'ThreadID (cdr (assq 'thread_id comment)))))
+     (lambda (result)
+       (let ((err (cdr (assq 'error result))))
+         (if err
+             (message "Error updating thread: %s" (if (stringp err) err (cdr (assq 'message err))))
+           (let ((review-buffer (get-buffer (format "* Review %s/%s #%d *" owner repo number))))
+             (when review-buffer
+               (crs--render-and-update review-buffer result original-line))
+             (message "Thread %s" (if resolved "unresolved" "resolved")))))))))
+
 (defun crs--get-local-comment-at-point ()
   "Get the local comment ID at point, or nil if not on a local comment.
 Returns a plist with :id, :owner, :repo, :number if on a local comment."
@@ -1606,6 +2155,109 @@ If not on a local comment, displays a warning message."
                      (crs--render-and-update review-buffer result))
                    (message "Local comment deleted")))))))))))
 
+(defun crs--note-id-at-point ()
+  "Return the ID of the private note block at point, or nil."
+  (save-excursion
+    (end-of-line)
+    (when (and (string-match-p "^    [│┌└]" (buffer-substring-no-properties (line-beginning-position) (line-end-position)))
+               (re-search-backward "^    ┌─ " nil t)
+               (looking-at "    ┌─ PRIVATE NOTE"))
+      (forward-line 2)
+      (when (looking-at ".* : note-\\([0-9]+\\)$")
+        (string-to-number (match-string 1))))))
+
+(defun crs--note-request (method params success)
+  "Call METHOD with PARAMS for the current review, then report SUCCESS and re-render it."
+  (let ((buffer (current-buffer)))
+    (crs--send-request
+     method
+     (vector params)
+     (lambda (result)
+       (let ((err (cdr (assq 'error result))))
+         (if err
+             (message "Error: %s" (if (stringp err) err (cdr (assq 'message err))))
+           (when (buffer-live-p buffer)
+             (crs--render-and-update buffer result))
+           (message "%s" success)))))))
+
+(defun crs-add-note (body &optional whole-pr)
+  "Add a private note with BODY on the diff line at point, or on the PR with WHOLE-PR.
+Notes stay in the local database and are never submitted with a review."
+  (interactive (list (read-string "Private note: ") current-prefix-arg))
+  (let* ((ctx (crs--get-comment-context))
+         (filename (unless whole-pr (nth 3 ctx))))
+    (unless (and (nth 0 ctx) (nth 1 ctx) (nth 2 ctx))
+      (user-error "Not in a review buffer"))
+    (crs--note-request
+     "RPCHandler.AddNote"
+     (list (cons 'Owner (nth 0 ctx))
+           (cons 'Repo (nth 1 ctx))
+           (cons 'Number (nth 2 ctx))
+           (cons 'Body body)
+           (cons 'Filename (or filename ""))
+           (cons 'Position (or (and filename (nth 4 ctx)) 0)))
+     (if filename (format "Added a private note on %s" filename) "Added a private note"))))
+
+(defun crs-delete-note ()
+  "Delete the private note at point, or pick one of the current review's notes."
+  (interactive)
+  (let* ((ctx (crs--get-comment-context))
+         (id (or (crs--note-id-at-point)
+                 (let ((choices (mapcar (lambda (note)
+                                          (cons (format "%s%s"
+                                                        (let ((path (cdr (assq 'path note))))
+                                                          (if path (format "%s: " path) ""))
+                                                        (car (split-string (cdr (assq 'body note)) "\n")))
+                                                (cdr (assq 'id note))))
+                                        crs--buffer-notes)))
+                   (unless choices
+                     (user-error "No private notes on this PR"))
+                   (cdr (assoc (completing-read "Delete note: " choices nil t) choices))))))
+    (crs--note-request
+     "RPCHandler.DeleteNote"
+     (list (cons 'Owner (nth 0 ctx))
+           (cons 'Repo (nth 1 ctx))
+           (cons 'Number (nth 2 ctx))
+           (cons 'ID id))
+     "Private note deleted")))
+
+(defun crs-toggle-checklist-item ()
+  "Check or uncheck an item of the review checklists attached to the current PR.
+Items are checked at the PR's head commit, so new commits leave them to check again."
+  (interactive)
+  (let* ((ctx (crs--get-comment-context))
+         (buffer (current-buffer))
+         (choices (mapcan (lambda (checklist)
+                            (let ((name (cdr (assq 'name checklist))))
+                              (mapcar (lambda (item)
+                                        (let ((text (cdr (assq 'text item))))
+                                          (cons (format "[%s] %s: %s"
+                                                        (if (eq (cdr (assq 'checked item)) t) "X" " ")
+                                                        name text)
+                                                (cons name text))))
+                                      (append (cdr (assq 'items checklist)) nil))))
+                          crs--buffer-checklists)))
+    (unless choices
+      (user-error "No review checklists on this PR"))
+    (let ((choice (cdr (assoc (completing-read "Toggle checklist item: " choices nil t) choices))))
+      (crs--send-request
+       "RPCHandler.ToggleChecklistItem"
+       (vector (list (cons 'Owner (nth 0 ctx))
+                     (cons 'Repo (nth 1 ctx))
+                     (cons 'Number (nth 2 ctx))
+                     (cons 'Checklist (car choice))
+                     (cons 'Item (cdr choice))))
+       (lambda (result)
+         (let ((err (cdr (assq 'error result))))
+           (if err
+               (message "Error: %s" (if (stringp err) err (cdr (assq 'message err))))
+             (when (buffer-live-p buffer)
+               (with-current-buffer buffer
+                 (setq crs--buffer-checklists (append (cdr (assq 'checklists result)) nil))
+                 (crs--render-and-update buffer nil (line-number-at-pos))))
+             (message "%s %s" (if (eq (cdr (assq 'checked result)) t) "Checked" "Unchecked")
+                      (cdr choice)))))))))
+
 (defun crs-submit-review (event)
   "Submit a review with EVENT.
 The body is taken from `crs--buffer-review-feedback`.
@@ -1640,8 +2292,14 @@ If the body is empty, prompts the user."
              (when review-buffer
                (with-current-buffer review-buffer
                  (setq crs--buffer-review-feedback nil)
-                 (crs--render-and-update review-buffer result)))
-             (message "Review submitted successfully!"))))))))
+                 ;; No content means the PR couldn't be refreshed after the review went in
+                 (unless (string-empty-p (or (cdr (assq 'content result)) ""))
+                   (crs--render-and-update review-buffer result))))
+             (let ((jira-actions (cdr (assq 'jira_actions result))))
+               (if (> (length jira-actions) 0)
+                   (message "Review submitted successfully! Jira: %s"
+                            (mapconcat #'identity jira-actions "; "))
+                 (message "Review submitted successfully!"))))))))))
 
 (defun crs-set-review-feedback ()
   "Set the review feedback for the current PR."
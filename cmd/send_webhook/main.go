@@ -0,0 +1,63 @@
+// send_webhook posts a signed GitHub webhook to a local `codereviewserver -webhook` listener,
+// standing in for GitHub when testing. The payload is read from a file or stdin.
+//
+//	send_webhook -url http://localhost:8080 -event pull_request payload.json
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/", "Webhook listener URL")
+	event := flag.String("event", "pull_request", "GitHub event name sent as X-GitHub-Event")
+	flag.Parse()
+
+	secret := os.Getenv("CRS_WEBHOOK_SECRET")
+	if secret == "" {
+		fmt.Println("CRS_WEBHOOK_SECRET is not set")
+		os.Exit(1)
+	}
+
+	var payload []byte
+	var err error
+	if flag.NArg() > 0 {
+		payload, err = os.ReadFile(flag.Arg(0))
+	} else {
+		payload, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Printf("Error reading payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	req, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(payload))
+	if err != nil {
+		fmt.Printf("Error building request: %v\n", err)
+		os.Exit(1)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", *event)
+	req.Header.Set("X-GitHub-Delivery", "send_webhook")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error sending webhook: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Printf("%s %s\n", resp.Status, bytes.TrimSpace(body))
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// RepoConfigFile is the file a repo can check in at its root to define its own checklists.
+const RepoConfigFile = ".crs.toml"
+
+// Checklist is a list of review checks attached to the PRs it matches: PRs in Repos (all repos
+// if empty) that change a file matching Paths or carry one of Labels. A checklist with neither
+// Paths nor Labels matches every PR in its repos.
+type Checklist struct {
+	Name   string
+	Repos  []string // "owner/repo"; ignored in a repo's .crs.toml, which only applies to that repo
+	Paths  []string // Globs of changed files; ** spans directories, and a glob without a / matches file names at any depth
+	Labels []string
+	Items  []ChecklistItem
+}
+
+type ChecklistItem struct {
+	Text     string
+	Required bool // SubmitReview refuses APPROVE while it is unchecked, when ChecklistsBlockApprove is set
+}
+
+// Matches reports whether the checklist applies to a PR in owner/repo changing files and labeled labels.
+func (c Checklist) Matches(owner, repo string, files, labels []string) bool {
+	if len(c.Repos) > 0 && !slices.ContainsFunc(c.Repos, func(r string) bool { return strings.EqualFold(r, owner+"/"+repo) }) {
+		return false
+	}
+	if len(c.Paths) == 0 && len(c.Labels) == 0 {
+		return true
+	}
+	for _, label := range labels {
+		if slices.ContainsFunc(c.Labels, func(l string) bool { return strings.EqualFold(l, label) }) {
+			return true
+		}
+	}
+	for _, file := range files {
+		for _, pattern := range c.Paths {
+			if MatchGlob(pattern, file) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated file name against a glob in which ** matches any number of
+// directories. A glob without a / is matched against the base name, so "*.sql" matches "db/x.sql".
+func MatchGlob(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func validateChecklists(checklists []Checklist) error {
+	names := map[string]bool{}
+	for i, checklist := range checklists {
+		if checklist.Name == "" {
+			return fmt.Errorf("Checklists[%d]: a checklist needs a Name", i)
+		}
+		if names[checklist.Name] {
+			return fmt.Errorf("duplicate checklist name found: %s", checklist.Name)
+		}
+		names[checklist.Name] = true
+		if len(checklist.Items) == 0 {
+			return fmt.Errorf("checklist %s: no Items", checklist.Name)
+		}
+		for _, item := range checklist.Items {
+			if strings.TrimSpace(item.Text) == "" {
+				return fmt.Errorf("checklist %s: Items need a Text", checklist.Name)
+			}
+		}
+		for _, pattern := range checklist.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("checklist %s: invalid Paths glob %q", checklist.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// RepoDir returns where the local clone of repo lives under RepoLocation.
+func RepoDir(repo string) string {
+	return C.RepoDir(repo)
+}
+
+// RepoDir returns where the local clone of repo lives under c's RepoLocation, with ~ expanded;
+// an empty repo gives RepoLocation itself.
+func (c *Config) RepoDir(repo string) string {
+	location := c.RepoLocation
+	if strings.HasPrefix(location, "~") {
+		if home, err := UserHomeDir(); err == nil {
+			location = strings.Replace(location, "~", home, 1)
+		}
+	}
+	return filepath.Join(location, repo)
+}
+
+// LoadRepoChecklists reads the checklists from the .crs.toml in dir, returning none if there
+// is no such file.
+func LoadRepoChecklists(dir string) ([]Checklist, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RepoConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var repoConfig struct {
+		Checklists []Checklist
+	}
+	if err := toml.Unmarshal(data, &repoConfig); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, RepoConfigFile), err)
+	}
+	if err := validateChecklists(repoConfig.Checklists); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, RepoConfigFile), err)
+	}
+	for i := range repoConfig.Checklists {
+		repoConfig.Checklists[i].Repos = nil
+	}
+	return repoConfig.Checklists, nil
+}
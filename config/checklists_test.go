@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"db/migrations/**", "db/migrations/0001_init.sql", true},
+		{"db/migrations/**", "db/migrations/2024/0002.sql", true},
+		{"db/migrations/**", "db/schema.sql", false},
+		{"**/flags.go", "internal/features/flags.go", true},
+		{"**/flags.go", "flags.go", true},
+		{"docs/*.md", "docs/index.md", true},
+		{"docs/*.md", "docs/api/index.md", false},
+		{"*.sql", "db/migrations/0001_init.sql", true},
+		{"/go.mod", "go.mod", true},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChecklistMatches(t *testing.T) {
+	checklist := Checklist{Name: "Migrations", Repos: []string{"org/api"}, Paths: []string{"db/migrations/**"}, Labels: []string{"schema"}}
+	tests := []struct {
+		name          string
+		repo          string
+		files, labels []string
+		want          bool
+	}{
+		{"changed path", "api", []string{"main.go", "db/migrations/0001.sql"}, nil, true},
+		{"label", "api", []string{"main.go"}, []string{"Schema"}, true},
+		{"no match", "api", []string{"main.go"}, []string{"bug"}, false},
+		{"other repo", "web", []string{"db/migrations/0001.sql"}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := checklist.Matches("org", tt.repo, tt.files, tt.labels); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(Checklist{Name: "Always"}).Matches("org", "web", nil, nil) {
+		t.Errorf("a checklist without Paths or Labels should match every PR")
+	}
+}
+
+func TestLoadRepoChecklists(t *testing.T) {
+	dir := t.TempDir()
+	if checklists, err := LoadRepoChecklists(dir); err != nil || checklists != nil {
+		t.Errorf("LoadRepoChecklists() without a file = %v, %v, want nothing", checklists, err)
+	}
+
+	content := `[[Checklists]]
+Name = "Migrations"
+Repos = ["org/other"]
+Paths = ["db/migrations/**"]
+Items = [
+  { Text = "Migration is reversible", Required = true },
+  { Text = "Backfill is batched" },
+]
+`
+	if err := os.WriteFile(filepath.Join(dir, RepoConfigFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checklists, err := LoadRepoChecklists(dir)
+	if err != nil {
+		t.Fatalf("LoadRepoChecklists() error = %v", err)
+	}
+	if len(checklists) != 1 || len(checklists[0].Items) != 2 || !checklists[0].Items[0].Required || checklists[0].Repos != nil {
+		t.Errorf("LoadRepoChecklists() = %+v, want one checklist for this repo only", checklists)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, RepoConfigFile), []byte("[[Checklists]]\nName = \"Empty\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRepoChecklists(dir); err == nil {
+		t.Errorf("LoadRepoChecklists() with an empty checklist: want an error")
+	}
+}
+
+func TestParseConfig_Checklists(t *testing.T) {
+	tests := []struct {
+		content string
+		wantErr bool
+	}{
+		{content: "ChecklistsBlockApprove = true\n[[Checklists]]\nName = \"Docs\"\nLabels = [\"api\"]\nItems = [{ Text = \"Docs updated\" }]\n"},
+		{content: "[[Checklists]]\nItems = [{ Text = \"Docs updated\" }]\n", wantErr: true},
+		{content: "[[Checklists]]\nName = \"Docs\"\nPaths = [\"docs/[\"]\nItems = [{ Text = \"Docs updated\" }]\n", wantErr: true},
+		{content: "[[Checklists]]\nName = \"Docs\"\nItems = [{ Text = \"a\" }]\n[[Checklists]]\nName = \"Docs\"\nItems = [{ Text = \"b\" }]\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseConfig([]byte(tt.content))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseConfig(%q) error = %v, wantErr %v", tt.content, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (!got.ChecklistsBlockApprove || len(got.Checklists) != 1) {
+			t.Errorf("parseConfig(%q) = %+v", tt.content, got.Checklists)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package config
 
 import (
 	"crs/database"
+	"crs/filter_expr"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
 )
 
 // This struct implements all possible values a workflow can define, then they're written as-needed.
@@ -19,14 +25,111 @@ type RawWorkflow struct {
 	Repo                string
 	Repos               []string
 	JiraEpic            string
+	JQL                 string // Jira query synced by ProjectListWorkflow, e.g. "sprint in openSprints()"
 	Filters             []string
+	Filter              string // Boolean filter expression, ANDed with Filters
 	SectionTitle        string
 	PRState             string
 	ReleaseCheckCommand string
 	Prune               string
 	GithubUsername      string
 	IncludeDiff         bool
-	Teams               []string // Teams to filter PRs by when using FilterTeamRequested
+	Teams               []string            // Teams to filter PRs by when using FilterTeamRequested
+	Reasons             []string            // Notification reasons synced by NotificationsWorkflow
+	Query               string              // GitHub search query synced by SearchWorkflow
+	Host                string              // GithubHosts entry the workflow's repos, search or notifications come from
+	Positions           map[string]Position `toml:"-"` // Where the entry's keys are in the config file, for error messages
+}
+
+// Position is a line and column in codereviewserver.toml.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("codereviewserver.toml:%d:%d", p.Line, p.Column)
+}
+
+// Errorf returns an error about the workflow placed at key's value in the config file, or at
+// its [[Workflows]] header when the key isn't set. Elements of list keys are named like "Filters[1]".
+func (w RawWorkflow) Errorf(key string, format string, args ...any) error {
+	msg := fmt.Sprintf("workflow %q: %s", w.Name, fmt.Sprintf(format, args...))
+	pos, ok := w.Positions[key]
+	if !ok {
+		pos, ok = w.Positions[""]
+	}
+	if !ok {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%s: %s", pos, msg)
+}
+
+// FilterError places an error from the workflow's Filter expression at its line and column
+// in the config file.
+func (w RawWorkflow) FilterError(err error) error {
+	var exprErr *filter_expr.Error
+	pos, ok := w.Positions["Filter"]
+	if !ok || !errors.As(err, &exprErr) {
+		return fmt.Errorf("workflow %q: Filter: %w", w.Name, err)
+	}
+	pos.Column += exprErr.Offset
+	return fmt.Errorf("%s: workflow %q: Filter: %s", pos, w.Name, exprErr.Msg)
+}
+
+// workflowPositions returns where each [[Workflows]] entry's keys are, keyed by the entry's
+// index. "" is the entry's header; string values start after their opening quote so offsets
+// into a Filter expression line up.
+func workflowPositions(data []byte) map[int]map[string]Position {
+	positions := map[int]map[string]Position{}
+	p := unstable.Parser{}
+	p.Reset(data)
+	workflow := -1
+	inWorkflows := false
+	valuePosition := func(value *unstable.Node) Position {
+		start := p.Shape(value.Raw).Start
+		if value.Kind == unstable.String {
+			start.Column++
+		}
+		return Position{Line: start.Line, Column: start.Column}
+	}
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.ArrayTable, unstable.Table:
+			inWorkflows = expr.Kind == unstable.ArrayTable && keyString(expr) == "Workflows"
+			if inWorkflows {
+				workflow++
+				key := expr.Key()
+				key.Next()
+				start := p.Shape(key.Node().Raw).Start
+				positions[workflow] = map[string]Position{"": {Line: start.Line, Column: 1}}
+			}
+		case unstable.KeyValue:
+			if !inWorkflows {
+				continue
+			}
+			name := keyString(expr)
+			value := expr.Value()
+			positions[workflow][name] = valuePosition(value)
+			if value.Kind == unstable.Array {
+				elements := value.Children()
+				for i := 0; elements.Next(); i++ {
+					positions[workflow][fmt.Sprintf("%s[%d]", name, i)] = valuePosition(elements.Node())
+				}
+			}
+		}
+	}
+	return positions
+}
+
+func keyString(expr *unstable.Node) string {
+	parts := []string{}
+	it := expr.Key()
+	for it.Next() {
+		parts = append(parts, string(it.Node().Data))
+	}
+	return strings.Join(parts, ".")
 }
 
 // Plugin defines the configuration for an installed plugin
@@ -38,22 +141,52 @@ type Plugin struct {
 	IncludeComments bool
 }
 
+// JiraRule acts on the Jira issues linked to a PR when you submit a review of it.
+type JiraRule struct {
+	Event      string   // Review event the rule fires on: APPROVE, REQUEST_CHANGES or COMMENT
+	Transition string   // Status (or transition name) to move the issue to
+	Comment    string   // Comment to post on the issue; {reviewer}, {review_url}, {pr_url}, {title} and {repo} are filled in
+	Projects   []string // Jira project keys the rule applies to; if empty, only issues synced by a ProjectListWorkflow
+}
+
 // Define your classes
 type Config struct {
-	Repos          []string // List of repositories in "owner/repo" format. Workflows can override this.
-	RawWorkflows   []RawWorkflow
-	SleepDuration  time.Duration
-	JiraDomain     string
-	GithubUsername string
-	RepoLocation   string
-	AutoWorktree   bool
-	SectionPriority map[string]int // Map of section title to priority (lower is better)
-	Plugins         []Plugin
-	DB              *database.DB
+	Repos                       []string // List of repositories in "owner/repo" format. Workflows can override this.
+	RawWorkflows                []RawWorkflow
+	SleepDuration               time.Duration
+	JiraDomain                  string
+	JiraAcceptanceCriteriaField string // Custom field with a ticket's acceptance criteria, e.g. customfield_10035
+	GithubUsername              string
+	GithubHosts                 []GithubHost // GitHub Enterprise instances and extra accounts besides github.com
+	GitlabURL                   string       // Base URL of the GitLab instance serving gitlab: repos
+	GitlabUsername              string       // Your GitLab username, reported as GithubUsername on merge requests
+	RepoLocation                string
+	AutoWorktree                bool
+	SyncDraftReviews            bool           // Mirror local comments into a pending GitHub review instead of keeping them local until submit
+	SectionPriority             map[string]int // Map of section title to priority (lower is better)
+	RateLimitReserve            int            // Defer workflow cycles while fewer GitHub API requests than this remain
+	UserStatusPrecedence        string         // "user": your item status holds until a reset; "sync": also until the synced status changes
+	UserStatusResetOn           []string       // PR changes that clear your item status: NewCommits, NewActivity
+	Checklists                  []Checklist    // Review checklists attached to matching PRs, besides those in each repo's .crs.toml
+	ChecklistsBlockApprove      bool           // SubmitReview refuses APPROVE while a required checklist item is unchecked
+	JiraRules                   []JiraRule
+	JiraDryRun                  bool // Log the Jira rule actions instead of performing them
+	Plugins                     []Plugin
+	DB                          *database.DB
 }
 
 var C Config
 
+// mu keeps Apply from swapping C while RPCs, webhooks and plugins read it. Sync cycles don't
+// take it; the workflow manager only reloads between cycles.
+var mu sync.RWMutex
+
+// RLock holds off config reloads until RUnlock. Take it once per request or background task:
+// taking it again further down the same call can deadlock against a waiting Apply.
+func RLock() { mu.RLock() }
+
+func RUnlock() { mu.RUnlock() }
+
 var UserHomeDir = os.UserHomeDir
 
 func getCRSHome() (string, error) {
@@ -82,15 +215,27 @@ func getXDGConfigHome() (string, error) {
 // It does NOT initialize the database.
 func parseConfig(data []byte) (*Config, error) {
 	var intermediate_config struct {
-		Repos           []string
-		JiraDomain      string
-		SleepDuration   int64
-		Workflows       []RawWorkflow
-		GithubUsername  string
-		RepoLocation    string
-		AutoWorktree    bool
-		SectionPriority map[string]int
-		Plugins         []Plugin
+		Repos                       []string
+		JiraDomain                  string
+		JiraAcceptanceCriteriaField string
+		SleepDuration               int64
+		Workflows                   []RawWorkflow
+		GithubUsername              string
+		GithubHosts                 []GithubHost
+		GitlabURL                   string
+		GitlabUsername              string
+		RepoLocation                string
+		AutoWorktree                bool
+		SyncDraftReviews            bool
+		SectionPriority             map[string]int
+		RateLimitReserve            *int
+		UserStatusPrecedence        string
+		UserStatusResetOn           *[]string
+		Checklists                  []Checklist
+		ChecklistsBlockApprove      bool
+		JiraRules                   []JiraRule
+		JiraDryRun                  bool
+		Plugins                     []Plugin
 	}
 
 	err := toml.Unmarshal(data, &intermediate_config)
@@ -98,6 +243,40 @@ func parseConfig(data []byte) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	for i, rule := range intermediate_config.JiraRules {
+		switch rule.Event {
+		case "APPROVE", "REQUEST_CHANGES", "COMMENT":
+		default:
+			return nil, fmt.Errorf("JiraRules[%d]: unknown Event %q (expected APPROVE, REQUEST_CHANGES or COMMENT)", i, rule.Event)
+		}
+		if rule.Transition == "" && rule.Comment == "" {
+			return nil, fmt.Errorf("JiraRules[%d]: a rule needs a Transition or a Comment", i)
+		}
+	}
+
+	switch intermediate_config.UserStatusPrecedence {
+	case "", "user", "sync":
+	default:
+		return nil, fmt.Errorf("unknown UserStatusPrecedence %q (expected user or sync)", intermediate_config.UserStatusPrecedence)
+	}
+	userStatusResetOn := []string{"NewCommits"}
+	if intermediate_config.UserStatusResetOn != nil {
+		userStatusResetOn = *intermediate_config.UserStatusResetOn
+	}
+	for _, reset := range userStatusResetOn {
+		if reset != "NewCommits" && reset != "NewActivity" {
+			return nil, fmt.Errorf("unknown UserStatusResetOn entry %q (expected NewCommits or NewActivity)", reset)
+		}
+	}
+	userStatusPrecedence := intermediate_config.UserStatusPrecedence
+	if userStatusPrecedence == "" {
+		userStatusPrecedence = "user"
+	}
+
+	if err := validateChecklists(intermediate_config.Checklists); err != nil {
+		return nil, err
+	}
+
 	pluginNames := make(map[string]bool)
 	for _, p := range intermediate_config.Plugins {
 		if pluginNames[p.Name] {
@@ -106,10 +285,34 @@ func parseConfig(data []byte) (*Config, error) {
 		pluginNames[p.Name] = true
 	}
 
+	hostNames := make(map[string]bool)
+	for _, h := range intermediate_config.GithubHosts {
+		if h.Host == "" {
+			return nil, fmt.Errorf("GithubHosts entries need a Host")
+		}
+		if hostNames[strings.ToLower(h.Host)] {
+			return nil, fmt.Errorf("duplicate GithubHosts entry found: %s", h.Host)
+		}
+		hostNames[strings.ToLower(h.Host)] = true
+		if h.TokenSource != "" && !slices.Contains(TokenSources, h.TokenSource) {
+			return nil, fmt.Errorf("GithubHosts entry %s: unknown TokenSource %q (expected one of %s)", h.Host, h.TokenSource, strings.Join(TokenSources, ", "))
+		}
+		if h.TokenSource == "app" && (h.AppID == 0 || h.AppPrivateKeyPath == "") {
+			return nil, fmt.Errorf("GithubHosts entry %s: TokenSource \"app\" needs AppID and AppPrivateKeyPath", h.Host)
+		}
+	}
+
+	positions := workflowPositions(data)
 	for i := range intermediate_config.Workflows {
+		intermediate_config.Workflows[i].Positions = positions[i]
 		if intermediate_config.Workflows[i].GithubUsername == "" {
 			intermediate_config.Workflows[i].GithubUsername = intermediate_config.GithubUsername
 		}
+		if intermediate_config.Workflows[i].Filter != "" {
+			if _, err := filter_expr.Parse(intermediate_config.Workflows[i].Filter); err != nil {
+				return nil, intermediate_config.Workflows[i].FilterError(err)
+			}
+		}
 	}
 
 	repoLocation := intermediate_config.RepoLocation
@@ -122,34 +325,71 @@ func parseConfig(data []byte) (*Config, error) {
 		parsed_sleep_duration = time.Duration(intermediate_config.SleepDuration) * time.Minute
 	}
 
+	rateLimitReserve := 100
+	if intermediate_config.RateLimitReserve != nil {
+		rateLimitReserve = *intermediate_config.RateLimitReserve
+	}
+
 	return &Config{
-		Repos:           intermediate_config.Repos,
-		RawWorkflows:    intermediate_config.Workflows,
-		SleepDuration:   parsed_sleep_duration,
-		JiraDomain:      intermediate_config.JiraDomain,
-		GithubUsername:  intermediate_config.GithubUsername,
-		RepoLocation:    repoLocation,
-		AutoWorktree:    intermediate_config.AutoWorktree,
-		SectionPriority: intermediate_config.SectionPriority,
-		Plugins:         intermediate_config.Plugins,
+		Repos:                       intermediate_config.Repos,
+		RawWorkflows:                intermediate_config.Workflows,
+		SleepDuration:               parsed_sleep_duration,
+		JiraDomain:                  intermediate_config.JiraDomain,
+		JiraAcceptanceCriteriaField: intermediate_config.JiraAcceptanceCriteriaField,
+		GithubUsername:              intermediate_config.GithubUsername,
+		GithubHosts:                 intermediate_config.GithubHosts,
+		GitlabURL:                   intermediate_config.GitlabURL,
+		GitlabUsername:              intermediate_config.GitlabUsername,
+		RepoLocation:                repoLocation,
+		AutoWorktree:                intermediate_config.AutoWorktree,
+		SyncDraftReviews:            intermediate_config.SyncDraftReviews,
+		SectionPriority:             intermediate_config.SectionPriority,
+		RateLimitReserve:            rateLimitReserve,
+		UserStatusPrecedence:        userStatusPrecedence,
+		UserStatusResetOn:           userStatusResetOn,
+		Checklists:                  intermediate_config.Checklists,
+		ChecklistsBlockApprove:      intermediate_config.ChecklistsBlockApprove,
+		JiraRules:                   intermediate_config.JiraRules,
+		JiraDryRun:                  intermediate_config.JiraDryRun,
+		Plugins:                     intermediate_config.Plugins,
 	}, nil
 }
 
-// Initialize loads the configuration from the config file and initializes the database.
-// This should be called from main() to allow proper error handling.
-func Initialize() error {
+// Path returns the location of codereviewserver.toml.
+func Path() (string, error) {
 	configHome, err := getXDGConfigHome()
 	if err != nil {
-		return fmt.Errorf("failed to get config home: %w", err)
+		return "", fmt.Errorf("failed to get config home: %w", err)
 	}
+	return filepath.Join(configHome, "codereviewserver.toml"), nil
+}
 
-	configPath := filepath.Join(configHome, "codereviewserver.toml")
+// Load reads and parses the config file without touching the database or C.
+func Load() (*Config, error) {
+	configPath, err := Path()
+	if err != nil {
+		return nil, err
+	}
 	the_bytes, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config file at %s: %w", configPath, err)
+		return nil, fmt.Errorf("failed to read config file at %s: %w", configPath, err)
 	}
+	return parseConfig(the_bytes)
+}
+
+// Apply makes a config loaded with Load the current one, keeping the open database. It waits
+// for the readers holding RLock to finish.
+func Apply(config *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	config.DB = C.DB
+	C = *config
+}
 
-	config, err := parseConfig(the_bytes)
+// Initialize loads the configuration from the config file and initializes the database.
+// This should be called from main() to allow proper error handling.
+func Initialize() error {
+	config, err := Load()
 	if err != nil {
 		return err
 	}
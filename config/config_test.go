@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -133,6 +134,18 @@ SleepDuration = 5
 			},
 			wantErr: false,
 		},
+		{
+			name: "Sync Draft Reviews",
+			content: `
+SyncDraftReviews = true
+`,
+			want: &Config{
+				RepoLocation:     "~/",
+				SleepDuration:    10 * time.Minute,
+				SyncDraftReviews: true,
+			},
+			wantErr: false,
+		},
 		{
 			name: "Duplicate Plugins",
 			content: `
@@ -166,6 +179,9 @@ Command = "echo 2"
 				if got.SleepDuration != tt.want.SleepDuration {
 					t.Errorf("SleepDuration = %v, want %v", got.SleepDuration, tt.want.SleepDuration)
 				}
+				if got.SyncDraftReviews != tt.want.SyncDraftReviews {
+					t.Errorf("SyncDraftReviews = %v, want %v", got.SyncDraftReviews, tt.want.SyncDraftReviews)
+				}
 				if len(got.Repos) != len(tt.want.Repos) {
 					t.Errorf("Repos length = %v, want %v", len(got.Repos), len(tt.want.Repos))
 				}
@@ -180,4 +196,102 @@ Command = "echo 2"
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestParseConfig_RateLimitReserve(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"", 100},
+		{"RateLimitReserve = 500", 500},
+		{"RateLimitReserve = 0", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseConfig([]byte(tt.content))
+		if err != nil {
+			t.Fatalf("parseConfig(%q) error = %v", tt.content, err)
+		}
+		if got.RateLimitReserve != tt.want {
+			t.Errorf("parseConfig(%q).RateLimitReserve = %d, want %d", tt.content, got.RateLimitReserve, tt.want)
+		}
+	}
+}
+
+func TestParseConfig_FilterExpressionError(t *testing.T) {
+	content := `Repos = ["org/api"]
+
+[[Workflows]]
+WorkflowType = "SyncReviewRequestsWorkflow"
+Name = "ok"
+Filter = "FilterWaitingOnMe or label:urgent"
+
+[[Workflows]]
+WorkflowType = "SyncReviewRequestsWorkflow"
+Name = "broken"
+Filter = "FilterWaitingOnMe or (label:urgent and"
+`
+	_, err := parseConfig([]byte(content))
+	if err == nil {
+		t.Fatal("parseConfig() error = nil, want a Filter syntax error")
+	}
+	want := `codereviewserver.toml:11:49: workflow "broken": Filter: expected a filter, found end of expression`
+	if err.Error() != want {
+		t.Errorf("parseConfig() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestParseConfig_JiraRules(t *testing.T) {
+	content := `JiraDomain = "https://acme.atlassian.net"
+JiraDryRun = true
+
+[[JiraRules]]
+Event = "APPROVE"
+Transition = "In QA"
+Projects = ["PROJ"]
+`
+	cfg, err := parseConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if !cfg.JiraDryRun || len(cfg.JiraRules) != 1 || cfg.JiraRules[0].Transition != "In QA" {
+		t.Errorf("JiraRules = %+v, JiraDryRun = %v", cfg.JiraRules, cfg.JiraDryRun)
+	}
+
+	for _, rule := range []string{
+		"[[JiraRules]]\nEvent = \"MERGE\"\nTransition = \"Done\"\n",
+		"[[JiraRules]]\nEvent = \"APPROVE\"\n",
+	} {
+		if _, err := parseConfig([]byte(rule)); err == nil {
+			t.Errorf("parseConfig(%q) error = nil, want an invalid rule error", rule)
+		}
+	}
+}
+
+func TestParseConfig_UserStatus(t *testing.T) {
+	tests := []struct {
+		content        string
+		wantPrecedence string
+		wantResetOn    []string
+		wantErr        bool
+	}{
+		{content: "", wantPrecedence: "user", wantResetOn: []string{"NewCommits"}},
+		{content: "UserStatusPrecedence = \"sync\"\nUserStatusResetOn = []", wantPrecedence: "sync", wantResetOn: []string{}},
+		{content: "UserStatusResetOn = [\"NewCommits\", \"NewActivity\"]", wantPrecedence: "user", wantResetOn: []string{"NewCommits", "NewActivity"}},
+		{content: "UserStatusPrecedence = \"github\"", wantErr: true},
+		{content: "UserStatusResetOn = [\"Merged\"]", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseConfig([]byte(tt.content))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseConfig(%q) error = %v, wantErr %v", tt.content, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got.UserStatusPrecedence != tt.wantPrecedence || !slices.Equal(got.UserStatusResetOn, tt.wantResetOn) {
+			t.Errorf("parseConfig(%q) = %q, %v, want %q, %v", tt.content, got.UserStatusPrecedence, got.UserStatusResetOn, tt.wantPrecedence, tt.wantResetOn)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultGithubHost is the host of repo entries without a host prefix.
+const DefaultGithubHost = "github.com"
+
+// GithubHost is a GitHub instance besides github.com, such as a GitHub Enterprise Server, or a
+// second github.com account. Repos entries pick a host with a prefix (ghe.acme.com/team/repo)
+// and workflows with Host. An entry with Host = "github.com" sets how github.com authenticates.
+type GithubHost struct {
+	Host              string   // Name used in repo prefixes and workflows, usually the hostname, e.g. ghe.acme.com
+	BaseURL           string   // REST API root; defaults to https://<Host>/api/v3/
+	TokenSource       string   // Where the token comes from: env (default), app, gh or keyring
+	TokenEnv          string   // Environment variable holding the host's token; defaults to CRS_GITHUB_TOKEN
+	AppID             int64    // GitHub App ID, for TokenSource = "app"
+	AppInstallationID int64    // Installation to mint tokens for; found automatically when the app has only one
+	AppPrivateKeyPath string   // PEM private key of the app
+	KeyringService    string   // Secret Service "service" attribute of the token; defaults to codereviewserver
+	GithubUsername    string   // Your login on the host; defaults to the top-level GithubUsername
+	Owners            []string // Orgs and users on the host, so their repos route here without a prefix
+}
+
+// TokenSources are the accepted values of GithubHost.TokenSource.
+var TokenSources = []string{"env", "app", "gh", "keyring"}
+
+// APIURL returns the host's REST API root.
+func (h *GithubHost) APIURL() string {
+	if h.BaseURL != "" {
+		return strings.TrimSuffix(h.BaseURL, "/") + "/"
+	}
+	if h.Host == DefaultGithubHost {
+		return "https://api.github.com/"
+	}
+	return "https://" + h.Host + "/api/v3/"
+}
+
+// TokenEnv returns the environment variable holding the token for host, where nil is github.com.
+func TokenEnv(host *GithubHost) string {
+	if host != nil && host.TokenEnv != "" {
+		return host.TokenEnv
+	}
+	return "CRS_GITHUB_TOKEN"
+}
+
+// TokenSource returns where the token for host comes from, where nil is github.com.
+func TokenSource(host *GithubHost) string {
+	if host != nil && host.TokenSource != "" {
+		return host.TokenSource
+	}
+	return "env"
+}
+
+// WebHost returns the hostname serving the host's web UI, as gh and git know it.
+func (h *GithubHost) WebHost() string {
+	if h.BaseURL == "" {
+		return h.Host
+	}
+	api, err := url.Parse(h.APIURL())
+	if err != nil || api.Host == "" {
+		return h.Host
+	}
+	return strings.TrimPrefix(api.Host, "api.")
+}
+
+// SplitHost splits a repo entry into its host prefix, if it has one, and the owner/repo part.
+func SplitHost(entry string) (string, string) {
+	if strings.HasPrefix(entry, "gitlab:") || strings.Count(entry, "/") < 2 {
+		return "", entry
+	}
+	host, repo, _ := strings.Cut(entry, "/")
+	return host, repo
+}
+
+// WithHost prefixes the unprefixed entries with host.
+func WithHost(host string, entries []string) []string {
+	if host == "" {
+		return entries
+	}
+	prefixed := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, _ := SplitHost(entry); prefix == "" && entry != "" && !strings.HasPrefix(entry, "gitlab:") {
+			entry = host + "/" + entry
+		}
+		prefixed = append(prefixed, entry)
+	}
+	return prefixed
+}
+
+// Host returns the configured host called name, or nil.
+func (c *Config) Host(name string) *GithubHost {
+	for i := range c.GithubHosts {
+		if strings.EqualFold(c.GithubHosts[i].Host, name) {
+			return &c.GithubHosts[i]
+		}
+	}
+	return nil
+}
+
+// HostFor returns the host serving owner/repo: the one named by the repo's prefix in a Repos
+// entry, by the Host of a workflow listing the repo, or whose Owners include owner. An empty
+// repo matches any repo of owner. nil means github.com with CRS_GITHUB_TOKEN.
+func (c *Config) HostFor(owner, repo string) *GithubHost {
+	matches := func(host string, entries []string) string {
+		for _, entry := range entries {
+			prefix, name := SplitHost(entry)
+			if prefix == "" {
+				prefix = host
+			}
+			entryOwner, entryRepo, _ := strings.Cut(name, "/")
+			if prefix != "" && strings.EqualFold(entryOwner, owner) && (repo == "" || strings.EqualFold(entryRepo, repo)) {
+				return prefix
+			}
+		}
+		return ""
+	}
+	if host := matches("", c.Repos); host != "" {
+		return c.Host(host)
+	}
+	for _, raw := range c.RawWorkflows {
+		entries := append([]string{}, raw.Repos...)
+		if strings.Contains(raw.Repo, "/") {
+			entries = append(entries, raw.Repo)
+		} else if raw.Owner != "" && raw.Repo != "" {
+			entries = append(entries, raw.Owner+"/"+raw.Repo)
+		}
+		if host := matches(raw.Host, entries); host != "" {
+			return c.Host(host)
+		}
+	}
+	for i, host := range c.GithubHosts {
+		for _, hostOwner := range host.Owners {
+			if strings.EqualFold(hostOwner, owner) {
+				return &c.GithubHosts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// HostForURL returns the configured host serving a web or API URL, or nil.
+func (c *Config) HostForURL(rawURL string) *GithubHost {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	for i := range c.GithubHosts {
+		host := &c.GithubHosts[i]
+		api, _ := url.Parse(host.APIURL())
+		if strings.EqualFold(parsed.Host, host.Host) || (api != nil && strings.EqualFold(parsed.Host, api.Host) && parsed.Host != "api.github.com") {
+			return host
+		}
+	}
+	return nil
+}
+
+// Username returns your login on host, where nil is github.com.
+func (c *Config) Username(host *GithubHost) string {
+	if host == nil {
+		host = c.Host(DefaultGithubHost)
+	}
+	if host != nil && host.GithubUsername != "" {
+		return host.GithubUsername
+	}
+	return c.GithubUsername
+}
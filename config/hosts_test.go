@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestHostFor(t *testing.T) {
+	content := `GithubUsername = "me"
+Repos = ["octo/api", "ghe.acme.com/team/billing"]
+
+[[GithubHosts]]
+Host = "ghe.acme.com"
+TokenEnv = "CRS_GHE_TOKEN"
+GithubUsername = "jdoe"
+Owners = ["platform"]
+
+[[Workflows]]
+WorkflowType = "SyncReviewRequestsWorkflow"
+Name = "ghe"
+Host = "ghe.acme.com"
+Repos = ["team/web"]
+`
+	cfg, err := parseConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		owner, repo string
+		want        string
+	}{
+		{"octo", "api", ""},
+		{"team", "billing", "ghe.acme.com"},
+		{"team", "web", "ghe.acme.com"},
+		{"platform", "anything", "ghe.acme.com"},
+		{"platform", "", "ghe.acme.com"},
+		{"someone", "else", ""},
+	}
+	for _, tt := range tests {
+		host := cfg.HostFor(tt.owner, tt.repo)
+		got := ""
+		if host != nil {
+			got = host.Host
+		}
+		if got != tt.want {
+			t.Errorf("HostFor(%q, %q) = %q, want %q", tt.owner, tt.repo, got, tt.want)
+		}
+	}
+
+	ghe := cfg.Host("ghe.acme.com")
+	if ghe.APIURL() != "https://ghe.acme.com/api/v3/" || TokenEnv(ghe) != "CRS_GHE_TOKEN" || TokenEnv(nil) != "CRS_GITHUB_TOKEN" {
+		t.Errorf("APIURL() = %q, TokenEnv() = %q", ghe.APIURL(), TokenEnv(ghe))
+	}
+	if cfg.Username(ghe) != "jdoe" || cfg.Username(nil) != "me" {
+		t.Errorf("Username() = %q on ghe.acme.com, %q on github.com", cfg.Username(ghe), cfg.Username(nil))
+	}
+	if cfg.HostForURL("https://ghe.acme.com/team/web/pull/3") != ghe || cfg.HostForURL("https://github.com/octo/api/pull/1") != nil {
+		t.Errorf("HostForURL() did not match hosts by URL")
+	}
+
+	if _, err := parseConfig([]byte("[[GithubHosts]]\nHost = \"a\"\n[[GithubHosts]]\nHost = \"A\"\n")); err == nil {
+		t.Errorf("parseConfig() error = nil, want a duplicate host error")
+	}
+	if _, err := parseConfig([]byte("[[GithubHosts]]\nHost = \"a\"\nTokenSource = \"app\"\nAppID = 1\n")); err == nil {
+		t.Errorf("parseConfig() error = nil, want an error for an app without AppPrivateKeyPath")
+	}
+	if _, err := parseConfig([]byte("[[GithubHosts]]\nHost = \"a\"\nTokenSource = \"vault\"\n")); err == nil {
+		t.Errorf("parseConfig() error = nil, want an unknown TokenSource error")
+	}
+}
+
+func TestSplitHostAndWithHost(t *testing.T) {
+	tests := []struct {
+		entry, host, repo string
+	}{
+		{"octo/api", "", "octo/api"},
+		{"ghe.acme.com/team/web", "ghe.acme.com", "team/web"},
+		{"gitlab:group/sub/project", "", "gitlab:group/sub/project"},
+	}
+	for _, tt := range tests {
+		if host, repo := SplitHost(tt.entry); host != tt.host || repo != tt.repo {
+			t.Errorf("SplitHost(%q) = %q, %q, want %q, %q", tt.entry, host, repo, tt.host, tt.repo)
+		}
+	}
+
+	got := WithHost("ghe.acme.com", []string{"team/web", "github.com/octo/api", "gitlab:group/project", ""})
+	want := []string{"ghe.acme.com/team/web", "github.com/octo/api", "gitlab:group/project", ""}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("WithHost()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
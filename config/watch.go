@@ -0,0 +1,33 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Watch polls the config file and calls onChange after its contents change. Editors often
+// save by replacing the file, so the contents are compared rather than relying on file events.
+func Watch(interval time.Duration, onChange func()) {
+	configPath, err := Path()
+	if err != nil {
+		slog.Error("Not watching config file", "error", err)
+		return
+	}
+	last, _ := os.ReadFile(configPath)
+	for {
+		time.Sleep(interval)
+		current, err := os.ReadFile(configPath)
+		if err != nil {
+			// Mid-save, or moved away; keep the running config
+			continue
+		}
+		if bytes.Equal(current, last) {
+			continue
+		}
+		last = current
+		slog.Info("Config file changed, reloading", "path", configPath)
+		onChange()
+	}
+}
@@ -3,9 +3,11 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -32,17 +34,75 @@ type Item struct {
 	Tags        string // Comma-separated tags
 	Archived    bool
 	TTL         int64
+	UserStatus  string // Keyword set with SetUserStatus, shown instead of Status; "" when unset
+}
+
+// DisplayStatus returns the keyword to show for the item: yours if you set one, else the synced one.
+func (i *Item) DisplayStatus() string {
+	if i.UserStatus != "" {
+		return i.UserStatus
+	}
+	return i.Status
 }
 
 type LocalComment struct {
 	ID        int64
-	Owner     string    // GitHub owner/org
-	Repo      string    // GitHub repository name
-	Number    int       // PR number
-	Filename  string    // going to be the rel file like src/main.rs
+	Owner     string // GitHub owner/org
+	Repo      string // GitHub repository name
+	Number    int    // PR number
+	Filename  string // going to be the rel file like src/main.rs
 	Position  int64
 	Body      *string
-	ReplyToID *int64    // ID of the comment being replied to, or nil if top-level
+	ReplyToID *int64 // ID of the comment being replied to, or nil if top-level
+	// ID of the mirrored comment in the user's pending GitHub review, or nil if it only exists locally
+	GithubCommentID *int64
+	CommentAnchor
+	CommitSHA string // Head SHA of the PR when the comment was written
+	Orphaned  bool   // The commented line no longer exists after a push; needs manual re-placement
+	Kind      string // CommentKindComment or CommentKindSuggestion
+	// For suggestions, the lines being replaced as they read when the suggestion was written
+	OriginalLines string
+}
+
+const (
+	CommentKindComment    = "comment"
+	CommentKindSuggestion = "suggestion"
+)
+
+// CommentAnchor places a comment on lines of the file rather than on a diff position.
+// A zero Line means the comment predates line anchoring and only has a Position.
+type CommentAnchor struct {
+	Line      int64
+	Side      string // "LEFT" (original file) or "RIGHT" (new file)
+	StartLine int64  // First line of a multi-line comment, 0 for single-line comments
+	StartSide string
+}
+
+const localCommentColumns = "id, owner, repo, number, filename, position, body, reply_to_id, github_comment_id, line, side, start_line, start_side, commit_sha, orphaned, kind, original_lines"
+
+const itemColumns = "items.id, items.section_id, items.identifier, items.status, items.title, items.details_json, items.tags, items.archived, items.ttl, COALESCE(UserStatuses.status, '')"
+
+// itemTables joins the user statuses, which are kept per PR rather than per section.
+const itemTables = "items LEFT JOIN UserStatuses ON UserStatuses.identifier = items.identifier"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanItem(row rowScanner) (*Item, error) {
+	var item Item
+	var archivedInt int
+	if err := row.Scan(&item.ID, &item.SectionID, &item.Identifier, &item.Status, &item.Title, &item.DetailsJSON, &item.Tags, &archivedInt, &item.TTL, &item.UserStatus); err != nil {
+		return nil, err
+	}
+	item.Archived = archivedInt == 1
+	return &item, nil
+}
+
+func scanLocalComment(row rowScanner) (LocalComment, error) {
+	var comment LocalComment
+	err := row.Scan(&comment.ID, &comment.Owner, &comment.Repo, &comment.Number, &comment.Filename, &comment.Position, &comment.Body, &comment.ReplyToID, &comment.GithubCommentID, &comment.Line, &comment.Side, &comment.StartLine, &comment.StartSide, &comment.CommitSHA, &comment.Orphaned, &comment.Kind, &comment.OriginalLines)
+	return comment, err
 }
 
 func NewDB(dbPath string) (*DB, error) {
@@ -74,6 +134,11 @@ func NewDB(dbPath string) (*DB, error) {
 		conn.Close()
 		return nil, err
 	}
+	if pruned, err := db.PruneHTTPCache(HTTPCacheMaxAge, HTTPCacheMaxBytes); err != nil {
+		slog.Warn("Error pruning HTTP cache", "error", err)
+	} else if pruned > 0 {
+		slog.Info("Pruned HTTP cache", "entries", pruned)
+	}
 
 	slog.Info("Database connection established and schema initialized", "path", dbPath)
 	return db, nil
@@ -84,6 +149,16 @@ func (db *DB) Close() error {
 }
 
 func (db *DB) initSchema() error {
+	// Migration: PullRequests used to keep a row per head ever seen, without the owner. It only
+	// caches diffs, so drop the old layout and let the next fetch fill the new one.
+	var prColumns, prOwner int
+	err := db.conn.QueryRow("SELECT COUNT(*), COUNT(CASE WHEN name = 'owner' THEN 1 END) FROM pragma_table_info('PullRequests')").Scan(&prColumns, &prOwner)
+	if err == nil && prColumns > 0 && prOwner == 0 {
+		if _, err := db.conn.Exec("DROP TABLE PullRequests"); err != nil {
+			return err
+		}
+	}
+
 	schema := `
 	CREATE TABLE IF NOT EXISTS sections (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -128,11 +203,14 @@ func (db *DB) initSchema() error {
 		);
 
 	CREATE TABLE IF NOT EXISTS PullRequests (
+		owner TEXT NOT NULL,
 		pr_number INTEGER NOT NULL,
 		repo TEXT NOT NULL,
 		latest_sha TEXT NOT NULL,
 		body TEXT NOT NULL,
-		UNIQUE(pr_number, repo, latest_sha)
+		previous_sha TEXT NOT NULL DEFAULT '',
+		previous_body TEXT NOT NULL DEFAULT '',
+		UNIQUE(owner, repo, pr_number)
 	);
 
 	CREATE TABLE IF NOT EXISTS PRComments (
@@ -156,6 +234,13 @@ func (db *DB) initSchema() error {
 		UNIQUE(pr_number, repo)
 	);
 
+	CREATE TABLE IF NOT EXISTS PRReviewThreads (
+		pr_number INTEGER NOT NULL,
+		repo TEXT NOT NULL,
+		threads_json TEXT NOT NULL,
+		UNIQUE(pr_number, repo)
+	);
+
 	CREATE TABLE IF NOT EXISTS CIStatus (
 		pr_number INTEGER NOT NULL,
 		repo TEXT NOT NULL,
@@ -173,21 +258,110 @@ func (db *DB) initSchema() error {
 		UNIQUE(pr_number, repo, owner)
 	);
 
+	CREATE TABLE IF NOT EXISTS HTTPCache (
+		cache_key TEXT PRIMARY KEY,
+		etag TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		headers_json TEXT NOT NULL DEFAULT '{}',
+		body BLOB,
+		identity TEXT NOT NULL DEFAULT '',
+		cached_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS JiraIssues (
+		issue_key TEXT PRIMARY KEY,
+		issue_json TEXT NOT NULL,
+		cached_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS Snoozes (
+		identifier TEXT PRIMARY KEY,
+		until INTEGER NOT NULL DEFAULT 0,
+		until_activity BOOLEAN NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS MuteRules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		value TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(kind, value)
+	);
+
+	CREATE TABLE IF NOT EXISTS UserStatuses (
+		identifier TEXT PRIMARY KEY,
+		status TEXT NOT NULL,
+		set_at INTEGER NOT NULL,
+		head_sha TEXT NOT NULL DEFAULT '',
+		synced_status TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS Notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		number INTEGER NOT NULL,
+		filename TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0,
+		line INTEGER NOT NULL DEFAULT 0,
+		side TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS ChecklistChecks (
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		number INTEGER NOT NULL,
+		sha TEXT NOT NULL,
+		checklist TEXT NOT NULL,
+		item TEXT NOT NULL,
+		checked_at INTEGER NOT NULL,
+		PRIMARY KEY(owner, repo, number, sha, checklist, item)
+	);
+
+	CREATE TABLE IF NOT EXISTS HiddenItems (
+		section_id INTEGER NOT NULL,
+		identifier TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		PRIMARY KEY(section_id, identifier),
+		FOREIGN KEY(section_id) REFERENCES sections(id) ON DELETE CASCADE
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_items_section ON items(section_id);
 	CREATE INDEX IF NOT EXISTS idx_items_identifier ON items(identifier);
-	CREATE INDEX IF NOT EXISTS idx_pullrequests_lookup ON PullRequests(pr_number, repo, latest_sha);
 	CREATE INDEX IF NOT EXISTS idx_prcomments_lookup ON PRComments(pr_number, repo);
 	CREATE INDEX IF NOT EXISTS idx_localcomments_pr ON LocalComment(owner, repo, number);
+	CREATE INDEX IF NOT EXISTS idx_notes_pr ON Notes(owner, repo, number);
 	`
 
-	_, err := db.conn.Exec(schema)
+	_, err = db.conn.Exec(schema)
 	if err != nil {
 		return err
 	}
 
+	// Migration: Keep the diff of the head before the latest, for re-anchoring after a sync saw a push
+	var count int
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('PullRequests') WHERE name='previous_sha'").Scan(&count)
+	if err == nil && count == 0 {
+		for _, column := range []string{"previous_sha", "previous_body"} {
+			if _, err := db.conn.Exec("ALTER TABLE PullRequests ADD COLUMN " + column + " TEXT NOT NULL DEFAULT ''"); err != nil {
+				slog.Warn("Error adding column to PullRequests", "column", column, "error", err)
+			}
+		}
+	}
+
+	// Migration: Record which token stored each HTTP cache entry, now that entries are kept per URL
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('HTTPCache') WHERE name='identity'").Scan(&count)
+	if err == nil && count == 0 {
+		if _, err := db.conn.Exec("ALTER TABLE HTTPCache ADD COLUMN identity TEXT NOT NULL DEFAULT ''"); err != nil {
+			slog.Warn("Error adding identity column to HTTPCache", "error", err)
+		}
+	}
+
 	// Migration: Add PR columns to LocalComment table if they don't exist
 	// Check if owner column exists by querying pragma_table_info
-	var count int
 	err = db.conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('LocalComment') WHERE name='owner'").Scan(&count)
 	if err == nil && count == 0 {
 		// Add the new columns (Legacy migration code kept for completeness)
@@ -226,6 +400,35 @@ func (db *DB) initSchema() error {
 			slog.Warn("Error adding reply_to_id column to LocalComment", "error", err)
 		}
 	}
+	// Migration: Add github_comment_id column for comments mirrored into a pending GitHub review
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('LocalComment') WHERE name='github_comment_id'").Scan(&count)
+	if err == nil && count == 0 {
+		_, err = db.conn.Exec("ALTER TABLE LocalComment ADD COLUMN github_comment_id INTEGER DEFAULT NULL")
+		if err != nil {
+			slog.Warn("Error adding github_comment_id column to LocalComment", "error", err)
+		}
+	}
+	// Migration: Add line/side anchoring columns, which replace diff positions when submitting,
+	// the commit each comment was written against so it can be re-anchored after a push,
+	// and the comment kind for suggested changes
+	for _, column := range []struct{ name, definition string }{
+		{"line", "INTEGER NOT NULL DEFAULT 0"},
+		{"side", "TEXT NOT NULL DEFAULT ''"},
+		{"start_line", "INTEGER NOT NULL DEFAULT 0"},
+		{"start_side", "TEXT NOT NULL DEFAULT ''"},
+		{"commit_sha", "TEXT NOT NULL DEFAULT ''"},
+		{"orphaned", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"kind", "TEXT NOT NULL DEFAULT 'comment'"},
+		{"original_lines", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		err = db.conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('LocalComment') WHERE name=?", column.name).Scan(&count)
+		if err == nil && count == 0 {
+			_, err = db.conn.Exec("ALTER TABLE LocalComment ADD COLUMN " + column.name + " " + column.definition)
+			if err != nil {
+				slog.Warn("Error adding column to LocalComment", "column", column.name, "error", err)
+			}
+		}
+	}
 	// Migration: Add status column to PluginResults if it doesn't exist
 	err = db.conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('PluginResults') WHERE name='status'").Scan(&count)
 	if err == nil && count == 0 {
@@ -469,6 +672,15 @@ func (db *DB) GetAllSections() ([]*Section, error) {
 	return sections, rows.Err()
 }
 
+// DeleteSection removes a section and its items.
+func (db *DB) DeleteSection(sectionID int64) error {
+	if _, err := db.conn.Exec("DELETE FROM items WHERE section_id = ?", sectionID); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec("DELETE FROM sections WHERE id = ?", sectionID)
+	return err
+}
+
 func (db *DB) UpsertItem(sectionID int64, identifier, status, title string, details []string, tags []string, archived bool, ttl int64) (*Item, error) {
 	detailsJSON, err := json.Marshal(details)
 	if err != nil {
@@ -537,22 +749,36 @@ func (db *DB) UpsertItem(sectionID int64, identifier, status, title string, deta
 }
 
 func (db *DB) GetItem(sectionID int64, identifier string) (*Item, error) {
-	var item Item
-	err := db.conn.QueryRow(
-		"SELECT id, section_id, identifier, status, title, details_json, tags, archived, ttl FROM items WHERE section_id = ? AND identifier = ?",
+	return scanItem(db.conn.QueryRow(
+		"SELECT "+itemColumns+" FROM "+itemTables+" WHERE items.section_id = ? AND items.identifier = ?",
 		sectionID, identifier,
-	).Scan(&item.ID, &item.SectionID, &item.Identifier, &item.Status, &item.Title, &item.DetailsJSON, &item.Tags, &item.Archived, &item.TTL)
+	))
+}
 
+func (db *DB) GetItemsBySection(sectionID int64) ([]*Item, error) {
+	rows, err := db.conn.Query(
+		"SELECT "+itemColumns+" FROM "+itemTables+" WHERE items.section_id = ? ORDER BY items.id",
+		sectionID,
+	)
 	if err != nil {
 		return nil, err
 	}
-	return &item, nil
+	defer rows.Close()
+
+	var items []*Item
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
 }
 
-func (db *DB) GetItemsBySection(sectionID int64) ([]*Item, error) {
+func (db *DB) GetAllItems() ([]*Item, error) {
 	rows, err := db.conn.Query(
-		"SELECT id, section_id, identifier, status, title, details_json, tags, archived, ttl FROM items WHERE section_id = ? ORDER BY id",
-		sectionID,
+		"SELECT "+itemColumns+" FROM "+itemTables+" ORDER BY items.section_id, items.id",
 	)
 	if err != nil {
 		return nil, err
@@ -561,20 +787,20 @@ func (db *DB) GetItemsBySection(sectionID int64) ([]*Item, error) {
 
 	var items []*Item
 	for rows.Next() {
-		var item Item
-		var archivedInt int
-		if err := rows.Scan(&item.ID, &item.SectionID, &item.Identifier, &item.Status, &item.Title, &item.DetailsJSON, &item.Tags, &archivedInt, &item.TTL); err != nil {
+		item, err := scanItem(rows)
+		if err != nil {
 			return nil, err
 		}
-		item.Archived = archivedInt == 1
-		items = append(items, &item)
+		items = append(items, item)
 	}
 	return items, rows.Err()
 }
 
-func (db *DB) GetAllItems() ([]*Item, error) {
+// GetItemsByIdentifier returns the items for an identifier across all sections.
+func (db *DB) GetItemsByIdentifier(identifier string) ([]*Item, error) {
 	rows, err := db.conn.Query(
-		"SELECT id, section_id, identifier, status, title, details_json, tags, archived, ttl FROM items ORDER BY section_id, id",
+		"SELECT "+itemColumns+" FROM "+itemTables+" WHERE items.identifier = ? ORDER BY items.section_id",
+		identifier,
 	)
 	if err != nil {
 		return nil, err
@@ -583,13 +809,11 @@ func (db *DB) GetAllItems() ([]*Item, error) {
 
 	var items []*Item
 	for rows.Next() {
-		var item Item
-		var archivedInt int
-		if err := rows.Scan(&item.ID, &item.SectionID, &item.Identifier, &item.Status, &item.Title, &item.DetailsJSON, &item.Tags, &archivedInt, &item.TTL); err != nil {
+		item, err := scanItem(rows)
+		if err != nil {
 			return nil, err
 		}
-		item.Archived = archivedInt == 1
-		items = append(items, &item)
+		items = append(items, item)
 	}
 	return items, rows.Err()
 }
@@ -597,7 +821,7 @@ func (db *DB) GetAllItems() ([]*Item, error) {
 func (db *DB) GetExpiredItems(sectionID int64) ([]*Item, error) {
 	now := time.Now().Unix()
 	rows, err := db.conn.Query(
-		"SELECT id, section_id, identifier, status, title, details_json, tags, archived, ttl FROM items WHERE section_id = ? AND ttl > 0 AND ttl < ?",
+		"SELECT "+itemColumns+" FROM "+itemTables+" WHERE items.section_id = ? AND items.ttl > 0 AND items.ttl < ?",
 		sectionID, now,
 	)
 	if err != nil {
@@ -607,13 +831,11 @@ func (db *DB) GetExpiredItems(sectionID int64) ([]*Item, error) {
 
 	var items []*Item
 	for rows.Next() {
-		var item Item
-		var archivedInt int
-		if err := rows.Scan(&item.ID, &item.SectionID, &item.Identifier, &item.Status, &item.Title, &item.DetailsJSON, &item.Tags, &archivedInt, &item.TTL); err != nil {
+		item, err := scanItem(rows)
+		if err != nil {
 			return nil, err
 		}
-		item.Archived = archivedInt == 1
-		items = append(items, &item)
+		items = append(items, item)
 	}
 	return items, rows.Err()
 }
@@ -663,8 +885,15 @@ func (db *DB) DeleteItemsNotInList(sectionID int64, identifiers []string) error
 	return err
 }
 
-func (db *DB) InsertLocalComment(owner, repo string, number int, filename string, position int64, body *string, replyToID *int64) (LocalComment, error) {
-	stmt, err := db.conn.Prepare("INSERT INTO LocalComment (owner, repo, number, filename, position, body, reply_to_id) VALUES (?, ?, ?, ?, ?, ?, ?)")
+// InsertLocalComment stores a new comment and returns it with its ID set. A zero Kind is stored
+// as CommentKindComment.
+func (db *DB) InsertLocalComment(comment LocalComment) (LocalComment, error) {
+	if comment.Kind == "" {
+		comment.Kind = CommentKindComment
+	}
+	stmt, err := db.conn.Prepare(`INSERT INTO LocalComment
+		(owner, repo, number, filename, position, body, reply_to_id, github_comment_id, line, side, start_line, start_side, commit_sha, kind, original_lines)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		slog.Error("Failed to prepare statement", "error", err)
 		return LocalComment{}, err
@@ -672,7 +901,8 @@ func (db *DB) InsertLocalComment(owner, repo string, number int, filename string
 	defer stmt.Close()
 
 	// Execute the insertion
-	res, err := stmt.Exec(owner, repo, number, filename, position, body, replyToID)
+	res, err := stmt.Exec(comment.Owner, comment.Repo, comment.Number, comment.Filename, comment.Position, comment.Body, comment.ReplyToID,
+		comment.GithubCommentID, comment.Line, comment.Side, comment.StartLine, comment.StartSide, comment.CommitSHA, comment.Kind, comment.OriginalLines)
 	if err != nil {
 		slog.Error("Failed to execute insertion", "error", err)
 		return LocalComment{}, err
@@ -684,9 +914,9 @@ func (db *DB) InsertLocalComment(owner, repo string, number int, filename string
 		slog.Error("Failed to get last insert ID", "error", err)
 		return LocalComment{}, err
 	}
-	return LocalComment{
-		ID: id, Owner: owner, Repo: repo, Number: number, Filename: filename, Position: position, Body: body, ReplyToID: replyToID,
-	}, nil
+	comment.ID = id
+	comment.Orphaned = false
+	return comment, nil
 }
 
 func (db *DB) InsertFeedback(owner, repo string, number int, body *string) error {
@@ -710,7 +940,7 @@ func (db *DB) InsertFeedback(owner, repo string, number int, body *string) error
 }
 
 func (db *DB) GetAllLocalComments() ([]LocalComment, error) {
-	rows, err := db.conn.Query("SELECT id, owner, repo, number, filename, position, body, reply_to_id FROM LocalComment")
+	rows, err := db.conn.Query("SELECT " + localCommentColumns + " FROM LocalComment")
 	if err != nil {
 		return nil, err
 	}
@@ -718,8 +948,8 @@ func (db *DB) GetAllLocalComments() ([]LocalComment, error) {
 
 	var comments []LocalComment
 	for rows.Next() {
-		var comment LocalComment
-		if err := rows.Scan(&comment.ID, &comment.Owner, &comment.Repo, &comment.Number, &comment.Filename, &comment.Position, &comment.Body, &comment.ReplyToID); err != nil {
+		comment, err := scanLocalComment(rows)
+		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
@@ -728,7 +958,7 @@ func (db *DB) GetAllLocalComments() ([]LocalComment, error) {
 }
 
 func (db *DB) GetLocalCommentsForPR(owner, repo string, number int) ([]LocalComment, error) {
-	rows, err := db.conn.Query("SELECT id, owner, repo, number, filename, position, body, reply_to_id FROM LocalComment WHERE owner = ? AND repo = ? AND number = ?", owner, repo, number)
+	rows, err := db.conn.Query("SELECT "+localCommentColumns+" FROM LocalComment WHERE owner = ? AND repo = ? AND number = ?", owner, repo, number)
 	if err != nil {
 		return nil, err
 	}
@@ -736,8 +966,8 @@ func (db *DB) GetLocalCommentsForPR(owner, repo string, number int) ([]LocalComm
 
 	var comments []LocalComment
 	for rows.Next() {
-		var comment LocalComment
-		if err := rows.Scan(&comment.ID, &comment.Owner, &comment.Repo, &comment.Number, &comment.Filename, &comment.Position, &comment.Body, &comment.ReplyToID); err != nil {
+		comment, err := scanLocalComment(rows)
+		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
@@ -745,6 +975,24 @@ func (db *DB) GetLocalCommentsForPR(owner, repo string, number int) ([]LocalComm
 	return comments, rows.Err()
 }
 
+func (db *DB) GetLocalComment(id int64) (*LocalComment, error) {
+	comment, err := scanLocalComment(db.conn.QueryRow("SELECT "+localCommentColumns+" FROM LocalComment WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// SetLocalCommentGithubID records which pending review comment on GitHub mirrors a local comment.
+// Passing nil clears the link.
+func (db *DB) SetLocalCommentGithubID(id int64, githubCommentID *int64) error {
+	_, err := db.conn.Exec("UPDATE LocalComment SET github_comment_id = ? WHERE id = ?", githubCommentID, id)
+	return err
+}
+
 func (db *DB) DeleteAllLocalComments() error {
 	_, err := db.conn.Exec("DELETE FROM LocalComment")
 	return err
@@ -760,17 +1008,46 @@ func (db *DB) UpdateLocalComment(id int64, body string) error {
 	return err
 }
 
+// UpdateLocalCommentAnchor moves a comment to a new place in the diff, e.g. after the PR head changed.
+func (db *DB) UpdateLocalCommentAnchor(id int64, filename string, position int64, anchor CommentAnchor, commitSHA string, orphaned bool) error {
+	_, err := db.conn.Exec(
+		"UPDATE LocalComment SET filename = ?, position = ?, line = ?, side = ?, start_line = ?, start_side = ?, commit_sha = ?, orphaned = ? WHERE id = ?",
+		filename, position, anchor.Line, anchor.Side, anchor.StartLine, anchor.StartSide, commitSHA, orphaned, id,
+	)
+	return err
+}
+
 func (db *DB) DeleteLocalComment(id int64) error {
 	_, err := db.conn.Exec("DELETE FROM LocalComment WHERE id = ?", id)
 	return err
 }
 
-func (db *DB) GetPullRequest(prNumber int, repo string) (string, string, error) {
+// GetPullRequest returns the diff last stored for a PR and the head SHA it belongs to.
+func (db *DB) GetPullRequest(prNumber int, owner, repo string) (string, string, error) {
 	var body string
 	var sha string
 	err := db.conn.QueryRow(
-		"SELECT body, latest_sha FROM PullRequests WHERE pr_number = ? AND repo = ? LIMIT 1",
-		prNumber, repo,
+		"SELECT body, latest_sha FROM PullRequests WHERE owner = ? AND repo = ? AND pr_number = ?",
+		owner, repo, prNumber,
+	).Scan(&body, &sha)
+
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return body, sha, nil
+}
+
+// GetPreviousPullRequest returns the diff and SHA of the head stored before the latest one, or
+// empty strings if the stored head never moved.
+func (db *DB) GetPreviousPullRequest(prNumber int, owner, repo string) (string, string, error) {
+	var body string
+	var sha string
+	err := db.conn.QueryRow(
+		"SELECT previous_body, previous_sha FROM PullRequests WHERE owner = ? AND repo = ? AND pr_number = ?",
+		owner, repo, prNumber,
 	).Scan(&body, &sha)
 
 	if err == sql.ErrNoRows {
@@ -782,13 +1059,18 @@ func (db *DB) GetPullRequest(prNumber int, repo string) (string, string, error)
 	return body, sha, nil
 }
 
-func (db *DB) UpsertPullRequest(prNumber int, repo, latestSha, body string) error {
+// UpsertPullRequest stores a PR's diff at head latestSha. When the head moved, the diff stored
+// for the old head is kept as the previous one, so comments written against it can be re-anchored.
+func (db *DB) UpsertPullRequest(prNumber int, owner, repo, latestSha, body string) error {
 	_, err := db.conn.Exec(
-		`INSERT INTO PullRequests (pr_number, repo, latest_sha, body)
-		 VALUES (?, ?, ?, ?)
-		 ON CONFLICT(pr_number, repo, latest_sha) DO UPDATE SET
+		`INSERT INTO PullRequests (owner, pr_number, repo, latest_sha, body)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(owner, repo, pr_number) DO UPDATE SET
+			previous_sha = CASE WHEN latest_sha NOT IN ('', excluded.latest_sha) THEN latest_sha ELSE previous_sha END,
+			previous_body = CASE WHEN latest_sha NOT IN ('', excluded.latest_sha) THEN body ELSE previous_body END,
+			latest_sha = excluded.latest_sha,
 			body = excluded.body`,
-		prNumber, repo, latestSha, body,
+		owner, prNumber, repo, latestSha, body,
 	)
 	return err
 }
@@ -890,6 +1172,23 @@ func (db *DB) UpsertCIStatus(prNumber int, repo, sha, statusJSON string) error {
 	return err
 }
 
+func (db *DB) DeleteCIStatus(prNumber int, repo string) error {
+	_, err := db.conn.Exec(
+		"DELETE FROM CIStatus WHERE pr_number = ? AND repo = ?",
+		prNumber, repo,
+	)
+	return err
+}
+
+// DeleteCIStatusForSHA drops the cached CI status of every PR in repo whose head is sha.
+func (db *DB) DeleteCIStatusForSHA(repo, sha string) error {
+	_, err := db.conn.Exec(
+		"DELETE FROM CIStatus WHERE repo = ? AND sha = ?",
+		repo, sha,
+	)
+	return err
+}
+
 func (db *DB) GetPRMetadataCache(owner string, repo string, prNumber int) (string, error) {
 	var metadataJSON string
 	err := db.conn.QueryRow(
@@ -926,6 +1225,457 @@ func (db *DB) DeletePRMetadataCache(owner string, repo string, prNumber int) err
 	return err
 }
 
+// Snooze keeps an item's PR out of the sections until a time, until the PR has new activity,
+// or both, whichever comes first.
+type Snooze struct {
+	Identifier    string // Item identifier, owner/repo-number
+	Until         int64  // Unix time the item wakes at, 0 for no time limit
+	UntilActivity bool   // Wake when the PR is updated after the snooze was created
+	CreatedAt     int64  // Unix time
+}
+
+// MuteRule hides every PR it matches until it is removed.
+type MuteRule struct {
+	ID    int64  `json:"id"`
+	Kind  string `json:"kind"` // One of MuteKinds
+	Value string `json:"value"`
+}
+
+const (
+	MuteKindPR     = "pr"     // Value is an item identifier, owner/repo-number
+	MuteKindAuthor = "author" // Value is a login
+	MuteKindLabel  = "label"
+	MuteKindRepo   = "repo" // Value is owner/repo
+)
+
+// MuteKinds are the accepted MuteRule kinds.
+var MuteKinds = []string{MuteKindPR, MuteKindAuthor, MuteKindLabel, MuteKindRepo}
+
+// Reasons recorded in HiddenItems.
+const (
+	HiddenSnoozed = "snoozed"
+	HiddenMuted   = "muted"
+)
+
+func (db *DB) UpsertSnooze(snooze Snooze) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO Snoozes (identifier, until, until_activity, created_at)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT(identifier) DO UPDATE SET
+			until = excluded.until,
+			until_activity = excluded.until_activity,
+			created_at = excluded.created_at`,
+		snooze.Identifier, snooze.Until, snooze.UntilActivity, snooze.CreatedAt,
+	)
+	return err
+}
+
+func (db *DB) DeleteSnooze(identifier string) error {
+	_, err := db.conn.Exec("DELETE FROM Snoozes WHERE identifier = ?", identifier)
+	return err
+}
+
+// GetSnoozes returns the snoozes by item identifier.
+func (db *DB) GetSnoozes() (map[string]Snooze, error) {
+	rows, err := db.conn.Query("SELECT identifier, until, until_activity, created_at FROM Snoozes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snoozes := map[string]Snooze{}
+	for rows.Next() {
+		var snooze Snooze
+		if err := rows.Scan(&snooze.Identifier, &snooze.Until, &snooze.UntilActivity, &snooze.CreatedAt); err != nil {
+			return nil, err
+		}
+		snoozes[snooze.Identifier] = snooze
+	}
+	return snoozes, rows.Err()
+}
+
+// AddMuteRule stores a rule, returning the existing one if the same rule was already added.
+func (db *DB) AddMuteRule(kind, value string) (MuteRule, error) {
+	rule := MuteRule{Kind: kind, Value: value}
+	_, err := db.conn.Exec("INSERT OR IGNORE INTO MuteRules (kind, value) VALUES (?, ?)", kind, value)
+	if err != nil {
+		return rule, err
+	}
+	err = db.conn.QueryRow("SELECT id FROM MuteRules WHERE kind = ? AND value = ?", kind, value).Scan(&rule.ID)
+	return rule, err
+}
+
+func (db *DB) DeleteMuteRule(id int64) error {
+	_, err := db.conn.Exec("DELETE FROM MuteRules WHERE id = ?", id)
+	return err
+}
+
+func (db *DB) GetMuteRules() ([]MuteRule, error) {
+	rows, err := db.conn.Query("SELECT id, kind, value FROM MuteRules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []MuteRule{}
+	for rows.Next() {
+		var rule MuteRule
+		if err := rows.Scan(&rule.ID, &rule.Kind, &rule.Value); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SetHiddenItems records which of a section's synced identifiers are hidden and why. Rows for
+// identifiers outside the sync are left alone, so items kept by Prune = "Keep" stay hidden.
+func (db *DB) SetHiddenItems(sectionID int64, synced []string, hidden map[string]string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, identifier := range synced {
+		if _, err := tx.Exec("DELETE FROM HiddenItems WHERE section_id = ? AND identifier = ?", sectionID, identifier); err != nil {
+			return err
+		}
+		if reason, ok := hidden[identifier]; ok {
+			if _, err := tx.Exec("INSERT INTO HiddenItems (section_id, identifier, reason) VALUES (?, ?, ?)", sectionID, identifier, reason); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// HideItem hides identifier in every section it is in, without waiting for the next sync.
+func (db *DB) HideItem(identifier, reason string) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO HiddenItems (section_id, identifier, reason)
+		 SELECT section_id, identifier, ? FROM items WHERE identifier = ?
+		 ON CONFLICT(section_id, identifier) DO UPDATE SET reason = excluded.reason`,
+		reason, identifier,
+	)
+	return err
+}
+
+// UnhideItem shows identifier again where it was hidden for reason.
+func (db *DB) UnhideItem(identifier, reason string) error {
+	_, err := db.conn.Exec("DELETE FROM HiddenItems WHERE identifier = ? AND reason = ?", identifier, reason)
+	return err
+}
+
+// UnhideAll shows every item hidden for reason; the next sync hides the ones still matched.
+func (db *DB) UnhideAll(reason string) error {
+	_, err := db.conn.Exec("DELETE FROM HiddenItems WHERE reason = ?", reason)
+	return err
+}
+
+// GetHiddenItems returns the reason each hidden item is hidden, by section ID and identifier.
+// Snoozes whose time ran out count as awake even before the next sync clears them.
+func (db *DB) GetHiddenItems(now int64) (map[int64]map[string]string, error) {
+	rows, err := db.conn.Query(
+		`SELECT h.section_id, h.identifier, h.reason FROM HiddenItems h
+		 LEFT JOIN Snoozes s ON s.identifier = h.identifier
+		 WHERE h.reason != ? OR (s.identifier IS NOT NULL AND (s.until = 0 OR s.until > ?))`,
+		HiddenSnoozed, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hidden := map[int64]map[string]string{}
+	for rows.Next() {
+		var sectionID int64
+		var identifier, reason string
+		if err := rows.Scan(&sectionID, &identifier, &reason); err != nil {
+			return nil, err
+		}
+		if hidden[sectionID] == nil {
+			hidden[sectionID] = map[string]string{}
+		}
+		hidden[sectionID][identifier] = reason
+	}
+	return hidden, rows.Err()
+}
+
+// UserStatus is the keyword you gave a PR's items, which syncs leave alone until a reset rule
+// clears it. It applies to the PR in every section.
+type UserStatus struct {
+	Identifier   string // Item identifier, owner/repo-number
+	Status       string // One of UserStatusKeywords
+	SetAt        int64  // Unix time
+	HeadSHA      string // Head commit when the status was set, "" until known
+	SyncedStatus string // The synced status when yours was set
+}
+
+// UserStatusKeywords are the keywords SetUserStatus accepts.
+var UserStatusKeywords = []string{"TODO", "WAITING", "DONE", "CANCELLED"}
+
+func (db *DB) SetUserStatus(status UserStatus) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO UserStatuses (identifier, status, set_at, head_sha, synced_status)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(identifier) DO UPDATE SET
+			status = excluded.status,
+			set_at = excluded.set_at,
+			head_sha = excluded.head_sha,
+			synced_status = excluded.synced_status`,
+		status.Identifier, status.Status, status.SetAt, status.HeadSHA, status.SyncedStatus,
+	)
+	return err
+}
+
+// SetUserStatusSHA records the head commit for a status set before the commit was known.
+func (db *DB) SetUserStatusSHA(identifier, sha string) error {
+	_, err := db.conn.Exec("UPDATE UserStatuses SET head_sha = ? WHERE identifier = ?", sha, identifier)
+	return err
+}
+
+func (db *DB) ClearUserStatus(identifier string) error {
+	_, err := db.conn.Exec("DELETE FROM UserStatuses WHERE identifier = ?", identifier)
+	return err
+}
+
+// GetUserStatuses returns the user statuses by item identifier.
+func (db *DB) GetUserStatuses() (map[string]UserStatus, error) {
+	rows, err := db.conn.Query("SELECT identifier, status, set_at, head_sha, synced_status FROM UserStatuses")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := map[string]UserStatus{}
+	for rows.Next() {
+		var status UserStatus
+		if err := rows.Scan(&status.Identifier, &status.Status, &status.SetAt, &status.HeadSHA, &status.SyncedStatus); err != nil {
+			return nil, err
+		}
+		statuses[status.Identifier] = status
+	}
+	return statuses, rows.Err()
+}
+
+// Note is a private reviewer note on a PR. Notes live only in the local database and are never
+// part of a review. Filename is empty for a note on the whole PR.
+type Note struct {
+	ID        int64
+	Owner     string
+	Repo      string
+	Number    int
+	Filename  string
+	Position  int64  // Diff position when the note was written, used when Line is unknown
+	Line      int64  // File line the note is on, 0 for none
+	Side      string // "LEFT" or "RIGHT", as for comments
+	Body      string
+	CreatedAt int64 // Unix time
+}
+
+// InsertNote stores a new note and returns it with its ID set.
+func (db *DB) InsertNote(note Note) (Note, error) {
+	res, err := db.conn.Exec(
+		"INSERT INTO Notes (owner, repo, number, filename, position, line, side, body, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		note.Owner, note.Repo, note.Number, note.Filename, note.Position, note.Line, note.Side, note.Body, note.CreatedAt,
+	)
+	if err != nil {
+		return Note{}, err
+	}
+	note.ID, err = res.LastInsertId()
+	return note, err
+}
+
+// GetNotesForPR returns a PR's notes, oldest first.
+func (db *DB) GetNotesForPR(owner, repo string, number int) ([]Note, error) {
+	rows, err := db.conn.Query(
+		"SELECT id, owner, repo, number, filename, position, line, side, body, created_at FROM Notes WHERE owner = ? AND repo = ? AND number = ? ORDER BY created_at, id",
+		owner, repo, number,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		var note Note
+		if err := rows.Scan(&note.ID, &note.Owner, &note.Repo, &note.Number, &note.Filename, &note.Position, &note.Line, &note.Side, &note.Body, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// DeleteNote deletes a PR's note. It fails with sql.ErrNoRows when the PR has no note with that ID.
+func (db *DB) DeleteNote(owner, repo string, number int, id int64) error {
+	result, err := db.conn.Exec("DELETE FROM Notes WHERE owner = ? AND repo = ? AND number = ? AND id = ?", owner, repo, number, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("note %d on %s/%s#%d: %w", id, owner, repo, number, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ChecklistCheck is a checked review checklist item, recorded against the PR head it was checked at.
+type ChecklistCheck struct {
+	SHA       string
+	Checklist string // Checklist name
+	Item      string // Item text
+	CheckedAt int64  // Unix time
+}
+
+// SetChecklistItem checks or unchecks an item for a PR at head sha.
+func (db *DB) SetChecklistItem(owner, repo string, number int, check ChecklistCheck, checked bool) error {
+	if !checked {
+		_, err := db.conn.Exec(
+			"DELETE FROM ChecklistChecks WHERE owner = ? AND repo = ? AND number = ? AND sha = ? AND checklist = ? AND item = ?",
+			owner, repo, number, check.SHA, check.Checklist, check.Item,
+		)
+		return err
+	}
+	_, err := db.conn.Exec(
+		`INSERT INTO ChecklistChecks (owner, repo, number, sha, checklist, item, checked_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(owner, repo, number, sha, checklist, item) DO UPDATE SET checked_at = excluded.checked_at`,
+		owner, repo, number, check.SHA, check.Checklist, check.Item, check.CheckedAt,
+	)
+	return err
+}
+
+// GetChecklistChecks returns a PR's checked items at every head they were checked at.
+func (db *DB) GetChecklistChecks(owner, repo string, number int) ([]ChecklistCheck, error) {
+	rows, err := db.conn.Query(
+		"SELECT sha, checklist, item, checked_at FROM ChecklistChecks WHERE owner = ? AND repo = ? AND number = ? ORDER BY checked_at",
+		owner, repo, number,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []ChecklistCheck
+	for rows.Next() {
+		var check ChecklistCheck
+		if err := rows.Scan(&check.SHA, &check.Checklist, &check.Item, &check.CheckedAt); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+// HTTPCacheEntry is a GitHub response stored so it can be revalidated with a conditional request.
+type HTTPCacheEntry struct {
+	Key          string // Method, URL and Accept header of the request
+	ETag         string
+	LastModified string
+	HeadersJSON  string // The original response headers, replayed when GitHub answers 304
+	Body         []byte
+	Identity     string // Hash of the token the response was fetched with
+}
+
+// GetHTTPCacheEntry returns the cached response for key, or nil if there is none.
+func (db *DB) GetHTTPCacheEntry(key string) (*HTTPCacheEntry, error) {
+	entry := HTTPCacheEntry{Key: key}
+	err := db.conn.QueryRow(
+		"SELECT etag, last_modified, headers_json, body, identity FROM HTTPCache WHERE cache_key = ?",
+		key,
+	).Scan(&entry.ETag, &entry.LastModified, &entry.HeadersJSON, &entry.Body, &entry.Identity)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (db *DB) UpsertHTTPCacheEntry(entry HTTPCacheEntry) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO HTTPCache (cache_key, etag, last_modified, headers_json, body, identity, cached_at)
+		 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(cache_key) DO UPDATE SET
+			etag = excluded.etag,
+			last_modified = excluded.last_modified,
+			headers_json = excluded.headers_json,
+			body = excluded.body,
+			identity = excluded.identity,
+			cached_at = CURRENT_TIMESTAMP`,
+		entry.Key, entry.ETag, entry.LastModified, entry.HeadersJSON, entry.Body, entry.Identity,
+	)
+	return err
+}
+
+// Limits on the HTTP cache, applied when the database is opened. An evicted response only
+// costs one full request the next time it is needed.
+const (
+	HTTPCacheMaxAge   = 14 * 24 * time.Hour
+	HTTPCacheMaxBytes = 256 << 20
+)
+
+// PruneHTTPCache deletes responses cached longer than maxAge ago, then the oldest ones until
+// the bodies left take up at most maxBytes. It returns how many entries it deleted.
+func (db *DB) PruneHTTPCache(maxAge time.Duration, maxBytes int64) (int64, error) {
+	result, err := db.conn.Exec(
+		"DELETE FROM HTTPCache WHERE cached_at < datetime('now', ?)",
+		fmt.Sprintf("-%d seconds", int64(maxAge.Seconds())),
+	)
+	if err != nil {
+		return 0, err
+	}
+	expired, _ := result.RowsAffected()
+	result, err = db.conn.Exec(
+		`DELETE FROM HTTPCache WHERE cache_key IN (
+			SELECT cache_key FROM (
+				SELECT cache_key, SUM(length(body)) OVER (ORDER BY cached_at DESC, rowid DESC) AS total
+				FROM HTTPCache
+			) WHERE total > ?
+		)`,
+		maxBytes,
+	)
+	if err != nil {
+		return expired, err
+	}
+	oversize, _ := result.RowsAffected()
+	return expired + oversize, nil
+}
+
+// GetJiraIssueCache returns the cached issue and when it was cached, or "" if it isn't cached.
+func (db *DB) GetJiraIssueCache(issueKey string) (string, time.Time, error) {
+	var issueJSON string
+	var cachedAt time.Time
+	err := db.conn.QueryRow(
+		"SELECT issue_json, cached_at FROM JiraIssues WHERE issue_key = ?",
+		issueKey,
+	).Scan(&issueJSON, &cachedAt)
+
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return issueJSON, cachedAt, nil
+}
+
+func (db *DB) UpsertJiraIssueCache(issueKey string, issueJSON string) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO JiraIssues (issue_key, issue_json, cached_at)
+		 VALUES (?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(issue_key) DO UPDATE SET
+			issue_json = excluded.issue_json,
+			cached_at = CURRENT_TIMESTAMP`,
+		issueKey, issueJSON,
+	)
+	return err
+}
+
 func (item *Item) GetDetails() ([]string, error) {
 	var details []string
 	if err := json.Unmarshal([]byte(item.DetailsJSON), &details); err != nil {
@@ -986,6 +1736,41 @@ func (db *DB) DeletePRReviews(prNumber int, repo string) error {
 	return err
 }
 
+func (db *DB) GetPRReviewThreads(prNumber int, repo string) (string, error) {
+	var threadsJSON string
+	err := db.conn.QueryRow(
+		"SELECT threads_json FROM PRReviewThreads WHERE pr_number = ? AND repo = ?",
+		prNumber, repo,
+	).Scan(&threadsJSON)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return threadsJSON, nil
+}
+
+func (db *DB) UpsertPRReviewThreads(prNumber int, repo, threadsJSON string) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO PRReviewThreads (pr_number, repo, threads_json)
+		 VALUES (?, ?, ?)
+		 ON CONFLICT(pr_number, repo) DO UPDATE SET
+			threads_json = excluded.threads_json`,
+		prNumber, repo, threadsJSON,
+	)
+	return err
+}
+
+func (db *DB) DeletePRReviewThreads(prNumber int, repo string) error {
+	_, err := db.conn.Exec(
+		"DELETE FROM PRReviewThreads WHERE pr_number = ? AND repo = ?",
+		prNumber, repo,
+	)
+	return err
+}
+
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 	return db.conn.Exec(query, args...)
 }
@@ -997,3 +1782,29 @@ func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 	return db.conn.QueryRow(query, args...)
 }
+
+// IntegrityCheck runs SQLite's integrity check and returns the problems it finds.
+func (db *DB) IntegrityCheck() error {
+	rows, err := db.conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	problems := []string{}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
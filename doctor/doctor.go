@@ -0,0 +1,400 @@
+// Package doctor checks that the config, credentials and local setup the server depends on
+// actually work, so mistakes show up in one report instead of as warnings in the log.
+// It backs the -check-config flag and the Doctor RPC.
+package doctor
+
+import (
+	"context"
+	"crs/config"
+	"crs/forge"
+	"crs/git_tools"
+	"crs/jira"
+	"crs/workflows"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v48/github"
+)
+
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+)
+
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// OK reports whether no check failed. Warnings don't count.
+func (r Report) OK() bool {
+	return !slices.ContainsFunc(r.Checks, func(c Check) bool { return c.Status == Fail })
+}
+
+// String renders the report one check per line, followed by a summary.
+func (r Report) String() string {
+	var sb strings.Builder
+	failed := 0
+	for _, c := range r.Checks {
+		fmt.Fprintf(&sb, "[%s] %s: %s\n", c.Status, c.Name, c.Detail)
+		if c.Status == Fail {
+			failed++
+		}
+	}
+	if failed == 0 {
+		fmt.Fprintf(&sb, "All %d checks passed\n", len(r.Checks))
+	} else {
+		fmt.Fprintf(&sb, "%d of %d checks failed\n", failed, len(r.Checks))
+	}
+	return sb.String()
+}
+
+// ConfigFailure is the report for a config file that doesn't load at all.
+func ConfigFailure(err error) Report {
+	return Report{Checks: []Check{{Name: "Config", Status: Fail, Detail: err.Error()}}}
+}
+
+// Run checks cfg: the workflows, the GitHub token and its scopes, access to every configured
+// repo, RepoLocation, the repos' .crs.toml checklists, plugin commands, Jira credentials and, when
+// cfg.DB is open, the database.
+func Run(cfg *config.Config) Report {
+	report := Report{}
+	report.Checks = append(report.Checks, checkWorkflows(cfg)...)
+
+	clients := map[string]*github.Client{}
+	for _, host := range append([]*config.GithubHost{nil}, hostList(cfg)...) {
+		if host == nil && len(cfg.GithubHosts) > 0 && (!usesGithubCom(cfg) || cfg.Host(config.DefaultGithubHost) != nil) {
+			// Either unused, or checked with its GithubHosts entry
+			continue
+		}
+		client, err := git_tools.GetHostClient(host)
+		clients[hostName(host)] = client
+		report.Checks = append(report.Checks, checkGithubToken(cfg, host, client, err))
+	}
+	report.Checks = append(report.Checks, checkRepos(cfg, clients)...)
+	report.Checks = append(report.Checks, checkRepoLocation(cfg))
+	report.Checks = append(report.Checks, checkRepoChecklists(cfg)...)
+	report.Checks = append(report.Checks, checkPlugins(cfg)...)
+	if check, ok := checkJira(cfg); ok {
+		report.Checks = append(report.Checks, check)
+	}
+	if cfg.DB != nil {
+		report.Checks = append(report.Checks, checkDatabase(cfg))
+	}
+	return report
+}
+
+func checkWorkflows(cfg *config.Config) []Check {
+	errs := workflows.ValidateWorkflows(cfg)
+	if len(errs) == 0 {
+		return []Check{{Name: "Workflows", Status: Pass, Detail: fmt.Sprintf("%d workflows are valid", len(cfg.RawWorkflows))}}
+	}
+	checks := []Check{}
+	for _, err := range errs {
+		checks = append(checks, Check{Name: "Workflows", Status: Fail, Detail: err.Error()})
+	}
+	return checks
+}
+
+func hostList(cfg *config.Config) []*config.GithubHost {
+	hosts := []*config.GithubHost{}
+	for i := range cfg.GithubHosts {
+		hosts = append(hosts, &cfg.GithubHosts[i])
+	}
+	return hosts
+}
+
+// usesGithubCom reports whether any repo or workflow is served by github.com.
+func usesGithubCom(cfg *config.Config) bool {
+	for _, entry := range configuredRepos(cfg) {
+		if forge.IsGitLabEntry(entry) {
+			continue
+		}
+		prefix, name := config.SplitHost(entry)
+		owner, repo, _ := strings.Cut(name, "/")
+		if (prefix == "" && cfg.HostFor(owner, repo) == nil) || strings.EqualFold(prefix, config.DefaultGithubHost) {
+			return true
+		}
+	}
+	return slices.ContainsFunc(cfg.RawWorkflows, func(raw config.RawWorkflow) bool {
+		return raw.Host == "" && (raw.WorkflowType == "SearchWorkflow" || raw.WorkflowType == "NotificationsWorkflow")
+	})
+}
+
+func hostName(host *config.GithubHost) string {
+	if host == nil {
+		return config.DefaultGithubHost
+	}
+	return host.Host
+}
+
+// requiredScopes lists the classic token scopes the workflows on host need, with the reason.
+func requiredScopes(cfg *config.Config, host *config.GithubHost) map[string]string {
+	scopes := map[string]string{}
+	if host == nil {
+		host = cfg.Host(config.DefaultGithubHost)
+	}
+	for _, raw := range cfg.RawWorkflows {
+		workflowHost := cfg.Host(raw.Host)
+		if raw.Host == "" {
+			workflowHost = cfg.Host(config.DefaultGithubHost)
+		}
+		if workflowHost != host {
+			continue
+		}
+		if raw.WorkflowType == "NotificationsWorkflow" {
+			scopes["notifications"] = fmt.Sprintf("workflow %q syncs notifications", raw.Name)
+		}
+		if strings.Contains(raw.Filter, "in(") {
+			scopes["read:org"] = fmt.Sprintf("workflow %q filters by team membership", raw.Name)
+		}
+	}
+	return scopes
+}
+
+func checkGithubToken(cfg *config.Config, host *config.GithubHost, client *github.Client, clientErr error) Check {
+	check := Check{Name: "GitHub token"}
+	if host != nil {
+		check.Name += " (" + host.Host + ")"
+	}
+	if clientErr != nil {
+		check.Status, check.Detail = Fail, clientErr.Error()
+		return check
+	}
+	if config.TokenSource(host) == "app" {
+		return checkAppToken(cfg, host, client, check)
+	}
+	user, resp, err := client.Users.Get(context.Background(), "")
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("token was rejected: %v", err)
+		return check
+	}
+
+	check.Status = Pass
+	problems := []string{}
+	if username := cfg.Username(host); username != "" && !strings.EqualFold(username, user.GetLogin()) {
+		check.Status = Warn
+		problems = append(problems, fmt.Sprintf("GithubUsername is %q but the token belongs to %q", username, user.GetLogin()))
+	}
+
+	// Only classic tokens report their scopes; fine-grained tokens are checked per repo
+	header := resp.Header.Get("X-OAuth-Scopes")
+	if header == "" {
+		check.Detail = fmt.Sprintf("authenticated as %s with a fine-grained token", user.GetLogin())
+	} else {
+		scopes := strings.Split(strings.ReplaceAll(header, " ", ""), ",")
+		check.Detail = fmt.Sprintf("authenticated as %s with scopes %s", user.GetLogin(), strings.Join(scopes, ", "))
+		if !slices.Contains(scopes, "repo") {
+			check.Status = Warn
+			problems = append(problems, "missing the repo scope, only public repos can be read and reviewed")
+		}
+		needed := requiredScopes(cfg, host)
+		for _, scope := range slices.Sorted(maps.Keys(needed)) {
+			// The repo scope includes notifications access
+			if slices.Contains(scopes, scope) || (scope == "notifications" && slices.Contains(scopes, "repo")) {
+				continue
+			}
+			check.Status = Fail
+			problems = append(problems, fmt.Sprintf("missing the %s scope: %s", scope, needed[scope]))
+		}
+	}
+	if len(problems) > 0 {
+		check.Detail += "; " + strings.Join(problems, "; ")
+	}
+	return check
+}
+
+// checkAppToken checks a GitHub App installation token. It has no user or scopes; what it can
+// read is the set of repos the app is installed on.
+func checkAppToken(cfg *config.Config, host *config.GithubHost, client *github.Client, check Check) Check {
+	repos, _, err := client.Apps.ListRepos(context.Background(), &github.ListOptions{PerPage: 1})
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("could not get an installation token for GitHub App %d: %v", host.AppID, err)
+		return check
+	}
+	check.Status = Pass
+	check.Detail = fmt.Sprintf("GitHub App %d installation can read %d repos", host.AppID, repos.GetTotalCount())
+	if reason, ok := requiredScopes(cfg, host)["notifications"]; ok {
+		check.Status = Fail
+		check.Detail += "; app installations have no notifications inbox: " + reason
+	}
+	return check
+}
+
+// configuredRepos returns every repo entry in the config, once.
+func configuredRepos(cfg *config.Config) []string {
+	repos := []string{}
+	add := func(entry string) {
+		if entry != "" && !slices.Contains(repos, entry) {
+			repos = append(repos, entry)
+		}
+	}
+	for _, entry := range cfg.Repos {
+		add(entry)
+	}
+	for _, raw := range cfg.RawWorkflows {
+		for _, entry := range raw.Repos {
+			add(entry)
+		}
+		if raw.Owner != "" && raw.Repo != "" {
+			add(raw.Owner + "/" + raw.Repo)
+		}
+	}
+	return repos
+}
+
+func checkRepos(cfg *config.Config, clients map[string]*github.Client) []Check {
+	checks := []Check{}
+	var gitlab *forge.GitLab
+	for _, entry := range configuredRepos(cfg) {
+		check := Check{Name: "Repo " + entry, Status: Pass, Detail: "accessible"}
+		owner, repo, err := forge.ParseRepo(entry)
+		switch {
+		case err != nil:
+			// ValidateWorkflows already reports malformed entries
+			continue
+		case forge.IsGitLabEntry(entry):
+			if gitlab == nil {
+				gitlab = forge.NewGitLab()
+			}
+			if gitlab.Token == "" {
+				check.Status, check.Detail = Fail, "CRS_GITLAB_TOKEN is not set"
+			} else if err := gitlab.CheckProject(owner, repo); err != nil {
+				check.Status, check.Detail = Fail, err.Error()
+			}
+		default:
+			host := cfg.HostFor(owner, repo)
+			if prefix, _ := config.SplitHost(entry); prefix != "" {
+				host = cfg.Host(prefix)
+				if host == nil && !strings.EqualFold(prefix, config.DefaultGithubHost) {
+					// ValidateWorkflows already reports unknown hosts
+					continue
+				}
+			}
+			client := clients[hostName(host)]
+			if client == nil {
+				check.Status, check.Detail = Fail, "not checked without "+config.TokenEnv(host)
+			} else if _, _, err := client.Repositories.Get(context.Background(), owner, repo); err != nil {
+				check.Status, check.Detail = Fail, err.Error()
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func checkRepoLocation(cfg *config.Config) Check {
+	check := Check{Name: "RepoLocation"}
+	location := cfg.RepoDir("")
+	info, err := os.Stat(location)
+	if err != nil || !info.IsDir() {
+		check.Status, check.Detail = Fail, fmt.Sprintf("%s is not a directory", location)
+		return check
+	}
+
+	missing := []string{}
+	for _, entry := range configuredRepos(cfg) {
+		_, repo, err := forge.ParseRepo(entry)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(location, repo)); err != nil {
+			missing = append(missing, entry)
+		}
+	}
+	check.Status, check.Detail = Pass, location
+	if len(missing) > 0 {
+		check.Detail = fmt.Sprintf("%s has no clone of %s", location, strings.Join(missing, ", "))
+		// Clones are only required to create worktrees
+		if cfg.AutoWorktree {
+			check.Status = Warn
+			check.Detail += "; AutoWorktree skips these repos"
+		}
+	}
+	return check
+}
+
+// checkRepoChecklists loads the .crs.toml of each cloned repo that has one.
+func checkRepoChecklists(cfg *config.Config) []Check {
+	checks := []Check{}
+	for _, entry := range configuredRepos(cfg) {
+		_, repo, err := forge.ParseRepo(entry)
+		if err != nil {
+			continue
+		}
+		dir := cfg.RepoDir(repo)
+		if _, err := os.Stat(filepath.Join(dir, config.RepoConfigFile)); err != nil {
+			continue
+		}
+		check := Check{Name: "Checklists " + entry}
+		checklists, err := config.LoadRepoChecklists(dir)
+		if err != nil {
+			check.Status, check.Detail = Fail, err.Error()
+		} else {
+			check.Status, check.Detail = Pass, fmt.Sprintf("%d checklists in %s", len(checklists), config.RepoConfigFile)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func checkPlugins(cfg *config.Config) []Check {
+	checks := []Check{}
+	for _, plugin := range cfg.Plugins {
+		check := Check{Name: "Plugin " + plugin.Name}
+		path, err := exec.LookPath(plugin.Command)
+		if err != nil {
+			check.Status, check.Detail = Fail, fmt.Sprintf("command %q not found on PATH", plugin.Command)
+		} else {
+			check.Status, check.Detail = Pass, path
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkJira checks the Jira credentials when anything in the config talks to Jira.
+func checkJira(cfg *config.Config) (Check, bool) {
+	check := Check{Name: "Jira"}
+	usesJira := len(cfg.JiraRules) > 0 || slices.ContainsFunc(cfg.RawWorkflows, func(raw config.RawWorkflow) bool {
+		return raw.WorkflowType == "ProjectListWorkflow"
+	})
+	if cfg.JiraDomain == "" {
+		if !usesJira {
+			return check, false
+		}
+		check.Status, check.Detail = Fail, "JiraDomain is not set"
+		return check, true
+	}
+	if os.Getenv("JIRA_API_EMAIL") == "" || os.Getenv("JIRA_API_TOKEN") == "" {
+		check.Status, check.Detail = Fail, "JIRA_API_EMAIL and JIRA_API_TOKEN must be set"
+		return check, true
+	}
+	name, err := jira.Myself(cfg.JiraDomain)
+	if err != nil {
+		check.Status, check.Detail = Fail, err.Error()
+		return check, true
+	}
+	check.Status, check.Detail = Pass, fmt.Sprintf("authenticated to %s as %s", cfg.JiraDomain, name)
+	return check, true
+}
+
+func checkDatabase(cfg *config.Config) Check {
+	if err := cfg.DB.IntegrityCheck(); err != nil {
+		return Check{Name: "Database", Status: Fail, Detail: err.Error()}
+	}
+	return Check{Name: "Database", Status: Pass, Detail: "integrity check passed"}
+}
@@ -0,0 +1,97 @@
+package doctor
+
+import (
+	"crs/config"
+	"crs/testutil"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckGithubToken(t *testing.T) {
+	scopes := "repo"
+	client := testutil.NewGithubClient(t, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-OAuth-Scopes", scopes)
+		fmt.Fprint(w, `{"login":"me"}`)
+	}))
+	cfg := &config.Config{
+		GithubUsername: "me",
+		RawWorkflows:   []config.RawWorkflow{{WorkflowType: "SearchWorkflow", Name: "team", Filter: "author:in(org/core)"}},
+	}
+
+	check := checkGithubToken(cfg, nil, client, nil)
+	if check.Status != Fail || !strings.Contains(check.Detail, `missing the read:org scope: workflow "team" filters by team membership`) {
+		t.Errorf("checkGithubToken() = %+v, want a missing read:org failure", check)
+	}
+
+	scopes = "repo, read:org"
+	if check := checkGithubToken(cfg, nil, client, nil); check.Status != Pass {
+		t.Errorf("checkGithubToken() = %+v, want a pass", check)
+	}
+
+	cfg.GithubUsername = "someone-else"
+	if check := checkGithubToken(cfg, nil, client, nil); check.Status != Warn {
+		t.Errorf("checkGithubToken() = %+v, want a username mismatch warning", check)
+	}
+
+	if check := checkGithubToken(cfg, nil, nil, errors.New("no GitHub token: CRS_GITHUB_TOKEN is not set")); check.Status != Fail {
+		t.Errorf("checkGithubToken(nil) = %+v, want a missing token failure", check)
+	}
+}
+
+func TestCheckRepoLocationAndPlugins(t *testing.T) {
+	location := t.TempDir()
+	if err := os.Mkdir(filepath.Join(location, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Repos:        []string{"org/api", "org/web"},
+		RepoLocation: location,
+		AutoWorktree: true,
+		Plugins: []config.Plugin{
+			{Name: "shell", Command: "sh"},
+			{Name: "missing", Command: "crs-no-such-plugin"},
+		},
+	}
+
+	check := checkRepoLocation(cfg)
+	if check.Status != Warn || !strings.Contains(check.Detail, "no clone of org/web;") {
+		t.Errorf("checkRepoLocation() = %+v, want a warning about org/web", check)
+	}
+	cfg.RepoLocation = filepath.Join(location, "nope")
+	if check := checkRepoLocation(cfg); check.Status != Fail {
+		t.Errorf("checkRepoLocation() = %+v, want a failure for a missing directory", check)
+	}
+
+	checks := checkPlugins(cfg)
+	if len(checks) != 2 || checks[0].Status != Pass || checks[1].Status != Fail {
+		t.Errorf("checkPlugins() = %+v, want sh to pass and the missing command to fail", checks)
+	}
+}
+
+func TestReport(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	report := Report{Checks: []Check{
+		checkDatabase(&config.Config{DB: db}),
+		{Name: "RepoLocation", Status: Warn, Detail: "no clones"},
+	}}
+	if !report.OK() {
+		t.Errorf("OK() = false, want warnings not to fail the report")
+	}
+	want := "[PASS] Database: integrity check passed\n" +
+		"[WARN] RepoLocation: no clones\n" +
+		"All 2 checks passed\n"
+	if report.String() != want {
+		t.Errorf("String() =\n%s\nwant\n%s", report.String(), want)
+	}
+
+	report.Checks = append(report.Checks, ConfigFailure(fmt.Errorf("bad toml")).Checks...)
+	if report.OK() || !strings.HasSuffix(report.String(), "[FAIL] Config: bad toml\n1 of 3 checks failed\n") {
+		t.Errorf("String() =\n%s\nwant a failed config check", report.String())
+	}
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"sync"
+)
+
+// Notification methods pushed to JSON-RPC 2.0 clients.
+const (
+	ReviewsChanged = "reviews/changed"
+	PluginFinished = "plugin/finished"
+	PRUpdated      = "pr/updated"
+	PRChanged      = "pr/changed"
+)
+
+// ReviewsChangedParams is published after a ManagerService cycle applied changes to the sections.
+type ReviewsChangedParams struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// PluginFinishedParams is published once a plugin result has been stored.
+type PluginFinishedParams struct {
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Plugin string `json:"plugin"`
+	Status string `json:"status"`
+	SHA    string `json:"sha"`
+}
+
+// PRUpdatedParams is published when a new head SHA is seen for a PR.
+type PRUpdatedParams struct {
+	Owner       string `json:"owner"`
+	Repo        string `json:"repo"`
+	Number      int    `json:"number"`
+	PreviousSHA string `json:"previous_sha"`
+	SHA         string `json:"sha"`
+}
+
+// PRChangedParams is published when a webhook reported activity on a PR and its caches were dropped.
+type PRChangedParams struct {
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Event  string `json:"event"` // The GitHub webhook event, e.g. "pull_request_review"
+}
+
+type Listener func(method string, params interface{})
+
+var (
+	mu        sync.RWMutex
+	listeners = make(map[int]Listener)
+	nextID    int
+)
+
+// Subscribe registers a listener for every published event.
+// The returned function removes the listener again.
+func Subscribe(listener Listener) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	listeners[id] = listener
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(listeners, id)
+	}
+}
+
+// Publish fans an event out to all listeners. It is a no-op when nobody is subscribed,
+// e.g. in oneoff mode or when no JSON-RPC 2.0 client is attached.
+func Publish(method string, params interface{}) {
+	mu.RLock()
+	current := make([]Listener, 0, len(listeners))
+	for _, l := range listeners {
+		current = append(current, l)
+	}
+	mu.RUnlock()
+
+	for _, l := range current {
+		l(method, params)
+	}
+}
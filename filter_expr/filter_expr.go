@@ -0,0 +1,271 @@
+// Package filter_expr parses the boolean filter expressions workflows accept in their Filter key, e.g.
+//
+//	FilterWaitingOnMe or (label:urgent and not FilterIsDraft)
+//
+// Parsing only checks the syntax; the workflows package resolves the terms to PR filters.
+package filter_expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a node of a parsed filter expression: And, Or, Not or Term.
+type Expr interface {
+	String() string
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+// Term is a single filter, either a bare name (FilterIsDraft), a name with an argument
+// (label:foo, FilterByLabel:foo, author:in(team)) or a comparison (age>3d).
+type Term struct {
+	Name   string
+	Op     string // "", ":", ">", ">=", "<", "<=" or "="
+	Arg    string
+	In     bool // Arg was written as in(...)
+	Offset int  // Byte offset of the term in the expression
+}
+
+func (e And) String() string { return "(" + e.Left.String() + " and " + e.Right.String() + ")" }
+func (e Or) String() string  { return "(" + e.Left.String() + " or " + e.Right.String() + ")" }
+func (e Not) String() string { return "not " + e.Expr.String() }
+
+func (t Term) String() string {
+	arg := t.Arg
+	if strings.ContainsAny(arg, " ()\"") {
+		arg = fmt.Sprintf("%q", arg)
+	}
+	if t.In {
+		arg = "in(" + arg + ")"
+	}
+	return t.Name + t.Op + arg
+}
+
+// Error is a syntax or resolution error at a byte offset of the expression.
+type Error struct {
+	Offset int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Offset+1, e.Msg)
+}
+
+// Errorf returns an *Error pointing at the term.
+func (t Term) Errorf(format string, args ...any) error {
+	return &Error{Offset: t.Offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+const (
+	tokEOF = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokOp
+)
+
+type token struct {
+	kind   int
+	text   string
+	offset int
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("_-./@", c) >= 0
+}
+
+func lex(input string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == ':' || c == '=':
+			tokens = append(tokens, token{tokOp, string(c), i})
+			i++
+		case c == '>' || c == '<':
+			op := string(c)
+			if i+1 < len(input) && input[i+1] == '=' {
+				op += "="
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		case c == '"':
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, &Error{Offset: i, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{tokString, input[i+1 : i+1+end], i})
+			i += end + 2
+		case isWordByte(c):
+			start := i
+			for i < len(input) && isWordByte(input[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokWord, input[start:i], start})
+		default:
+			return nil, &Error{Offset: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(input)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// Parse parses a filter expression. "and" binds tighter than "or"; both are left associative.
+// Keywords are case-insensitive.
+func Parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, &Error{Offset: 0, Msg: "empty expression"}
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Offset: t.offset, Msg: fmt.Sprintf("expected and, or or end of expression, found %q", t.text)}
+	}
+	return expr, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.keyword("not") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: expr}, nil
+	}
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &Error{Offset: closing.offset, Msg: fmt.Sprintf("expected ) to close ( at column %d", t.offset+1)}
+		}
+		return expr, nil
+	case tokWord:
+		if isKeyword(t.text) {
+			return nil, &Error{Offset: t.offset, Msg: fmt.Sprintf("expected a filter, found %q", t.text)}
+		}
+		return p.parseTerm(t)
+	case tokEOF:
+		return nil, &Error{Offset: t.offset, Msg: "expected a filter, found end of expression"}
+	default:
+		return nil, &Error{Offset: t.offset, Msg: fmt.Sprintf("expected a filter, found %q", t.text)}
+	}
+}
+
+func (p *parser) parseTerm(name token) (Expr, error) {
+	term := Term{Name: name.text, Offset: name.offset}
+	if p.peek().kind != tokOp {
+		return term, nil
+	}
+	term.Op = p.next().text
+
+	arg := p.next()
+	switch {
+	case arg.kind == tokWord && strings.EqualFold(arg.text, "in") && p.peek().kind == tokLParen:
+		p.next()
+		inner := p.next()
+		if inner.kind != tokWord && inner.kind != tokString {
+			return nil, &Error{Offset: inner.offset, Msg: "expected a value inside in(...)"}
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &Error{Offset: closing.offset, Msg: "expected ) to close in(...)"}
+		}
+		term.Arg = inner.text
+		term.In = true
+	case arg.kind == tokWord || arg.kind == tokString:
+		term.Arg = arg.text
+	default:
+		return nil, &Error{Offset: arg.offset, Msg: fmt.Sprintf("expected a value after %s%s", term.Name, term.Op)}
+	}
+	return term, nil
+}
+
+func isKeyword(word string) bool {
+	return strings.EqualFold(word, "and") || strings.EqualFold(word, "or") || strings.EqualFold(word, "not")
+}
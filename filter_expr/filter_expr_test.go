@@ -0,0 +1,68 @@
+package filter_expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"FilterIsDraft", "FilterIsDraft"},
+		{"FilterByLabel:bug", "FilterByLabel:bug"},
+		{"label:area/backend", "label:area/backend"},
+		{`label:"needs review"`, `label:"needs review"`},
+		{"age>3d", "age>3d"},
+		{"age <= 12h", "age<=12h"},
+		{"author:in(platform)", "author:in(platform)"},
+		{"a or b and c", "(a or (b and c))"},
+		{"(a or b) and c", "((a or b) and c)"},
+		{"a and b and c", "((a and b) and c)"},
+		{"not a or b", "(not a or b)"},
+		{"NOT not a", "not not a"},
+		{"FilterWaitingOnMe OR (FilterByLabel:urgent AND NOT FilterIsDraft)", "(FilterWaitingOnMe or (FilterByLabel:urgent and not FilterIsDraft))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		offset int
+	}{
+		{"", 0},
+		{"a and", 5},
+		{"a or or b", 5},
+		{"(a or b", 7},
+		{"a b", 2},
+		{"label:", 6},
+		{`label:"oops`, 6},
+		{"author:in(team", 14},
+		{"a & b", 2},
+		{"a)", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var exprErr *Error
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.input, err)
+			}
+			if exprErr.Offset != tt.offset {
+				t.Errorf("Parse(%q) error offset = %d (%v), want %d", tt.input, exprErr.Offset, err, tt.offset)
+			}
+		})
+	}
+}
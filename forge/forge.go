@@ -0,0 +1,96 @@
+// Package forge hides which code host a repo lives on. Every backend speaks in go-github types,
+// so the org sections, the caches and the renderer handle GitLab merge requests exactly like
+// GitHub pull requests.
+package forge
+
+import (
+	"crs/config"
+	"crs/git_tools"
+	"strings"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// Forge is the set of code host operations the workflows and the PR view need.
+type Forge interface {
+	// ListPRs lists the PRs of a repo in the given state: open, closed or all.
+	ListPRs(owner, repo, state string) ([]*github.PullRequest, error)
+	GetPR(owner, repo string, number int) (*github.PullRequest, error)
+	// GetDiff returns the PR's unified diff.
+	GetDiff(owner, repo string, number int) (string, error)
+	// CompareDiff returns the unified diff between two commits of a repo.
+	CompareDiff(owner, repo, base, head string) (string, error)
+	// ListComments returns inline and conversation comments, oldest first.
+	ListComments(owner, repo string, number int) ([]*github.PullRequestComment, error)
+	ListReviews(owner, repo string, number int) ([]*github.PullRequestReview, error)
+	ListCommits(owner, repo string, number int) ([]*github.RepositoryCommit, error)
+	// SubmitReview posts the review's comments and body, then applies its event (APPROVE, ...).
+	SubmitReview(owner, repo string, number int, review *github.PullRequestReviewRequest) error
+	SubmitReply(owner, repo string, number int, body string, replyToID int64) error
+}
+
+// GitLabPrefix marks a configured repo as a GitLab project, e.g. gitlab:group/project.
+const GitLabPrefix = "gitlab:"
+
+// IsGitLabEntry reports whether a config repo entry names a GitLab project.
+func IsGitLabEntry(entry string) bool {
+	return strings.HasPrefix(entry, GitLabPrefix)
+}
+
+// ParseRepo splits a config repo entry into owner and repo. GitLab owners are the project's
+// namespace and may contain slashes. A GitHub host prefix (ghe.acme.com/owner/repo) is dropped;
+// config.C.HostFor finds it again from the owner and repo.
+func ParseRepo(entry string) (string, string, error) {
+	if path, found := strings.CutPrefix(entry, GitLabPrefix); found {
+		slash := strings.LastIndex(path, "/")
+		if slash <= 0 || slash == len(path)-1 {
+			return "", "", &InvalidRepoError{Entry: entry}
+		}
+		return path[:slash], path[slash+1:], nil
+	}
+	_, name := config.SplitHost(entry)
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 {
+		return "", "", &InvalidRepoError{Entry: entry}
+	}
+	return parts[0], parts[1], nil
+}
+
+type InvalidRepoError struct {
+	Entry string
+}
+
+func (e *InvalidRepoError) Error() string {
+	return "invalid repo entry: " + e.Entry + " (expected 'owner/repo', 'host/owner/repo' or 'gitlab:group/project')"
+}
+
+// For returns the forge of a repo the client refers to by owner and name: GitLab if the repo
+// is configured with the gitlab: prefix anywhere in the config, otherwise the GitHub host serving it.
+func For(owner, repo string) Forge {
+	if IsGitLab(owner, repo) {
+		return NewGitLab()
+	}
+	return &GitHub{Client: git_tools.GetGithubClientFor(owner, repo)}
+}
+
+// IsGitLab reports whether owner/repo is configured as a GitLab project.
+func IsGitLab(owner, repo string) bool {
+	target := GitLabPrefix + owner + "/" + repo
+	matches := func(entries []string) bool {
+		for _, entry := range entries {
+			if strings.EqualFold(entry, target) {
+				return true
+			}
+		}
+		return false
+	}
+	if matches(config.C.Repos) {
+		return true
+	}
+	for _, raw := range config.C.RawWorkflows {
+		if matches(raw.Repos) || matches([]string{raw.Repo}) {
+			return true
+		}
+	}
+	return false
+}
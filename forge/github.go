@@ -0,0 +1,86 @@
+package forge
+
+import (
+	"context"
+	"crs/git_tools"
+	"sort"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// GitHub is the Forge backed by the GitHub REST API.
+type GitHub struct {
+	Client *github.Client
+}
+
+func NewGitHub() *GitHub {
+	return &GitHub{Client: git_tools.GetGithubClient()}
+}
+
+func (g *GitHub) ListPRs(owner, repo, state string) ([]*github.PullRequest, error) {
+	return git_tools.GetPRs(g.Client, state, owner, repo)
+}
+
+func (g *GitHub) GetPR(owner, repo string, number int) (*github.PullRequest, error) {
+	pr, _, err := g.Client.PullRequests.Get(context.Background(), owner, repo, number)
+	return pr, err
+}
+
+func (g *GitHub) GetDiff(owner, repo string, number int) (string, error) {
+	diff, _, err := g.Client.PullRequests.GetRaw(context.Background(), owner, repo, number, github.RawOptions{Type: github.Diff})
+	return diff, err
+}
+
+func (g *GitHub) CompareDiff(owner, repo, base, head string) (string, error) {
+	return git_tools.GetCompareDiff(g.Client, owner, repo, base, head)
+}
+
+func (g *GitHub) ListComments(owner, repo string, number int) ([]*github.PullRequestComment, error) {
+	ctx := context.Background()
+	comments, _, err := g.Client.PullRequests.ListComments(ctx, owner, repo, number, &github.PullRequestListCommentsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	issueComments, _, err := g.Client.Issues.ListComments(ctx, owner, repo, number, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, ic := range issueComments {
+		comments = append(comments, convertIssueCommentToPRComment(ic))
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].GetCreatedAt().Before(comments[j].GetCreatedAt())
+	})
+	return comments, nil
+}
+
+func (g *GitHub) ListReviews(owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	reviews, _, err := g.Client.PullRequests.ListReviews(context.Background(), owner, repo, number, nil)
+	return reviews, err
+}
+
+func (g *GitHub) ListCommits(owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	commits, _, err := g.Client.PullRequests.ListCommits(context.Background(), owner, repo, number, nil)
+	return commits, err
+}
+
+func (g *GitHub) SubmitReview(owner, repo string, number int, review *github.PullRequestReviewRequest) error {
+	return git_tools.SubmitReview(g.Client, owner, repo, number, review)
+}
+
+func (g *GitHub) SubmitReply(owner, repo string, number int, body string, replyToID int64) error {
+	return git_tools.SubmitReply(g.Client, owner, repo, number, body, replyToID)
+}
+
+// convertIssueCommentToPRComment converts a *github.IssueComment to *github.PullRequestComment
+func convertIssueCommentToPRComment(ic *github.IssueComment) *github.PullRequestComment {
+	return &github.PullRequestComment{
+		ID:        ic.ID,
+		Body:      ic.Body,
+		User:      ic.User,
+		CreatedAt: ic.CreatedAt,
+		UpdatedAt: ic.UpdatedAt,
+		URL:       ic.URL,
+		HTMLURL:   ic.HTMLURL,
+	}
+}
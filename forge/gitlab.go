@@ -0,0 +1,680 @@
+package forge
+
+import (
+	"bytes"
+	"crs/config"
+	"crs/git_tools"
+	"crs/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// GitLab is the Forge backed by the GitLab REST API (v4). Merge requests are converted into
+// go-github types: the MR iid is the PR number and discussions become review comments.
+type GitLab struct {
+	BaseURL string // e.g. https://gitlab.com
+	Token   string
+	HTTP    *http.Client
+}
+
+func NewGitLab() *GitLab {
+	baseURL := config.C.GitlabURL
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+	token := os.Getenv("CRS_GITLAB_TOKEN")
+	if token == "" {
+		slog.Error("Error! No GitLab Token! Set CRS_GITLAB_TOKEN to use gitlab: repos")
+	}
+	return &GitLab{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTP: git_tools.NewHTTPClient(gitlabTimeout)}
+}
+
+// gitlabTimeout bounds each API request; large merge request diffs come in a single response.
+const gitlabTimeout = 30 * time.Second
+
+type glUser struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type glMergeRequest struct {
+	ID             int64      `json:"id"`
+	IID            int        `json:"iid"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	State          string     `json:"state"`
+	Draft          bool       `json:"draft"`
+	WorkInProgress bool       `json:"work_in_progress"`
+	WebURL         string     `json:"web_url"`
+	SourceBranch   string     `json:"source_branch"`
+	TargetBranch   string     `json:"target_branch"`
+	SHA            string     `json:"sha"`
+	MergeCommitSHA string     `json:"merge_commit_sha"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	MergedAt       *time.Time `json:"merged_at"`
+	Author         *glUser    `json:"author"`
+	Assignees      []glUser   `json:"assignees"`
+	Reviewers      []glUser   `json:"reviewers"`
+	Labels         []string   `json:"labels"`
+	Milestone      *struct {
+		Title string `json:"title"`
+	} `json:"milestone"`
+	DiffRefs     *glDiffRefs `json:"diff_refs"`
+	HeadPipeline *struct {
+		ID     int64  `json:"id"`
+		Status string `json:"status"`
+	} `json:"head_pipeline"`
+}
+
+type glDiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	HeadSHA  string `json:"head_sha"`
+	StartSHA string `json:"start_sha"`
+}
+
+type glDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+type glPosition struct {
+	BaseSHA      string `json:"base_sha"`
+	StartSHA     string `json:"start_sha"`
+	HeadSHA      string `json:"head_sha"`
+	PositionType string `json:"position_type"`
+	OldPath      string `json:"old_path,omitempty"`
+	NewPath      string `json:"new_path,omitempty"`
+	OldLine      int    `json:"old_line,omitempty"`
+	NewLine      int    `json:"new_line,omitempty"`
+}
+
+type glNote struct {
+	ID        int64       `json:"id"`
+	Body      string      `json:"body"`
+	Author    glUser      `json:"author"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	System    bool        `json:"system"`
+	Position  *glPosition `json:"position"`
+}
+
+type glDiscussion struct {
+	ID    string   `json:"id"`
+	Notes []glNote `json:"notes"`
+}
+
+type glCommit struct {
+	ID           string    `json:"id"`
+	Message      string    `json:"message"`
+	AuthorName   string    `json:"author_name"`
+	AuthoredDate time.Time `json:"authored_date"`
+	WebURL       string    `json:"web_url"`
+}
+
+// CurrentUser returns the username the token authenticates as.
+func (g *GitLab) CurrentUser() (string, error) {
+	var user glUser
+	if _, err := g.request("GET", "/user", nil, nil, &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// CheckProject returns an error when the token can't read the project.
+func (g *GitLab) CheckProject(owner, repo string) error {
+	_, err := g.request("GET", projectPath(owner, repo), nil, nil, nil)
+	return err
+}
+
+// projectPath is the URL-encoded project ID GitLab accepts in place of a numeric one.
+func projectPath(owner, repo string) string {
+	return "/projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+func mrPath(owner, repo string, number int) string {
+	return projectPath(owner, repo) + "/merge_requests/" + strconv.Itoa(number)
+}
+
+// request sends an API request and decodes the JSON response into out, when given. It returns
+// the response headers for pagination.
+func (g *GitLab) request(method, path string, query url.Values, body any, out any) (http.Header, error) {
+	endpoint := g.BaseURL + "/api/v4" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gitlab %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// getAll follows GitLab's X-Next-Page header and returns the items of every page.
+func getAll[T any](g *GitLab, path string, query url.Values) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", "100")
+	var all []T
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+		var items []T
+		header, err := g.request(http.MethodGet, path, query, nil, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		page = header.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// gitlabState maps a GitHub PR state onto GitLab's MR states. GitHub counts merged PRs as
+// closed, so closed lists everything and ListPRs drops the open ones.
+func gitlabState(state string) string {
+	if state == "open" {
+		return "opened"
+	}
+	return "all"
+}
+
+func (g *GitLab) ListPRs(owner, repo, state string) ([]*github.PullRequest, error) {
+	mrs, err := getAll[glMergeRequest](g, projectPath(owner, repo)+"/merge_requests", url.Values{"state": {gitlabState(state)}})
+	if err != nil {
+		slog.Error("Error listing merge requests", "project", owner+"/"+repo, "error", err)
+		return nil, err
+	}
+	prs := []*github.PullRequest{}
+	for i := range mrs {
+		pr := mrs[i].toPullRequest(owner, repo)
+		if state == "closed" && pr.GetState() != "closed" {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+func (g *GitLab) getMR(owner, repo string, number int) (*glMergeRequest, error) {
+	var mr glMergeRequest
+	if _, err := g.request(http.MethodGet, mrPath(owner, repo, number), nil, nil, &mr); err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
+
+func (g *GitLab) GetPR(owner, repo string, number int) (*github.PullRequest, error) {
+	mr, err := g.getMR(owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return mr.toPullRequest(owner, repo), nil
+}
+
+func (g *GitLab) GetDiff(owner, repo string, number int) (string, error) {
+	diffs, err := getAll[glDiff](g, mrPath(owner, repo, number)+"/diffs", nil)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(diffs), nil
+}
+
+func (g *GitLab) CompareDiff(owner, repo, base, head string) (string, error) {
+	var compare struct {
+		Diffs []glDiff `json:"diffs"`
+	}
+	query := url.Values{"from": {base}, "to": {head}, "straight": {"true"}}
+	if _, err := g.request(http.MethodGet, projectPath(owner, repo)+"/repository/compare", query, nil, &compare); err != nil {
+		return "", err
+	}
+	return unifiedDiff(compare.Diffs), nil
+}
+
+// unifiedDiff stitches GitLab's per-file diffs, which lack file headers, into one git diff.
+func unifiedDiff(diffs []glDiff) string {
+	var sb strings.Builder
+	for _, d := range diffs {
+		oldName, newName := "a/"+d.OldPath, "b/"+d.NewPath
+		if d.NewFile {
+			oldName = "/dev/null"
+		}
+		if d.DeletedFile {
+			newName = "/dev/null"
+		}
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- %s\n+++ %s\n", d.OldPath, d.NewPath, oldName, newName)
+		sb.WriteString(d.Diff)
+		if !strings.HasSuffix(d.Diff, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// ListComments flattens the MR's discussions: the first note of a discussion is the thread
+// root and later notes reply to it. Positioned notes get a diff position computed from the
+// current diff; those no longer in the diff are reported as outdated.
+func (g *GitLab) ListComments(owner, repo string, number int) ([]*github.PullRequestComment, error) {
+	mr, err := g.getMR(owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	discussions, err := getAll[glDiscussion](g, mrPath(owner, repo, number)+"/discussions", nil)
+	if err != nil {
+		return nil, err
+	}
+	rawDiff, err := g.GetDiff(owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	diff, _ := utils.Parse(rawDiff)
+	return discussionComments(discussions, diff, mr.WebURL), nil
+}
+
+func discussionComments(discussions []glDiscussion, diff *utils.Diff, webURL string) []*github.PullRequestComment {
+	comments := []*github.PullRequestComment{}
+	for _, discussion := range discussions {
+		var rootID int64
+		for _, note := range discussion.Notes {
+			if note.System {
+				continue
+			}
+			comment := note.toComment(diff, webURL)
+			if rootID == 0 {
+				rootID = note.ID
+			} else {
+				comment.InReplyTo = github.Int64(rootID)
+			}
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].GetCreatedAt().Before(comments[j].GetCreatedAt())
+	})
+	return comments
+}
+
+func (n glNote) toComment(diff *utils.Diff, webURL string) *github.PullRequestComment {
+	created, updated := n.CreatedAt, n.UpdatedAt
+	comment := &github.PullRequestComment{
+		ID:        github.Int64(n.ID),
+		Body:      github.String(n.Body),
+		User:      toUser(&glUser{Username: n.Author.Username, Name: n.Author.Name}),
+		CreatedAt: &created,
+		UpdatedAt: &updated,
+		HTMLURL:   github.String(fmt.Sprintf("%s#note_%d", webURL, n.ID)),
+	}
+	p := n.Position
+	if p == nil || p.PositionType != "text" {
+		return comment
+	}
+
+	path, line, side := p.NewPath, p.NewLine, "RIGHT"
+	if line == 0 {
+		path, line, side = p.OldPath, p.OldLine, "LEFT"
+	}
+	comment.Path = github.String(path)
+	comment.CommitID = github.String(p.HeadSHA)
+	comment.OriginalLine = github.Int(line)
+	comment.Side = github.String(side)
+	position := 0
+	if diff != nil {
+		position = diff.PositionOfLine(path, line, side == "LEFT")
+	}
+	// Notes on lines no longer in the diff keep only OriginalLine and show as outdated
+	if position != 0 {
+		comment.Position = github.Int(position)
+		comment.Line = github.Int(line)
+	}
+	return comment
+}
+
+// ListReviews reports approvals as APPROVED reviews; GitLab has no other review states.
+func (g *GitLab) ListReviews(owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	var approvals struct {
+		ApprovedBy []struct {
+			User glUser `json:"user"`
+		} `json:"approved_by"`
+	}
+	if _, err := g.request(http.MethodGet, mrPath(owner, repo, number)+"/approvals", nil, nil, &approvals); err != nil {
+		return nil, err
+	}
+	reviews := []*github.PullRequestReview{}
+	for _, approval := range approvals.ApprovedBy {
+		user := approval.User
+		reviews = append(reviews, &github.PullRequestReview{
+			User:  toUser(&user),
+			State: github.String("APPROVED"),
+		})
+	}
+	return reviews, nil
+}
+
+func (g *GitLab) ListCommits(owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	glCommits, err := getAll[glCommit](g, mrPath(owner, repo, number)+"/commits", nil)
+	if err != nil {
+		return nil, err
+	}
+	commits := []*github.RepositoryCommit{}
+	for _, c := range glCommits {
+		date := c.AuthoredDate
+		commits = append(commits, &github.RepositoryCommit{
+			SHA:     github.String(c.ID),
+			HTMLURL: github.String(c.WebURL),
+			Commit: &github.Commit{
+				Message: github.String(c.Message),
+				Author:  &github.CommitAuthor{Name: github.String(c.AuthorName), Date: &date},
+			},
+		})
+	}
+	return commits, nil
+}
+
+// SubmitReview posts the inline comments as draft notes and publishes them, adds the body as an
+// MR note and approves for APPROVE. GitLab has no request-changes state, so REQUEST_CHANGES only
+// publishes the comments.
+func (g *GitLab) SubmitReview(owner, repo string, number int, review *github.PullRequestReviewRequest) error {
+	mr, err := g.getMR(owner, repo, number)
+	if err != nil {
+		return err
+	}
+	if len(review.Comments) > 0 {
+		rawDiff, err := g.GetDiff(owner, repo, number)
+		if err != nil {
+			return err
+		}
+		diff, _ := utils.Parse(rawDiff)
+		if err := g.publishDraftNotes(owner, repo, number, review.Comments, diff, mr.DiffRefs); err != nil {
+			return err
+		}
+	}
+	if review.GetBody() != "" {
+		if _, err := g.request(http.MethodPost, mrPath(owner, repo, number)+"/notes", nil, map[string]string{"body": review.GetBody()}, nil); err != nil {
+			return err
+		}
+	}
+	if review.GetEvent() == "APPROVE" {
+		if _, err := g.request(http.MethodPost, mrPath(owner, repo, number)+"/approve", nil, map[string]string{"sha": mr.SHA}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishDraftNotes creates a draft note for each comment and then publishes all of the user's
+// drafts on the MR in one bulk call, the way GitLab's "Submit review" does, so a review is never
+// left half-published. When a draft can't be created the ones created so far are deleted again.
+func (g *GitLab) publishDraftNotes(owner, repo string, number int, comments []*github.DraftReviewComment, diff *utils.Diff, refs *glDiffRefs) error {
+	path := mrPath(owner, repo, number) + "/draft_notes"
+	var created []int64
+	for _, c := range comments {
+		note := map[string]any{"note": c.GetBody()}
+		if position := draftNotePosition(c, diff, refs); position != nil {
+			note["position"] = position
+		}
+		var draft struct {
+			ID int64 `json:"id"`
+		}
+		if _, err := g.request(http.MethodPost, path, nil, note, &draft); err != nil {
+			for _, id := range created {
+				if _, err := g.request(http.MethodDelete, fmt.Sprintf("%s/%d", path, id), nil, nil, nil); err != nil {
+					slog.Error("Error deleting draft note", "id", id, "error", err)
+				}
+			}
+			return err
+		}
+		created = append(created, draft.ID)
+	}
+	_, err := g.request(http.MethodPost, path+"/bulk_publish", nil, nil, nil)
+	return err
+}
+
+// draftNotePosition anchors a review comment to a diff line. Comments stored before line
+// anchoring existed only carry a diff position, which is looked up in the current diff.
+func draftNotePosition(c *github.DraftReviewComment, diff *utils.Diff, refs *glDiffRefs) *glPosition {
+	if refs == nil || c.GetPath() == "" {
+		return nil
+	}
+	position := &glPosition{
+		BaseSHA:      refs.BaseSHA,
+		StartSHA:     refs.StartSHA,
+		HeadSHA:      refs.HeadSHA,
+		PositionType: "text",
+		OldPath:      c.GetPath(),
+		NewPath:      c.GetPath(),
+	}
+	line, side := c.GetLine(), c.GetSide()
+	diffPosition := c.GetPosition()
+	if line == 0 && diff != nil {
+		diffLine := diff.LineAtPosition(c.GetPath(), diffPosition)
+		if diffLine == nil {
+			return nil
+		}
+		line, side = diffLine.Number, "RIGHT"
+		if diffLine.Mode == utils.REMOVED {
+			side = "LEFT"
+		}
+	} else if diff != nil {
+		diffPosition = diff.PositionOfLine(c.GetPath(), line, side == "LEFT")
+	}
+	if side == "LEFT" {
+		position.OldLine = line
+	} else {
+		position.NewLine = line
+	}
+	// GitLab rejects a context line unless it gets the line's number on both sides
+	if diff != nil && diffPosition != 0 {
+		if oldLine, newLine := diff.LinesAtPosition(c.GetPath(), diffPosition); oldLine != 0 && newLine != 0 {
+			position.OldLine, position.NewLine = oldLine, newLine
+		}
+	}
+	return position
+}
+
+// SubmitReply adds a note to the discussion that contains the comment being replied to.
+func (g *GitLab) SubmitReply(owner, repo string, number int, body string, replyToID int64) error {
+	discussions, err := getAll[glDiscussion](g, mrPath(owner, repo, number)+"/discussions", nil)
+	if err != nil {
+		return err
+	}
+	for _, discussion := range discussions {
+		for _, note := range discussion.Notes {
+			if note.ID == replyToID {
+				_, err := g.request(http.MethodPost, mrPath(owner, repo, number)+"/discussions/"+discussion.ID+"/notes", nil, map[string]string{"body": body}, nil)
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("no discussion on %s/%s!%d contains note %d", owner, repo, number, replyToID)
+}
+
+// CIStatus summarises the MR's head pipeline the way git_tools.GetCIStatus summarises GitHub
+// workflow runs. An MR without a pipeline has no status, so the CI filters leave it out.
+func (g *GitLab) CIStatus(owner, repo string, number int) (git_tools.CIStatusInfo, error) {
+	info := git_tools.CIStatusInfo{Statuses: []string{}}
+	mr, err := g.getMR(owner, repo, number)
+	if err != nil || mr.HeadPipeline == nil {
+		return info, err
+	}
+	pipeline := mr.HeadPipeline
+	mark := " "
+	switch pipeline.Status {
+	case "success":
+		mark, info.OverallStatus = "✅", "DONE"
+	case "failed":
+		mark, info.OverallStatus = "❌", "TODO"
+	case "canceled", "skipped":
+		// Like GitHub runs that neither passed nor failed
+		info.OverallStatus = "DONE"
+	default:
+		info.OverallStatus = "WAITING"
+	}
+	info.Statuses = append(info.Statuses, fmt.Sprintf("[%s] [%s] Pipeline #%d", mark, pipeline.Status, pipeline.ID))
+	return info, nil
+}
+
+// InteractionState finds when me and everyone else last commented on the MR. Approvals carry no
+// time, so only notes count.
+func (g *GitLab) InteractionState(owner, repo string, pr *github.PullRequest, me string) (git_tools.InteractionState, error) {
+	state := git_tools.InteractionState{}
+	if pr.UpdatedAt != nil {
+		state.LastCommitTime = *pr.UpdatedAt
+	}
+	comments, err := g.ListComments(owner, repo, pr.GetNumber())
+	if err != nil {
+		return state, err
+	}
+	for _, c := range comments {
+		if c.CreatedAt == nil {
+			continue
+		}
+		if c.GetUser().GetLogin() == me {
+			if c.CreatedAt.After(state.LastMeTime) {
+				state.LastMeTime = *c.CreatedAt
+			}
+		} else if c.CreatedAt.After(state.LastOthersTime) {
+			state.LastOthersTime = *c.CreatedAt
+		}
+	}
+	return state, nil
+}
+
+// PRState answers the smart filters' CI and activity lookups for GitLab merge requests and
+// leaves GitHub PRs to git_tools.
+type PRState struct{}
+
+func (PRState) CIStatus(pr *github.PullRequest) (git_tools.CIStatusInfo, bool) {
+	owner, repo := pr.Base.Repo.Owner.GetLogin(), pr.Base.Repo.GetName()
+	if !IsGitLab(owner, repo) {
+		return git_tools.CIStatusInfo{}, false
+	}
+	info, err := NewGitLab().CIStatus(owner, repo, pr.GetNumber())
+	if err != nil {
+		slog.Error("Error getting pipeline status", "project", owner+"/"+repo, "mr", pr.GetNumber(), "error", err)
+	}
+	return info, true
+}
+
+func (PRState) InteractionState(pr *github.PullRequest) (git_tools.InteractionState, bool) {
+	owner, repo := pr.Base.Repo.Owner.GetLogin(), pr.Base.Repo.GetName()
+	if !IsGitLab(owner, repo) {
+		return git_tools.InteractionState{}, false
+	}
+	state, err := NewGitLab().InteractionState(owner, repo, pr, git_tools.MyLogin(pr))
+	if err != nil {
+		slog.Error("Error getting merge request activity", "project", owner+"/"+repo, "mr", pr.GetNumber(), "error", err)
+	}
+	return state, true
+}
+
+// toUser reports the configured GitLab account under the GitHub username, so the filters that
+// look for the current user (FilterMyPRs, FilterMyReviewRequested, ...) work on MRs too.
+func toUser(u *glUser) *github.User {
+	if u == nil {
+		return nil
+	}
+	login := u.Username
+	if config.C.GitlabUsername != "" && login == config.C.GitlabUsername && config.C.GithubUsername != "" {
+		login = config.C.GithubUsername
+	}
+	user := &github.User{Login: github.String(login)}
+	if u.Name != "" {
+		user.Name = github.String(u.Name)
+	}
+	return user
+}
+
+// toPullRequest fills in the fields the workflows, filters and PR view read.
+func (mr *glMergeRequest) toPullRequest(owner, repo string) *github.PullRequest {
+	state := "open"
+	if mr.State != "opened" {
+		state = "closed"
+	}
+	created, updated := mr.CreatedAt, mr.UpdatedAt
+	fullName := owner + "/" + repo
+	pr := &github.PullRequest{
+		ID:        github.Int64(mr.ID),
+		Number:    github.Int(mr.IID),
+		Title:     github.String(mr.Title),
+		Body:      github.String(mr.Description),
+		HTMLURL:   github.String(mr.WebURL),
+		State:     github.String(state),
+		Draft:     github.Bool(mr.Draft || mr.WorkInProgress),
+		CreatedAt: &created,
+		UpdatedAt: &updated,
+		MergedAt:  mr.MergedAt,
+		User:      toUser(mr.Author),
+		Base: &github.PullRequestBranch{
+			Ref:   github.String(mr.TargetBranch),
+			Label: github.String(owner + ":" + mr.TargetBranch),
+			Repo: &github.Repository{
+				Name:     github.String(repo),
+				FullName: github.String(fullName),
+				Owner:    &github.User{Login: github.String(owner)},
+			},
+		},
+		Head: &github.PullRequestBranch{
+			Ref:   github.String(mr.SourceBranch),
+			Label: github.String(owner + ":" + mr.SourceBranch),
+			SHA:   github.String(mr.SHA),
+			Repo: &github.Repository{
+				Name:  github.String(repo),
+				Owner: &github.User{Login: github.String(owner)},
+			},
+		},
+	}
+	if mr.MergeCommitSHA != "" {
+		pr.MergeCommitSHA = github.String(mr.MergeCommitSHA)
+	}
+	for _, l := range mr.Labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+	}
+	for i := range mr.Assignees {
+		pr.Assignees = append(pr.Assignees, toUser(&mr.Assignees[i]))
+	}
+	for i := range mr.Reviewers {
+		pr.RequestedReviewers = append(pr.RequestedReviewers, toUser(&mr.Reviewers[i]))
+	}
+	if mr.Milestone != nil {
+		pr.Milestone = &github.Milestone{Title: github.String(mr.Milestone.Title)}
+	}
+	return pr
+}
@@ -0,0 +1,277 @@
+package forge
+
+import (
+	"crs/config"
+	"crs/git_tools"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+const testMR = `{"id":501,"iid":12,"title":"Add widgets","description":"Body","state":"opened","draft":false,
+  "web_url":"https://gitlab.example.com/acme/tools/api/-/merge_requests/12",
+  "source_branch":"widgets","target_branch":"main","sha":"head1",
+  "created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-02T00:00:00Z",
+  "author":{"username":"alice","name":"Alice"},
+  "reviewers":[{"username":"me-on-gitlab"}],"labels":["bug"],
+  "diff_refs":{"base_sha":"base1","head_sha":"head1","start_sha":"start1"}}`
+
+const testMRDiffs = `[{"old_path":"main.go","new_path":"main.go",
+  "diff":"@@ -1,3 +1,3 @@\n package main\n-var a = 1\n+var a = 2\n func main() {}\n"}]`
+
+const testDiscussions = `[
+  {"id":"d1","notes":[
+    {"id":1,"body":"Why 2?","author":{"username":"bob"},"created_at":"2024-01-03T00:00:00Z",
+     "position":{"position_type":"text","new_path":"main.go","old_path":"main.go","new_line":2,"head_sha":"head1"}},
+    {"id":2,"body":"Because","author":{"username":"alice"},"created_at":"2024-01-04T00:00:00Z"}]},
+  {"id":"d2","notes":[{"id":3,"body":"assigned to @bob","system":true,"author":{"username":"alice"},"created_at":"2024-01-03T00:00:00Z"}]},
+  {"id":"d3","notes":[{"id":4,"body":"Looks good overall","author":{"username":"carol"},"created_at":"2024-01-05T00:00:00Z"}]}]`
+
+func testGitLab(t *testing.T, handler http.HandlerFunc) *GitLab {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &GitLab{BaseURL: server.URL, Token: "token", HTTP: server.Client()}
+}
+
+func TestGitLabListPRs(t *testing.T) {
+	config.C.GithubUsername, config.C.GitlabUsername = "me", "me-on-gitlab"
+	t.Cleanup(func() { config.C.GithubUsername, config.C.GitlabUsername = "", "" })
+
+	pages := 0
+	g := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			t.Errorf("missing token header")
+		}
+		if r.URL.EscapedPath() != "/api/v4/projects/acme%2Ftools%2Fapi/merge_requests" || r.URL.Query().Get("state") != "opened" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		pages++
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+			w.Write([]byte("[" + testMR + "]"))
+			return
+		}
+		w.Write([]byte("[]"))
+	})
+
+	prs, err := g.ListPRs("acme/tools", "api", "open")
+	if err != nil {
+		t.Fatalf("ListPRs() error = %v", err)
+	}
+	if pages != 2 || len(prs) != 1 {
+		t.Fatalf("got %d PRs over %d pages, want 1 over 2", len(prs), pages)
+	}
+	pr := prs[0]
+	if pr.GetNumber() != 12 || pr.GetState() != "open" || pr.GetUser().GetLogin() != "alice" || pr.Head.GetSHA() != "head1" {
+		t.Errorf("MR fields not converted: %+v", pr)
+	}
+	if pr.Base.Repo.GetFullName() != "acme/tools/api" || pr.Base.GetRef() != "main" {
+		t.Errorf("base = %+v, want acme/tools/api main", pr.Base)
+	}
+	// The configured GitLab account shows up as the GitHub username for the "my" filters
+	if len(pr.RequestedReviewers) != 1 || pr.RequestedReviewers[0].GetLogin() != "me" {
+		t.Errorf("RequestedReviewers = %v, want me", pr.RequestedReviewers)
+	}
+}
+
+func TestGitLabListComments(t *testing.T) {
+	g := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/discussions"):
+			w.Write([]byte(testDiscussions))
+		case strings.HasSuffix(r.URL.Path, "/diffs"):
+			w.Write([]byte(testMRDiffs))
+		default:
+			w.Write([]byte(testMR))
+		}
+	})
+
+	comments, err := g.ListComments("acme", "api", 12)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	if len(comments) != 3 {
+		t.Fatalf("got %d comments, want 3 (system notes skipped)", len(comments))
+	}
+	root, reply, general := comments[0], comments[1], comments[2]
+	if root.GetPath() != "main.go" || root.GetLine() != 2 || root.GetSide() != "RIGHT" || root.GetPosition() != 3 {
+		t.Errorf("root comment anchor = %s:%d %s position %d, want main.go:2 RIGHT position 3", root.GetPath(), root.GetLine(), root.GetSide(), root.GetPosition())
+	}
+	if reply.GetInReplyTo() != 1 {
+		t.Errorf("reply InReplyTo = %d, want 1", reply.GetInReplyTo())
+	}
+	if general.Path != nil || general.InReplyTo != nil {
+		t.Errorf("general note should be a conversation comment: %+v", general)
+	}
+}
+
+func TestGitLabSubmitReview(t *testing.T) {
+	var calls []string
+	var drafts []map[string]any
+	g := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v4/projects/acme/api/merge_requests/12")
+		if r.Method == http.MethodGet {
+			if path == "/diffs" {
+				w.Write([]byte(testMRDiffs))
+			} else {
+				w.Write([]byte(testMR))
+			}
+			return
+		}
+		calls = append(calls, r.Method+" "+path)
+		if path == "/draft_notes" {
+			var note map[string]any
+			json.NewDecoder(r.Body).Decode(&note)
+			drafts = append(drafts, note)
+			fmt.Fprintf(w, `{"id":%d}`, 100+len(drafts))
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	position := 2 // "-var a = 1" in the diff
+	review := &github.PullRequestReviewRequest{
+		Event: github.String("APPROVE"),
+		Body:  github.String("Nice"),
+		Comments: []*github.DraftReviewComment{
+			{Path: github.String("main.go"), Body: github.String("new line"), Line: github.Int(2), Side: github.String("RIGHT")},
+			{Path: github.String("main.go"), Body: github.String("old line"), Position: &position},
+			{Path: github.String("main.go"), Body: github.String("context line"), Line: github.Int(3), Side: github.String("RIGHT")},
+		},
+	}
+	if err := g.SubmitReview("acme", "api", 12, review); err != nil {
+		t.Fatalf("SubmitReview() error = %v", err)
+	}
+
+	// The drafts are published in one call, so a failure can't leave some of them published
+	want := []string{"POST /draft_notes", "POST /draft_notes", "POST /draft_notes",
+		"POST /draft_notes/bulk_publish", "POST /notes", "POST /approve"}
+	if strings.Join(calls, " ") != strings.Join(want, " ") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	newLine := drafts[0]["position"].(map[string]any)
+	oldLine := drafts[1]["position"].(map[string]any)
+	if newLine["new_line"] != float64(2) || newLine["head_sha"] != "head1" || newLine["base_sha"] != "base1" {
+		t.Errorf("first draft position = %v, want new_line 2 on head1", newLine)
+	}
+	if oldLine["old_line"] != float64(2) || oldLine["new_line"] != nil {
+		t.Errorf("second draft position = %v, want old_line 2", oldLine)
+	}
+	context := drafts[2]["position"].(map[string]any)
+	if context["old_line"] != float64(3) || context["new_line"] != float64(3) {
+		t.Errorf("third draft position = %v, want old_line 3 and new_line 3", context)
+	}
+}
+
+func TestGitLabSubmitReview_DraftFails(t *testing.T) {
+	var calls []string
+	g := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v4/projects/acme/api/merge_requests/12")
+		if r.Method == http.MethodGet {
+			if path == "/diffs" {
+				w.Write([]byte(testMRDiffs))
+			} else {
+				w.Write([]byte(testMR))
+			}
+			return
+		}
+		calls = append(calls, r.Method+" "+path)
+		if path == "/draft_notes" {
+			if len(calls) > 1 {
+				http.Error(w, "invalid position", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"id":101}`))
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	review := &github.PullRequestReviewRequest{
+		Event: github.String("COMMENT"),
+		Comments: []*github.DraftReviewComment{
+			{Path: github.String("main.go"), Body: github.String("first"), Line: github.Int(2), Side: github.String("RIGHT")},
+			{Path: github.String("main.go"), Body: github.String("second"), Line: github.Int(1), Side: github.String("RIGHT")},
+		},
+	}
+	if err := g.SubmitReview("acme", "api", 12, review); err == nil {
+		t.Fatal("SubmitReview() error = nil, want the failed draft's error")
+	}
+	want := []string{"POST /draft_notes", "POST /draft_notes", "DELETE /draft_notes/101"}
+	if strings.Join(calls, " ") != strings.Join(want, " ") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestGitLabCIFilters(t *testing.T) {
+	pipelines := map[string]string{
+		"12": `,"head_pipeline":{"id":900,"status":"failed"}`,
+		"13": `,"head_pipeline":{"id":901,"status":"success"}`,
+		"14": ``,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		fmt.Fprintf(w, `{"iid":%s,"state":"opened","sha":"head%s"%s}`, iid, iid, pipelines[iid])
+	}))
+	defer server.Close()
+
+	saved := config.C
+	t.Cleanup(func() {
+		config.C = saved
+		git_tools.ForgetPRState("acme", "api", nil)
+	})
+	t.Setenv("CRS_GITLAB_TOKEN", "token")
+	config.C.Repos = []string{"gitlab:acme/api"}
+	config.C.GitlabURL = server.URL
+
+	var prs []*github.PullRequest
+	for iid := 12; iid <= 14; iid++ {
+		prs = append(prs, (&glMergeRequest{IID: iid, State: "opened", SourceBranch: "feature"}).toPullRequest("acme", "api"))
+	}
+
+	// Only the MR whose pipeline failed; one without a pipeline has no CI status to fail
+	failing := git_tools.MakeCIFailingFilter(PRState{})(prs)
+	if len(failing) != 1 || failing[0].GetNumber() != 12 {
+		t.Errorf("FilterCIFailing() = %v, want !12", failing)
+	}
+	passing := git_tools.MakeCIPassingFilter(PRState{})(prs)
+	if len(passing) != 1 || passing[0].GetNumber() != 13 {
+		t.Errorf("FilterCIPassing() = %v, want !13", passing)
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	tests := []struct {
+		entry       string
+		owner, repo string
+		gitlab      bool
+	}{
+		{"octo/widgets", "octo", "widgets", false},
+		{"ghe.acme.com/octo/widgets", "octo", "widgets", false},
+		{"gitlab:group/project", "group", "project", true},
+		{"gitlab:group/sub/project", "group/sub", "project", true},
+	}
+	for _, tt := range tests {
+		owner, repo, err := ParseRepo(tt.entry)
+		if err != nil || owner != tt.owner || repo != tt.repo || IsGitLabEntry(tt.entry) != tt.gitlab {
+			t.Errorf("ParseRepo(%q) = %q, %q, %v", tt.entry, owner, repo, err)
+		}
+	}
+	for _, entry := range []string{"widgets", "ghe.acme.com/a/b/c", "gitlab:project", "gitlab:group/"} {
+		if _, _, err := ParseRepo(entry); err == nil {
+			t.Errorf("ParseRepo(%q) error = nil, want an error", entry)
+		}
+	}
+
+	config.C.Repos = []string{"octo/widgets", "gitlab:group/sub/project"}
+	t.Cleanup(func() { config.C.Repos = nil })
+	if !IsGitLab("group/sub", "project") || IsGitLab("octo", "widgets") {
+		t.Errorf("IsGitLab did not follow the configured prefixes")
+	}
+}
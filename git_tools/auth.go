@@ -0,0 +1,276 @@
+package git_tools
+
+import (
+	"crs/config"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokenRefreshMargin renews tokens this long before they expire, so a sync cycle that
+	// started with a valid token doesn't run into its expiry.
+	tokenRefreshMargin = 5 * time.Minute
+	// commandTokenTTL is how long a token read from gh or the keyring is reused before the
+	// command runs again, picking up a `gh auth refresh` or a rotated keyring secret.
+	commandTokenTTL = 15 * time.Minute
+	// defaultKeyringService is the Secret Service "service" attribute tokens are stored under.
+	defaultKeyringService = "codereviewserver"
+	// appTokenTimeout bounds the request that mints an installation token, so a hung API
+	// doesn't stall every client waiting on the token.
+	appTokenTimeout = 10 * time.Second
+)
+
+var (
+	tokenSourcesMu sync.Mutex
+	// tokenSources holds one refreshing token source per host and auth settings, so GitHub App
+	// installation tokens are minted once an hour rather than for every client.
+	tokenSources = map[string]oauth2.TokenSource{}
+)
+
+// runCommand runs an auth helper command and returns its stdout. Tests replace it.
+var runCommand = func(name string, args ...string) ([]byte, error) {
+	out, err := exec.Command(name, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return out, err
+}
+
+// hostTokenSource returns the token source configured for host, where nil is github.com.
+// Errors only come back for the env source, whose token is needed at startup; the other
+// sources report failures from Token, so a sync cycle fails its requests instead of exiting.
+func hostTokenSource(host *config.GithubHost) (oauth2.TokenSource, error) {
+	source := config.TokenSource(host)
+	if source == "env" {
+		tokenEnv := config.TokenEnv(host)
+		token := os.Getenv(tokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("%s is not set", tokenEnv)
+		}
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}), nil
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%d|%d|%s|%s", host.Host, host.APIURL(), source, host.AppID, host.AppInstallationID, host.AppPrivateKeyPath, host.KeyringService)
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	if ts, ok := tokenSources[key]; ok {
+		return ts, nil
+	}
+
+	var src oauth2.TokenSource
+	switch source {
+	case "app":
+		app, err := newAppTokenSource(host)
+		if err != nil {
+			// Not cached, so a fixed key file is picked up by the next client
+			return errorTokenSource{err}, nil
+		}
+		src = app
+	case "gh":
+		src = commandTokenSource{name: "gh", args: []string{"auth", "token", "--hostname", host.WebHost()}}
+	case "keyring":
+		service := host.KeyringService
+		if service == "" {
+			service = defaultKeyringService
+		}
+		src = commandTokenSource{name: "secret-tool", args: []string{"lookup", "service", service, "account", host.Host}}
+	default:
+		return nil, fmt.Errorf("unknown TokenSource %q", source)
+	}
+	ts := oauth2.ReuseTokenSourceWithExpiry(nil, src, tokenRefreshMargin)
+	tokenSources[key] = ts
+	return ts, nil
+}
+
+// errorTokenSource fails every request, for auth settings that can't produce tokens.
+type errorTokenSource struct{ err error }
+
+func (s errorTokenSource) Token() (*oauth2.Token, error) {
+	return nil, s.err
+}
+
+// commandTokenSource reads the token from the output of a command, like `gh auth token` or
+// `secret-tool lookup`.
+type commandTokenSource struct {
+	name string
+	args []string
+}
+
+func (s commandTokenSource) Token() (*oauth2.Token, error) {
+	out, err := runCommand(s.name, s.args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", s.name, strings.Join(s.args, " "), err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return nil, fmt.Errorf("%s %s returned no token", s.name, strings.Join(s.args, " "))
+	}
+	return &oauth2.Token{AccessToken: token, Expiry: time.Now().Add(commandTokenTTL)}, nil
+}
+
+// appTokenSource mints installation tokens for a GitHub App. Each token lasts an hour; the
+// ReuseTokenSource around it asks for a new one before then.
+type appTokenSource struct {
+	apiURL         string
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	client         *http.Client
+	now            func() time.Time
+}
+
+func newAppTokenSource(host *config.GithubHost) (*appTokenSource, error) {
+	path := host.AppPrivateKeyPath
+	if strings.HasPrefix(path, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = strings.Replace(path, "~", home, 1)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the GitHub App private key: %w", err)
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host.AppPrivateKeyPath, err)
+	}
+	return &appTokenSource{
+		apiURL:         host.APIURL(),
+		appID:          host.AppID,
+		installationID: host.AppInstallationID,
+		key:            key,
+		client:         &http.Client{Timeout: appTokenTimeout},
+		now:            time.Now,
+	}, nil
+}
+
+// parsePrivateKey reads an RSA key in the PKCS#1 PEM GitHub hands out, or PKCS#8.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt signs the short-lived RS256 token that authenticates as the app itself. iat is set a
+// minute back to allow for clock drift, as GitHub recommends.
+func (s *appTokenSource) jwt() (string, error) {
+	now := s.now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// appRequest calls the API as the app and decodes the JSON response into out.
+func (s *appTokenSource) appRequest(method, path string, out any) error {
+	jwt, err := s.jwt()
+	if err != nil {
+		return fmt.Errorf("signing the GitHub App JWT: %w", err)
+	}
+	req, err := http.NewRequest(method, s.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, apiErr.Message)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// installation returns the configured installation, or the app's only one.
+func (s *appTokenSource) installation() (int64, error) {
+	if s.installationID != 0 {
+		return s.installationID, nil
+	}
+	var installations []struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	if err := s.appRequest(http.MethodGet, "app/installations", &installations); err != nil {
+		return 0, err
+	}
+	switch len(installations) {
+	case 0:
+		return 0, fmt.Errorf("GitHub App %d is not installed anywhere", s.appID)
+	case 1:
+		s.installationID = installations[0].ID
+		return s.installationID, nil
+	}
+	accounts := []string{}
+	for _, installation := range installations {
+		accounts = append(accounts, fmt.Sprintf("%s (%d)", installation.Account.Login, installation.ID))
+	}
+	return 0, fmt.Errorf("GitHub App %d has several installations, set AppInstallationID to one of %s", s.appID, strings.Join(accounts, ", "))
+}
+
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	id, err := s.installation()
+	if err != nil {
+		return nil, err
+	}
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := s.appRequest(http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", id), &token); err != nil {
+		return nil, fmt.Errorf("minting a GitHub App installation token: %w", err)
+	}
+	return &oauth2.Token{AccessToken: token.Token, Expiry: token.ExpiresAt}, nil
+}
@@ -0,0 +1,149 @@
+package git_tools
+
+import (
+	"context"
+	"crs/config"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppTokenSource(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	minted := 0
+	// The first token is about to expire, so the next request must mint a fresh one
+	lifetimes := []time.Duration{2 * time.Minute, time.Hour}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			http.Error(w, `{"message":"no JWT"}`, http.StatusUnauthorized)
+			return
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			http.Error(w, `{"message":"bad signature"}`, http.StatusUnauthorized)
+			return
+		}
+		claims := map[string]int64{}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		json.Unmarshal(payload, &claims)
+		if claims["iss"] != 42 {
+			http.Error(w, `{"message":"wrong app"}`, http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/app/installations":
+			fmt.Fprint(w, `[{"id":7,"account":{"login":"acme"}}]`)
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/7/access_tokens":
+			expires := time.Now().Add(lifetimes[min(minted, len(lifetimes)-1)])
+			minted++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, minted, expires.Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	host := &config.GithubHost{Host: "apps.test", BaseURL: server.URL, TokenSource: "app", AppID: 42, AppPrivateKeyPath: keyPath}
+	ts, err := hostTokenSource(host)
+	if err != nil {
+		t.Fatalf("hostTokenSource() error = %v", err)
+	}
+	for i, want := range []string{"ghs_1", "ghs_2", "ghs_2"} {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Token() #%d error = %v", i, err)
+		}
+		if token.AccessToken != want {
+			t.Errorf("Token() #%d = %q, want %q", i, token.AccessToken, want)
+		}
+	}
+	if again, _ := hostTokenSource(host); again != ts {
+		t.Errorf("hostTokenSource() did not reuse the cached source for the same host")
+	}
+
+	broken := &config.GithubHost{Host: "broken.test", BaseURL: server.URL, TokenSource: "app", AppID: 42, AppPrivateKeyPath: filepath.Join(t.TempDir(), "missing.pem")}
+	ts, err = hostTokenSource(broken)
+	if err != nil {
+		t.Fatalf("hostTokenSource() error = %v, want the failure deferred to Token()", err)
+	}
+	if _, err := ts.Token(); err == nil || !strings.Contains(err.Error(), "reading the GitHub App private key") {
+		t.Errorf("Token() error = %v, want a missing key error", err)
+	}
+}
+
+func TestCommandTokenSources(t *testing.T) {
+	var ran []string
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		ran = append(ran, name+" "+strings.Join(args, " "))
+		if name == "secret-tool" {
+			return nil, fmt.Errorf("exit status 1")
+		}
+		return []byte("gho_fromgh\n"), nil
+	}
+
+	ts, err := hostTokenSource(&config.GithubHost{Host: "work", BaseURL: "https://api.github.com/", TokenSource: "gh"})
+	if err != nil {
+		t.Fatalf("hostTokenSource() error = %v", err)
+	}
+	if token, err := ts.Token(); err != nil || token.AccessToken != "gho_fromgh" {
+		t.Errorf("Token() = %v, %v, want gho_fromgh", token, err)
+	}
+
+	ts, _ = hostTokenSource(&config.GithubHost{Host: "ghe.acme.com", TokenSource: "keyring"})
+	if _, err := ts.Token(); err == nil {
+		t.Errorf("Token() error = nil, want the keyring lookup failure")
+	}
+
+	want := []string{"gh auth token --hostname github.com", "secret-tool lookup service codereviewserver account ghe.acme.com"}
+	if strings.Join(ran, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+
+	t.Setenv("CRS_GITHUB_TOKEN", "")
+	if _, err := hostTokenSource(nil); err == nil || err.Error() != "CRS_GITHUB_TOKEN is not set" {
+		t.Errorf("hostTokenSource(nil) error = %v, want CRS_GITHUB_TOKEN is not set", err)
+	}
+}
+
+func TestGetHostClient_MissingToken(t *testing.T) {
+	saved := config.C
+	t.Cleanup(func() { config.C = saved })
+	config.C = config.Config{}
+	t.Setenv("CRS_GITHUB_TOKEN", "")
+
+	if _, err := GetHostClient(nil); err == nil || !strings.Contains(err.Error(), "CRS_GITHUB_TOKEN is not set") {
+		t.Errorf("GetHostClient(nil) error = %v, want CRS_GITHUB_TOKEN is not set", err)
+	}
+	// The convenience clients fail their requests with the same reason rather than exiting
+	if _, _, err := GetGithubClientFor("owner", "repo").PullRequests.Get(context.Background(), "owner", "repo", 1); err == nil || !strings.Contains(err.Error(), "CRS_GITHUB_TOKEN is not set") {
+		t.Errorf("request error = %v, want CRS_GITHUB_TOKEN is not set", err)
+	}
+}
@@ -0,0 +1,411 @@
+package git_tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// The REST API needs a handful of calls per PR to learn who reviewed it, what CI says and who
+// commented last. The bulk fetcher gets all of that for a page of PRs in one GraphQL query and
+// primes GlobalCache with it, so GetCIStatus and GetInteractionState (and the smart filters built
+// on them) are answered without further calls.
+
+const bulkPRsPageSize = 25
+
+// Same ceiling as GetPRs: 500 PRs per repo.
+const bulkPRsMaxPages = 20
+
+// bulkPRFields are the PullRequest fields toPullRequest, ciStatus and interactionState read.
+const bulkPRFields = `fragment BulkPRFields on PullRequest {
+  databaseId
+  number
+  title
+  body
+  url
+  state
+  isDraft
+  createdAt
+  updatedAt
+  mergedAt
+  mergeCommit { oid }
+  author { login ... on User { name } }
+  baseRefName
+  headRefName
+  headRefOid
+  headRepository { name owner { login } }
+  labels(first: 20) { nodes { name } }
+  reviewRequests(first: 20) {
+    nodes { requestedReviewer { __typename ... on User { login } ... on Team { slug name } } }
+  }
+  reviews(last: 50) { nodes { author { login } state submittedAt } }
+  comments(last: 50) { nodes { author { login } createdAt } }
+  reviewThreads(last: 50) {
+    nodes { comments(last: 20) { nodes { author { login } createdAt } } }
+  }
+  commits(last: 1) {
+    nodes {
+      commit {
+        statusCheckRollup {
+          state
+          contexts(first: 50) {
+            nodes {
+              __typename
+              ... on CheckRun { name status conclusion }
+              ... on StatusContext { context state }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const bulkPRsQuery = `query($owner: String!, $repo: String!, $states: [PullRequestState!], $first: Int!, $after: String) {
+  repository(owner: $owner, name: $repo) {
+    name
+    nameWithOwner
+    owner { login }
+    pullRequests(states: $states, first: $first, after: $after, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...BulkPRFields }
+    }
+  }
+}
+` + bulkPRFields
+
+type gqlActor struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type gqlCIContext struct {
+	Typename   string `json:"__typename"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	Context    string `json:"context"`
+	State      string `json:"state"`
+}
+
+type gqlPullRequest struct {
+	DatabaseID  int64      `json:"databaseId"`
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	URL         string     `json:"url"`
+	State       string     `json:"state"`
+	IsDraft     bool       `json:"isDraft"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	MergedAt    *time.Time `json:"mergedAt"`
+	MergeCommit *struct {
+		OID string `json:"oid"`
+	} `json:"mergeCommit"`
+	Author         *gqlActor `json:"author"`
+	BaseRefName    string    `json:"baseRefName"`
+	HeadRefName    string    `json:"headRefName"`
+	HeadRefOID     string    `json:"headRefOid"`
+	HeadRepository *struct {
+		Name  string   `json:"name"`
+		Owner gqlActor `json:"owner"`
+	} `json:"headRepository"`
+	Labels struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	ReviewRequests struct {
+		Nodes []struct {
+			RequestedReviewer *struct {
+				Typename string `json:"__typename"`
+				Login    string `json:"login"`
+				Slug     string `json:"slug"`
+				Name     string `json:"name"`
+			} `json:"requestedReviewer"`
+		} `json:"nodes"`
+	} `json:"reviewRequests"`
+	Reviews struct {
+		Nodes []struct {
+			Author      *gqlActor  `json:"author"`
+			State       string     `json:"state"`
+			SubmittedAt *time.Time `json:"submittedAt"`
+		} `json:"nodes"`
+	} `json:"reviews"`
+	Comments struct {
+		Nodes []struct {
+			Author    *gqlActor `json:"author"`
+			CreatedAt time.Time `json:"createdAt"`
+		} `json:"nodes"`
+	} `json:"comments"`
+	ReviewThreads struct {
+		Nodes []struct {
+			Comments struct {
+				Nodes []struct {
+					Author    *gqlActor `json:"author"`
+					CreatedAt time.Time `json:"createdAt"`
+				} `json:"nodes"`
+			} `json:"comments"`
+		} `json:"nodes"`
+	} `json:"reviewThreads"`
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					State    string `json:"state"`
+					Contexts struct {
+						Nodes []gqlCIContext `json:"nodes"`
+					} `json:"contexts"`
+				} `json:"statusCheckRollup"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"commits"`
+}
+
+type bulkPRsData struct {
+	Repository *struct {
+		Name          string   `json:"name"`
+		NameWithOwner string   `json:"nameWithOwner"`
+		Owner         gqlActor `json:"owner"`
+		PullRequests  struct {
+			PageInfo struct {
+				HasNextPage bool   `json:"hasNextPage"`
+				EndCursor   string `json:"endCursor"`
+			} `json:"pageInfo"`
+			Nodes []gqlPullRequest `json:"nodes"`
+		} `json:"pullRequests"`
+	} `json:"repository"`
+}
+
+// graphQLPRStates maps a REST state filter onto GraphQL's, where merged PRs are their own state.
+func graphQLPRStates(state string) []string {
+	switch state {
+	case "open":
+		return []string{"OPEN"}
+	case "closed":
+		return []string{"CLOSED", "MERGED"}
+	default:
+		return nil
+	}
+}
+
+func ciStatusCacheKey(owner, repo, branch string) string {
+	return fmt.Sprintf("ci_status:%s/%s:%s", owner, repo, branch)
+}
+
+func interactionStateCacheKey(owner, repo string, number int) string {
+	return fmt.Sprintf("interaction_state:%s/%s:%d", owner, repo, number)
+}
+
+// ForgetPRState drops the cached CI and interaction state of a repo's PRs, so the smart filters
+// look them up again. CI status is keyed by the head branch, which not every webhook carries, so
+// the CI status of all of owner's PRs goes.
+func ForgetPRState(owner, repo string, numbers []int) {
+	GlobalCache.DeletePrefix(fmt.Sprintf("ci_status:%s/", owner))
+	for _, number := range numbers {
+		GlobalCache.Delete(interactionStateCacheKey(owner, repo, number))
+	}
+}
+
+// GetManyRepoPRsGraphQL is GetManyRepoPRs backed by one GraphQL query per page of PRs. Besides
+// returning the PRs it caches their CI and interaction state for the smart filters.
+func GetManyRepoPRsGraphQL(client *github.Client, state string, repos []string) ([]*github.PullRequest, error) {
+	var prs []*github.PullRequest
+	for _, repoEntry := range repos {
+		owner, repo, err := ParseRepoName(repoEntry)
+		if err != nil {
+			return nil, err
+		}
+		repoPRs, err := getRepoPRsGraphQL(client, state, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		prs = append(prs, repoPRs...)
+	}
+	return prs, nil
+}
+
+func getRepoPRsGraphQL(client *github.Client, state string, owner string, repo string) ([]*github.PullRequest, error) {
+	variables := map[string]interface{}{
+		"owner": owner,
+		"repo":  repo,
+		"first": bulkPRsPageSize,
+	}
+	if states := graphQLPRStates(state); states != nil {
+		variables["states"] = states
+	}
+
+	var prs []*github.PullRequest
+	for page := 0; page < bulkPRsMaxPages; page++ {
+		var data bulkPRsData
+		if err := GraphQL(client, bulkPRsQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		if data.Repository == nil {
+			return nil, fmt.Errorf("repository %s/%s not found", owner, repo)
+		}
+		r := data.Repository
+		for i := range r.PullRequests.Nodes {
+			node := &r.PullRequests.Nodes[i]
+			pr := node.toPullRequest(r.Owner.Login, r.Name, r.NameWithOwner)
+			prs = append(prs, pr)
+			node.cacheState(pr, MyLogin(pr))
+		}
+		if !r.PullRequests.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = r.PullRequests.PageInfo.EndCursor
+	}
+	return prs, nil
+}
+
+// cacheState primes GlobalCache with the PR's CI and interaction state for the smart filters.
+func (n *gqlPullRequest) cacheState(pr *github.PullRequest, myLogin string) {
+	GlobalCache.Set(ciStatusCacheKey(pr.Base.Repo.Owner.GetLogin(), pr.Head.Repo.GetName(), pr.Head.GetLabel()), n.ciStatus(), 5*time.Minute)
+	GlobalCache.Set(interactionStateCacheKey(pr.Base.Repo.Owner.GetLogin(), pr.Base.Repo.GetName(), pr.GetNumber()), n.interactionState(myLogin), 10*time.Minute)
+}
+
+// toPullRequest fills in the REST fields the workflows and filters read.
+func (n *gqlPullRequest) toPullRequest(owner, repo, fullName string) *github.PullRequest {
+	state := "open"
+	if n.State != "OPEN" {
+		state = "closed"
+	}
+
+	baseRepo := &github.Repository{
+		Name:     github.String(repo),
+		FullName: github.String(fullName),
+		Owner:    &github.User{Login: github.String(owner)},
+	}
+	// A deleted fork has no head repository; treat the branch as living in the base repo.
+	headOwner, headRepo := owner, repo
+	if n.HeadRepository != nil {
+		headOwner, headRepo = n.HeadRepository.Owner.Login, n.HeadRepository.Name
+	}
+
+	pr := &github.PullRequest{
+		ID:        github.Int64(n.DatabaseID),
+		Number:    github.Int(n.Number),
+		Title:     github.String(n.Title),
+		Body:      github.String(n.Body),
+		HTMLURL:   github.String(n.URL),
+		State:     github.String(state),
+		Draft:     github.Bool(n.IsDraft),
+		CreatedAt: &n.CreatedAt,
+		UpdatedAt: &n.UpdatedAt,
+		MergedAt:  n.MergedAt,
+		Base: &github.PullRequestBranch{
+			Ref:   github.String(n.BaseRefName),
+			Label: github.String(owner + ":" + n.BaseRefName),
+			Repo:  baseRepo,
+		},
+		Head: &github.PullRequestBranch{
+			Ref:   github.String(n.HeadRefName),
+			Label: github.String(headOwner + ":" + n.HeadRefName),
+			SHA:   github.String(n.HeadRefOID),
+			Repo: &github.Repository{
+				Name:  github.String(headRepo),
+				Owner: &github.User{Login: github.String(headOwner)},
+			},
+		},
+	}
+	if n.Author != nil {
+		pr.User = &github.User{Login: github.String(n.Author.Login)}
+		if n.Author.Name != "" {
+			pr.User.Name = github.String(n.Author.Name)
+		}
+	}
+	if n.MergeCommit != nil {
+		pr.MergeCommitSHA = github.String(n.MergeCommit.OID)
+	}
+	for _, l := range n.Labels.Nodes {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l.Name)})
+	}
+	for _, req := range n.ReviewRequests.Nodes {
+		reviewer := req.RequestedReviewer
+		if reviewer == nil {
+			continue
+		}
+		switch reviewer.Typename {
+		case "User":
+			pr.RequestedReviewers = append(pr.RequestedReviewers, &github.User{Login: github.String(reviewer.Login)})
+		case "Team":
+			pr.RequestedTeams = append(pr.RequestedTeams, &github.Team{Slug: github.String(reviewer.Slug), Name: github.String(reviewer.Name)})
+		}
+	}
+	return pr
+}
+
+// ciStatus summarises the head commit's status rollup the way GetCIStatus summarises workflow runs.
+func (n *gqlPullRequest) ciStatus() CIStatusInfo {
+	var contexts []gqlCIContext
+	if len(n.Commits.Nodes) > 0 && n.Commits.Nodes[0].Commit.StatusCheckRollup != nil {
+		contexts = n.Commits.Nodes[0].Commit.StatusCheckRollup.Contexts.Nodes
+	}
+
+	statuses := []string{}
+	hasFailure := false
+	hasInProgress := false
+	for _, c := range contexts {
+		name, status, conclusion := c.Name, c.Status, c.Conclusion
+		if c.Typename == "StatusContext" {
+			// Commit statuses have a single state; PENDING and EXPECTED are still running.
+			name, status, conclusion = c.Context, "COMPLETED", c.State
+			if c.State == "PENDING" || c.State == "EXPECTED" {
+				status, conclusion = "IN_PROGRESS", ""
+			}
+		}
+
+		mark := " "
+		label := "[" + strings.ToLower(status) + "]"
+		switch conclusion {
+		case "SUCCESS":
+			mark, label = "✅", ""
+		case "FAILURE", "ERROR", "TIMED_OUT", "STARTUP_FAILURE":
+			mark = "❌"
+			hasFailure = true
+		case "":
+			hasInProgress = true
+		}
+		statuses = append(statuses, fmt.Sprintf("[%s] %s %s", mark, label, name))
+	}
+
+	overallStatus := "DONE"
+	if hasFailure {
+		overallStatus = "TODO"
+	} else if hasInProgress {
+		overallStatus = "WAITING"
+	}
+	return CIStatusInfo{Statuses: statuses, OverallStatus: overallStatus}
+}
+
+// interactionState works out when myLogin and everyone else last reviewed or commented.
+func (n *gqlPullRequest) interactionState(myLogin string) InteractionState {
+	state := InteractionState{LastCommitTime: n.UpdatedAt}
+	record := func(author *gqlActor, at time.Time) {
+		if author != nil && author.Login == myLogin {
+			if at.After(state.LastMeTime) {
+				state.LastMeTime = at
+			}
+		} else if at.After(state.LastOthersTime) {
+			state.LastOthersTime = at
+		}
+	}
+
+	for _, r := range n.Reviews.Nodes {
+		if r.SubmittedAt != nil {
+			record(r.Author, *r.SubmittedAt)
+		}
+	}
+	for _, c := range n.Comments.Nodes {
+		record(c.Author, c.CreatedAt)
+	}
+	for _, t := range n.ReviewThreads.Nodes {
+		for _, c := range t.Comments.Nodes {
+			record(c.Author, c.CreatedAt)
+		}
+	}
+	return state
+}
@@ -0,0 +1,93 @@
+package git_tools
+
+import (
+	"crs/config"
+	"crs/testutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const bulkPRsResponse = `{"data":{"repository":{
+  "name":"repo","nameWithOwner":"owner/repo","owner":{"login":"owner"},
+  "pullRequests":{"pageInfo":{"hasNextPage":false,"endCursor":""},"nodes":[{
+    "databaseId":1001,"number":7,"title":"Add widgets","body":"Body","url":"https://github.com/owner/repo/pull/7",
+    "state":"OPEN","isDraft":false,
+    "createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-03T00:00:00Z","mergedAt":null,"mergeCommit":null,
+    "author":{"login":"alice","name":"Alice"},
+    "baseRefName":"main","headRefName":"widgets","headRefOid":"abc123",
+    "headRepository":{"name":"repo","owner":{"login":"alice"}},
+    "labels":{"nodes":[{"name":"bug"}]},
+    "reviewRequests":{"nodes":[
+      {"requestedReviewer":{"__typename":"User","login":"me"}},
+      {"requestedReviewer":{"__typename":"Team","slug":"core","name":"Core"}}]},
+    "reviews":{"nodes":[{"author":{"login":"me"},"state":"COMMENTED","submittedAt":"2024-01-02T00:00:00Z"}]},
+    "comments":{"nodes":[{"author":{"login":"bob"},"createdAt":"2024-01-04T00:00:00Z"}]},
+    "reviewThreads":{"nodes":[{"comments":{"nodes":[{"author":{"login":"me"},"createdAt":"2024-01-05T00:00:00Z"}]}}]},
+    "commits":{"nodes":[{"commit":{"statusCheckRollup":{"state":"FAILURE","contexts":{"nodes":[
+      {"__typename":"CheckRun","name":"build","status":"COMPLETED","conclusion":"SUCCESS"},
+      {"__typename":"CheckRun","name":"test","status":"COMPLETED","conclusion":"FAILURE"},
+      {"__typename":"StatusContext","context":"ci/lint","state":"PENDING"}]}}}}]}
+  }]}}}}`
+
+func TestGetManyRepoPRsGraphQL(t *testing.T) {
+	config.C.GithubUsername = "me"
+	t.Cleanup(func() { config.C.GithubUsername = "" })
+
+	calls := 0
+	client := testutil.NewGithubClient(t, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(bulkPRsResponse))
+	}))
+
+	prs, err := GetManyRepoPRsGraphQL(client, "open", []string{"owner/repo"})
+	if err != nil {
+		t.Fatalf("GetManyRepoPRsGraphQL() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("made %d requests, want 1", calls)
+	}
+	if len(prs) != 1 {
+		t.Fatalf("got %d PRs, want 1", len(prs))
+	}
+
+	pr := prs[0]
+	if pr.GetNumber() != 7 || pr.GetState() != "open" || pr.GetDraft() || pr.GetUser().GetLogin() != "alice" {
+		t.Errorf("PR fields not converted: %+v", pr)
+	}
+	if pr.Base.Repo.GetFullName() != "owner/repo" || pr.Head.GetLabel() != "alice:widgets" || pr.Head.GetSHA() != "abc123" {
+		t.Errorf("branch fields not converted: base %+v head %+v", pr.Base, pr.Head)
+	}
+	if len(pr.RequestedReviewers) != 1 || len(pr.RequestedTeams) != 1 || pr.RequestedTeams[0].GetSlug() != "core" {
+		t.Errorf("review requests = %v / %v", pr.RequestedReviewers, pr.RequestedTeams)
+	}
+
+	// The smart filters read these from the cache instead of calling the REST API.
+	ci := GetCIStatus("owner", "repo", "alice:widgets")
+	if ci.OverallStatus != "TODO" || len(ci.Statuses) != 3 {
+		t.Errorf("cached CI status = %+v, want TODO with 3 statuses", ci)
+	}
+	state := GetInteractionState("owner", "repo", pr, nil)
+	if want := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC); !state.LastMeTime.Equal(want) {
+		t.Errorf("LastMeTime = %v, want %v", state.LastMeTime, want)
+	}
+	if want := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC); !state.LastOthersTime.Equal(want) {
+		t.Errorf("LastOthersTime = %v, want %v", state.LastOthersTime, want)
+	}
+}
+
+func TestGraphQLPRStates(t *testing.T) {
+	tests := []struct {
+		state string
+		want  int
+	}{
+		{"open", 1},
+		{"closed", 2},
+		{"all", 0},
+	}
+	for _, tt := range tests {
+		if got := graphQLPRStates(tt.state); len(got) != tt.want {
+			t.Errorf("graphQLPRStates(%q) = %v", tt.state, got)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crs/config"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"slices"
@@ -103,6 +104,12 @@ func GetPRDiff(client *github.Client, owner string, repo string, pr_number int)
 
 }
 
+// GetCompareDiff returns the diff between two commits, e.g. two heads of the same PR.
+func GetCompareDiff(client *github.Client, owner string, repo string, base string, head string) (string, error) {
+	diff, _, err := client.Repositories.CompareCommitsRaw(context.Background(), owner, repo, base, head, github.RawOptions{Type: github.Diff})
+	return diff, err
+}
+
 func GetPRComments(client *github.Client, owner string, repo string, number int) ([]*github.PullRequestComment, error) {
 	opts := github.PullRequestListCommentsOptions{}
 	comments, _, err := client.PullRequests.ListComments(context.Background(), owner, repo, number, &opts)
@@ -165,11 +172,23 @@ func FilterPRsByLabel(prs []*github.PullRequest, label string) []*github.PullReq
 }
 
 func FilterMyPRs(prs []*github.PullRequest) []*github.PullRequest {
-	return FilterPRsByAuthor(prs, config.C.GithubUsername)
+	filtered := []*github.PullRequest{}
+	for _, pr := range prs {
+		if pr.GetUser().GetLogin() == MyLogin(pr) {
+			filtered = append(filtered, pr)
+		}
+	}
+	return filtered
 }
 
 func FilterNotMyPRs(prs []*github.PullRequest) []*github.PullRequest {
-	return FilterPRsExcludeAuthor(prs, config.C.GithubUsername)
+	filtered := []*github.PullRequest{}
+	for _, pr := range prs {
+		if pr.GetUser().GetLogin() != MyLogin(pr) {
+			filtered = append(filtered, pr)
+		}
+	}
+	return filtered
 }
 
 func FilterIsDraft(prs []*github.PullRequest) []*github.PullRequest {
@@ -210,8 +229,9 @@ func MakeTeamFilters(teams []string) func([]*github.PullRequest) []*github.PullR
 func FilterMyReviewRequested(prs []*github.PullRequest) []*github.PullRequest {
 	filtered := []*github.PullRequest{}
 	for _, pr := range prs {
+		myLogin := MyLogin(pr)
 		for _, reviewer := range pr.RequestedReviewers {
-			if *reviewer.Login == config.C.GithubUsername {
+			if *reviewer.Login == myLogin {
 				filtered = append(filtered, pr)
 				break
 			}
@@ -220,19 +240,62 @@ func FilterMyReviewRequested(prs []*github.PullRequest) []*github.PullRequest {
 	return filtered
 }
 
+// GetGithubClient returns the github.com client, authenticated as its TokenSource says.
 func GetGithubClient() *github.Client {
+	return clientOrFailing(GetHostClient(nil))
+}
+
+// GetGithubClientFor returns the client for the host serving owner/repo.
+func GetGithubClientFor(owner, repo string) *github.Client {
+	return clientOrFailing(GetHostClient(config.C.HostFor(owner, repo)))
+}
+
+// clientOrFailing stands in a client whose requests all fail with err when a host's client
+// can't be built, so callers that only handle request errors report it instead of crashing.
+func clientOrFailing(client *github.Client, err error) *github.Client {
+	if err == nil {
+		return client
+	}
+	slog.Error("Error creating GitHub client", "error", err)
+	return github.NewClient(oauth2.NewClient(context.Background(), errorTokenSource{err}))
+}
+
+// GetHostClient returns a client for one of the configured GithubHosts; nil is github.com,
+// using the GithubHosts entry named github.com for its auth settings if there is one.
+func GetHostClient(host *config.GithubHost) (*github.Client, error) {
 	ctx := context.Background()
-	token := os.Getenv("CRS_GITHUB_TOKEN")
-	if token == "" {
-		slog.Error("Error! No Github Token!")
-		os.Exit(1)
+	if host == nil {
+		host = config.C.Host(config.DefaultGithubHost)
+	}
+	ts, err := hostTokenSource(host)
+	if err != nil {
+		return nil, fmt.Errorf("no GitHub token: %w", err)
 	}
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	return github.NewClient(tc)
+	// The cache sits under oauth2 so it sees which token a request carries.
+	tc := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: newConditionalTransport()}), ts)
+	if host == nil || (host.Host == config.DefaultGithubHost && host.BaseURL == "") {
+		return github.NewClient(tc), nil
+	}
+	client, err := github.NewEnterpriseClient(host.APIURL(), host.APIURL(), tc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BaseURL for GitHub host %s: %w", host.Host, err)
+	}
+	return client, nil
+}
+
+// hostOf returns the configured host serving pr, or nil for github.com.
+func hostOf(pr *github.PullRequest) *config.GithubHost {
+	if host := config.C.HostForURL(pr.GetHTMLURL()); host != nil {
+		return host
+	}
+	repo := pr.GetBase().GetRepo()
+	return config.C.HostFor(repo.GetOwner().GetLogin(), repo.GetName())
+}
+
+// MyLogin returns your login on the host serving pr.
+func MyLogin(pr *github.PullRequest) string {
+	return config.C.Username(hostOf(pr))
 }
 
 func GetLeankitCardTitle(pr PullRequest) string {
@@ -406,15 +469,56 @@ func (c *DataCache) Get(key string) (interface{}, bool) {
 	return entry.Value, true
 }
 
+// Delete drops the entry stored under key.
+func (c *DataCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.store, key)
+}
+
+// DeletePrefix drops every entry whose key starts with prefix.
+func (c *DataCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.store {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.store, key)
+		}
+	}
+}
+
 var GlobalCache = NewDataCache()
 
+// PRStateSource looks up the state the smart filters need for PRs that don't live on GitHub,
+// like GitLab merge requests. ok is false for GitHub PRs, which are looked up here.
+type PRStateSource interface {
+	CIStatus(pr *github.PullRequest) (info CIStatusInfo, ok bool)
+	InteractionState(pr *github.PullRequest) (state InteractionState, ok bool)
+}
+
+// PRCIStatus returns the CI status of a PR's head, asking src first when it is not nil.
+func PRCIStatus(pr *github.PullRequest, src PRStateSource) CIStatusInfo {
+	owner, repo := pr.Base.Repo.Owner.GetLogin(), pr.Base.Repo.GetName()
+	if src != nil {
+		cacheKey := ciStatusCacheKey(owner, repo, fmt.Sprintf("!%d", pr.GetNumber()))
+		if val, found := GlobalCache.Get(cacheKey); found {
+			return val.(CIStatusInfo)
+		}
+		if info, ok := src.CIStatus(pr); ok {
+			GlobalCache.Set(cacheKey, info, 5*time.Minute)
+			return info
+		}
+	}
+	return GetCIStatus(owner, pr.Head.Repo.GetName(), pr.Head.GetLabel())
+}
+
 func GetCIStatus(owner string, repo string, branch string) CIStatusInfo {
-	cacheKey := fmt.Sprintf("ci_status:%s/%s:%s", owner, repo, branch)
+	cacheKey := ciStatusCacheKey(owner, repo, branch)
 	if val, found := GlobalCache.Get(cacheKey); found {
 		return val.(CIStatusInfo)
 	}
 
-	client := GetGithubClient()
+	client := GetGithubClientFor(owner, repo)
 	// branch typically comes as "username:branch_name" from GitHub API
 	branchParts := strings.Split(branch, ":")
 	apiBranch := branch
@@ -510,26 +614,30 @@ func ProcessWorkflowRuns(runs []*github.WorkflowRun) []*github.WorkflowRun {
 	return output
 }
 
-func FilterCIPassing(prs []*github.PullRequest) []*github.PullRequest {
-	filtered := []*github.PullRequest{}
-	for _, pr := range prs {
-		info := GetCIStatus(*pr.Base.Repo.Owner.Login, *pr.Head.Repo.Name, *pr.Head.Label)
-		if info.OverallStatus == "DONE" {
-			filtered = append(filtered, pr)
+func MakeCIPassingFilter(src PRStateSource) PRFilter {
+	return func(prs []*github.PullRequest) []*github.PullRequest {
+		filtered := []*github.PullRequest{}
+		for _, pr := range prs {
+			info := PRCIStatus(pr, src)
+			if info.OverallStatus == "DONE" {
+				filtered = append(filtered, pr)
+			}
 		}
+		return filtered
 	}
-	return filtered
 }
 
-func FilterCIFailing(prs []*github.PullRequest) []*github.PullRequest {
-	filtered := []*github.PullRequest{}
-	for _, pr := range prs {
-		info := GetCIStatus(*pr.Base.Repo.Owner.Login, *pr.Head.Repo.Name, *pr.Head.Label)
-		if info.OverallStatus == "TODO" {
-			filtered = append(filtered, pr)
+func MakeCIFailingFilter(src PRStateSource) PRFilter {
+	return func(prs []*github.PullRequest) []*github.PullRequest {
+		filtered := []*github.PullRequest{}
+		for _, pr := range prs {
+			info := PRCIStatus(pr, src)
+			if info.OverallStatus == "TODO" {
+				filtered = append(filtered, pr)
+			}
 		}
+		return filtered
 	}
-	return filtered
 }
 
 func FilterStale(prs []*github.PullRequest) []*github.PullRequest {
@@ -560,15 +668,24 @@ type InteractionState struct {
 	LastCommitTime time.Time
 }
 
-func GetInteractionState(owner, repo string, pr *github.PullRequest) InteractionState {
-	cacheKey := fmt.Sprintf("interaction_state:%s/%s:%d", owner, repo, *pr.Number)
+// GetInteractionState returns when the current user, others and the author last acted on a PR,
+// asking src first when it is not nil.
+func GetInteractionState(owner, repo string, pr *github.PullRequest, src PRStateSource) InteractionState {
+	cacheKey := interactionStateCacheKey(owner, repo, *pr.Number)
 	if val, found := GlobalCache.Get(cacheKey); found {
 		return val.(InteractionState)
 	}
 
-	client := GetGithubClient()
+	if src != nil {
+		if state, ok := src.InteractionState(pr); ok {
+			GlobalCache.Set(cacheKey, state, 10*time.Minute)
+			return state
+		}
+	}
+
+	client := GetGithubClientFor(owner, repo)
 	ctx := context.Background()
-	myLogin := config.C.GithubUsername
+	myLogin := MyLogin(pr)
 
 	state := InteractionState{}
 
@@ -638,13 +755,17 @@ func GetInteractionState(owner, repo string, pr *github.PullRequest) Interaction
 	return state
 }
 
-func GetMyTeams() []string {
+// GetMyTeams returns the slugs of your teams on host, where nil is github.com.
+func GetMyTeams(host *config.GithubHost) []string {
 	cacheKey := "my_teams"
+	if host != nil {
+		cacheKey += ":" + host.Host
+	}
 	if val, found := GlobalCache.Get(cacheKey); found {
 		return val.([]string)
 	}
 
-	client := GetGithubClient()
+	client := clientOrFailing(GetHostClient(host))
 	ctx := context.Background()
 
 	// List teams for the authenticated user
@@ -666,51 +787,86 @@ func GetMyTeams() []string {
 	return slugs
 }
 
-func FilterWaitingOnMe(prs []*github.PullRequest) []*github.PullRequest {
-	filtered := []*github.PullRequest{}
-	myLogin := config.C.GithubUsername
-	myTeams := GetMyTeams()
+// GetTeamMembers returns the logins of the members of org/slug.
+func GetTeamMembers(org, slug string) []string {
+	cacheKey := "team_members:" + org + "/" + slug
+	if val, found := GlobalCache.Get(cacheKey); found {
+		return val.([]string)
+	}
 
-	for _, pr := range prs {
-		// Is Requested?
-		isRequested := false
-		for _, r := range pr.RequestedReviewers {
-			if r.Login != nil && *r.Login == myLogin {
-				isRequested = true
-				break
+	client := clientOrFailing(GetHostClient(config.C.HostFor(org, "")))
+	ctx := context.Background()
+	options := github.TeamListTeamMembersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	logins := []string{}
+	for {
+		members, resp, err := client.Teams.ListTeamMembersBySlug(ctx, org, slug, &options)
+		if err != nil {
+			slog.Error("Error fetching team members", "org", org, "team", slug, "error", err)
+			return []string{}
+		}
+		for _, m := range members {
+			if m.Login != nil {
+				logins = append(logins, *m.Login)
 			}
 		}
-		if !isRequested {
-			for _, t := range pr.RequestedTeams {
-				if t.Slug != nil && slices.Contains(myTeams, *t.Slug) {
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+
+	GlobalCache.Set(cacheKey, logins, 30*time.Minute)
+	return logins
+}
+
+func MakeWaitingOnMeFilter(src PRStateSource) PRFilter {
+	return func(prs []*github.PullRequest) []*github.PullRequest {
+		filtered := []*github.PullRequest{}
+
+		for _, pr := range prs {
+			myLogin := MyLogin(pr)
+			// Is Requested?
+			isRequested := false
+			for _, r := range pr.RequestedReviewers {
+				if r.Login != nil && *r.Login == myLogin {
 					isRequested = true
 					break
 				}
 			}
-		}
+			if !isRequested {
+				for _, t := range pr.RequestedTeams {
+					if t.Slug != nil && slices.Contains(GetMyTeams(hostOf(pr)), *t.Slug) {
+						isRequested = true
+						break
+					}
+				}
+			}
 
-		if isRequested {
-			state := GetInteractionState(*pr.Base.Repo.Owner.Login, *pr.Base.Repo.Name, pr)
-			actedSinceOthers := !state.LastMeTime.IsZero() && state.LastMeTime.After(state.LastOthersTime) && state.LastMeTime.After(state.LastCommitTime)
-			if !actedSinceOthers {
-				filtered = append(filtered, pr)
+			if isRequested {
+				state := GetInteractionState(*pr.Base.Repo.Owner.Login, *pr.Base.Repo.Name, pr, src)
+				actedSinceOthers := !state.LastMeTime.IsZero() && state.LastMeTime.After(state.LastOthersTime) && state.LastMeTime.After(state.LastCommitTime)
+				if !actedSinceOthers {
+					filtered = append(filtered, pr)
+				}
 			}
 		}
+		return filtered
 	}
-	return filtered
 }
 
-func FilterWaitingOnAuthor(prs []*github.PullRequest) []*github.PullRequest {
-	filtered := []*github.PullRequest{}
+func MakeWaitingOnAuthorFilter(src PRStateSource) PRFilter {
+	return func(prs []*github.PullRequest) []*github.PullRequest {
+		filtered := []*github.PullRequest{}
 
-	for _, pr := range prs {
-		state := GetInteractionState(*pr.Base.Repo.Owner.Login, *pr.Base.Repo.Name, pr)
-		actedSinceOthers := !state.LastMeTime.IsZero() && state.LastMeTime.After(state.LastOthersTime) && state.LastMeTime.After(state.LastCommitTime)
-		if actedSinceOthers {
-			filtered = append(filtered, pr)
+		for _, pr := range prs {
+			state := GetInteractionState(*pr.Base.Repo.Owner.Login, *pr.Base.Repo.Name, pr, src)
+			actedSinceOthers := !state.LastMeTime.IsZero() && state.LastMeTime.After(state.LastOthersTime) && state.LastMeTime.After(state.LastCommitTime)
+			if actedSinceOthers {
+				filtered = append(filtered, pr)
+			}
 		}
+		return filtered
 	}
-	return filtered
 }
 
 func MakeLabelFilter(targetLabel string) PRFilter {
@@ -733,6 +889,24 @@ func MakeAuthorFilter(authorLogin string) PRFilter {
 	}
 }
 
+// MakeTeamAuthorFilter keeps PRs authored by a member of team, given as "org/slug" or as a
+// slug of the PR's own organization.
+func MakeTeamAuthorFilter(team string) PRFilter {
+	return func(prs []*github.PullRequest) []*github.PullRequest {
+		filtered := []*github.PullRequest{}
+		for _, pr := range prs {
+			org, slug, found := strings.Cut(team, "/")
+			if !found {
+				org, slug = pr.GetBase().GetRepo().GetOwner().GetLogin(), team
+			}
+			if slices.Contains(GetTeamMembers(org, slug), pr.GetUser().GetLogin()) {
+				filtered = append(filtered, pr)
+			}
+		}
+		return filtered
+	}
+}
+
 func MakeExcludeAuthorFilter(authorLogin string) PRFilter {
 	return func(prs []*github.PullRequest) []*github.PullRequest {
 		return FilterPRsExcludeAuthor(prs, authorLogin)
@@ -1,6 +1,7 @@
 package git_tools
 
 import (
+    "crs/config"
     "testing"
 
     "github.com/google/go-github/v48/github"
@@ -323,3 +324,30 @@ t.Errorf("expected %d PRs, got %d", tt.expectedCount, len(result))
 })
 }
 }
+
+func TestFilterMyPRsPerHost(t *testing.T) {
+    saved := config.C
+    t.Cleanup(func() { config.C = saved })
+    config.C = config.Config{
+        GithubUsername: "me",
+        GithubHosts:    []config.GithubHost{{Host: "ghe.acme.com", GithubUsername: "jdoe"}},
+    }
+
+    makePR := func(author, url string) *github.PullRequest {
+        return &github.PullRequest{User: &github.User{Login: github.String(author)}, HTMLURL: github.String(url)}
+    }
+    prs := []*github.PullRequest{
+        makePR("me", "https://github.com/octo/api/pull/1"),
+        makePR("jdoe", "https://github.com/octo/api/pull/2"),
+        makePR("jdoe", "https://ghe.acme.com/team/web/pull/3"),
+        makePR("me", "https://ghe.acme.com/team/web/pull/4"),
+    }
+
+    mine := FilterMyPRs(prs)
+    if len(mine) != 2 || mine[0] != prs[0] || mine[1] != prs[2] {
+        t.Errorf("FilterMyPRs() kept %d PRs, want #1 on github.com and #3 on ghe.acme.com", len(mine))
+    }
+    if len(FilterNotMyPRs(prs)) != 2 {
+        t.Errorf("FilterNotMyPRs() kept %d PRs, want 2", len(FilterNotMyPRs(prs)))
+    }
+}
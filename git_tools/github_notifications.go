@@ -3,29 +3,70 @@ package git_tools
 import (
 	"context"
 	"log/slog"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/google/go-github/v48/github"
 )
 
-type NotificationTODO struct {
+// NotificationReasons are the notification reasons the inbox workflow syncs by default:
+// review requests, mentions and CI results.
+var NotificationReasons = []string{"review_requested", "mention", "team_mention", "ci_activity"}
+
+// GetNotifications returns every unread notification of the authenticated user.
+func GetNotifications(client *github.Client) ([]*github.Notification, error) {
+	options := github.NotificationListOptions{ListOptions: github.ListOptions{PerPage: 50}}
+	var notifications []*github.Notification
+	for {
+		page, resp, err := client.Activity.ListNotifications(context.Background(), &options)
+		if err != nil {
+			slog.Error("Error gathering notifications", "error", err)
+			return nil, err
+		}
+		notifications = append(notifications, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+	return notifications, nil
 }
 
-func (n NotificationTODO) ItemTitle(indent_level int) string {
-	return "abc"
+// MarkNotificationRead marks a single notification thread as read.
+func MarkNotificationRead(client *github.Client, threadID string) error {
+	_, err := client.Activity.MarkThreadRead(context.Background(), threadID)
+	return err
 }
 
-func GetNotifications() []*github.Notification {
-	client := GetGithubClient()
-	options := github.NotificationListOptions{}
-	notifications, _, err := client.Activity.ListNotifications(context.Background(), &options)
+// NotificationNumber returns the PR or issue number a notification is about, or 0 for
+// subjects without one (check suites, releases, ...).
+func NotificationNumber(notification *github.Notification) int {
+	subjectURL := notification.GetSubject().GetURL()
+	if subjectURL == "" {
+		return 0
+	}
+	parts := strings.Split(subjectURL, "/")
+	if len(parts) < 2 || (parts[len(parts)-2] != "pulls" && parts[len(parts)-2] != "issues") {
+		return 0
+	}
+	number, err := strconv.Atoi(parts[len(parts)-1])
 	if err != nil {
-		slog.Error("Error gathering notifications", "error", err)
+		return 0
 	}
-	return notifications
+	return number
 }
 
-func MarkRead(since time.Time) {
-	client := GetGithubClient()
-	client.Activity.MarkRepositoryNotificationsRead(context.Background(), "owner", "repo", since)
+// NotificationHTMLURL turns the API URL of a notification's subject into the page a browser
+// would open, falling back to the repository page.
+func NotificationHTMLURL(notification *github.Notification) string {
+	repoURL := notification.GetRepository().GetHTMLURL()
+	number := NotificationNumber(notification)
+	if number == 0 || repoURL == "" {
+		return repoURL
+	}
+	kind := "issues"
+	if notification.GetSubject().GetType() == "PullRequest" {
+		kind = "pull"
+	}
+	return repoURL + "/" + kind + "/" + strconv.Itoa(number)
 }
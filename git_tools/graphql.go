@@ -0,0 +1,51 @@
+package git_tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v48/github"
+)
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// graphQLPath returns the GraphQL endpoint relative to the client's REST base URL.
+// GitHub Enterprise serves REST under /api/v3/ and GraphQL under /api/graphql.
+func graphQLPath(client *github.Client) string {
+	if path := client.BaseURL.Path; strings.HasSuffix(path, "/api/v3/") {
+		return strings.TrimSuffix(path, "v3/") + "graphql"
+	}
+	return "graphql"
+}
+
+// GraphQL runs a query or mutation through the REST client, so it shares its authentication
+// and transport, and decodes the response's data into out.
+func GraphQL(client *github.Client, query string, variables map[string]interface{}, out interface{}) error {
+	req, err := client.NewRequest("POST", graphQLPath(client), graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+	var resp graphQLResponse
+	if _, err := client.Do(context.Background(), req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("graphql: %s", resp.Errors[0].Message)
+	}
+	if out == nil || len(resp.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, out)
+}
@@ -0,0 +1,90 @@
+package git_tools
+
+import (
+	"context"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// GetPendingReview returns the authenticated user's pending (unsubmitted) review on a PR, or nil if there is none.
+// GitHub only ever exposes pending reviews to their author, so any PENDING review in the list is ours.
+func GetPendingReview(client *github.Client, owner string, repo string, number int) (*github.PullRequestReview, error) {
+	ctx := context.Background()
+	opts := &github.ListOptions{PerPage: 100, Page: 1}
+	for {
+		reviews, resp, err := client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, review := range reviews {
+			if review.GetState() == "PENDING" {
+				return review, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func GetPendingReviewComments(client *github.Client, owner string, repo string, number int, reviewID int64) ([]*github.PullRequestComment, error) {
+	ctx := context.Background()
+	opts := &github.ListOptions{PerPage: 100, Page: 1}
+	var comments []*github.PullRequestComment
+	for {
+		page, resp, err := client.PullRequests.ListReviewComments(ctx, owner, repo, number, reviewID, opts)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+		if resp.NextPage == 0 {
+			return comments, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CreatePendingReview opens a review without an event, which GitHub keeps as a draft until it is submitted.
+// CreatePendingReview creates the pending review with its comments anchored against commitID,
+// or against the current head when commitID is empty.
+func CreatePendingReview(client *github.Client, owner string, repo string, number int, commitID string, body string, comments []*github.DraftReviewComment) (*github.PullRequestReview, error) {
+	ctx := context.Background()
+	review := &github.PullRequestReviewRequest{Comments: comments}
+	if commitID != "" {
+		review.CommitID = &commitID
+	}
+	if body != "" {
+		review.Body = &body
+	}
+	created, _, err := client.PullRequests.CreateReview(ctx, owner, repo, number, review)
+	return created, err
+}
+
+func DeletePendingReview(client *github.Client, owner string, repo string, number int, reviewID int64) error {
+	ctx := context.Background()
+	_, _, err := client.PullRequests.DeletePendingReview(ctx, owner, repo, number, reviewID)
+	return err
+}
+
+func SubmitPendingReview(client *github.Client, owner string, repo string, number int, reviewID int64, event string, body string) error {
+	ctx := context.Background()
+	review := &github.PullRequestReviewRequest{Event: &event}
+	if body != "" {
+		review.Body = &body
+	}
+	_, _, err := client.PullRequests.SubmitReview(ctx, owner, repo, number, reviewID, review)
+	return err
+}
+
+func EditReviewComment(client *github.Client, owner string, repo string, commentID int64, body string) error {
+	ctx := context.Background()
+	_, _, err := client.PullRequests.EditComment(ctx, owner, repo, commentID, &github.PullRequestComment{Body: &body})
+	return err
+}
+
+func DeleteReviewComment(client *github.Client, owner string, repo string, commentID int64) error {
+	ctx := context.Background()
+	_, err := client.PullRequests.DeleteComment(ctx, owner, repo, commentID)
+	return err
+}
@@ -0,0 +1,296 @@
+package git_tools
+
+import (
+	"bytes"
+	"context"
+	"crs/config"
+	"crs/database"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// RateLimit is the request budget a GitHub host last reported for one rate limit resource
+// ("core" for REST, "graphql", "search", ...).
+type RateLimit struct {
+	Host      string    `json:"host"` // github.com or a GitHub Enterprise host
+	Resource  string    `json:"resource"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset"`
+}
+
+// reservedResources are the ones RateLimitReserve holds back requests from. Search has a
+// budget of 30 a minute, which a reserve sized for the hourly ones would always be under.
+var reservedResources = []string{"core", "graphql"}
+
+var (
+	rateLimitMu sync.RWMutex
+	rateLimits  = make(map[string]RateLimit) // by host and resource
+)
+
+func setRateLimit(limit RateLimit) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimits[limit.Host+" "+limit.Resource] = limit
+}
+
+// rateLimitHost names the host an API URL belongs to; api.github.com is github.com.
+func rateLimitHost(u *url.URL) string {
+	if u == nil || u.Hostname() == "api.github.com" {
+		return config.DefaultGithubHost
+	}
+	return u.Hostname()
+}
+
+// recordRateLimit stores the budget from a response's X-RateLimit-* headers, if it has them.
+func recordRateLimit(host string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit := RateLimit{Host: host, Resource: header.Get("X-RateLimit-Resource"), Remaining: remaining}
+	if limit.Resource == "" {
+		limit.Resource = "core"
+	}
+	limit.Limit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	limit.Used, _ = strconv.Atoi(header.Get("X-RateLimit-Used"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		limit.Reset = time.Unix(reset, 0)
+	}
+	setRateLimit(limit)
+}
+
+// RateLimits returns the last budget seen for each host and resource, sorted by host and resource.
+func RateLimits() []RateLimit {
+	rateLimitMu.RLock()
+	defer rateLimitMu.RUnlock()
+	limits := make([]RateLimit, 0, len(rateLimits))
+	for _, limit := range rateLimits {
+		limits = append(limits, limit)
+	}
+	sort.Slice(limits, func(i, j int) bool {
+		if limits[i].Host != limits[j].Host {
+			return limits[i].Host < limits[j].Host
+		}
+		return limits[i].Resource < limits[j].Resource
+	})
+	return limits
+}
+
+// FetchRateLimits asks the client's host for the current budget of every resource. The
+// rate_limit endpoint does not count against the quota.
+func FetchRateLimits(client *github.Client) ([]RateLimit, error) {
+	host := rateLimitHost(client.BaseURL)
+	limits, _, err := client.RateLimits(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for resource, rate := range map[string]*github.Rate{
+		"core":    limits.GetCore(),
+		"graphql": limits.GetGraphQL(),
+		"search":  limits.GetSearch(),
+	} {
+		if rate == nil {
+			continue
+		}
+		setRateLimit(RateLimit{
+			Host:      host,
+			Resource:  resource,
+			Limit:     rate.Limit,
+			Remaining: rate.Remaining,
+			Used:      rate.Limit - rate.Remaining,
+			Reset:     rate.Reset.Time,
+		})
+	}
+	return RateLimits(), nil
+}
+
+// RateLimitBackoff returns how long to hold off before spending more quota: the time until
+// the latest reset among the core and graphql budgets of any host with fewer than reserve
+// requests left, or zero.
+func RateLimitBackoff(reserve int) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, limit := range RateLimits() {
+		if !slices.Contains(reservedResources, limit.Resource) {
+			continue
+		}
+		if limit.Remaining < reserve && limit.Reset.After(now) {
+			wait = max(wait, limit.Reset.Sub(now))
+		}
+	}
+	return wait
+}
+
+// httpCacheStore persists responses for conditional requests. *database.DB implements it.
+type httpCacheStore interface {
+	GetHTTPCacheEntry(key string) (*database.HTTPCacheEntry, error)
+	UpsertHTTPCacheEntry(entry database.HTTPCacheEntry) error
+}
+
+// conditionalTransport records the rate limit headers of every response and revalidates
+// GET requests with the stored ETag or Last-Modified. GitHub does not count 304 responses
+// against the quota, so polling unchanged PRs is free.
+type conditionalTransport struct {
+	base  http.RoundTripper
+	store httpCacheStore
+}
+
+// newConditionalTransport caches in config.C.DB when there is one.
+func newConditionalTransport() *conditionalTransport {
+	transport := &conditionalTransport{base: http.DefaultTransport}
+	if config.C.DB != nil {
+		transport.store = config.C.DB
+	}
+	return transport
+}
+
+// NewHTTPClient returns a client that revalidates GET requests against the HTTP cache and gives
+// up on a request after timeout, for forge APIs that aren't reached through go-github.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: newConditionalTransport(), Timeout: timeout}
+}
+
+// httpCacheKey separates responses for the same URL in different media types, like a PR and its diff.
+// The URL carries the host; the token is left out so a rotated token finds the same entry.
+func httpCacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String() + " " + req.Header.Get("Accept")
+}
+
+// requestIdentity hashes the token a request carries (Authorization for GitHub, PRIVATE-TOKEN for
+// GitLab), so the cache can tell whose response an entry holds without storing credentials.
+func requestIdentity(req *http.Request) string {
+	identity := sha256.Sum256([]byte(req.Header.Get("Authorization") + req.Header.Get("PRIVATE-TOKEN")))
+	return hex.EncodeToString(identity[:8])
+}
+
+// reusableFor reports whether a cached entry may be revalidated for req. Entries stored with the
+// same token always may. Another token, a rotated one or another account's, may only when the
+// stored response said through Vary that the server picks it per credential, as GitHub's do: its
+// validators then only match when that token would get the same response.
+func reusableFor(cached *database.HTTPCacheEntry, identity string) bool {
+	if cached.Identity == identity {
+		return true
+	}
+	header := http.Header{}
+	if err := json.Unmarshal([]byte(cached.HeadersJSON), &header); err != nil {
+		return false
+	}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if strings.EqualFold(name, "Authorization") || strings.EqualFold(name, "PRIVATE-TOKEN") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || t.store == nil {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			recordRateLimit(rateLimitHost(req.URL), resp.Header)
+		}
+		return resp, err
+	}
+
+	key, identity := httpCacheKey(req), requestIdentity(req)
+	cached, err := t.store.GetHTTPCacheEntry(key)
+	if err != nil {
+		slog.Warn("Error reading HTTP cache", "key", key, "error", err)
+		cached = nil
+	}
+	if cached != nil && !reusableFor(cached, identity) {
+		cached = nil
+	}
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	recordRateLimit(rateLimitHost(req.URL), resp.Header)
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		if cached.Identity != identity {
+			// The server vouched for the entry under this token, so future requests can skip the Vary check
+			cached.Identity = identity
+			if err := t.store.UpsertHTTPCacheEntry(*cached); err != nil {
+				slog.Warn("Error writing HTTP cache", "key", key, "error", err)
+			}
+		}
+		return cachedResponse(req, resp, cached), nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	headersJSON, _ := json.Marshal(resp.Header)
+	if err := t.store.UpsertHTTPCacheEntry(database.HTTPCacheEntry{
+		Key:          key,
+		ETag:         etag,
+		LastModified: lastModified,
+		HeadersJSON:  string(headersJSON),
+		Body:         body,
+		Identity:     identity,
+	}); err != nil {
+		slog.Warn("Error writing HTTP cache", "key", key, "error", err)
+	}
+	return resp, nil
+}
+
+// cachedResponse turns a 304 into the stored 200 response, keeping the fresh headers
+// (rate limits, date) that GitHub sent with the 304.
+func cachedResponse(req *http.Request, notModified *http.Response, cached *database.HTTPCacheEntry) *http.Response {
+	header := http.Header{}
+	if err := json.Unmarshal([]byte(cached.HeadersJSON), &header); err != nil {
+		slog.Warn("Error decoding cached headers", "key", cached.Key, "error", err)
+	}
+	for name, values := range notModified.Header {
+		header[name] = values
+	}
+	header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
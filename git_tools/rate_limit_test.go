@@ -0,0 +1,233 @@
+package git_tools
+
+import (
+	"context"
+	"crs/config"
+	"crs/database"
+	"crs/testutil"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryHTTPCache map[string]database.HTTPCacheEntry
+
+func (m memoryHTTPCache) GetHTTPCacheEntry(key string) (*database.HTTPCacheEntry, error) {
+	entry, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (m memoryHTTPCache) UpsertHTTPCacheEntry(entry database.HTTPCacheEntry) error {
+	m[entry.Key] = entry
+	return nil
+}
+
+func TestConditionalTransport(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	var conditional []string
+	store := memoryHTTPCache{}
+	client := testutil.NewGithubClient(t, &http.Client{Transport: &conditionalTransport{base: http.DefaultTransport, store: store}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.Header().Set("X-RateLimit-Resource", "core")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"number": 7, "title": "Cached"}`))
+	}))
+
+	for i := 0; i < 2; i++ {
+		pr, _, err := client.PullRequests.Get(context.Background(), "owner", "repo", 7)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if pr.GetTitle() != "Cached" {
+			t.Errorf("request %d: title = %q, want %q", i, pr.GetTitle(), "Cached")
+		}
+	}
+
+	if len(conditional) != 2 || conditional[0] != "" || conditional[1] != `"v1"` {
+		t.Errorf("If-None-Match headers = %q, want [\"\" \"\\\"v1\\\"\"]", conditional)
+	}
+	if len(store) != 1 {
+		t.Errorf("cached %d responses, want 1", len(store))
+	}
+
+	var core *RateLimit
+	for _, limit := range RateLimits() {
+		if limit.Resource == "core" {
+			core = &limit
+		}
+	}
+	if core == nil || core.Remaining != 42 || core.Limit != 5000 {
+		t.Fatalf("core rate limit = %+v, want 42 of 5000 remaining", core)
+	}
+	if wait := RateLimitBackoff(10); wait != 0 {
+		t.Errorf("RateLimitBackoff(10) = %v, want 0", wait)
+	}
+	if wait := RateLimitBackoff(100); wait <= 0 || wait > time.Hour {
+		t.Errorf("RateLimitBackoff(100) = %v, want until the reset", wait)
+	}
+}
+
+func TestConditionalTransport_PerAccount(t *testing.T) {
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if r.Header.Get("If-None-Match") == strconv.Quote(token) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", strconv.Quote(token))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"number": 7, "title": %q}`, token)
+	}))
+	defer server.Close()
+
+	testutil.NewDB(t)
+	t.Setenv("CRS_TOKEN_ALICE", "alice")
+	t.Setenv("CRS_TOKEN_BOB", "bob")
+	// Two accounts on one host must not be served each other's cached responses. The server
+	// doesn't say its responses vary by token, so each account refetches what the other stored.
+	for _, account := range []string{"alice", "bob", "alice"} {
+		host := &config.GithubHost{Host: account, BaseURL: server.URL + "/", TokenEnv: "CRS_TOKEN_" + strings.ToUpper(account)}
+		client, err := GetHostClient(host)
+		if err != nil {
+			t.Fatalf("%s: %v", account, err)
+		}
+		pr, _, err := client.PullRequests.Get(context.Background(), "owner", "repo", 7)
+		if err != nil {
+			t.Fatalf("%s: %v", account, err)
+		}
+		if pr.GetTitle() != account {
+			t.Errorf("%s: title = %q, want %q", account, pr.GetTitle(), account)
+		}
+	}
+	if want := []string{"", "", ""}; !slices.Equal(conditional, want) {
+		t.Errorf("If-None-Match headers = %q, want %q", conditional, want)
+	}
+}
+
+func TestConditionalTransport_RotatedToken(t *testing.T) {
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		w.Header().Set("Vary", "Accept, Authorization, Cookie")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"number": 7, "title": "Cached"}`))
+	}))
+	defer server.Close()
+
+	store := memoryHTTPCache{}
+	for _, token := range []string{"old", "new", "new"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/repos/owner/repo/pulls/7", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := (&conditionalTransport{base: http.DefaultTransport, store: store}).RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", token, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "Cached") {
+			t.Errorf("%s: got %d %q, want the cached PR", token, resp.StatusCode, body)
+		}
+	}
+	// The rotated token revalidates the entry the old one stored instead of refetching it
+	if want := []string{"", `"v1"`, `"v1"`}; !slices.Equal(conditional, want) {
+		t.Errorf("If-None-Match headers = %q, want %q", conditional, want)
+	}
+	if len(store) != 1 {
+		t.Errorf("cached %d responses, want 1 per URL", len(store))
+	}
+	for _, entry := range store {
+		if entry.Identity == "" || entry.Identity != requestIdentity(&http.Request{Header: http.Header{"Authorization": {"Bearer new"}}}) {
+			t.Errorf("entry identity = %q, want the new token's", entry.Identity)
+		}
+	}
+}
+
+func TestRateLimitBackoff_PerHostAndResource(t *testing.T) {
+	rateLimitMu.Lock()
+	saved := rateLimits
+	rateLimits = make(map[string]RateLimit)
+	rateLimitMu.Unlock()
+	t.Cleanup(func() {
+		rateLimitMu.Lock()
+		rateLimits = saved
+		rateLimitMu.Unlock()
+	})
+
+	reset := time.Now().Add(30 * time.Minute)
+	recordRateLimit("github.com", http.Header{
+		"X-Ratelimit-Remaining": {"4000"},
+		"X-Ratelimit-Resource":  {"core"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+	})
+	// Search allows 30 a minute, far below the reserve, and must not hold off cycles
+	recordRateLimit("github.com", http.Header{
+		"X-Ratelimit-Remaining": {"12"},
+		"X-Ratelimit-Resource":  {"search"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)},
+	})
+	if wait := RateLimitBackoff(100); wait != 0 {
+		t.Errorf("RateLimitBackoff(100) with only search low = %v, want 0", wait)
+	}
+
+	// A GitHub Enterprise host keeps its own budget instead of overwriting github.com's
+	recordRateLimit("ghe.acme.com", http.Header{
+		"X-Ratelimit-Remaining": {"50"},
+		"X-Ratelimit-Resource":  {"core"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+	})
+	limits := RateLimits()
+	if len(limits) != 3 || limits[0].Host != "ghe.acme.com" || limits[1].Remaining != 4000 || limits[2].Resource != "search" {
+		t.Fatalf("RateLimits() = %+v, want ghe.acme.com core, github.com core and search", limits)
+	}
+	if wait := RateLimitBackoff(100); wait <= 0 || wait > 30*time.Minute {
+		t.Errorf("RateLimitBackoff(100) = %v, want until the enterprise reset", wait)
+	}
+
+	if got := rateLimitHost(&url.URL{Host: "api.github.com"}); got != "github.com" {
+		t.Errorf("rateLimitHost(api.github.com) = %q, want github.com", got)
+	}
+}
+
+func TestPruneHTTPCache(t *testing.T) {
+	db := testutil.NewDB(t)
+	for _, key := range []string{"oldest", "middle", "newest"} {
+		if err := db.UpsertHTTPCacheEntry(database.HTTPCacheEntry{Key: key, ETag: `"v1"`, Body: []byte("0123456789")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := db.PruneHTTPCache(time.Hour, 25)
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneHTTPCache() = %d, %v, want 1 entry pruned", pruned, err)
+	}
+	for key, kept := range map[string]bool{"oldest": false, "middle": true, "newest": true} {
+		if entry, _ := db.GetHTTPCacheEntry(key); (entry != nil) != kept {
+			t.Errorf("entry %q kept = %v, want %v", key, entry != nil, kept)
+		}
+	}
+}
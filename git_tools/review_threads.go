@@ -0,0 +1,97 @@
+package git_tools
+
+import (
+	"github.com/google/go-github/v48/github"
+)
+
+// ReviewThread is a PR review thread as GitHub's GraphQL API sees it. The REST API has no notion
+// of threads, so CommentIDs (REST comment IDs) is how threads are matched to comments.
+type ReviewThread struct {
+	ID         string  `json:"id"`
+	IsResolved bool    `json:"is_resolved"`
+	ResolvedBy string  `json:"resolved_by"`
+	CommentIDs []int64 `json:"comment_ids"`
+}
+
+const reviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $after: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $after) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          id
+          isResolved
+          resolvedBy { login }
+          comments(first: 100) { nodes { databaseId } }
+        }
+      }
+    }
+  }
+}`
+
+type reviewThreadsData struct {
+	Repository struct {
+		PullRequest struct {
+			ReviewThreads struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []struct {
+					ID         string `json:"id"`
+					IsResolved bool   `json:"isResolved"`
+					ResolvedBy *struct {
+						Login string `json:"login"`
+					} `json:"resolvedBy"`
+					Comments struct {
+						Nodes []struct {
+							DatabaseID int64 `json:"databaseId"`
+						} `json:"nodes"`
+					} `json:"comments"`
+				} `json:"nodes"`
+			} `json:"reviewThreads"`
+		} `json:"pullRequest"`
+	} `json:"repository"`
+}
+
+func GetReviewThreads(client *github.Client, owner string, repo string, number int) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	variables := map[string]interface{}{"owner": owner, "repo": repo, "number": number}
+	for {
+		var data reviewThreadsData
+		if err := GraphQL(client, reviewThreadsQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		page := data.Repository.PullRequest.ReviewThreads
+		for _, node := range page.Nodes {
+			thread := ReviewThread{ID: node.ID, IsResolved: node.IsResolved}
+			if node.ResolvedBy != nil {
+				thread.ResolvedBy = node.ResolvedBy.Login
+			}
+			for _, c := range node.Comments.Nodes {
+				thread.CommentIDs = append(thread.CommentIDs, c.DatabaseID)
+			}
+			threads = append(threads, thread)
+		}
+		if !page.PageInfo.HasNextPage {
+			return threads, nil
+		}
+		variables["after"] = page.PageInfo.EndCursor
+	}
+}
+
+const resolveReviewThreadMutation = `mutation($id: ID!) {
+  resolveReviewThread(input: {threadId: $id}) { thread { id } }
+}`
+
+const unresolveReviewThreadMutation = `mutation($id: ID!) {
+  unresolveReviewThread(input: {threadId: $id}) { thread { id } }
+}`
+
+func ResolveReviewThread(client *github.Client, threadID string) error {
+	return GraphQL(client, resolveReviewThreadMutation, map[string]interface{}{"id": threadID}, nil)
+}
+
+func UnresolveReviewThread(client *github.Client, threadID string) error {
+	return GraphQL(client, unresolveReviewThreadMutation, map[string]interface{}{"id": threadID}, nil)
+}
@@ -0,0 +1,76 @@
+package git_tools
+
+import (
+	"crs/testutil"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func TestGraphQLPath(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://api.github.com/", "graphql"},
+		{"https://github.example.com/api/v3/", "/api/graphql"},
+	}
+	for _, tt := range tests {
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(tt.base)
+		if got := graphQLPath(client); got != tt.want {
+			t.Errorf("graphQLPath(%s) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestGetReviewThreads(t *testing.T) {
+	pages := []string{
+		`{"data":{"repository":{"pullRequest":{"reviewThreads":{
+			"pageInfo":{"hasNextPage":true,"endCursor":"c1"},
+			"nodes":[{"id":"T1","isResolved":true,"resolvedBy":{"login":"alice"},"comments":{"nodes":[{"databaseId":10},{"databaseId":11}]}}]}}}}}`,
+		`{"data":{"repository":{"pullRequest":{"reviewThreads":{
+			"pageInfo":{"hasNextPage":false,"endCursor":""},
+			"nodes":[{"id":"T2","isResolved":false,"resolvedBy":null,"comments":{"nodes":[{"databaseId":20}]}}]}}}}}`,
+	}
+	var cursors []interface{}
+	client := testutil.NewGithubClient(t, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			t.Errorf("request to %s, want /graphql", r.URL.Path)
+		}
+		var req graphQLRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		cursors = append(cursors, req.Variables["after"])
+		w.Write([]byte(pages[len(cursors)-1]))
+	}))
+
+	threads, err := GetReviewThreads(client, "owner", "repo", 1)
+	if err != nil {
+		t.Fatalf("GetReviewThreads() error = %v", err)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("GetReviewThreads() returned %d threads, want 2", len(threads))
+	}
+	if threads[0].ID != "T1" || !threads[0].IsResolved || threads[0].ResolvedBy != "alice" || len(threads[0].CommentIDs) != 2 {
+		t.Errorf("first thread = %+v", threads[0])
+	}
+	if threads[1].IsResolved || threads[1].ResolvedBy != "" || threads[1].CommentIDs[0] != 20 {
+		t.Errorf("second thread = %+v", threads[1])
+	}
+	if cursors[0] != nil || cursors[1] != "c1" {
+		t.Errorf("cursors = %v, want [<nil> c1]", cursors)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	client := testutil.NewGithubClient(t, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":null,"errors":[{"message":"Could not resolve to a node"}]}`))
+	}))
+
+	if err := ResolveReviewThread(client, "T1"); err == nil {
+		t.Errorf("ResolveReviewThread() should surface GraphQL errors")
+	}
+}
@@ -0,0 +1,141 @@
+package git_tools
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// The Search API returns at most 1000 results for a query.
+const searchPRsMaxPages = 1000 / bulkPRsPageSize
+
+const searchPRsQuery = `query($query: String!, $first: Int!, $after: String) {
+  search(query: $query, type: ISSUE, first: $first, after: $after) {
+    pageInfo { hasNextPage endCursor }
+    nodes {
+      __typename
+      ... on PullRequest {
+        repository { name nameWithOwner owner { login } }
+        ...BulkPRFields
+      }
+    }
+  }
+}
+` + bulkPRFields
+
+type searchPRNode struct {
+	Typename   string `json:"__typename"`
+	Repository struct {
+		Name          string   `json:"name"`
+		NameWithOwner string   `json:"nameWithOwner"`
+		Owner         gqlActor `json:"owner"`
+	} `json:"repository"`
+	gqlPullRequest
+}
+
+type searchPRsData struct {
+	Search struct {
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+		Nodes []searchPRNode `json:"nodes"`
+	} `json:"search"`
+}
+
+// SearchQuery makes sure a search only matches pull requests.
+func SearchQuery(query string) string {
+	for _, field := range strings.Fields(query) {
+		if field == "is:pr" || field == "type:pr" {
+			return query
+		}
+	}
+	return strings.TrimSpace("is:pr " + query)
+}
+
+// SearchQueryRepos returns the repos named by repo: qualifiers in a search query.
+func SearchQueryRepos(query string) []string {
+	repos := []string{}
+	for _, field := range strings.Fields(query) {
+		if repo, found := strings.CutPrefix(field, "repo:"); found {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
+// SearchPRsGraphQL pages through the PRs matching a GitHub search query, like
+// GetManyRepoPRsGraphQL does for a list of repos.
+func SearchPRsGraphQL(client *github.Client, query string) ([]*github.PullRequest, error) {
+	variables := map[string]interface{}{
+		"query": SearchQuery(query),
+		"first": bulkPRsPageSize,
+	}
+
+	var prs []*github.PullRequest
+	for page := 0; page < searchPRsMaxPages; page++ {
+		var data searchPRsData
+		if err := GraphQL(client, searchPRsQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		for i := range data.Search.Nodes {
+			node := &data.Search.Nodes[i]
+			if node.Typename != "PullRequest" {
+				continue
+			}
+			r := node.Repository
+			pr := node.toPullRequest(r.Owner.Login, r.Name, r.NameWithOwner)
+			prs = append(prs, pr)
+			node.cacheState(pr, MyLogin(pr))
+		}
+		if !data.Search.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = data.Search.PageInfo.EndCursor
+	}
+	return prs, nil
+}
+
+// SearchPRs is the REST fallback for SearchPRsGraphQL. Search results are issues, so each PR
+// is fetched on its own.
+func SearchPRs(client *github.Client, query string) ([]*github.PullRequest, error) {
+	ctx := context.Background()
+	options := github.SearchOptions{Sort: "updated", ListOptions: github.ListOptions{PerPage: 100}}
+	var prs []*github.PullRequest
+	for {
+		result, resp, err := client.Search.Issues(ctx, SearchQuery(query), &options)
+		if err != nil {
+			slog.Error("Error searching PRs", "query", query, "error", err)
+			return nil, err
+		}
+		for _, issue := range result.Issues {
+			if !issue.IsPullRequest() {
+				continue
+			}
+			owner, repo, err := ParseRepoName(repoFromAPIURL(issue.GetRepositoryURL()))
+			if err != nil {
+				slog.Error("Skipping search result with an unknown repo", "url", issue.GetRepositoryURL(), "error", err)
+				continue
+			}
+			pr, _, err := client.PullRequests.Get(ctx, owner, repo, issue.GetNumber())
+			if err != nil {
+				slog.Error("Error Getting PR", "owner", owner, "repo", repo, "number", issue.GetNumber(), "error", err)
+				return nil, err
+			}
+			prs = append(prs, pr)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+	return prs, nil
+}
+
+// repoFromAPIURL turns https://api.github.com/repos/owner/repo into owner/repo.
+func repoFromAPIURL(apiURL string) string {
+	_, repo, _ := strings.Cut(apiURL, "/repos/")
+	return repo
+}
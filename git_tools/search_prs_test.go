@@ -0,0 +1,72 @@
+package git_tools
+
+import (
+	"crs/testutil"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+const searchPRsPage = `{"data":{"search":{
+  "pageInfo":{"hasNextPage":%s,"endCursor":"%s"},
+  "nodes":[%s]}}}`
+
+const searchPRNodeJSON = `{"__typename":"PullRequest",
+  "repository":{"name":"%s","nameWithOwner":"acme/%s","owner":{"login":"acme"}},
+  "databaseId":1,"number":%d,"title":"T","body":"","url":"","state":"OPEN","isDraft":false,
+  "createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z",
+  "author":{"login":"alice"},"baseRefName":"main","headRefName":"topic","headRefOid":"abc",
+  "labels":{"nodes":[]},"reviewRequests":{"nodes":[]},"reviews":{"nodes":[]},
+  "comments":{"nodes":[]},"reviewThreads":{"nodes":[]},"commits":{"nodes":[]}}`
+
+func TestSearchPRsGraphQL(t *testing.T) {
+	var queries []string
+	client := testutil.NewGithubClient(t, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		queries = append(queries, body.Variables["query"].(string))
+		if body.Variables["after"] == nil {
+			fmt.Fprintf(w, searchPRsPage, "true", "cursor1", fmt.Sprintf(searchPRNodeJSON, "api", "api", 1)+`,{"__typename":"Issue"}`)
+			return
+		}
+		fmt.Fprintf(w, searchPRsPage, "false", "", fmt.Sprintf(searchPRNodeJSON, "web", "web", 2))
+	}))
+
+	prs, err := SearchPRsGraphQL(client, "review-requested:@me org:acme")
+	if err != nil {
+		t.Fatalf("SearchPRsGraphQL() error = %v", err)
+	}
+	if len(queries) != 2 || queries[0] != "is:pr review-requested:@me org:acme" {
+		t.Errorf("queries = %q, want two pages of the query with is:pr", queries)
+	}
+	repos := []string{}
+	for _, pr := range prs {
+		repos = append(repos, pr.Base.Repo.GetFullName())
+	}
+	if !slices.Equal(repos, []string{"acme/api", "acme/web"}) {
+		t.Errorf("got PRs from %v, want acme/api and acme/web (issues skipped)", repos)
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"is:open org:acme", "is:pr is:open org:acme"},
+		{"is:pr is:open", "is:pr is:open"},
+		{"type:pr author:@me", "type:pr author:@me"},
+	}
+	for _, tt := range tests {
+		if got := SearchQuery(tt.query); got != tt.want {
+			t.Errorf("SearchQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	if got := SearchQueryRepos("is:open repo:acme/api repo:acme/web label:bug"); !slices.Equal(got, []string{"acme/api", "acme/web"}) {
+		t.Errorf("SearchQueryRepos() = %v", got)
+	}
+}
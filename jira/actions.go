@@ -0,0 +1,123 @@
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var issueKeyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// FindIssueKeys returns the issue keys mentioned in a branch name, title or body, e.g.
+// "PROJ-123" in "feature/PROJ-123-add-widgets", in order and without duplicates.
+func FindIssueKeys(texts ...string) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, key := range issueKeyPattern.FindAllString(text, -1) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// ProjectKey is the project part of an issue key: PROJ for PROJ-123.
+func ProjectKey(issueKey string) string {
+	project, _, _ := strings.Cut(issueKey, "-")
+	return project
+}
+
+type transition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// TransitionIssue moves an issue to the status (or through the transition) with the given
+// name. Jira only offers the transitions allowed from the issue's current status.
+func TransitionIssue(domain string, key string, status string) error {
+	transitionsURL := fmt.Sprintf("%s/rest/api/3/issue/%s/transitions", strings.TrimSuffix(domain, "/"), key)
+
+	var data struct {
+		Transitions []transition `json:"transitions"`
+	}
+	if err := doRequest("GET", transitionsURL, nil, &data); err != nil {
+		return err
+	}
+	for _, t := range data.Transitions {
+		if strings.EqualFold(t.To.Name, status) || strings.EqualFold(t.Name, status) {
+			body := map[string]any{"transition": map[string]string{"id": t.ID}}
+			return doRequest("POST", transitionsURL, body, nil)
+		}
+	}
+	available := []string{}
+	for _, t := range data.Transitions {
+		available = append(available, t.To.Name)
+	}
+	return fmt.Errorf("no transition to %q for %s (available: %s)", status, key, strings.Join(available, ", "))
+}
+
+// AddComment posts a plain text comment on an issue.
+func AddComment(domain string, key string, comment string) error {
+	// Version 2 of the API takes plain text instead of the document format version 3 requires
+	commentURL := fmt.Sprintf("%s/rest/api/2/issue/%s/comment", strings.TrimSuffix(domain, "/"), key)
+	return doRequest("POST", commentURL, map[string]string{"body": comment}, nil)
+}
+
+// Myself returns the display name of the account the Jira credentials authenticate as.
+func Myself(domain string) (string, error) {
+	var user User
+	if err := doRequest("GET", strings.TrimSuffix(domain, "/")+"/rest/api/3/myself", nil, &user); err != nil {
+		return "", err
+	}
+	return user.DisplayName, nil
+}
+
+// httpClient bounds Jira requests, since the PR view waits on the issue lookups.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func doRequest(method string, url string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	jiraEmail, token := getAuth()
+	req.SetBasicAuth(jiraEmail, token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("jira %s %s failed with status %d: %s", method, url, resp.StatusCode, message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,100 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IssueDetails is what a reviewer needs to check a PR against its ticket.
+type IssueDetails struct {
+	Key                string   `json:"key"`
+	URL                string   `json:"url"`
+	Summary            string   `json:"summary"`
+	Status             string   `json:"status"`
+	Assignee           string   `json:"assignee"`
+	FixVersions        []string `json:"fix_versions"`
+	AcceptanceCriteria string   `json:"acceptance_criteria"`
+}
+
+// GetIssue fetches an issue's details. acceptanceField is the custom field holding the
+// acceptance criteria (e.g. customfield_10035); when it's empty or unset on the issue, the
+// "Acceptance Criteria" section of the description is used instead.
+func GetIssue(domain string, key string, acceptanceField string) (*IssueDetails, error) {
+	fields := []string{"summary", "status", "assignee", "fixVersions", "description"}
+	if acceptanceField != "" {
+		fields = append(fields, acceptanceField)
+	}
+	// Version 2 of the API returns the description and text custom fields as plain text
+	issueURL := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=%s", strings.TrimSuffix(domain, "/"), key, strings.Join(fields, ","))
+
+	var data struct {
+		Key    string                     `json:"key"`
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := doRequest("GET", issueURL, nil, &data); err != nil {
+		return nil, err
+	}
+
+	var issueFields struct {
+		Summary string `json:"summary"`
+		Status  struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Assignee    *User  `json:"assignee"`
+		Description string `json:"description"`
+		FixVersions []struct {
+			Name string `json:"name"`
+		} `json:"fixVersions"`
+	}
+	raw, _ := json.Marshal(data.Fields)
+	if err := json.Unmarshal(raw, &issueFields); err != nil {
+		return nil, err
+	}
+
+	details := &IssueDetails{
+		Key:         data.Key,
+		URL:         BrowseURL(domain, data.Key),
+		Summary:     issueFields.Summary,
+		Status:      issueFields.Status.Name,
+		FixVersions: []string{},
+	}
+	if issueFields.Assignee != nil {
+		details.Assignee = issueFields.Assignee.DisplayName
+	}
+	for _, version := range issueFields.FixVersions {
+		details.FixVersions = append(details.FixVersions, version.Name)
+	}
+	if acceptanceField != "" {
+		// Text fields come back as strings; anything else (null, rich content) is skipped
+		json.Unmarshal(data.Fields[acceptanceField], &details.AcceptanceCriteria)
+	}
+	if details.AcceptanceCriteria == "" {
+		details.AcceptanceCriteria = AcceptanceCriteriaFromDescription(issueFields.Description)
+	}
+	return details, nil
+}
+
+var acceptanceHeading = regexp.MustCompile(`(?i)^\s*(h[1-6]\.\s*|#+\s*|\*)?\s*acceptance criteria\s*:?\s*\*?\s*:?\s*$`)
+var headingLine = regexp.MustCompile(`^\s*(h[1-6]\.|#+\s)`)
+
+// AcceptanceCriteriaFromDescription returns the lines under an "Acceptance Criteria" heading
+// in a description, up to the next heading.
+func AcceptanceCriteriaFromDescription(description string) string {
+	lines := strings.Split(strings.ReplaceAll(description, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if !acceptanceHeading.MatchString(line) {
+			continue
+		}
+		section := []string{}
+		for _, next := range lines[i+1:] {
+			if headingLine.MatchString(next) {
+				break
+			}
+			section = append(section, next)
+		}
+		return strings.TrimSpace(strings.Join(section, "\n"))
+	}
+	return ""
+}
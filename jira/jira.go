@@ -3,10 +3,13 @@ package jira
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,15 +29,51 @@ type DevStatusResponse struct {
 }
 
 type JiraSearchResponse struct {
-	Issues []Issue `json:"issues"`
+	Issues        []Issue `json:"issues"`
+	NextPageToken string  `json:"nextPageToken"`
+	IsLast        bool    `json:"isLast"`
 }
 
 type Issue struct {
-	ID string `json:"id"`
+	ID     string      `json:"id"`
+	Key    string      `json:"key"`
+	Fields IssueFields `json:"fields"`
+}
+
+type IssueFields struct {
+	Summary string `json:"summary"`
+	Status  struct {
+		Name string `json:"name"`
+	} `json:"status"`
+	Assignee *User `json:"assignee"`
+}
+
+type User struct {
+	DisplayName string `json:"displayName"`
+}
+
+func (i Issue) Status() string {
+	return i.Fields.Status.Name
+}
+
+// Assignee returns the assignee's display name, or "" for unassigned issues.
+func (i Issue) Assignee() string {
+	if i.Fields.Assignee == nil {
+		return ""
+	}
+	return i.Fields.Assignee.DisplayName
+}
+
+// LinkedPR is a GitHub PR linked to a Jira issue through the development panel.
+type LinkedPR struct {
+	Issue  Issue
+	Owner  string
+	Repo   string
+	Number int
 }
 
 func getDevURL(domain string, issueID string) string {
-	return fmt.Sprintf("%s/rest/dev-status/1.0/issue/details?issueId=%s&applicationType=github&dataType=pullrequest", domain, issueID)
+	return fmt.Sprintf("%s/rest/dev-status/1.0/issue/details?issueId=%s&applicationType=github&dataType=pullrequest", strings.TrimSuffix(domain, "/"), issueID)
 }
 
 func getAuth() (string, string) {
@@ -43,128 +82,129 @@ func getAuth() (string, string) {
 	return jiraEmail, token
 }
 
-// / Get all of the PRs #s for a repo under a JIRA epic
-func GetProjectPRKeys(domain string, epicKey string, repo_name string) []int {
-	// fmt.Printf("Searching for project shas for project: `%s`\n", epicKey)
+// EpicJQL is the query for the children of an epic.
+func EpicJQL(epicKey string) string {
+	return fmt.Sprintf("Parent = %s", epicKey)
+}
+
+// SearchIssues returns every issue matching a JQL query, with the key, status and assignee filled in.
+func SearchIssues(domain string, jql string) ([]Issue, error) {
 	if !strings.HasSuffix(domain, "/") {
 		domain += "/"
 	}
-
 	searchURL := fmt.Sprintf("%srest/api/3/search/jql", domain)
-
 	jiraEmail, token := getAuth()
 
-	params := url.Values{}
-	params.Add("jql", fmt.Sprintf("Parent = %s", epicKey))
-
-	req, err := http.NewRequest("GET", searchURL, nil)
-	if err != nil {
-		slog.Error("Error creating request", "error", err)
-		return []int{}
-	}
-	req.URL.RawQuery = params.Encode()
-
-	req.SetBasicAuth(jiraEmail, token)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		slog.Error("Error making request", "error", err)
-		return []int{}
+	issues := []Issue{}
+	nextPageToken := ""
+	for {
+		params := url.Values{}
+		params.Add("jql", jql)
+		params.Add("fields", "summary,status,assignee")
+		if nextPageToken != "" {
+			params.Add("nextPageToken", nextPageToken)
+		}
+
+		req, err := http.NewRequest("GET", searchURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.URL.RawQuery = params.Encode()
+		req.SetBasicAuth(jiraEmail, token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			// Jira explains bad JQL in the body
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, fmt.Errorf("jira search failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var data JiraSearchResponse
+		err = json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, data.Issues...)
+		if data.IsLast || data.NextPageToken == "" {
+			return issues, nil
+		}
+		nextPageToken = data.NextPageToken
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		slog.Error("Error getting JIRA search")
-		// Read the response body to get the error message.
-		body := make([]byte, 1024)
-		n, _ := resp.Body.Read(body) // We ignore the error here.
-		slog.Error("JIRA response body", "content", string(body[:n]))
-		return []int{}
-	}
-
-	var data JiraSearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		slog.Error("Error decoding JSON", "error", err)
-		return []int{}
-	}
-
-	return processIssues(domain, data, repo_name)
-
 }
 
-func processIssues(domain string, data JiraSearchResponse, target_repo string) []int {
-	// this function right now only works for a single repo.
-	// Returns a list of the PR numbers.
-
+// GetLinkedPRs resolves the GitHub PRs linked to each issue and keeps those in one of the
+// "owner/repo" repos; PRs in any repo are kept when repos is empty.
+func GetLinkedPRs(domain string, issues []Issue, repos []string) []LinkedPR {
 	var (
-		PRNumbers []int
-		mu        sync.Mutex
-		wg        sync.WaitGroup
+		linked []LinkedPR
+		mu     sync.Mutex
+		wg     sync.WaitGroup
 	)
 
-	errChan := make(chan error, len(data.Issues))   // Buffered channel for errors
-	resultsChan := make(chan int, len(data.Issues)) // Buffered channel for merge SHAs
-
-	for _, issue := range data.Issues {
+	for _, issue := range issues {
 		wg.Add(1)
 		go func(issue Issue) {
 			defer wg.Done()
 
-			pr, err := getPRLinkForIssue(domain, issue.ID)
-			if pr == nil {
-				errChan <- fmt.Errorf("pr is nil %s", issue.ID)
-				return
-			}
-
+			prs, err := getPRLinksForIssue(domain, issue.ID)
 			if err != nil {
-				errChan <- fmt.Errorf("error getting PR link for issue %s: %w", issue.ID, err)
+				slog.Error("Error getting PR links for issue", "issue", issue.Key, "error", err)
 				return
 			}
-
-			if pr.URL == "" {
-				errChan <- fmt.Errorf("Err getting PR link for issue %s: URL is empty", issue.ID)
-				return
-			}
-
-			// fmt.Println("checking the url: " + pr.URL)
-			prNumber := strings.Split(pr.URL, "/")
-			repo := prNumber[len(prNumber)-3]
-			if repo != target_repo {
-				errChan <- fmt.Errorf("Issue PR is for a separate repo: %s", repo)
-				return
+			for _, pr := range prs {
+				owner, repo, number, ok := ParsePRURL(pr.URL)
+				if !ok {
+					slog.Debug("Skipping unrecognized PR link", "issue", issue.Key, "url", pr.URL)
+					continue
+				}
+				if len(repos) > 0 && !slices.ContainsFunc(repos, func(r string) bool {
+					return strings.EqualFold(r, owner+"/"+repo)
+				}) {
+					continue
+				}
+				mu.Lock()
+				linked = append(linked, LinkedPR{Issue: issue, Owner: owner, Repo: repo, Number: number})
+				mu.Unlock()
 			}
-			prNum := prNumber[len(prNumber)-1]
-			num, err := strconv.Atoi(prNum)
-			if err != nil {
-				errChan <- fmt.Errorf("Failed to convert prNum %s to int", prNum)
-			}
-			resultsChan <- num
 		}(issue)
 	}
-
 	wg.Wait()
-	close(errChan)
-	close(resultsChan)
-
-	// Collect errors
-	// for err := range errChan {
-	//	fmt.Println(err)
-	// }
-
-	// Collect merge SHAs
-	for PRNumber := range resultsChan {
-		mu.Lock()
-		PRNumbers = append(PRNumbers, PRNumber)
-		mu.Unlock()
-	}
 
-	return PRNumbers
+	// Results arrive in goroutine order; keep the output stable between syncs
+	sort.Slice(linked, func(i, j int) bool {
+		if linked[i].Owner+"/"+linked[i].Repo != linked[j].Owner+"/"+linked[j].Repo {
+			return linked[i].Owner+"/"+linked[i].Repo < linked[j].Owner+"/"+linked[j].Repo
+		}
+		return linked[i].Number < linked[j].Number
+	})
+	return linked
 }
 
-func getPRLinkForIssue(domain string, issueID string) (*JiraPullRequestIdentifier, error) {
-	/// Get first the PRs (Jira calls them dev-status) for an issue
+// ParsePRURL splits a PR link like https://github.com/owner/repo/pull/12.
+func ParsePRURL(prURL string) (string, string, int, bool) {
+	parsed, err := url.Parse(prURL)
+	if err != nil {
+		return "", "", 0, false
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "pull" {
+		return "", "", 0, false
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return parts[0], parts[1], number, true
+}
+
+func getPRLinksForIssue(domain string, issueID string) ([]JiraPullRequestIdentifier, error) {
+	/// Get the PRs (Jira calls them dev-status) for an issue
 	jiraEmail, token := getAuth()
 	devURL := getDevURL(domain, issueID)
 
@@ -176,8 +216,7 @@ func getPRLinkForIssue(domain string, issueID string) (*JiraPullRequestIdentifie
 	req.SetBasicAuth(jiraEmail, token)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -192,14 +231,14 @@ func getPRLinkForIssue(domain string, issueID string) (*JiraPullRequestIdentifie
 		return nil, err
 	}
 
-	if len(data.Detail) == 0 || len(data.Detail[0].PullRequests) == 0 {
-		// fmt.Printf("No PR for issue: %s\n", issueID)
-		return nil, nil // Indicate no PR found without an error
+	prs := []JiraPullRequestIdentifier{}
+	for _, detail := range data.Detail {
+		prs = append(prs, detail.PullRequests...)
 	}
+	return prs, nil
+}
 
-	pr := data.Detail[0].PullRequests[0]
-	// fmt.Printf("URL: %s\n", pr.URL)
-	// fmt.Printf("Status: %s\n", pr.Status)
-
-	return &pr, nil
+// BrowseURL is the link to an issue in the Jira web UI.
+func BrowseURL(domain string, key string) string {
+	return strings.TrimSuffix(domain, "/") + "/browse/" + key
 }
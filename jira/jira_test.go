@@ -0,0 +1,103 @@
+package jira
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchIssuesAndLinkedPRs(t *testing.T) {
+	var jqls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/3/search/jql":
+			jqls = append(jqls, r.URL.Query().Get("jql"))
+			if r.URL.Query().Get("nextPageToken") == "" {
+				fmt.Fprint(w, `{"issues":[{"id":"1","key":"PROJ-1","fields":{"status":{"name":"In Review"},"assignee":{"displayName":"Jane"}}}],"nextPageToken":"p2"}`)
+				return
+			}
+			fmt.Fprint(w, `{"issues":[{"id":"2","key":"PROJ-2","fields":{"status":{"name":"To Do"},"assignee":null}}],"isLast":true}`)
+		case "/rest/dev-status/1.0/issue/details":
+			if r.URL.Query().Get("issueId") == "1" {
+				fmt.Fprint(w, `{"detail":[{"pullRequests":[
+				  {"url":"https://github.com/acme/web/pull/5"},
+				  {"url":"https://github.com/acme/api/pull/12"}]}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"detail":[{"pullRequests":[{"url":"https://github.com/other/lib/pull/3"}]}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	issues, err := SearchIssues(server.URL, "sprint in openSprints()")
+	if err != nil {
+		t.Fatalf("SearchIssues() error = %v", err)
+	}
+	if len(jqls) != 2 || jqls[1] != "sprint in openSprints()" {
+		t.Errorf("queries = %q, want two pages of the JQL", jqls)
+	}
+	if len(issues) != 2 || issues[0].Status() != "In Review" || issues[0].Assignee() != "Jane" || issues[1].Assignee() != "" {
+		t.Fatalf("issues = %+v", issues)
+	}
+
+	linked := GetLinkedPRs(server.URL, issues, []string{"acme/api", "ACME/web"})
+	got := []string{}
+	for _, pr := range linked {
+		got = append(got, fmt.Sprintf("%s %s/%s#%d", pr.Issue.Key, pr.Owner, pr.Repo, pr.Number))
+	}
+	want := []string{"PROJ-1 acme/api#12", "PROJ-1 acme/web#5"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetLinkedPRs() = %v, want %v", got, want)
+	}
+}
+
+func TestParsePRURL(t *testing.T) {
+	owner, repo, number, ok := ParsePRURL("https://github.com/acme/api/pull/12")
+	if !ok || owner != "acme" || repo != "api" || number != 12 {
+		t.Errorf("ParsePRURL() = %q, %q, %d, %v", owner, repo, number, ok)
+	}
+	for _, url := range []string{"https://github.com/acme/api/issues/12", "https://github.com/acme/api/pull/x", ""} {
+		if _, _, _, ok := ParsePRURL(url); ok {
+			t.Errorf("ParsePRURL(%q) ok = true, want false", url)
+		}
+	}
+}
+
+func TestGetIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/issue/PROJ-7" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"key":"PROJ-7","fields":{
+		  "summary":"Add widgets","status":{"name":"In Review"},"assignee":{"displayName":"Jane"},
+		  "fixVersions":[{"name":"2.4"}],"customfield_1":null,
+		  "description":"Widgets are missing.\n\nh3. Acceptance Criteria\n* Widgets render\n* Widgets sort\n\nh3. Notes\nNone"}}`)
+	}))
+	defer server.Close()
+
+	issue, err := GetIssue(server.URL, "PROJ-7", "customfield_1")
+	if err != nil {
+		t.Fatalf("GetIssue() error = %v", err)
+	}
+	if issue.Summary != "Add widgets" || issue.Status != "In Review" || issue.Assignee != "Jane" || fmt.Sprint(issue.FixVersions) != "[2.4]" {
+		t.Errorf("GetIssue() = %+v", issue)
+	}
+	if issue.URL != server.URL+"/browse/PROJ-7" {
+		t.Errorf("URL = %q", issue.URL)
+	}
+	// The custom field is empty, so the criteria come from the description
+	if issue.AcceptanceCriteria != "* Widgets render\n* Widgets sort" {
+		t.Errorf("AcceptanceCriteria = %q", issue.AcceptanceCriteria)
+	}
+}
+
+func TestFindIssueKeys(t *testing.T) {
+	got := FindIssueKeys("feature/PROJ-12-widgets", "PROJ-12: Add widgets (see OPS-3)", "lowercase proj-4 is not a key")
+	if fmt.Sprint(got) != "[PROJ-12 OPS-3]" {
+		t.Errorf("FindIssueKeys() = %v, want [PROJ-12 OPS-3]", got)
+	}
+}
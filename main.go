@@ -2,6 +2,7 @@ package main
 
 import (
 	"crs/config"
+	"crs/doctor"
 	"crs/logger"
 	"crs/server"
 	"crs/workflows"
@@ -9,6 +10,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 )
 
 func main() {
@@ -16,17 +18,34 @@ func main() {
 	slog.SetDefault(log)
 	slog.Info("starting")
 
+	oneOff := flag.Bool("oneoff", false, "Pass oneoff to only run once")
+	serverFlag := flag.Bool("server", false, "Run as an RPC server")
+	testFlag := flag.Bool("test", false, "Run in test mode")
+	listenFlag := flag.String("listen", "", "Serve RPC clients on a socket instead of stdio (unix:///path or tcp://host:port)")
+	webhookFlag := flag.String("webhook", "", "Receive GitHub webhooks on this address (e.g. :8080) for instant PR updates")
+	checkConfig := flag.Bool("check-config", false, "Validate the config, check credentials, repos, plugins and the database, then exit")
+	flag.Parse()
+
 	// Initialize configuration
 	if err := config.Initialize(); err != nil {
+		if *checkConfig {
+			fmt.Print(doctor.ConfigFailure(err))
+			os.Exit(1)
+		}
 		slog.Error("Failed to initialize configuration", "error", err)
 		os.Exit(1)
 	}
 	defer config.C.DB.Close()
 
-	oneOff := flag.Bool("oneoff", false, "Pass oneoff to only run once")
-	serverFlag := flag.Bool("server", false, "Run as an RPC server")
-	testFlag := flag.Bool("test", false, "Run in test mode")
-	flag.Parse()
+	if *checkConfig {
+		report := doctor.Run(&config.C)
+		fmt.Print(report)
+		if !report.OK() {
+			config.C.DB.Close()
+			os.Exit(1)
+		}
+		return
+	}
 
 	if *testFlag {
 		content, err := server.GetFullPRResponse("C-Hipple", "gtdbot", 9, false, nil)
@@ -38,10 +57,14 @@ func main() {
 		return
 	}
 
-	if *oneOff && *serverFlag {
+	if *oneOff && (*serverFlag || *listenFlag != "") {
 		slog.Error("Cannot run in both server and oneoff mode")
 		os.Exit(1)
 	}
+	if *oneOff && *webhookFlag != "" {
+		slog.Error("Cannot receive webhooks in oneoff mode")
+		os.Exit(1)
+	}
 
 	workflows_list := workflows.MatchWorkflows(config.C.RawWorkflows, &config.C.Repos, config.C.JiraDomain)
 	ms := workflows.NewManagerService(
@@ -50,8 +73,33 @@ func main() {
 		config.C.SleepDuration,
 	)
 	ms.Initialize()
+	server.ConfigReloader = func() error { return ms.Reload(log) }
+	if !*oneOff {
+		go config.Watch(2*time.Second, func() { ms.Reload(log) })
+	}
+
+	if *webhookFlag != "" {
+		secret := os.Getenv("CRS_WEBHOOK_SECRET")
+		if secret == "" {
+			slog.Error("-webhook requires CRS_WEBHOOK_SECRET to verify GitHub signatures")
+			os.Exit(1)
+		}
+		go func() {
+			refresh := func(repo string) { ms.RunForRepo(log, repo) }
+			if err := server.ListenWebhooks(log, *webhookFlag, secret, refresh); err != nil {
+				slog.Error("Error receiving webhooks", "webhook", *webhookFlag, "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
 
-	if *serverFlag {
+	if *listenFlag != "" {
+		go ms.Run(log)
+		if err := server.ListenAndServe(log, *listenFlag); err != nil {
+			slog.Error("Error serving RPC clients", "listen", *listenFlag, "error", err)
+			os.Exit(1)
+		}
+	} else if *serverFlag {
 		go ms.Run(log)
 		server.RunServer(log)
 	} else {
@@ -74,6 +74,9 @@ func (d *DBOrgDocument) DeleteItemInSection(sectionName string, itemToDelete Org
 				slog.Warn("Error deleting PR comments", "pr", prNumber, "repo", repo, "error", err)
 				// Continue even if cleanup fails
 			}
+			if err := d.DB.DeletePRReviewThreads(prNumber, repo); err != nil {
+				slog.Warn("Error deleting PR review threads", "pr", prNumber, "repo", repo, "error", err)
+			}
 			if err := d.DB.DeletePullRequests(prNumber, repo); err != nil {
 				slog.Warn("Error deleting PR diffs", "pr", prNumber, "repo", repo, "error", err)
 				// Continue even if cleanup fails
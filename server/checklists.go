@@ -0,0 +1,232 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/forge"
+	"crs/utils"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ChecklistJSON is a review checklist attached to a PR, with the state of its items.
+type ChecklistJSON struct {
+	Name  string              `json:"name"`
+	Items []ChecklistItemJSON `json:"items"`
+}
+
+type ChecklistItemJSON struct {
+	Text     string `json:"text"`
+	Required bool   `json:"required,omitempty"`
+	Checked  bool   `json:"checked"`
+	// Checked at an earlier head commit only; it counts as unchecked until checked again
+	Stale bool `json:"stale,omitempty"`
+}
+
+// matchingChecklists returns the checklists from the repo's .crs.toml and from the config that
+// apply to the PR. A repo checklist replaces a configured one of the same name.
+func matchingChecklists(owner, repo string, files, labels []string) []config.Checklist {
+	repoChecklists, err := config.LoadRepoChecklists(config.RepoDir(repo))
+	if err != nil {
+		slog.Error("Error loading repo checklists", "repo", repo, "error", err)
+	}
+	matching := []config.Checklist{}
+	for _, checklist := range append(repoChecklists, config.C.Checklists...) {
+		taken := slices.ContainsFunc(matching, func(c config.Checklist) bool { return c.Name == checklist.Name })
+		if !taken && checklist.Matches(owner, repo, files, labels) {
+			matching = append(matching, checklist)
+		}
+	}
+	return matching
+}
+
+// diffFiles returns the names of the files a diff touches, old and new for renames.
+func diffFiles(diff *utils.Diff) []string {
+	files := []string{}
+	if diff == nil {
+		return files
+	}
+	for _, f := range diff.Files {
+		for _, name := range []string{f.OrigName, f.NewName} {
+			if name != "" && name != "/dev/null" && !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+	return files
+}
+
+// prChecklists attaches the matching checklists to a PR whose diff is at head sha. Items count
+// as checked when they were checked at sha.
+func prChecklists(owner, repo string, number int, sha string, diff *utils.Diff, labels []string) []ChecklistJSON {
+	checklists := matchingChecklists(owner, repo, diffFiles(diff), labels)
+	result := []ChecklistJSON{}
+	if len(checklists) == 0 {
+		return result
+	}
+	checks, err := config.C.DB.GetChecklistChecks(owner, repo, number)
+	if err != nil {
+		slog.Error("Error loading checklist state", "pr", number, "repo", repo, "error", err)
+	}
+	for _, checklist := range checklists {
+		checklistJSON := ChecklistJSON{Name: checklist.Name, Items: []ChecklistItemJSON{}}
+		for _, item := range checklist.Items {
+			itemJSON := ChecklistItemJSON{Text: item.Text, Required: item.Required}
+			for _, check := range checks {
+				if check.Checklist != checklist.Name || check.Item != item.Text {
+					continue
+				}
+				if check.SHA == sha {
+					itemJSON.Checked = true
+				} else {
+					itemJSON.Stale = true
+				}
+			}
+			if itemJSON.Checked {
+				itemJSON.Stale = false
+			}
+			checklistJSON.Items = append(checklistJSON.Items, itemJSON)
+		}
+		result = append(result, checklistJSON)
+	}
+	return result
+}
+
+// checklistsForPR is prChecklists for the head the PR view last showed, the one GetPRDetails
+// stored with its diff. It fails when no head is stored yet, since checks need one to belong to.
+func checklistsForPR(owner, repo string, number int) ([]ChecklistJSON, string, error) {
+	rawDiff, sha, err := config.C.DB.GetPullRequest(number, owner, repo)
+	if err != nil {
+		return nil, "", err
+	}
+	if sha == "" {
+		return nil, "", fmt.Errorf("head commit of %s/%s#%d unknown; open the PR first", owner, repo, number)
+	}
+	diff, _ := utils.Parse(rawDiff)
+	var metadata PRMetadata
+	if cached, err := config.C.DB.GetPRMetadataCache(owner, repo, number); err == nil && cached != "" {
+		json.Unmarshal([]byte(cached), &metadata)
+	}
+	return prChecklists(owner, repo, number, sha, diff, metadata.Labels), sha, nil
+}
+
+// uncheckedRequired lists the required items that aren't checked at the current head.
+func uncheckedRequired(checklists []ChecklistJSON) []string {
+	unchecked := []string{}
+	for _, checklist := range checklists {
+		for _, item := range checklist.Items {
+			if item.Required && !item.Checked {
+				unchecked = append(unchecked, checklist.Name+": "+item.Text)
+			}
+		}
+	}
+	return unchecked
+}
+
+// checkRequiredItems returns an error naming the PR's unchecked required items, if any. Items
+// must be checked at the head GitHub would approve, so a push the PR view hasn't shown yet
+// also refuses.
+func checkRequiredItems(owner, repo string, number int) error {
+	repoChecklists, _ := config.LoadRepoChecklists(config.RepoDir(repo))
+	if len(repoChecklists) == 0 && len(config.C.Checklists) == 0 {
+		return nil
+	}
+	checklists, sha, err := checklistsForPR(owner, repo, number)
+	if err != nil {
+		return err
+	}
+	// New commits can also bring new checklists along, so this comes first
+	pr, err := forge.For(owner, repo).GetPR(owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("checking the head commit of %s/%s#%d: %w", owner, repo, number, err)
+	}
+	if pr.GetHead().GetSHA() != sha {
+		return fmt.Errorf("%s/%s#%d has new commits; sync it and check its checklists again", owner, repo, number)
+	}
+	if unchecked := uncheckedRequired(checklists); len(unchecked) > 0 {
+		return fmt.Errorf("%d required checklist item(s) unchecked: %s", len(unchecked), strings.Join(unchecked, "; "))
+	}
+	return nil
+}
+
+// writeChecklistsDrawer lists a PR's checklists as org checkboxes in a :CHECKLISTS: drawer.
+func writeChecklistsDrawer(sb *strings.Builder, checklists []ChecklistJSON) {
+	if len(checklists) == 0 {
+		return
+	}
+	sb.WriteString(":CHECKLISTS:\n")
+	for _, checklist := range checklists {
+		done := 0
+		for _, item := range checklist.Items {
+			if item.Checked {
+				done++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%s [%d/%d]\n", checklist.Name, done, len(checklist.Items)))
+		for _, item := range checklist.Items {
+			box := "[ ]"
+			if item.Checked {
+				box = "[X]"
+			}
+			line := fmt.Sprintf("- %s %s", box, item.Text)
+			if item.Required {
+				line += " (required)"
+			}
+			if item.Stale {
+				line += " (checked at an earlier commit)"
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+	sb.WriteString(":END:\n")
+}
+
+type ToggleChecklistItemArgs struct {
+	Owner     string `json:"Owner"`
+	Repo      string `json:"Repo"`
+	Number    int    `json:"Number"`
+	Checklist string `json:"Checklist"` // Checklist name
+	Item      string `json:"Item"`      // Item text
+}
+
+type ToggleChecklistItemReply struct {
+	Checked    bool            `json:"checked"`
+	Checklists []ChecklistJSON `json:"checklists"`
+}
+
+// ToggleChecklistItem checks or unchecks an item of one of the PR's checklists at its current
+// head. An item checked only at an earlier head is checked again.
+func (h *RPCHandler) ToggleChecklistItem(args *ToggleChecklistItemArgs, reply *ToggleChecklistItemReply) error {
+	checklists, sha, err := checklistsForPR(args.Owner, args.Repo, args.Number)
+	if err != nil {
+		return err
+	}
+	var item *ChecklistItemJSON
+	for i := range checklists {
+		if checklists[i].Name != args.Checklist {
+			continue
+		}
+		for j := range checklists[i].Items {
+			if checklists[i].Items[j].Text == args.Item {
+				item = &checklists[i].Items[j]
+			}
+		}
+	}
+	if item == nil {
+		return fmt.Errorf("no checklist item %q in %q on %s/%s#%d", args.Item, args.Checklist, args.Owner, args.Repo, args.Number)
+	}
+
+	checked := !item.Checked
+	check := database.ChecklistCheck{SHA: sha, Checklist: args.Checklist, Item: args.Item, CheckedAt: time.Now().Unix()}
+	if err := config.C.DB.SetChecklistItem(args.Owner, args.Repo, args.Number, check, checked); err != nil {
+		h.Log.Error("Error storing checklist item", "error", err)
+		return err
+	}
+	reply.Checked = checked
+	reply.Checklists, _, _ = checklistsForPR(args.Owner, args.Repo, args.Number)
+	return nil
+}
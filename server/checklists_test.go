@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/testutil"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPRChecklists(t *testing.T) {
+	db := testutil.NewDB(t)
+	location := t.TempDir()
+	if err := os.Mkdir(filepath.Join(location, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repoConfig := "[[Checklists]]\nName = \"Migrations\"\nPaths = [\"db/**\"]\nItems = [{ Text = \"Reversible\", Required = true }, { Text = \"Batched\" }]\n"
+	if err := os.WriteFile(filepath.Join(location, "api", config.RepoConfigFile), []byte(repoConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.C = config.Config{
+		DB:           db,
+		RepoLocation: location,
+		Checklists: []config.Checklist{
+			{Name: "Docs", Labels: []string{"api-change"}, Items: []config.ChecklistItem{{Text: "Docs updated", Required: true}}},
+			{Name: "Web", Repos: []string{"org/web"}, Items: []config.ChecklistItem{{Text: "Screenshots"}}},
+		},
+	}
+
+	diff := mustParseDiff(t, "diff --git a/db/0001.sql b/db/0001.sql\nnew file mode 100644\n--- /dev/null\n+++ b/db/0001.sql\n@@ -0,0 +1,1 @@\n+CREATE TABLE t (id INT);\n")
+	for _, check := range []database.ChecklistCheck{
+		{SHA: "old", Checklist: "Migrations", Item: "Reversible"},
+		{SHA: "head", Checklist: "Migrations", Item: "Batched"},
+	} {
+		if err := db.SetChecklistItem("org", "api", 7, check, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checklists := prChecklists("org", "api", 7, "head", diff, []string{"api-change"})
+	if len(checklists) != 2 || checklists[0].Name != "Migrations" || checklists[1].Name != "Docs" {
+		t.Fatalf("prChecklists() = %+v, want Migrations and Docs", checklists)
+	}
+	reversible, batched := checklists[0].Items[0], checklists[0].Items[1]
+	if reversible.Checked || !reversible.Stale || !batched.Checked || batched.Stale {
+		t.Errorf("items = %+v, want Reversible stale and Batched checked", checklists[0].Items)
+	}
+	unchecked := uncheckedRequired(checklists)
+	if len(unchecked) != 2 || unchecked[0] != "Migrations: Reversible" || unchecked[1] != "Docs: Docs updated" {
+		t.Errorf("uncheckedRequired() = %q", unchecked)
+	}
+
+	var sb strings.Builder
+	writeChecklistsDrawer(&sb, checklists[:1])
+	want := ":CHECKLISTS:\nMigrations [1/2]\n- [ ] Reversible (required) (checked at an earlier commit)\n- [X] Batched\n:END:\n"
+	if sb.String() != want {
+		t.Errorf("writeChecklistsDrawer() = %q, want %q", sb.String(), want)
+	}
+
+	if got := prChecklists("org", "api", 7, "head", nil, nil); len(got) != 0 {
+		t.Errorf("prChecklists() without matching files or labels = %+v, want none", got)
+	}
+}
+
+func TestChecklistsAtStoredHead(t *testing.T) {
+	db := testutil.NewDB(t)
+	head := "head1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"iid":7,"state":"opened","sha":%q}`, head)
+	}))
+	defer server.Close()
+	config.C = config.Config{
+		DB:           db,
+		RepoLocation: t.TempDir(),
+		Repos:        []string{"gitlab:org/api"},
+		GitlabURL:    server.URL,
+		Checklists:   []config.Checklist{{Name: "Release", Items: []config.ChecklistItem{{Text: "Changelog", Required: true}}}},
+	}
+	h := &RPCHandler{Log: slog.Default()}
+	args := &ToggleChecklistItemArgs{Owner: "org", Repo: "api", Number: 7, Checklist: "Release", Item: "Changelog"}
+
+	// Checks need a head to belong to, so nothing is checked or approved before the PR is opened
+	if err := h.ToggleChecklistItem(args, &ToggleChecklistItemReply{}); err == nil {
+		t.Error("ToggleChecklistItem() without a stored head error = nil")
+	}
+	if err := checkRequiredItems("org", "api", 7); err == nil {
+		t.Error("checkRequiredItems() without a stored head error = nil")
+	}
+
+	if err := db.UpsertPullRequest(7, "org", "api", "head1", diffHeader+"@@ -1,2 +1,3 @@\n a\n+b\n c\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRequiredItems("org", "api", 7); err == nil || !strings.Contains(err.Error(), "Release: Changelog") {
+		t.Errorf("checkRequiredItems() error = %v, want the unchecked Changelog", err)
+	}
+	var reply ToggleChecklistItemReply
+	if err := h.ToggleChecklistItem(args, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Checked || len(reply.Checklists) != 1 || !reply.Checklists[0].Items[0].Checked {
+		t.Errorf("ToggleChecklistItem() = %+v, want Changelog checked", reply)
+	}
+	// The PR view shows the same head the item was checked at
+	if got := prChecklists("org", "api", 7, "head1", nil, nil); !got[0].Items[0].Checked || got[0].Items[0].Stale {
+		t.Errorf("prChecklists() at the stored head = %+v, want Changelog checked", got)
+	}
+	if err := checkRequiredItems("org", "api", 7); err != nil {
+		t.Errorf("checkRequiredItems() error = %v, want nil", err)
+	}
+
+	head = "head2"
+	if err := checkRequiredItems("org", "api", 7); err == nil || !strings.Contains(err.Error(), "new commits") {
+		t.Errorf("checkRequiredItems() after a push error = %v, want new commits", err)
+	}
+}
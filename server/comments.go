@@ -0,0 +1,109 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/forge"
+	"crs/utils"
+
+	"github.com/google/go-github/v48/github"
+)
+
+const (
+	sideLeft  = "LEFT"
+	sideRight = "RIGHT"
+)
+
+// sideOf reports which side of the diff a line belongs to. Removed lines only exist in the
+// original file; everything else is addressed by its number in the new file.
+func sideOf(line *utils.DiffLine) string {
+	if line.Mode == utils.REMOVED {
+		return sideLeft
+	}
+	return sideRight
+}
+
+// resolveCommentAnchor works out the line/side anchor for a new comment. Clients point at
+// diff positions (and an optional start position for ranges); these are translated to file
+// lines because positions break as soon as the diff changes. Clients may also send lines
+// directly, in which case the position is derived so the comment still renders inline.
+func resolveCommentAnchor(diff *utils.Diff, filename string, position, startPosition int64, anchor database.CommentAnchor) (int64, database.CommentAnchor) {
+	if diff == nil {
+		return position, anchor
+	}
+	if startPosition > position {
+		startPosition, position = position, startPosition
+	}
+
+	if anchor.Line == 0 && position > 0 {
+		if line := diff.LineAtPosition(filename, int(position)); line != nil {
+			anchor.Line = int64(line.Number)
+			anchor.Side = sideOf(line)
+		}
+	}
+	if anchor.Line != 0 && anchor.Side == "" {
+		anchor.Side = sideRight
+	}
+	if position == 0 && anchor.Line != 0 {
+		position = int64(diff.PositionOfLine(filename, int(anchor.Line), anchor.Side == sideLeft))
+	}
+
+	if anchor.StartLine == 0 && startPosition > 0 && startPosition != position {
+		if line := diff.LineAtPosition(filename, int(startPosition)); line != nil {
+			anchor.StartLine = int64(line.Number)
+			anchor.StartSide = sideOf(line)
+		}
+	}
+	if anchor.StartLine != 0 && anchor.StartSide == "" {
+		anchor.StartSide = anchor.Side
+	}
+	if anchor.StartLine == anchor.Line && anchor.StartSide == anchor.Side {
+		// A range of one line is just a single-line comment; GitHub rejects start_line == line.
+		anchor.StartLine, anchor.StartSide = 0, ""
+	}
+	return position, anchor
+}
+
+// loadPRDiff returns the parsed diff for a PR and the head SHA it belongs to, preferring the
+// copy cached by GetPRDetails since that is what the client is looking at.
+func loadPRDiff(owner, repo string, number int) (*utils.Diff, string) {
+	diff, sha, err := config.C.DB.GetPullRequest(number, owner, repo)
+	if err != nil || diff == "" {
+		diff, _ = forge.For(owner, repo).GetDiff(owner, repo, number)
+		sha = ""
+	}
+	parsed, err := utils.Parse(diff)
+	if err != nil {
+		return nil, sha
+	}
+	return parsed, sha
+}
+
+// draftReviewComment builds the GitHub review comment for a top-level local comment, using
+// line/side anchoring when available and falling back to the deprecated diff position for
+// comments stored before anchors existed.
+func draftReviewComment(c database.LocalComment) *github.DraftReviewComment {
+	path := c.Filename
+	body := ""
+	if c.Body != nil {
+		body = *c.Body
+	}
+	draft := &github.DraftReviewComment{Path: &path, Body: &body}
+	if c.Line == 0 {
+		pos := int(c.Position)
+		draft.Position = &pos
+		return draft
+	}
+
+	line := int(c.Line)
+	side := c.Side
+	draft.Line = &line
+	draft.Side = &side
+	if c.StartLine != 0 {
+		startLine := int(c.StartLine)
+		startSide := c.StartSide
+		draft.StartLine = &startLine
+		draft.StartSide = &startSide
+	}
+	return draft
+}
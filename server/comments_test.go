@@ -0,0 +1,228 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/events"
+	"crs/forge"
+	"crs/git_tools"
+	"crs/testutil"
+	"crs/utils"
+	"crs/workflows"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const anchorTestDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,5 @@
+ package main
+-var a = 1
++var a = 2
++var z = 0
+ var b = 3
+ var c = 4
+`
+
+func TestResolveCommentAnchor(t *testing.T) {
+	diff, err := utils.Parse(anchorTestDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		position      int64
+		startPosition int64
+		anchor        database.CommentAnchor
+		wantPosition  int64
+		wantAnchor    database.CommentAnchor
+	}{
+		{
+			name:         "added line",
+			position:     3,
+			wantPosition: 3,
+			wantAnchor:   database.CommentAnchor{Line: 2, Side: "RIGHT"},
+		},
+		{
+			name:         "removed line is on the left",
+			position:     2,
+			wantPosition: 2,
+			wantAnchor:   database.CommentAnchor{Line: 2, Side: "LEFT"},
+		},
+		{
+			name:          "range across sides",
+			position:      4,
+			startPosition: 2,
+			wantPosition:  4,
+			wantAnchor:    database.CommentAnchor{Line: 3, Side: "RIGHT", StartLine: 2, StartSide: "LEFT"},
+		},
+		{
+			name:          "reversed range is normalized",
+			position:      3,
+			startPosition: 5,
+			wantPosition:  5,
+			wantAnchor:    database.CommentAnchor{Line: 4, Side: "RIGHT", StartLine: 2, StartSide: "RIGHT"},
+		},
+		{
+			name:         "explicit line derives the position",
+			anchor:       database.CommentAnchor{Line: 3},
+			wantPosition: 4,
+			wantAnchor:   database.CommentAnchor{Line: 3, Side: "RIGHT"},
+		},
+		{
+			name:         "position outside the diff keeps the legacy position",
+			position:     42,
+			wantPosition: 42,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, anchor := resolveCommentAnchor(diff, "main.go", tt.position, tt.startPosition, tt.anchor)
+			if position != tt.wantPosition {
+				t.Errorf("position = %d, want %d", position, tt.wantPosition)
+			}
+			if anchor != tt.wantAnchor {
+				t.Errorf("anchor = %+v, want %+v", anchor, tt.wantAnchor)
+			}
+		})
+	}
+}
+
+func TestDraftReviewComment(t *testing.T) {
+	body := "nit"
+	legacy := draftReviewComment(database.LocalComment{Filename: "main.go", Position: 7, Body: &body})
+	if legacy.GetPosition() != 7 || legacy.Line != nil {
+		t.Errorf("legacy comment should use position, got %+v", legacy)
+	}
+
+	ranged := draftReviewComment(database.LocalComment{
+		Filename:      "main.go",
+		Position:      7,
+		Body:          &body,
+		CommentAnchor: database.CommentAnchor{Line: 10, Side: "RIGHT", StartLine: 8, StartSide: "RIGHT"},
+	})
+	if ranged.Position != nil {
+		t.Errorf("anchored comment should not send a position, got %d", ranged.GetPosition())
+	}
+	if ranged.GetLine() != 10 || ranged.GetSide() != "RIGHT" || ranged.GetStartLine() != 8 || ranged.GetStartSide() != "RIGHT" {
+		t.Errorf("anchored comment = %+v", ranged)
+	}
+}
+
+// compareForge records the heads re-anchoring compares and answers with an empty diff.
+type compareForge struct {
+	forge.Forge
+	bases []string
+}
+
+func (f *compareForge) CompareDiff(owner, repo, base, head string) (string, error) {
+	f.bases = append(f.bases, base)
+	return "", nil
+}
+
+func TestLoadPRDiffAfterPush(t *testing.T) {
+	db := testutil.NewDB(t)
+	config.C = config.Config{DB: db}
+
+	// The older head sorts and was stored first, so a lookup that ignores which head is current finds it
+	oldDiff := anchorTestDiff
+	newDiff := diffHeader + "@@ -1,2 +1,3 @@\n a\n+b\n c\n"
+	for _, head := range []struct{ sha, diff string }{{"aaa111", oldDiff}, {"fff222", newDiff}} {
+		if err := db.UpsertPullRequest(7, "org", "api", head.sha, head.diff); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.UpsertPullRequest(7, "fork", "api", "ccc333", oldDiff); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, sha := loadPRDiff("org", "api", 7)
+	if sha != "fff222" || diff == nil || diff.PositionOfLine("main.go", 2, false) != 2 {
+		t.Fatalf("loadPRDiff() = %v, %q, want the diff at head fff222", diff, sha)
+	}
+	position, anchor := resolveCommentAnchor(diff, "main.go", 2, 0, database.CommentAnchor{})
+	if position != 2 || anchor.Line != 2 {
+		t.Errorf("resolveCommentAnchor() = %d, %+v, want line 2 of the current diff", position, anchor)
+	}
+
+	// Drafts from before commit tracking re-anchor from the head stored last
+	if _, err := db.InsertLocalComment(database.LocalComment{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Position: 2}); err != nil {
+		t.Fatal(err)
+	}
+	_, previousSHA, _ := db.GetPullRequest(7, "org", "api")
+	f := &compareForge{}
+	reanchorLocalComments(f, "org", "api", 7, previousSHA, "ddd444", diff, diff)
+	if len(f.bases) != 1 || f.bases[0] != "fff222" {
+		t.Errorf("compared from %v, want the previous head fff222", f.bases)
+	}
+}
+
+func TestSyncThenOpenAfterPush(t *testing.T) {
+	oldDiff := "@@ -1,2 +1,3 @@\n a\n+b\n c\n"
+	newDiff := "@@ -1,2 +1,4 @@\n+x\n a\n+b\n c\n"
+	gitlab := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/repository/compare"):
+			fmt.Fprintf(w, `{"diffs":[{"old_path":"main.go","new_path":"main.go","diff":%q}]}`, "@@ -1,3 +1,4 @@\n+x\n a\n b\n c\n")
+		case strings.HasSuffix(r.URL.Path, "/diffs"):
+			fmt.Fprintf(w, `[{"old_path":"main.go","new_path":"main.go","diff":%q}]`, newDiff)
+		case strings.HasSuffix(r.URL.Path, "/merge_requests/7"):
+			w.Write([]byte(`{"iid":7,"state":"opened","sha":"head2","source_branch":"feature",
+			  "diff_refs":{"base_sha":"base","head_sha":"head2","start_sha":"base"}}`))
+		case strings.HasSuffix(r.URL.Path, "/approvals"):
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer gitlab.Close()
+
+	db := testutil.NewDB(t)
+	t.Cleanup(func() { git_tools.ForgetPRState("org", "api", []int{7}) })
+	t.Setenv("CRS_GITLAB_TOKEN", "token")
+	config.C = config.Config{DB: db, Repos: []string{"gitlab:org/api"}, GitlabURL: gitlab.URL}
+
+	if err := db.UpsertPullRequest(7, "org", "api", "head1", diffHeader+oldDiff); err != nil {
+		t.Fatal(err)
+	}
+	// Only known by its position in the old diff, so it can't be re-anchored without it
+	local, err := db.InsertLocalComment(database.LocalComment{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Position: 2, CommitSHA: "head1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []events.PRUpdatedParams
+	unsubscribe := events.Subscribe(func(method string, params interface{}) {
+		if method == events.PRUpdated {
+			updates = append(updates, params.(events.PRUpdatedParams))
+		}
+	})
+	defer unsubscribe()
+
+	pr, err := forge.NewGitLab().GetPR("org", "api", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflows.PRToOrgBridge{PR: pr, IncludeDiff: true}.Details()
+	if _, err := GetPRDetails("org", "api", 7, true); err != nil {
+		t.Fatalf("GetPRDetails() error = %v", err)
+	}
+
+	if len(updates) != 1 || updates[0].PreviousSHA != "head1" || updates[0].SHA != "head2" {
+		t.Errorf("pr/updated = %+v, want one from head1 to head2", updates)
+	}
+	comment, err := db.GetLocalComment(local.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment.Orphaned || comment.CommitSHA != "head2" || comment.Line != 3 {
+		t.Errorf("comment = orphaned %v at %s line %d, want line 3 at head2", comment.Orphaned, comment.CommitSHA, comment.Line)
+	}
+}
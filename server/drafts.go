@@ -0,0 +1,274 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/forge"
+	"crs/git_tools"
+	"crs/utils"
+	"log/slog"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// When config.C.SyncDraftReviews is set, top-level local comments are mirrored into the user's
+// pending review on GitHub. The pending review is the source of truth: edits made in the web UI
+// or on another machine win over the local copy. Replies stay local until SubmitReview, because
+// GitHub does not allow replies inside a pending review.
+
+// syncsDraftReview reports whether a PR's local comments are mirrored into a pending review.
+// GitLab has no pending reviews, so its comments stay local until SubmitReview.
+func syncsDraftReview(owner, repo string) bool {
+	return config.C.SyncDraftReviews && !forge.IsGitLab(owner, repo)
+}
+
+type draftReconcilePlan struct {
+	Updates map[int64]string // local comment ID -> body from GitHub
+	Deletes []int64          // local comment IDs whose mirrored comment is gone
+	Inserts []*github.PullRequestComment
+}
+
+// planDraftReconcile compares the local comments with the comments in the pending review.
+// remote is nil when there is no pending review, which means it was submitted or discarded elsewhere.
+func planDraftReconcile(locals []database.LocalComment, remote []*github.PullRequestComment) draftReconcilePlan {
+	plan := draftReconcilePlan{Updates: make(map[int64]string)}
+
+	remoteByID := make(map[int64]*github.PullRequestComment)
+	for _, c := range remote {
+		remoteByID[c.GetID()] = c
+	}
+
+	known := make(map[int64]bool)
+	for _, local := range locals {
+		if local.GithubCommentID == nil {
+			continue
+		}
+		known[*local.GithubCommentID] = true
+		c, ok := remoteByID[*local.GithubCommentID]
+		if !ok {
+			plan.Deletes = append(plan.Deletes, local.ID)
+			continue
+		}
+		if local.Body == nil || *local.Body != c.GetBody() {
+			plan.Updates[local.ID] = c.GetBody()
+		}
+	}
+
+	for _, c := range remote {
+		if !known[c.GetID()] {
+			plan.Inserts = append(plan.Inserts, c)
+		}
+	}
+	return plan
+}
+
+// reconcileDraftReview pulls changes to the pending review into LocalComment and returns the
+// pending review, or nil if there is none.
+func reconcileDraftReview(client *github.Client, owner, repo string, number int) (*github.PullRequestReview, error) {
+	pending, err := git_tools.GetPendingReview(client, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var remote []*github.PullRequestComment
+	if pending != nil {
+		remote, err = git_tools.GetPendingReviewComments(client, owner, repo, number, pending.GetID())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	locals, err := config.C.DB.GetLocalCommentsForPR(owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := planDraftReconcile(locals, remote)
+	for id, body := range plan.Updates {
+		if err := config.C.DB.UpdateLocalComment(id, body); err != nil {
+			slog.Error("Error updating local comment from pending review", "id", id, "error", err)
+		}
+	}
+	for _, id := range plan.Deletes {
+		if err := config.C.DB.DeleteLocalComment(id); err != nil {
+			slog.Error("Error deleting local comment removed from pending review", "id", id, "error", err)
+		}
+	}
+	var diff *utils.Diff
+	var diffSHA string
+	diffLoaded := false
+	for _, c := range plan.Inserts {
+		position := c.GetPosition()
+		if position == 0 {
+			position = c.GetOriginalPosition()
+		}
+		body := c.GetBody()
+		githubID := c.GetID()
+		kind := database.CommentKindComment
+		originalLines := ""
+		if hasSuggestion(body) {
+			kind = database.CommentKindSuggestion
+			if !diffLoaded {
+				diff, diffSHA = loadPRDiff(owner, repo, number)
+				diffLoaded = true
+			}
+			originalLines = importedSuggestionOriginal(diff, diffSHA, c)
+		}
+		_, err := config.C.DB.InsertLocalComment(database.LocalComment{
+			Owner:           owner,
+			Repo:            repo,
+			Number:          number,
+			Filename:        c.GetPath(),
+			Position:        int64(position),
+			Body:            &body,
+			GithubCommentID: &githubID,
+			CommentAnchor: database.CommentAnchor{
+				Line:      int64(c.GetLine()),
+				Side:      c.GetSide(),
+				StartLine: int64(c.GetStartLine()),
+				StartSide: c.GetStartSide(),
+			},
+			CommitSHA:     c.GetCommitID(),
+			Kind:          kind,
+			OriginalLines: originalLines,
+		})
+		if err != nil {
+			slog.Error("Error inserting local comment from pending review", "github_id", githubID, "error", err)
+		}
+	}
+
+	return pending, nil
+}
+
+// matchDraftComments pairs local comments with the comments GitHub created for them, by path,
+// anchor and body. Each remote comment is used at most once so duplicates pair up in order.
+func matchDraftComments(locals []database.LocalComment, remote []*github.PullRequestComment) map[int64]int64 {
+	matches := make(map[int64]int64)
+	used := make(map[int64]bool)
+	for _, local := range locals {
+		if local.Body == nil {
+			continue
+		}
+		for _, c := range remote {
+			if used[c.GetID()] {
+				continue
+			}
+			sameAnchor := int64(c.GetPosition()) == local.Position
+			if local.Line != 0 {
+				sameAnchor = int64(c.GetLine()) == local.Line && c.GetSide() == local.Side
+			}
+			if c.GetPath() == local.Filename && sameAnchor && c.GetBody() == *local.Body {
+				matches[local.ID] = c.GetID()
+				used[c.GetID()] = true
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// pushDraftReview makes sure every top-level local comment is part of the pending review.
+// The REST API cannot add a comment to an existing pending review, so when something new needs
+// to go up the review is recreated with all comments and its body preserved.
+func pushDraftReview(client *github.Client, owner, repo string, number int) error {
+	// Re-anchoring may push on its own, so it goes before reading the pending review
+	headSHA, err := anchorToHead(&forge.GitHub{Client: client}, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	pending, err := reconcileDraftReview(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+
+	locals, err := config.C.DB.GetLocalCommentsForPR(owner, repo, number)
+	if err != nil {
+		return err
+	}
+
+	var topLevel []database.LocalComment
+	var drafts []*github.DraftReviewComment
+	needsPush := false
+	for _, c := range locals {
+		if c.Body == nil || c.ReplyToID != nil || c.Orphaned {
+			continue
+		}
+		if c.GithubCommentID == nil {
+			needsPush = true
+		}
+		topLevel = append(topLevel, c)
+		drafts = append(drafts, draftReviewComment(c))
+	}
+	if !needsPush {
+		return nil
+	}
+
+	reviewBody := ""
+	if pending != nil {
+		reviewBody = pending.GetBody()
+		// Unlink the comments before their review goes away. Should the new review fail to
+		// be created, the next reconcile then pushes them again instead of taking the missing
+		// review as submitted elsewhere and deleting them.
+		err := unlinkDraftComments(topLevel)
+		if err == nil {
+			err = git_tools.DeletePendingReview(client, owner, repo, number, pending.GetID())
+		}
+		if err != nil {
+			// The review and its comments are still there, so link them back up
+			for _, c := range topLevel {
+				if c.GithubCommentID != nil {
+					config.C.DB.SetLocalCommentGithubID(c.ID, c.GithubCommentID)
+				}
+			}
+			return err
+		}
+	}
+
+	created, err := git_tools.CreatePendingReview(client, owner, repo, number, headSHA, reviewBody, drafts)
+	if err != nil {
+		return err
+	}
+	remote, err := git_tools.GetPendingReviewComments(client, owner, repo, number, created.GetID())
+	if err != nil {
+		return err
+	}
+
+	matches := matchDraftComments(topLevel, remote)
+	for _, c := range topLevel {
+		githubID, ok := matches[c.ID]
+		if !ok {
+			slog.Warn("Could not find pending review comment for local comment", "id", c.ID)
+			continue
+		}
+		if err := config.C.DB.SetLocalCommentGithubID(c.ID, &githubID); err != nil {
+			slog.Error("Error linking local comment to pending review", "id", c.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// unlinkDraftComments forgets the pending review comments that mirror the given local comments.
+func unlinkDraftComments(locals []database.LocalComment) error {
+	for _, c := range locals {
+		if c.GithubCommentID == nil {
+			continue
+		}
+		if err := config.C.DB.SetLocalCommentGithubID(c.ID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterPendingReviewComments drops comments that belong to the pending review, since those are
+// already shown through their LocalComment mirror.
+func filterPendingReviewComments(comments []*github.PullRequestComment, pendingReviewID int64) []*github.PullRequestComment {
+	var filtered []*github.PullRequestComment
+	for _, c := range comments {
+		if c.GetPullRequestReviewID() == pendingReviewID {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
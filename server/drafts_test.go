@@ -0,0 +1,152 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/testutil"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func localComment(id int64, body string, githubID *int64) database.LocalComment {
+	return database.LocalComment{ID: id, Filename: "main.go", Position: 3, Body: &body, GithubCommentID: githubID}
+}
+
+func remoteComment(id int64, body string) *github.PullRequestComment {
+	return &github.PullRequestComment{ID: github.Int64(id), Path: github.String("main.go"), Position: github.Int(3), Body: github.String(body)}
+}
+
+func TestPlanDraftReconcile(t *testing.T) {
+	locals := []database.LocalComment{
+		localComment(1, "unchanged", github.Int64(101)),
+		localComment(2, "old body", github.Int64(102)),
+		localComment(3, "deleted on github", github.Int64(103)),
+		localComment(4, "not pushed yet", nil),
+	}
+	remote := []*github.PullRequestComment{
+		remoteComment(101, "unchanged"),
+		remoteComment(102, "edited in the web UI"),
+		remoteComment(105, "added on another machine"),
+	}
+
+	plan := planDraftReconcile(locals, remote)
+
+	if len(plan.Updates) != 1 || plan.Updates[2] != "edited in the web UI" {
+		t.Errorf("Updates = %v, want only comment 2 updated", plan.Updates)
+	}
+	if len(plan.Deletes) != 1 || plan.Deletes[0] != 3 {
+		t.Errorf("Deletes = %v, want [3]", plan.Deletes)
+	}
+	if len(plan.Inserts) != 1 || plan.Inserts[0].GetID() != 105 {
+		t.Errorf("Inserts = %v, want comment 105", plan.Inserts)
+	}
+}
+
+func TestPlanDraftReconcile_NoPendingReview(t *testing.T) {
+	locals := []database.LocalComment{
+		localComment(1, "mirrored", github.Int64(101)),
+		localComment(2, "local only", nil),
+	}
+
+	plan := planDraftReconcile(locals, nil)
+
+	// The review was submitted or discarded elsewhere, so only the mirrored comment goes away.
+	if len(plan.Deletes) != 1 || plan.Deletes[0] != 1 {
+		t.Errorf("Deletes = %v, want [1]", plan.Deletes)
+	}
+	if len(plan.Updates) != 0 || len(plan.Inserts) != 0 {
+		t.Errorf("unexpected updates %v or inserts %v", plan.Updates, plan.Inserts)
+	}
+}
+
+func TestMatchDraftComments(t *testing.T) {
+	locals := []database.LocalComment{
+		localComment(1, "same", nil),
+		localComment(2, "same", nil),
+		localComment(3, "different", nil),
+	}
+	remote := []*github.PullRequestComment{
+		remoteComment(201, "different"),
+		remoteComment(202, "same"),
+		remoteComment(203, "same"),
+	}
+
+	matches := matchDraftComments(locals, remote)
+
+	want := map[int64]int64{1: 202, 2: 203, 3: 201}
+	for local, githubID := range want {
+		if matches[local] != githubID {
+			t.Errorf("matches[%d] = %d, want %d", local, matches[local], githubID)
+		}
+	}
+}
+
+func TestFilterPendingReviewComments(t *testing.T) {
+	comments := []*github.PullRequestComment{
+		{ID: github.Int64(1), PullRequestReviewID: github.Int64(10)},
+		{ID: github.Int64(2), PullRequestReviewID: github.Int64(99)},
+	}
+
+	filtered := filterPendingReviewComments(comments, 99)
+
+	if len(filtered) != 1 || filtered[0].GetID() != 1 {
+		t.Errorf("filterPendingReviewComments() = %v, want only comment 1", filtered)
+	}
+}
+
+func TestPushDraftReview_CreateFails(t *testing.T) {
+	db := testutil.NewDB(t)
+	config.C = config.Config{DB: db, SyncDraftReviews: true}
+
+	for _, c := range []database.LocalComment{
+		{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Position: 3, Body: github.String("mirrored"), GithubCommentID: github.Int64(101), CommitSHA: "head1"},
+		{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Position: 3, Body: github.String("not pushed yet"), CommitSHA: "head1"},
+	} {
+		if _, err := db.InsertLocalComment(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending := true
+	client := testutil.NewGithubClient(t, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7":
+			fmt.Fprint(w, `{"number":7,"head":{"sha":"head1"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7/reviews":
+			if pending {
+				fmt.Fprint(w, `[{"id":1,"state":"PENDING","body":"overall"}]`)
+			} else {
+				fmt.Fprint(w, `[]`)
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7/reviews/1/comments":
+			fmt.Fprint(w, `[{"id":101,"path":"main.go","position":3,"body":"mirrored"}]`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/repos/org/api/pulls/7/reviews/1":
+			pending = false
+			fmt.Fprint(w, `{"id":1}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/org/api/pulls/7/reviews":
+			http.Error(w, `{"message":"Server Error"}`, http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	if err := pushDraftReview(client, "org", "api", 7); err == nil {
+		t.Fatal("pushDraftReview() error = nil, want the failed create")
+	}
+	// With the pending review gone, the next sync must not take the drafts as submitted elsewhere
+	if _, err := reconcileDraftReview(client, "org", "api", 7); err != nil {
+		t.Fatal(err)
+	}
+	locals, _ := db.GetLocalCommentsForPR("org", "api", 7)
+	if len(locals) != 2 {
+		t.Fatalf("local comments = %d, want both drafts kept", len(locals))
+	}
+	for _, c := range locals {
+		if c.GithubCommentID != nil {
+			t.Errorf("comment %d still linked to %d, want it pushed again", c.ID, *c.GithubCommentID)
+		}
+	}
+}
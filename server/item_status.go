@@ -0,0 +1,69 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/events"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type SetItemStatusArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+	Status string `json:"Status"` // TODO, WAITING, DONE or CANCELLED; empty to go back to the synced status
+}
+
+type SetItemStatusReply struct {
+	Okay         bool   `json:"okay"`
+	Status       string `json:"status"`        // The status the item now shows
+	SyncedStatus string `json:"synced_status"` // The status syncs compute for the PR
+}
+
+// SetItemStatus gives a PR's items your own status keyword, which syncs keep until one of
+// the UserStatusResetOn changes, like new commits, clears it.
+func (h *RPCHandler) SetItemStatus(args *SetItemStatusArgs, reply *SetItemStatusReply) error {
+	identifier := itemIdentifier(args.Owner, args.Repo, args.Number)
+	status := strings.ToUpper(strings.TrimSpace(args.Status))
+	if status != "" && !slices.Contains(database.UserStatusKeywords, status) {
+		return fmt.Errorf("unknown status %q (expected one of %s)", args.Status, strings.Join(database.UserStatusKeywords, ", "))
+	}
+	items, err := config.C.DB.GetItemsByIdentifier(identifier)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no review item for %s", identifier)
+	}
+	synced := items[0].Status
+
+	if status == "" || status == synced {
+		// Matching the synced status needs nothing to remember
+		err = config.C.DB.ClearUserStatus(identifier)
+	} else {
+		// The next sync records the head commit. A diff stored by the PR view may belong to
+		// an older head, which would read as new commits and clear the status right away.
+		err = config.C.DB.SetUserStatus(database.UserStatus{
+			Identifier:   identifier,
+			Status:       status,
+			SetAt:        time.Now().Unix(),
+			SyncedStatus: synced,
+		})
+	}
+	if err != nil {
+		h.Log.Error("Error storing item status", "identifier", identifier, "error", err)
+		return err
+	}
+	events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{Updated: len(items)})
+
+	reply.Okay = true
+	reply.Status = synced
+	if status != "" {
+		reply.Status = status
+	}
+	reply.SyncedStatus = synced
+	return nil
+}
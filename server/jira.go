@@ -0,0 +1,202 @@
+package server
+
+import (
+	"crs/config"
+	"crs/jira"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxLinkedJiraIssues caps the lookups for PR bodies which mention many tickets.
+const maxLinkedJiraIssues = 5
+
+// jiraIssueCacheTTL is how long a cached issue is shown before Jira is asked again, so status
+// and assignee changes show up without a re-sync.
+var jiraIssueCacheTTL = time.Hour
+
+// reviewStates maps the submitted review event to the state GitHub reports for the review.
+var reviewStates = map[string]string{
+	"APPROVE":         "APPROVED",
+	"REQUEST_CHANGES": "CHANGES_REQUESTED",
+	"COMMENT":         "COMMENTED",
+}
+
+// storedJiraKeys returns the issue keys ProjectListWorkflow recorded for the PR's items.
+func storedJiraKeys(owner, repo string, number int) []string {
+	items, err := config.C.DB.GetItemsByIdentifier(fmt.Sprintf("%s/%s-%d", owner, repo, number))
+	if err != nil {
+		slog.Error("Error getting items for Jira keys", "error", err)
+		return nil
+	}
+	keys := []string{}
+	for _, item := range items {
+		details, err := item.GetDetails()
+		if err != nil {
+			continue
+		}
+		for _, line := range details {
+			if rest, found := strings.CutPrefix(line, "Jira: "); found {
+				keys = append(keys, jira.FindIssueKeys(rest)...)
+			}
+		}
+	}
+	return keys
+}
+
+// cachedPRDetails holds the metadata cached by the last sync of a PR, for when it can't be
+// fetched again. Its reviews are unknown, so {review_url} falls back to the PR's URL.
+func cachedPRDetails(owner, repo string, number int) *PRDetails {
+	details := &PRDetails{}
+	if cached, err := config.C.DB.GetPRMetadataCache(owner, repo, number); err == nil && cached != "" {
+		json.Unmarshal([]byte(cached), &details.Metadata)
+	}
+	return details
+}
+
+// applyJiraRules runs the configured JiraRules for a submitted review against every issue linked
+// to the PR, from the Jira workflows or from a key in the branch or title. A key found in the
+// branch or title is only acted on by rules whose Projects name its project, since look-alikes
+// such as UTF-8 match the key pattern too. It returns what was done (or would be done, in dry run
+// mode) for the client to show.
+func applyJiraRules(log *slog.Logger, event string, owner, repo string, storedKeys []string, details *PRDetails) []string {
+	if len(config.C.JiraRules) == 0 || config.C.JiraDomain == "" {
+		return nil
+	}
+	synced := jira.FindIssueKeys(storedKeys...)
+	keys := jira.FindIssueKeys(append(storedKeys, details.Metadata.HeadRef, details.Metadata.Title)...)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	me := config.C.Username(config.C.HostFor(owner, repo))
+	reviewURL := details.Metadata.URL
+	for _, review := range details.Reviews {
+		// Reviews are oldest first, so the last match is the one just submitted
+		if review.User == me && review.State == reviewStates[event] && review.HTMLURL != "" {
+			reviewURL = review.HTMLURL
+		}
+	}
+	replacer := strings.NewReplacer(
+		"{reviewer}", me,
+		"{review_url}", reviewURL,
+		"{pr_url}", details.Metadata.URL,
+		"{title}", details.Metadata.Title,
+		"{repo}", owner+"/"+repo,
+	)
+
+	actions := []string{}
+	for _, rule := range config.C.JiraRules {
+		if rule.Event != event {
+			continue
+		}
+		for _, key := range keys {
+			inProjects := slices.ContainsFunc(rule.Projects, func(p string) bool {
+				return strings.EqualFold(p, jira.ProjectKey(key))
+			})
+			if len(rule.Projects) > 0 && !inProjects {
+				continue
+			}
+			if !inProjects && !slices.Contains(synced, key) {
+				continue
+			}
+			if rule.Transition != "" {
+				actions = append(actions, runJiraAction(log, fmt.Sprintf("moved %s to %s", key, rule.Transition), func() error {
+					return jira.TransitionIssue(config.C.JiraDomain, key, rule.Transition)
+				}))
+			}
+			if rule.Comment != "" {
+				actions = append(actions, runJiraAction(log, fmt.Sprintf("commented on %s", key), func() error {
+					return jira.AddComment(config.C.JiraDomain, key, replacer.Replace(rule.Comment))
+				}))
+			}
+		}
+	}
+	return actions
+}
+
+func runJiraAction(log *slog.Logger, description string, action func() error) string {
+	if config.C.JiraDryRun {
+		log.Info("Jira dry run", "action", description)
+		return "[dry run] " + description
+	}
+	if err := action(); err != nil {
+		log.Error("Jira action failed", "action", description, "error", err)
+		return "failed: " + description + ": " + err.Error()
+	}
+	log.Info("Jira action", "action", description)
+	return description
+}
+
+// getLinkedJiraIssues returns the issues a PR implements: the keys in its title, branch and body,
+// and the issues a ProjectListWorkflow synced it from. Issues are cached in SQLite, and the cache
+// is used while it's younger than jiraIssueCacheTTL, unless skipCache, or when Jira can't be reached.
+func getLinkedJiraIssues(owner, repo string, number int, metadata PRMetadata, skipCache bool) []jira.IssueDetails {
+	if config.C.JiraDomain == "" {
+		return nil
+	}
+	keys := jira.FindIssueKeys(append(storedJiraKeys(owner, repo, number), metadata.Title, metadata.HeadRef, metadata.Body)...)
+	if len(keys) > maxLinkedJiraIssues {
+		keys = keys[:maxLinkedJiraIssues]
+	}
+
+	issues := []jira.IssueDetails{}
+	for _, key := range keys {
+		var cached *jira.IssueDetails
+		cachedJSON, cachedAt, err := config.C.DB.GetJiraIssueCache(key)
+		if err == nil && cachedJSON != "" {
+			json.Unmarshal([]byte(cachedJSON), &cached)
+		}
+		if cached != nil && !skipCache && time.Since(cachedAt) < jiraIssueCacheTTL {
+			issues = append(issues, *cached)
+			continue
+		}
+
+		issue, err := jira.GetIssue(config.C.JiraDomain, key, config.C.JiraAcceptanceCriteriaField)
+		if err != nil {
+			// Keys found in free text can be false positives, like UTF-8
+			slog.Debug("Error fetching Jira issue", "key", key, "error", err)
+			if cached != nil {
+				issues = append(issues, *cached)
+			}
+			continue
+		}
+		if issueJSON, err := json.Marshal(issue); err == nil {
+			config.C.DB.UpsertJiraIssueCache(key, string(issueJSON))
+		}
+		issues = append(issues, *issue)
+	}
+	return issues
+}
+
+// writeJiraDrawer renders the linked issues as an org drawer under the PR header.
+func writeJiraDrawer(sb *strings.Builder, issues []jira.IssueDetails) {
+	if len(issues) == 0 {
+		return
+	}
+	sb.WriteString(":JIRA:\n")
+	for _, issue := range issues {
+		sb.WriteString(fmt.Sprintf("%s: \t%s\n", issue.Key, issue.Summary))
+		sb.WriteString(fmt.Sprintf("  Status: \t%s\n", issue.Status))
+		assignee := "Unassigned"
+		if issue.Assignee != "" {
+			assignee = issue.Assignee
+		}
+		sb.WriteString(fmt.Sprintf("  Assignee: \t%s\n", assignee))
+		if len(issue.FixVersions) > 0 {
+			sb.WriteString(fmt.Sprintf("  Fix Versions: \t%s\n", strings.Join(issue.FixVersions, ", ")))
+		}
+		sb.WriteString(fmt.Sprintf("  URL: \t%s\n", issue.URL))
+		if issue.AcceptanceCriteria != "" {
+			sb.WriteString("  Acceptance Criteria:\n")
+			for _, line := range strings.Split(issue.AcceptanceCriteria, "\n") {
+				// Indenting keeps bullets like "* item" from being read as org headings
+				sb.WriteString("    " + strings.TrimRight(line, " \t\r") + "\n")
+			}
+		}
+	}
+	sb.WriteString(":END:\n")
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"crs/config"
+	"crs/jira"
+	"crs/testutil"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyJiraRules(t *testing.T) {
+	var requests []string
+	var comment string
+	jiraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET":
+			fmt.Fprint(w, `{"transitions":[{"id":"11","name":"Start","to":{"name":"In Progress"}},{"id":"31","name":"Ready for QA","to":{"name":"In QA"}}]}`)
+		case r.URL.Path == "/rest/api/2/issue/PROJ-7/comment":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			comment = body["body"]
+		}
+	}))
+	defer jiraServer.Close()
+
+	saved := config.C
+	t.Cleanup(func() { config.C = saved })
+	config.C.JiraDomain = jiraServer.URL
+	config.C.GithubUsername = "me"
+	config.C.JiraRules = []config.JiraRule{
+		{Event: "APPROVE", Transition: "In QA", Projects: []string{"PROJ"}},
+		{Event: "REQUEST_CHANGES", Comment: "{reviewer} requested changes on {repo}: {review_url}", Projects: []string{"PROJ"}},
+		{Event: "COMMENT", Comment: "{reviewer} commented"},
+	}
+
+	details := &PRDetails{
+		Metadata: PRMetadata{Title: "Add widgets in UTF-8", HeadRef: "feature/PROJ-7-widgets", URL: "https://github.com/acme/api/pull/3"},
+		Reviews: []ReviewJSON{
+			{User: "me", State: "COMMENTED", HTMLURL: "https://github.com/acme/api/pull/3#pullrequestreview-1"},
+			{User: "me", State: "CHANGES_REQUESTED", HTMLURL: "https://github.com/acme/api/pull/3#pullrequestreview-2"},
+		},
+	}
+	log := slog.New(slog.DiscardHandler)
+
+	// The stored OTHER-1 key is outside the rule's projects
+	actions := applyJiraRules(log, "APPROVE", "acme", "api", []string{"OTHER-1"}, details)
+	if !slices.Equal(actions, []string{"moved PROJ-7 to In QA"}) {
+		t.Errorf("APPROVE actions = %q", actions)
+	}
+	if !slices.Equal(requests, []string{"GET /rest/api/3/issue/PROJ-7/transitions", "POST /rest/api/3/issue/PROJ-7/transitions"}) {
+		t.Errorf("requests = %q", requests)
+	}
+
+	actions = applyJiraRules(log, "REQUEST_CHANGES", "acme", "api", nil, details)
+	if !slices.Equal(actions, []string{"commented on PROJ-7"}) {
+		t.Errorf("REQUEST_CHANGES actions = %q", actions)
+	}
+	if comment != "me requested changes on acme/api: https://github.com/acme/api/pull/3#pullrequestreview-2" {
+		t.Errorf("comment = %q, want it to link the review", comment)
+	}
+
+	// Without Projects a rule only acts on the synced keys, not on look-alikes in the title
+	requests = nil
+	actions = applyJiraRules(log, "COMMENT", "acme", "api", []string{"OTHER-1"}, details)
+	if !slices.Equal(actions, []string{"commented on OTHER-1"}) {
+		t.Errorf("COMMENT actions = %q, want only the synced OTHER-1", actions)
+	}
+
+	requests = nil
+	config.C.JiraDryRun = true
+	actions = applyJiraRules(log, "APPROVE", "acme", "api", nil, details)
+	if !slices.Equal(actions, []string{"[dry run] moved PROJ-7 to In QA"}) || len(requests) != 0 {
+		t.Errorf("dry run actions = %q with %d requests, want no requests", actions, len(requests))
+	}
+}
+
+func TestSubmitReview_JiraRulesWhenRefreshFails(t *testing.T) {
+	var transitions []string
+	jiraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, `{"transitions":[{"id":"31","name":"Ready for QA","to":{"name":"In QA"}}]}`)
+			return
+		}
+		transitions = append(transitions, r.URL.Path)
+	}))
+	defer jiraServer.Close()
+	submitted := false
+	gitlab := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			submitted = true
+			w.Write([]byte("{}"))
+			return
+		}
+		if submitted {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"iid":7,"state":"opened","sha":"head1"}`))
+	}))
+	defer gitlab.Close()
+
+	db := testutil.NewDB(t)
+	t.Setenv("CRS_GITLAB_TOKEN", "token")
+	config.C.Repos = []string{"gitlab:org/api"}
+	config.C.GitlabURL = gitlab.URL
+	config.C.JiraDomain = jiraServer.URL
+	config.C.JiraRules = []config.JiraRule{{Event: "APPROVE", Transition: "In QA", Projects: []string{"PROJ"}}}
+	metadata, _ := json.Marshal(PRMetadata{Title: "Add widgets", HeadRef: "feature/PROJ-7-widgets"})
+	if err := db.UpsertPRMetadataCache("org", "api", 7, string(metadata)); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &RPCHandler{Log: slog.New(slog.DiscardHandler)}
+	var reply SubmitReviewReply
+	if err := h.SubmitReview(&SubmitReviewArgs{Owner: "org", Repo: "api", Number: 7, Event: "APPROVE"}, &reply); err != nil {
+		t.Fatalf("SubmitReview() error = %v, want the submitted review to succeed", err)
+	}
+	if !reply.Okay || !slices.Equal(reply.JiraActions, []string{"moved PROJ-7 to In QA"}) {
+		t.Errorf("reply = okay %v, Jira actions %q, want PROJ-7 moved from the cached branch", reply.Okay, reply.JiraActions)
+	}
+	if len(transitions) != 1 {
+		t.Errorf("transitions = %q, want one", transitions)
+	}
+}
+
+func TestGetLinkedJiraIssues_CacheTTL(t *testing.T) {
+	fetches := 0
+	jiraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		fmt.Fprint(w, `{"key":"PROJ-7","fields":{"summary":"Widgets","status":{"name":"In QA"}}}`)
+	}))
+	defer jiraServer.Close()
+
+	db := testutil.NewDB(t)
+	savedTTL := jiraIssueCacheTTL
+	t.Cleanup(func() { jiraIssueCacheTTL = savedTTL })
+	config.C.JiraDomain = jiraServer.URL
+	if err := db.UpsertJiraIssueCache("PROJ-7", `{"key":"PROJ-7","summary":"Widgets","status":"In Progress"}`); err != nil {
+		t.Fatal(err)
+	}
+	metadata := PRMetadata{HeadRef: "feature/PROJ-7-widgets"}
+
+	issues := getLinkedJiraIssues("acme", "api", 3, metadata, false)
+	if fetches != 0 || len(issues) != 1 || issues[0].Status != "In Progress" {
+		t.Fatalf("fresh cache: %d fetches, issues %+v, want the cached issue", fetches, issues)
+	}
+
+	jiraIssueCacheTTL = 0
+	issues = getLinkedJiraIssues("acme", "api", 3, metadata, false)
+	if fetches != 1 || len(issues) != 1 || issues[0].Status != "In QA" {
+		t.Fatalf("stale cache: %d fetches, issues %+v, want the issue fetched again", fetches, issues)
+	}
+}
+
+func TestGetPRDetails_RefreshesStaleJiraIssuesFromCache(t *testing.T) {
+	jiraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"key":"PROJ-7","fields":{"summary":"Widgets","status":{"name":"In QA"}}}`)
+	}))
+	defer jiraServer.Close()
+	gitlab := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer gitlab.Close()
+
+	db := testutil.NewDB(t)
+	savedTTL := jiraIssueCacheTTL
+	t.Cleanup(func() { jiraIssueCacheTTL = savedTTL })
+	t.Setenv("CRS_GITLAB_TOKEN", "token")
+	config.C = config.Config{DB: db, JiraDomain: jiraServer.URL, Repos: []string{"gitlab:org/api"}, GitlabURL: gitlab.URL}
+
+	// The PR's metadata is cached along with the issue as it was then
+	stale := jira.IssueDetails{Key: "PROJ-7", Summary: "Widgets", Status: "In Progress"}
+	metadata, _ := json.Marshal(PRMetadata{Number: 7, HeadRef: "feature/PROJ-7-widgets", JiraIssues: []jira.IssueDetails{stale}})
+	if err := db.UpsertPRMetadataCache("org", "api", 7, string(metadata)); err != nil {
+		t.Fatal(err)
+	}
+	issueJSON, _ := json.Marshal(stale)
+	if err := db.UpsertJiraIssueCache("PROJ-7", string(issueJSON)); err != nil {
+		t.Fatal(err)
+	}
+	jiraIssueCacheTTL = 0
+
+	details, err := GetPRDetails("org", "api", 7, false)
+	if err != nil {
+		t.Fatalf("GetPRDetails() error = %v", err)
+	}
+	if issues := details.Metadata.JiraIssues; len(issues) != 1 || issues[0].Status != "In QA" {
+		t.Errorf("JiraIssues = %+v, want PROJ-7 fetched again", issues)
+	}
+}
+
+func TestWriteJiraDrawer(t *testing.T) {
+	var sb strings.Builder
+	writeJiraDrawer(&sb, []jira.IssueDetails{{
+		Key:                "PROJ-7",
+		URL:                "https://acme.atlassian.net/browse/PROJ-7",
+		Summary:            "Add widgets",
+		Status:             "In Review",
+		FixVersions:        []string{"2.4"},
+		AcceptanceCriteria: "* Widgets render\n* Widgets sort",
+	}})
+	want := ":JIRA:\n" +
+		"PROJ-7: \tAdd widgets\n" +
+		"  Status: \tIn Review\n" +
+		"  Assignee: \tUnassigned\n" +
+		"  Fix Versions: \t2.4\n" +
+		"  URL: \thttps://acme.atlassian.net/browse/PROJ-7\n" +
+		"  Acceptance Criteria:\n" +
+		"    * Widgets render\n" +
+		"    * Widgets sort\n" +
+		":END:\n"
+	if sb.String() != want {
+		t.Errorf("writeJiraDrawer() =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
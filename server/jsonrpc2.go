@@ -0,0 +1,222 @@
+package server
+
+import (
+	"crs/config"
+	"crs/events"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/rpc"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// JSON-RPC 2.0 error codes used in responses.
+const (
+	jsonrpc2MethodNotFound = -32601
+	jsonrpc2InvalidParams  = -32602
+	jsonrpc2ServerError    = -32000
+)
+
+// serverCodec is an rpc.ServerCodec that speaks both JSON-RPC 1.0 (what net/rpc/jsonrpc
+// implements) and JSON-RPC 2.0. The version is detected per request from the "jsonrpc"
+// member, and each response is written in the version of its request.
+//
+// Once a connection has sent a 2.0 request it also receives server-initiated
+// notifications from the events package (reviews/changed, plugin/finished, pr/updated).
+type serverCodec struct {
+	dec *json.Decoder
+	c   io.Closer
+
+	// enc is shared between responses and notifications, which are written from different goroutines.
+	encMu sync.Mutex
+	enc   *json.Encoder
+
+	req jsonrpcRequest
+
+	mutex   sync.Mutex
+	seq     uint64
+	pending map[uint64]pendingRequest
+
+	v2          atomic.Bool
+	unsubscribe func()
+}
+
+type jsonrpcRequest struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  *json.RawMessage `json:"params"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+type pendingRequest struct {
+	id *json.RawMessage
+	v2 bool
+	// Whether the request holds config.RLock until its response is written
+	configLocked bool
+}
+
+// reloadConfigMethod swaps the config itself, so it must not hold off its own reload.
+const reloadConfigMethod = "RPCHandler.ReloadConfig"
+
+type jsonrpc1Response struct {
+	ID     *json.RawMessage `json:"id"`
+	Result interface{}      `json:"result"`
+	Error  interface{}      `json:"error"`
+}
+
+type jsonrpc2Response struct {
+	Version string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *jsonrpc2Error   `json:"error,omitempty"`
+}
+
+type jsonrpc2Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonrpc2Notification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+var null = json.RawMessage([]byte("null"))
+
+// NewServerCodec returns a JSON-RPC 1.0/2.0 codec on conn.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	codec := &serverCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]pendingRequest),
+	}
+	codec.unsubscribe = events.Subscribe(codec.notify)
+	return codec
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.req = jsonrpcRequest{}
+	if err := c.dec.Decode(&c.req); err != nil {
+		return err
+	}
+	isV2 := c.req.Version == "2.0"
+	if isV2 {
+		c.v2.Store(true)
+	}
+
+	r.ServiceMethod = c.req.Method
+
+	// net/rpc writes exactly one response for each header read, which releases the lock
+	configLocked := c.req.Method != reloadConfigMethod
+	if configLocked {
+		config.RLock()
+	}
+
+	c.mutex.Lock()
+	c.seq++
+	c.pending[c.seq] = pendingRequest{id: c.req.ID, v2: isV2, configLocked: configLocked}
+	r.Seq = c.seq
+	c.mutex.Unlock()
+
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	if c.req.Params == nil {
+		// 2.0 allows omitting params entirely; the handler then sees zero-value args.
+		if c.req.Version == "2.0" {
+			return nil
+		}
+		return errors.New("jsonrpc: request body missing params")
+	}
+
+	raw := strings.TrimSpace(string(*c.req.Params))
+	if strings.HasPrefix(raw, "[") {
+		// Positional params, the only form JSON-RPC 1.0 supports. net/rpc methods take a single argument.
+		var params [1]interface{}
+		params[0] = x
+		return json.Unmarshal(*c.req.Params, &params)
+	}
+	// Named params (2.0 only) map directly onto the args struct.
+	return json.Unmarshal(*c.req.Params, x)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	c.mutex.Lock()
+	req, ok := c.pending[r.Seq]
+	if !ok {
+		c.mutex.Unlock()
+		return errors.New("invalid sequence number in response")
+	}
+	delete(c.pending, r.Seq)
+	c.mutex.Unlock()
+	if req.configLocked {
+		config.RUnlock()
+	}
+
+	if req.v2 && req.id == nil {
+		// A 2.0 request without an id is a client notification and gets no response.
+		return nil
+	}
+	if req.id == nil {
+		req.id = &null
+	}
+
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+
+	if !req.v2 {
+		resp := jsonrpc1Response{ID: req.id}
+		if r.Error == "" {
+			resp.Result = x
+		} else {
+			resp.Error = r.Error
+		}
+		return c.enc.Encode(resp)
+	}
+
+	resp := jsonrpc2Response{Version: "2.0", ID: req.id}
+	if r.Error == "" {
+		resp.Result = x
+	} else {
+		resp.Error = &jsonrpc2Error{Code: jsonrpc2ErrorCode(r.Error), Message: r.Error}
+	}
+	return c.enc.Encode(resp)
+}
+
+func (c *serverCodec) Close() error {
+	c.unsubscribe()
+	return c.c.Close()
+}
+
+// notify pushes a server-initiated notification. 1.0 clients have no notion of
+// notifications, so nothing is sent until the connection has spoken 2.0.
+func (c *serverCodec) notify(method string, params interface{}) {
+	if !c.v2.Load() {
+		return
+	}
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+	if err := c.enc.Encode(jsonrpc2Notification{Version: "2.0", Method: method, Params: params}); err != nil {
+		slog.Warn("Failed to send notification", "method", method, "error", err)
+	}
+}
+
+func jsonrpc2ErrorCode(message string) int {
+	switch {
+	case strings.HasPrefix(message, "rpc: can't find"), strings.HasPrefix(message, "rpc: service/method request ill-formed"):
+		return jsonrpc2MethodNotFound
+	case strings.HasPrefix(message, "jsonrpc:"), strings.HasPrefix(message, "json:"):
+		return jsonrpc2InvalidParams
+	default:
+		return jsonrpc2ServerError
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"bufio"
+	"crs/config"
+	"crs/events"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"testing"
+	"time"
+)
+
+type EchoArgs struct {
+	Text string
+}
+
+type EchoReply struct {
+	Text string `json:"text"`
+}
+
+type EchoService struct{}
+
+func (s *EchoService) Echo(args *EchoArgs, reply *EchoReply) error {
+	if args.Text == "" {
+		return errors.New("empty text")
+	}
+	reply.Text = args.Text
+	return nil
+}
+
+// startCodecServer serves EchoService over one end of a pipe and returns the other end.
+func startCodecServer(t *testing.T) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.Register(&EchoService{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeCodec(NewServerCodec(serverConn))
+	t.Cleanup(func() { clientConn.Close() })
+	return clientConn, bufio.NewReader(clientConn)
+}
+
+func sendLine(t *testing.T, conn net.Conn, line string) {
+	t.Helper()
+	conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte(line + "\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+}
+
+func readMessage(t *testing.T, conn net.Conn, reader *bufio.Reader) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("ReadBytes() error = %v", err)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", line, err)
+	}
+	return msg
+}
+
+func TestServerCodec_JSONRPC1(t *testing.T) {
+	conn, reader := startCodecServer(t)
+
+	sendLine(t, conn, `{"method":"EchoService.Echo","params":[{"Text":"hi"}],"id":1}`)
+	msg := readMessage(t, conn, reader)
+	if _, ok := msg["jsonrpc"]; ok {
+		t.Errorf("1.0 response should not carry a jsonrpc member: %v", msg)
+	}
+	if msg["id"] != float64(1) || msg["error"] != nil {
+		t.Errorf("unexpected response: %v", msg)
+	}
+	if result, _ := msg["result"].(map[string]interface{}); result["text"] != "hi" {
+		t.Errorf("result = %v, want text=hi", msg["result"])
+	}
+
+	sendLine(t, conn, `{"method":"EchoService.Echo","params":[{"Text":""}],"id":2}`)
+	msg = readMessage(t, conn, reader)
+	if msg["error"] != "empty text" {
+		t.Errorf("1.0 error = %v, want plain string", msg["error"])
+	}
+}
+
+func TestServerCodec_JSONRPC2(t *testing.T) {
+	conn, reader := startCodecServer(t)
+
+	// Named params
+	sendLine(t, conn, `{"jsonrpc":"2.0","method":"EchoService.Echo","params":{"Text":"named"},"id":"a"}`)
+	msg := readMessage(t, conn, reader)
+	if msg["jsonrpc"] != "2.0" || msg["id"] != "a" {
+		t.Errorf("unexpected 2.0 envelope: %v", msg)
+	}
+	if _, ok := msg["error"]; ok {
+		t.Errorf("successful 2.0 response should omit error: %v", msg)
+	}
+	if result, _ := msg["result"].(map[string]interface{}); result["text"] != "named" {
+		t.Errorf("result = %v, want text=named", msg["result"])
+	}
+
+	// Errors are objects with a code
+	sendLine(t, conn, `{"jsonrpc":"2.0","method":"EchoService.Missing","params":[{}],"id":3}`)
+	msg = readMessage(t, conn, reader)
+	rpcErr, _ := msg["error"].(map[string]interface{})
+	if rpcErr == nil || rpcErr["code"] != float64(jsonrpc2MethodNotFound) {
+		t.Errorf("error = %v, want code %d", msg["error"], jsonrpc2MethodNotFound)
+	}
+
+	// A request without an id is a notification and must not be answered,
+	// so the next message read is the response to id 4.
+	sendLine(t, conn, `{"jsonrpc":"2.0","method":"EchoService.Echo","params":[{"Text":"quiet"}]}`)
+	sendLine(t, conn, `{"jsonrpc":"2.0","method":"EchoService.Echo","params":[{"Text":"loud"}],"id":4}`)
+	msg = readMessage(t, conn, reader)
+	if msg["id"] != float64(4) {
+		t.Errorf("got response for id %v, want 4", msg["id"])
+	}
+}
+
+func TestServerCodec_Notifications(t *testing.T) {
+	conn, reader := startCodecServer(t)
+
+	// Connections that only spoke 1.0 never receive notifications.
+	sendLine(t, conn, `{"method":"EchoService.Echo","params":[{"Text":"hi"}],"id":1}`)
+	readMessage(t, conn, reader)
+	go events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{Added: 1})
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if line, err := reader.ReadBytes('\n'); err == nil {
+		t.Fatalf("1.0 client received unexpected message: %s", line)
+	}
+
+	conn, reader = startCodecServer(t)
+	sendLine(t, conn, `{"jsonrpc":"2.0","method":"EchoService.Echo","params":[{"Text":"hi"}],"id":1}`)
+	readMessage(t, conn, reader)
+
+	go events.Publish(events.PluginFinished, events.PluginFinishedParams{Owner: "o", Repo: "r", Number: 7, Plugin: "p", Status: "success"})
+	msg := readMessage(t, conn, reader)
+	if msg["method"] != events.PluginFinished {
+		t.Fatalf("method = %v, want %s", msg["method"], events.PluginFinished)
+	}
+	if _, ok := msg["id"]; ok {
+		t.Errorf("notification must not carry an id: %v", msg)
+	}
+	params, _ := msg["params"].(map[string]interface{})
+	if params["number"] != float64(7) || params["plugin"] != "p" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+// Run with -race: reloads must not swap config.C while an RPC reads it.
+func TestServerCodec_ReloadDuringRPC(t *testing.T) {
+	saved, savedReloader := config.C, ConfigReloader
+	t.Cleanup(func() { config.C, ConfigReloader = saved, savedReloader })
+	config.C = config.Config{Plugins: []config.Plugin{{Name: "lint"}}}
+	reloaded := config.Config{Plugins: []config.Plugin{{Name: "lint"}, {Name: "test"}}}
+	// ReloadConfig swaps the config from inside an RPC, so it must not wait on itself
+	ConfigReloader = func() error {
+		cfg := reloaded
+		config.Apply(&cfg)
+		return nil
+	}
+
+	server := rpc.NewServer()
+	if err := server.Register(&RPCHandler{Log: slog.Default()}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeCodec(NewServerCodec(serverConn))
+	client := rpc.NewClientWithCodec(jsonrpc.NewClientCodec(clientConn))
+	t.Cleanup(func() { client.Close() })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				cfg := reloaded
+				config.Apply(&cfg)
+			}()
+			go func() {
+				defer wg.Done()
+				var reply ListPluginsReply
+				if err := client.Call("RPCHandler.ListPlugins", &ListPluginsArgs{}, &reply); err != nil || len(reply.Plugins) == 0 {
+					t.Errorf("ListPlugins() = %v, %v", reply.Plugins, err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				var reply ReloadConfigReply
+				if err := client.Call("RPCHandler.ReloadConfig", &ReloadConfigArgs{}, &reply); err != nil || !reply.Okay {
+					t.Errorf("ReloadConfig() = %+v, %v", reply, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("RPCs and reloads deadlocked")
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NoteJSON is a private reviewer note as sent to clients.
+type NoteJSON struct {
+	ID        int64     `json:"id"`
+	Path      string    `json:"path,omitempty"` // Empty for a note on the whole PR
+	Line      int64     `json:"line,omitempty"`
+	Side      string    `json:"side,omitempty"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func notesToJSON(notes []database.Note) []NoteJSON {
+	result := []NoteJSON{}
+	for _, note := range notes {
+		result = append(result, NoteJSON{
+			ID:        note.ID,
+			Path:      note.Filename,
+			Line:      note.Line,
+			Side:      note.Side,
+			Body:      note.Body,
+			CreatedAt: time.Unix(note.CreatedAt, 0),
+		})
+	}
+	return result
+}
+
+// NotePRComment wraps a line-anchored database.Note to implement PRComment, so it renders inline
+// like a local comment. Its ID is prefixed with "note-" to keep it apart from comment IDs.
+type NotePRComment struct {
+	*database.Note
+	position int64
+	outdated bool
+}
+
+func (c *NotePRComment) GetLogin() string        { return "private" }
+func (c *NotePRComment) GetBody() string         { return c.Body }
+func (c *NotePRComment) GetID() string           { return "note-" + strconv.FormatInt(c.ID, 10) }
+func (c *NotePRComment) GetPosition() string     { return strconv.FormatInt(c.position, 10) }
+func (c *NotePRComment) GetInReplyTo() int64     { return 0 }
+func (c *NotePRComment) GetPath() string         { return c.Filename }
+func (c *NotePRComment) GetCreatedAt() time.Time { return time.Unix(c.CreatedAt, 0) }
+func (c *NotePRComment) IsOutdated() bool        { return c.outdated }
+func (c *NotePRComment) GetCommitID() string     { return "" }
+func (c *NotePRComment) GetAnchor() database.CommentAnchor {
+	return database.CommentAnchor{Line: c.Line, Side: c.Side}
+}
+func (c *NotePRComment) GetOriginalLines() string { return "" }
+func (c *NotePRComment) IsResolved() bool         { return false }
+func (c *NotePRComment) GetResolvedBy() string    { return "" }
+
+// noteComments places the line-anchored notes in the current diff. Notes on lines the diff no
+// longer shows are listed with the outdated comments.
+func noteComments(notes []database.Note, diff *utils.Diff) []PRComment {
+	result := []PRComment{}
+	for i := range notes {
+		note := &notes[i]
+		if note.Filename == "" {
+			continue
+		}
+		comment := &NotePRComment{Note: note, position: note.Position}
+		if note.Line != 0 && diff != nil {
+			comment.position = int64(diff.PositionOfLine(note.Filename, int(note.Line), note.Side == sideLeft))
+			comment.outdated = comment.position == 0
+		}
+		result = append(result, comment)
+	}
+	return result
+}
+
+// writeNotesDrawer lists a PR's notes in a :NOTES: drawer, prefixing line notes with their place.
+func writeNotesDrawer(sb *strings.Builder, notes []NoteJSON) {
+	if len(notes) == 0 {
+		return
+	}
+	sb.WriteString(":NOTES:\n")
+	for _, note := range notes {
+		prefix := "- "
+		if note.Path != "" {
+			prefix += note.Path
+			if note.Line != 0 {
+				prefix += fmt.Sprintf(":%d", note.Line)
+			}
+			prefix += ": "
+		}
+		for i, line := range strings.Split(strings.TrimRight(note.Body, "\n"), "\n") {
+			if i > 0 {
+				// Indenting keeps lines like "* item" from being read as org headings
+				prefix = "  "
+			}
+			sb.WriteString(prefix + strings.TrimRight(line, " \t\r") + "\n")
+		}
+	}
+	sb.WriteString(":END:\n")
+}
+
+type AddNoteArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+	Body   string `json:"Body"`
+	// Optional: the file and diff position the note is on; leave both empty for a note on the PR
+	Filename string `json:"Filename"`
+	Position int64  `json:"Position"`
+	// Optional: the file line to anchor to instead of a diff position
+	Line int64  `json:"Line"`
+	Side string `json:"Side"`
+}
+
+type AddNoteReply struct {
+	ID               int64         `json:"id"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
+	OutdatedComments []CommentJSON `json:"outdated_comments"`
+	Reviews          []ReviewJSON  `json:"reviews"`
+	Notes            []NoteJSON    `json:"notes"`
+}
+
+// AddNote stores a private note on a PR, or on a line of it. Notes are never sent to GitHub.
+func (h *RPCHandler) AddNote(args *AddNoteArgs, reply *AddNoteReply) error {
+	if strings.TrimSpace(args.Body) == "" {
+		return fmt.Errorf("a note needs a Body")
+	}
+	note := database.Note{
+		Owner:     args.Owner,
+		Repo:      args.Repo,
+		Number:    args.Number,
+		Filename:  args.Filename,
+		Body:      args.Body,
+		CreatedAt: time.Now().Unix(),
+	}
+	if args.Filename != "" {
+		diff, _ := loadPRDiff(args.Owner, args.Repo, args.Number)
+		position, anchor := resolveCommentAnchor(diff, args.Filename, args.Position, 0, database.CommentAnchor{Line: args.Line, Side: args.Side})
+		note.Position, note.Line, note.Side = position, anchor.Line, anchor.Side
+	}
+	note, err := config.C.DB.InsertNote(note)
+	if err != nil {
+		h.Log.Error("Error inserting note", "error", err)
+		return err
+	}
+	reply.ID = note.ID
+
+	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+	if err != nil {
+		return err
+	}
+	reply.Content = content
+	reply.Metadata = &details.Metadata
+	reply.Diff = details.Diff
+	reply.Comments = details.Comments
+	reply.OutdatedComments = details.OutdatedComments
+	reply.Reviews = details.Reviews
+	reply.Notes = details.Notes
+	return nil
+}
+
+type ListNotesArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+}
+
+type ListNotesReply struct {
+	Notes []NoteJSON `json:"notes"`
+}
+
+func (h *RPCHandler) ListNotes(args *ListNotesArgs, reply *ListNotesReply) error {
+	notes, err := config.C.DB.GetNotesForPR(args.Owner, args.Repo, args.Number)
+	if err != nil {
+		return err
+	}
+	reply.Notes = notesToJSON(notes)
+	return nil
+}
+
+type DeleteNoteArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+	ID     int64  `json:"ID"`
+}
+
+type DeleteNoteReply struct {
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
+	OutdatedComments []CommentJSON `json:"outdated_comments"`
+	Reviews          []ReviewJSON  `json:"reviews"`
+	Notes            []NoteJSON    `json:"notes"`
+}
+
+func (h *RPCHandler) DeleteNote(args *DeleteNoteArgs, reply *DeleteNoteReply) error {
+	if err := config.C.DB.DeleteNote(args.Owner, args.Repo, args.Number, args.ID); err != nil {
+		h.Log.Error("Error deleting note", "error", err)
+		return err
+	}
+	reply.Okay = true
+
+	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+	if err != nil {
+		return err
+	}
+	reply.Content = content
+	reply.Metadata = &details.Metadata
+	reply.Diff = details.Diff
+	reply.Comments = details.Comments
+	reply.OutdatedComments = details.OutdatedComments
+	reply.Reviews = details.Reviews
+	reply.Notes = details.Notes
+	return nil
+}
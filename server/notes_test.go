@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crs/database"
+	"crs/testutil"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNoteComments(t *testing.T) {
+	diff := mustParseDiff(t, diffHeader+"@@ -1,3 +1,4 @@\n a\n+b\n c\n d\n")
+	notes := []database.Note{
+		{ID: 1, Body: "ask about the rollout"},
+		{ID: 2, Filename: "main.go", Position: 9, Line: 2, Side: "RIGHT", Body: "check b"},
+		{ID: 3, Filename: "main.go", Position: 3, Line: 40, Side: "RIGHT", Body: "gone"},
+	}
+	comments := noteComments(notes, diff)
+	if len(comments) != 2 {
+		t.Fatalf("noteComments() = %d comments, want the 2 line notes", len(comments))
+	}
+	if got := comments[0].GetPosition(); got != "2" {
+		t.Errorf("position = %s, want 2 from the note's line", got)
+	}
+
+	active, outdated := splitComments(comments)
+	if len(active) != 1 || len(outdated) != 1 {
+		t.Fatalf("splitComments() = %d active, %d outdated, want 1 each", len(active), len(outdated))
+	}
+	if c := active[0]; !c.Private || c.ID != "note-2" || c.Author != "private" || c.Path != "main.go" {
+		t.Errorf("note comment = %+v, want a private note-2 on main.go", c)
+	}
+	if !outdated[0].Private {
+		t.Errorf("outdated note not marked private")
+	}
+}
+
+func TestWriteNotesDrawer(t *testing.T) {
+	var sb strings.Builder
+	writeNotesDrawer(&sb, nil)
+	if sb.Len() != 0 {
+		t.Errorf("writeNotesDrawer(nil) = %q, want nothing", sb.String())
+	}
+	writeNotesDrawer(&sb, []NoteJSON{
+		{ID: 1, Body: "check migration ordering"},
+		{ID: 2, Path: "cache.go", Line: 12, Body: "ask Sam about the cache TTL\n* and eviction\n"},
+	})
+	want := ":NOTES:\n- check migration ordering\n- cache.go:12: ask Sam about the cache TTL\n  * and eviction\n:END:\n"
+	if sb.String() != want {
+		t.Errorf("writeNotesDrawer() = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestNotesStayOutOfLocalComments(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	note, err := db.InsertNote(database.Note{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Line: 2, Side: "RIGHT", Body: "private", CreatedAt: time.Now().Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SubmitReview and the draft review sync only read local comments
+	if comments, _ := db.GetLocalCommentsForPR("org", "api", 7); len(comments) != 0 {
+		t.Errorf("local comments = %v, want notes kept apart", comments)
+	}
+	notes, err := db.GetNotesForPR("org", "api", 7)
+	if err != nil || len(notes) != 1 || notes[0].ID != note.ID {
+		t.Fatalf("GetNotesForPR() = %v, %v, want the note", notes, err)
+	}
+	// The ID alone doesn't match a note on another PR
+	if err := db.DeleteNote("org", "api", 8, note.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteNote() on another PR error = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.DeleteNote("org", "api", 7, note.ID); err != nil {
+		t.Fatal(err)
+	}
+	if notes, _ := db.GetNotesForPR("org", "api", 7); len(notes) != 0 {
+		t.Errorf("notes after delete = %v", notes)
+	}
+}
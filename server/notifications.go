@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/events"
+	"crs/git_tools"
+	"strings"
+)
+
+type MarkNotificationReadArgs struct {
+	NotificationID string `json:"NotificationID"` // The notification_id of a review item
+}
+
+type MarkNotificationReadReply struct {
+	Okay    bool `json:"okay"`
+	Removed int  `json:"removed"` // Number of review items pruned for the notification
+}
+
+// MarkNotificationRead marks a notification thread read on GitHub and removes its items
+// from the sections right away instead of waiting for the next sync.
+func (h *RPCHandler) MarkNotificationRead(args *MarkNotificationReadArgs, reply *MarkNotificationReadReply) error {
+	items, err := config.C.DB.GetAllItems()
+	if err != nil {
+		h.Log.Error("Error loading items", "error", err)
+		return err
+	}
+	matching := []*database.Item{}
+	var host *config.GithubHost
+	for _, item := range items {
+		details, err := item.GetDetails()
+		if err != nil || notificationID(details) != args.NotificationID {
+			continue
+		}
+		matching = append(matching, item)
+		if host == nil {
+			host = notificationHost(details)
+		}
+	}
+
+	client, err := git_tools.GetHostClient(host)
+	if err != nil {
+		h.Log.Error("Error creating GitHub client", "notification", args.NotificationID, "error", err)
+		return err
+	}
+	if err := git_tools.MarkNotificationRead(client, args.NotificationID); err != nil {
+		h.Log.Error("Error marking notification read", "notification", args.NotificationID, "error", err)
+		return err
+	}
+
+	for _, item := range matching {
+		if err := config.C.DB.DeleteItem(item.SectionID, item.Identifier); err != nil {
+			h.Log.Error("Error pruning notification item", "identifier", item.Identifier, "error", err)
+			return err
+		}
+		reply.Removed++
+	}
+	if reply.Removed > 0 {
+		events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{Deleted: reply.Removed})
+	}
+	reply.Okay = true
+	return nil
+}
+
+// notificationHost returns the GitHub host a notification item came from, going by its URL
+// or its repo, or nil for github.com.
+func notificationHost(details []string) *config.GithubHost {
+	for _, line := range details {
+		line = strings.TrimSpace(line)
+		if host := config.C.HostForURL(line); host != nil {
+			return host
+		}
+		if repo, found := strings.CutPrefix(line, "Repo: "); found {
+			owner, name, _ := strings.Cut(repo, "/")
+			if host := config.C.HostFor(owner, name); host != nil {
+				return host
+			}
+		}
+	}
+	return nil
+}
+
+// notificationID returns the notification thread ID recorded in an item's details, if any.
+func notificationID(details []string) string {
+	for _, line := range details {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Notification:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Notification:"))
+		}
+	}
+	return ""
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"crs/config"
+	"crs/database"
+	"crs/events"
 	"fmt"
 	"log/slog"
 	"os/exec"
@@ -14,7 +16,12 @@ import (
 func RunPlugins(owner, repo string, number int, sha string, diff string, commentsJSON string, metadataJSON string) {
 	var wg sync.WaitGroup
 
-	for _, plugin := range config.C.Plugins {
+	// Plugins can run for a while, so don't hold off config reloads until they finish
+	config.RLock()
+	plugins, db := config.C.Plugins, config.C.DB
+	config.RUnlock()
+
+	for _, plugin := range plugins {
 		wg.Add(1)
 		go func(p config.Plugin) {
 			defer wg.Done()
@@ -23,16 +30,16 @@ func RunPlugins(owner, repo string, number int, sha string, diff string, comment
 					slog.Error("Plugin runner panicked", "plugin", p.Name, "panic", r)
 				}
 			}()
-			executePlugin(p, owner, repo, number, sha, diff, commentsJSON, metadataJSON)
+			executePlugin(db, p, owner, repo, number, sha, diff, commentsJSON, metadataJSON)
 		}(plugin)
 	}
 
 	wg.Wait()
 }
 
-func executePlugin(plugin config.Plugin, owner, repo string, number int, sha string, diff string, commentsJSON string, metadataJSON string) {
+func executePlugin(db *database.DB, plugin config.Plugin, owner, repo string, number int, sha string, diff string, commentsJSON string, metadataJSON string) {
 	// Check if we need to rerun this plugin
-	storedSHA, err := config.C.DB.GetPluginResultSHA(owner, repo, number, plugin.Name)
+	storedSHA, err := db.GetPluginResultSHA(owner, repo, number, plugin.Name)
 	if err != nil {
 		slog.Error("Failed to get stored SHA for plugin", "plugin", plugin.Name, "error", err)
 		// Continue anyway - we'll run the plugin
@@ -45,7 +52,7 @@ func executePlugin(plugin config.Plugin, owner, repo string, number int, sha str
 	}
 
 	// Set status to pending
-	err = config.C.DB.UpsertPluginResult(owner, repo, number, plugin.Name, "", "pending", sha)
+	err = db.UpsertPluginResult(owner, repo, number, plugin.Name, "", "pending", sha)
 	if err != nil {
 		slog.Error("Failed to set plugin status to pending", "plugin", plugin.Name, "error", err)
 	}
@@ -73,15 +80,29 @@ func executePlugin(plugin config.Plugin, owner, repo string, number int, sha str
 	resultStr := string(output)
 	if err != nil {
 		slog.Error("Plugin execution failed", "plugin", plugin.Name, "error", err, "output", resultStr)
-		config.C.DB.UpsertPluginResult(owner, repo, number, plugin.Name, fmt.Sprintf("Error: %v\nOutput: %s", err, resultStr), "error", sha)
+		db.UpsertPluginResult(owner, repo, number, plugin.Name, fmt.Sprintf("Error: %v\nOutput: %s", err, resultStr), "error", sha)
+		publishPluginFinished(plugin, owner, repo, number, "error", sha)
 		return
 	}
 
 	slog.Info("Plugin executed", "plugin", plugin.Name, "result_len", len(resultStr), "sha", sha)
 
 	// Store result
-	err = config.C.DB.UpsertPluginResult(owner, repo, number, plugin.Name, resultStr, "success", sha)
+	err = db.UpsertPluginResult(owner, repo, number, plugin.Name, resultStr, "success", sha)
 	if err != nil {
 		slog.Error("Failed to store plugin result", "plugin", plugin.Name, "error", err)
+		return
 	}
+	publishPluginFinished(plugin, owner, repo, number, "success", sha)
+}
+
+func publishPluginFinished(plugin config.Plugin, owner, repo string, number int, status string, sha string) {
+	events.Publish(events.PluginFinished, events.PluginFinishedParams{
+		Owner:  owner,
+		Repo:   repo,
+		Number: number,
+		Plugin: plugin.Name,
+		Status: status,
+		SHA:    sha,
+	})
 }
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"crs/config"
+	"crs/git_tools"
+)
+
+type GetRateLimitArgs struct{}
+
+type GetRateLimitReply struct {
+	Resources []git_tools.RateLimit `json:"resources"`
+	Reserve   int                   `json:"reserve"`
+	// Seconds until workflow cycles resume, or 0 if they are not being deferred
+	BackoffSeconds int `json:"backoff_seconds"`
+}
+
+func (h *RPCHandler) GetRateLimit(args *GetRateLimitArgs, reply *GetRateLimitReply) error {
+	resources := git_tools.RateLimits()
+	if len(resources) == 0 {
+		// Nothing has talked to GitHub yet, so ask for the budget directly.
+		var err error
+		resources, err = git_tools.FetchRateLimits(git_tools.GetGithubClient())
+		if err != nil {
+			h.Log.Error("Error fetching rate limits", "error", err)
+			return err
+		}
+	}
+
+	reply.Resources = resources
+	reply.Reserve = config.C.RateLimitReserve
+	reply.BackoffSeconds = int(git_tools.RateLimitBackoff(config.C.RateLimitReserve).Seconds())
+	return nil
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/forge"
+	"crs/git_tools"
+	"crs/utils"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// reanchorComment works out where a comment written against oldDiff belongs in newDiff.
+// headDiff is the diff between the two PR heads and is used to follow the commented line
+// through the push. After a force push that diff starts from the merge base rather than the
+// old head, so line content is compared against oldDiff when it is known.
+//
+// ok is false when the line was changed or removed. It is also false when the line no longer
+// falls inside the PR's diff, since GitHub only accepts comments on diff lines. The comment is
+// then orphaned and has to be moved by hand.
+func reanchorComment(c database.LocalComment, headDiff, oldDiff, newDiff *utils.Diff) (string, int64, database.CommentAnchor, bool) {
+	anchor := c.CommentAnchor
+	if anchor.Line == 0 {
+		// Comments from before line anchoring only know their position in the old diff.
+		_, anchor = resolveCommentAnchor(oldDiff, c.Filename, c.Position, 0, anchor)
+		if anchor.Line == 0 {
+			return c.Filename, c.Position, c.CommentAnchor, false
+		}
+	}
+
+	filename := c.Filename
+	var file *utils.DiffFile
+	if headDiff != nil {
+		file = headDiff.File(c.Filename)
+	}
+	if file != nil {
+		if file.Mode == utils.DELETED {
+			return c.Filename, c.Position, anchor, false
+		}
+		filename = file.NewName
+	}
+
+	// Follows one end of the comment. LEFT lines live in the base branch, which a push to
+	// the head does not touch, so only their content needs checking.
+	moveLine := func(line int64, side string) (int64, bool) {
+		newLine := line
+		if side == sideRight && file != nil {
+			mapped, ok := file.MapLine(int(line))
+			if !ok {
+				return 0, false
+			}
+			newLine = int64(mapped)
+		}
+		newPosition := newDiff.PositionOfLine(filename, int(newLine), side == sideLeft)
+		if newPosition == 0 {
+			return 0, false
+		}
+		if oldDiff != nil {
+			before := oldDiff.LineAtPosition(c.Filename, oldDiff.PositionOfLine(c.Filename, int(line), side == sideLeft))
+			after := newDiff.LineAtPosition(filename, newPosition)
+			if before != nil && after != nil && before.Content != after.Content {
+				return 0, false
+			}
+		}
+		return newLine, true
+	}
+
+	moved := anchor
+	var ok bool
+	if moved.Line, ok = moveLine(anchor.Line, anchor.Side); !ok {
+		return c.Filename, c.Position, c.CommentAnchor, false
+	}
+	if anchor.StartLine != 0 {
+		if moved.StartLine, ok = moveLine(anchor.StartLine, anchor.StartSide); !ok {
+			return c.Filename, c.Position, c.CommentAnchor, false
+		}
+	}
+	position := int64(newDiff.PositionOfLine(filename, int(moved.Line), moved.Side == sideLeft))
+	return filename, position, moved, true
+}
+
+// reanchorLocalComments moves unsubmitted top-level comments written against an older commit
+// onto the current PR head. Replies hang off existing GitHub threads and need no anchor of
+// their own. Mirrored pending review comments that moved or were orphaned are dropped, and
+// the moved ones pushed again at their new place.
+func reanchorLocalComments(f forge.Forge, owner, repo string, number int, previousSHA, headSHA string, oldDiff, newDiff *utils.Diff) {
+	if newDiff == nil {
+		return
+	}
+	locals, err := config.C.DB.GetLocalCommentsForPR(owner, repo, number)
+	if err != nil {
+		slog.Error("Error fetching local comments to re-anchor", "pr", number, "repo", repo, "error", err)
+		return
+	}
+
+	headDiffs := make(map[string]*utils.Diff)
+	repush := false
+	for _, c := range locals {
+		if c.ReplyToID != nil || c.Orphaned || c.CommitSHA == headSHA {
+			continue
+		}
+		// Comments saved before commit tracking were written against the previous head.
+		fromSHA := c.CommitSHA
+		if fromSHA == "" {
+			fromSHA = previousSHA
+		}
+		if fromSHA == "" || fromSHA == headSHA {
+			continue
+		}
+
+		headDiff, fetched := headDiffs[fromSHA]
+		if !fetched {
+			raw, err := f.CompareDiff(owner, repo, fromSHA, headSHA)
+			if err != nil {
+				slog.Warn("Error comparing PR heads, will retry re-anchoring later", "base", fromSHA, "head", headSHA, "error", err)
+			} else if headDiff, err = utils.Parse(raw); err != nil {
+				slog.Warn("Error parsing diff between PR heads", "base", fromSHA, "head", headSHA, "error", err)
+			}
+			headDiffs[fromSHA] = headDiff
+		}
+		if headDiff == nil {
+			// Leave the comment on its old commit so the next fetch tries again.
+			continue
+		}
+
+		before := oldDiff
+		if fromSHA != previousSHA {
+			// The cached diff belongs to a different head, so it can't vouch for line content.
+			before = nil
+		}
+		filename, position, anchor, ok := reanchorComment(c, headDiff, before, newDiff)
+		if !ok {
+			slog.Info("Orphaning local comment after push", "id", c.ID, "file", c.Filename, "line", c.Line)
+		}
+		if c.GithubCommentID != nil && (!ok || filename != c.Filename || anchor != c.CommentAnchor) {
+			// GitHub cannot move a pending review comment, so drop the mirror; orphans stay
+			// local until they are moved by hand.
+			if err := unlinkMovedDraftComment(owner, repo, c); err != nil {
+				// Leave the comment where its mirror is so the next fetch tries again.
+				slog.Error("Error removing pending review comment after push", "id", c.ID, "error", err)
+				continue
+			}
+			repush = repush || ok
+		}
+		if err := config.C.DB.UpdateLocalCommentAnchor(c.ID, filename, position, anchor, headSHA, !ok); err != nil {
+			slog.Error("Error re-anchoring local comment", "id", c.ID, "error", err)
+		}
+	}
+
+	if repush && syncsDraftReview(owner, repo) {
+		if err := pushDraftReview(git_tools.GetGithubClientFor(owner, repo), owner, repo, number); err != nil {
+			slog.Error("Error syncing re-anchored comments to pending review", "pr", number, "repo", repo, "error", err)
+		}
+	}
+}
+
+// unlinkMovedDraftComment deletes the pending review comment mirroring c and forgets its ID.
+func unlinkMovedDraftComment(owner, repo string, c database.LocalComment) error {
+	if syncsDraftReview(owner, repo) {
+		client := git_tools.GetGithubClientFor(owner, repo)
+		if err := git_tools.DeleteReviewComment(client, owner, repo, *c.GithubCommentID); err != nil {
+			return err
+		}
+	}
+	return config.C.DB.SetLocalCommentGithubID(c.ID, nil)
+}
+
+// anchorToHead makes sure the PR's top-level local comments are anchored against its current
+// head before they go to the forge, and returns that head. A push this server has not seen yet
+// is re-anchored here, since the comments would otherwise land on shifted lines. It returns ""
+// without asking the forge when there are no top-level comments.
+func anchorToHead(f forge.Forge, owner, repo string, number int) (string, error) {
+	locals, err := config.C.DB.GetLocalCommentsForPR(owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+	if !slices.ContainsFunc(locals, func(c database.LocalComment) bool { return c.ReplyToID == nil }) {
+		return "", nil
+	}
+	pr, err := f.GetPR(owner, repo, number)
+	if err != nil {
+		return "", fmt.Errorf("checking the head commit of %s/%s#%d: %w", owner, repo, number, err)
+	}
+	headSHA := pr.GetHead().GetSHA()
+
+	storedDiff, storedSHA, err := config.C.DB.GetPullRequest(number, owner, repo)
+	if err != nil {
+		return "", err
+	}
+	// Comments saved before commit tracking were written against the stored head.
+	notAtHead := func(locals []database.LocalComment, storedSHA string) int {
+		n := 0
+		for _, c := range locals {
+			sha := c.CommitSHA
+			if sha == "" {
+				sha = storedSHA
+			}
+			if c.ReplyToID == nil && !c.Orphaned && sha != headSHA {
+				n++
+			}
+		}
+		return n
+	}
+	if notAtHead(locals, storedSHA) == 0 {
+		return headSHA, nil
+	}
+
+	oldDiff, previousSHA, newDiff := storedDiff, storedSHA, storedDiff
+	if storedSHA == headSHA {
+		oldDiff, previousSHA, _ = config.C.DB.GetPreviousPullRequest(number, owner, repo)
+	} else {
+		if newDiff, err = f.GetDiff(owner, repo, number); err != nil {
+			return "", err
+		}
+		config.C.DB.UpsertPullRequest(number, owner, repo, headSHA, newDiff)
+	}
+	oldParsedDiff, _ := utils.Parse(oldDiff)
+	parsedDiff, _ := utils.Parse(newDiff)
+	reanchorLocalComments(f, owner, repo, number, previousSHA, headSHA, oldParsedDiff, parsedDiff)
+
+	if locals, err = config.C.DB.GetLocalCommentsForPR(owner, repo, number); err != nil {
+		return "", err
+	}
+	if n := notAtHead(locals, headSHA); n > 0 {
+		return "", fmt.Errorf("%d local comment(s) could not be moved onto the new head %s; try again", n, headSHA)
+	}
+	return headSHA, nil
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/forge"
+	"crs/testutil"
+	"crs/utils"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func mustParseDiff(t *testing.T, raw string) *utils.Diff {
+	t.Helper()
+	diff, err := utils.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return diff
+}
+
+const diffHeader = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+`
+
+func TestReanchorComment(t *testing.T) {
+	// The PR adds "b"; the author then pushes a commit adding "x" at the top.
+	oldDiff := mustParseDiff(t, diffHeader+"@@ -1,3 +1,4 @@\n a\n+b\n c\n d\n")
+	newDiff := mustParseDiff(t, diffHeader+"@@ -1,3 +1,5 @@\n+x\n a\n+b\n c\n d\n")
+	body := "why b?"
+	comment := database.LocalComment{
+		ID:            1,
+		Filename:      "main.go",
+		Position:      2,
+		Body:          &body,
+		CommentAnchor: database.CommentAnchor{Line: 2, Side: "RIGHT"},
+	}
+
+	tests := []struct {
+		name         string
+		comment      database.LocalComment
+		headDiff     string
+		wantOK       bool
+		wantLine     int64
+		wantPosition int64
+	}{
+		{
+			name:         "line shifted by an insertion above",
+			comment:      comment,
+			headDiff:     diffHeader + "@@ -1,1 +1,2 @@\n+x\n a\n",
+			wantOK:       true,
+			wantLine:     3,
+			wantPosition: 3,
+		},
+		{
+			name: "legacy comment with only a position",
+			comment: database.LocalComment{
+				ID:       2,
+				Filename: "main.go",
+				Position: 2,
+				Body:     &body,
+			},
+			headDiff:     diffHeader + "@@ -1,1 +1,2 @@\n+x\n a\n",
+			wantOK:       true,
+			wantLine:     3,
+			wantPosition: 3,
+		},
+		{
+			name:     "commented line rewritten",
+			comment:  comment,
+			headDiff: diffHeader + "@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+		},
+		{
+			name:    "file deleted",
+			comment: comment,
+			headDiff: `diff --git a/main.go b/main.go
+deleted file mode 100644
+index 1111111..0000000
+--- a/main.go
++++ /dev/null
+@@ -1,4 +0,0 @@
+-a
+-b
+-c
+-d
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename, position, anchor, ok := reanchorComment(tt.comment, mustParseDiff(t, tt.headDiff), oldDiff, newDiff)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if filename != "main.go" || anchor.Line != tt.wantLine || anchor.Side != "RIGHT" || position != tt.wantPosition {
+				t.Errorf("got %s line %d %s position %d, want main.go line %d RIGHT position %d",
+					filename, anchor.Line, anchor.Side, position, tt.wantLine, tt.wantPosition)
+			}
+		})
+	}
+}
+
+type headDiffForge struct {
+	forge.Forge
+	diff string
+}
+
+func (f headDiffForge) CompareDiff(owner, repo, base, head string) (string, error) {
+	return f.diff, nil
+}
+
+func TestReanchorLocalComments_SyncedDrafts(t *testing.T) {
+	var deleted []string
+	var created github.PullRequestReviewRequest
+	// A BaseURL makes an enterprise client, which puts the API under /api/v3
+	server := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete && (r.URL.Path == "/repos/org/api/pulls/comments/101" || r.URL.Path == "/repos/org/api/pulls/comments/102"):
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7":
+			fmt.Fprint(w, `{"number":7,"head":{"sha":"head2"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7/reviews":
+			fmt.Fprint(w, `[{"id":1,"state":"PENDING"}]`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7/reviews/1/comments":
+			fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/repos/org/api/pulls/7/reviews/1":
+			fmt.Fprint(w, `{"id":1}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/org/api/pulls/7/reviews":
+			json.NewDecoder(r.Body).Decode(&created)
+			fmt.Fprint(w, `{"id":2,"state":"PENDING"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7/reviews/2/comments":
+			fmt.Fprint(w, `[{"id":201,"path":"main.go","line":3,"side":"RIGHT","body":"why b?"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+	defer server.Close()
+
+	db := testutil.NewDB(t)
+	t.Setenv("CRS_GITHUB_TOKEN", "token")
+	config.C = config.Config{
+		DB:               db,
+		SyncDraftReviews: true,
+		GithubHosts:      []config.GithubHost{{Host: config.DefaultGithubHost, BaseURL: server.URL}},
+	}
+
+	moved, err := db.InsertLocalComment(database.LocalComment{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Position: 2,
+		Body: github.String("why b?"), GithubCommentID: github.Int64(101), CommitSHA: "head1",
+		CommentAnchor: database.CommentAnchor{Line: 2, Side: "RIGHT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not part of the PR's diff anymore, so it is orphaned
+	orphaned, err := db.InsertLocalComment(database.LocalComment{Owner: "org", Repo: "api", Number: 7, Filename: "other.go", Position: 2,
+		Body: github.String("gone"), GithubCommentID: github.Int64(102), CommitSHA: "head1",
+		CommentAnchor: database.CommentAnchor{Line: 2, Side: "RIGHT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldDiff := mustParseDiff(t, diffHeader+"@@ -1,3 +1,4 @@\n a\n+b\n c\n d\n")
+	newDiff := mustParseDiff(t, diffHeader+"@@ -1,3 +1,5 @@\n+x\n a\n+b\n c\n d\n")
+	f := headDiffForge{diff: diffHeader + "@@ -1,1 +1,2 @@\n+x\n a\n"}
+	reanchorLocalComments(f, "org", "api", 7, "head1", "head2", oldDiff, newDiff)
+
+	if len(deleted) != 2 {
+		t.Errorf("deleted pending review comments %v, want both mirrors removed", deleted)
+	}
+	if created.GetCommitID() != "head2" || len(created.Comments) != 1 || created.Comments[0].GetLine() != 3 || created.Comments[0].GetPath() != "main.go" {
+		t.Errorf("recreated pending review at %q with %v, want only the moved comment at head2 main.go line 3", created.GetCommitID(), created.Comments)
+	}
+
+	c, _ := db.GetLocalComment(moved.ID)
+	if c.Line != 3 || c.CommitSHA != "head2" || c.GithubCommentID == nil || *c.GithubCommentID != 201 {
+		t.Errorf("moved comment = line %d at %s linked to %v, want line 3 at head2 linked to 201", c.Line, c.CommitSHA, c.GithubCommentID)
+	}
+	c, _ = db.GetLocalComment(orphaned.ID)
+	if !c.Orphaned || c.GithubCommentID != nil {
+		t.Errorf("orphaned comment = orphaned %v linked to %v, want it orphaned and unlinked", c.Orphaned, c.GithubCommentID)
+	}
+}
+
+func TestSubmitReview_HeadMovedSinceLastView(t *testing.T) {
+	oldDiff := diffHeader + "@@ -1,3 +1,4 @@\n a\n+b\n c\n d\n"
+	newDiff := diffHeader + "@@ -1,3 +1,5 @@\n+x\n a\n+b\n c\n d\n"
+	var review github.PullRequestReviewRequest
+	server := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/pulls/7":
+			if strings.Contains(r.Header.Get("Accept"), "diff") {
+				fmt.Fprint(w, newDiff)
+				return
+			}
+			fmt.Fprint(w, `{"number":7,"head":{"sha":"head2"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/api/compare/head1...head2":
+			fmt.Fprint(w, diffHeader+"@@ -1,1 +1,2 @@\n+x\n a\n")
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/org/api/pulls/7/reviews":
+			json.NewDecoder(r.Body).Decode(&review)
+			fmt.Fprint(w, `{"id":1}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+	defer server.Close()
+
+	db := testutil.NewDB(t)
+	t.Setenv("CRS_GITHUB_TOKEN", "token")
+	config.C = config.Config{
+		DB:          db,
+		GithubHosts: []config.GithubHost{{Host: config.DefaultGithubHost, BaseURL: server.URL}},
+	}
+	// Last viewed at head1; the author pushed head2 since
+	if err := db.UpsertPullRequest(7, "org", "api", "head1", oldDiff); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.InsertLocalComment(database.LocalComment{Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Position: 2,
+		Body: github.String("why b?"), CommitSHA: "head1", CommentAnchor: database.CommentAnchor{Line: 2, Side: "RIGHT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &RPCHandler{Log: slog.New(slog.DiscardHandler)}
+	var reply SubmitReviewReply
+	if err := h.SubmitReview(&SubmitReviewArgs{Owner: "org", Repo: "api", Number: 7, Event: "COMMENT"}, &reply); err != nil {
+		t.Fatalf("SubmitReview() error = %v", err)
+	}
+
+	if review.GetCommitID() != "head2" {
+		t.Errorf("review commit_id = %q, want the new head head2", review.GetCommitID())
+	}
+	if len(review.Comments) != 1 || review.Comments[0].GetLine() != 3 {
+		t.Errorf("review comments = %v, want the comment moved to line 3", review.Comments)
+	}
+}
@@ -3,7 +3,10 @@ package server
 import (
 	"crs/config"
 	"crs/database"
+	"crs/events"
+	"crs/forge"
 	"crs/git_tools"
+	"crs/jira"
 	"crs/org"
 	"crs/utils"
 	"context"
@@ -48,6 +51,11 @@ func (r *OrgRenderer) RenderAllSectionsToString() (string, error) {
 		return sections[i].SectionName < sections[j].SectionName
 	})
 
+	hidden, err := r.db.GetHiddenItems(time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+
 	// Build the org file content
 	var content strings.Builder
 
@@ -57,9 +65,10 @@ func (r *OrgRenderer) RenderAllSectionsToString() (string, error) {
 		if err != nil {
 			return "", err
 		}
+		items, hiddenCount := visibleItems(section, items, hidden)
 
 		// Build section header
-		sectionHeader := r.buildSectionHeader(section, items)
+		sectionHeader := r.buildSectionHeader(section, items, hiddenCount)
 		content.WriteString(sectionHeader)
 		content.WriteString("\n")
 
@@ -100,6 +109,11 @@ func (r *OrgRenderer) RenderAndGetItems() (string, []ReviewItem, error) {
 		return "", nil, err
 	}
 
+	hidden, err := r.db.GetHiddenItems(time.Now().Unix())
+	if err != nil {
+		return "", nil, err
+	}
+
 	// Group items by sectionID
 	itemsBySection := make(map[int64][]*database.Item)
 	for _, item := range allItems {
@@ -112,10 +126,10 @@ func (r *OrgRenderer) RenderAndGetItems() (string, []ReviewItem, error) {
 
 	for _, section := range sections {
 		// Get items for this section
-		items := itemsBySection[section.ID]
+		items, hiddenCount := visibleItems(section, itemsBySection[section.ID], hidden)
 
 		// Build section header
-		sectionHeader := r.buildSectionHeader(section, items)
+		sectionHeader := r.buildSectionHeader(section, items, hiddenCount)
 		content.WriteString(sectionHeader)
 		content.WriteString("\n")
 
@@ -152,6 +166,12 @@ type ReviewItem struct {
 	Number   int    `json:"number"`
 	Author   string `json:"author"`
 	URL      string `json:"url"`
+	// Set for items synced by NotificationsWorkflow
+	Reason         string `json:"reason,omitempty"`
+	NotificationID string `json:"notification_id,omitempty"`
+	// Set when you gave the item a status with SetItemStatus; Status is then UserStatus
+	UserStatus   string `json:"user_status,omitempty"`
+	SyncedStatus string `json:"synced_status,omitempty"`
 }
 
 // GetAllReviewItems returns structured review items from all sections
@@ -161,6 +181,11 @@ func (r *OrgRenderer) GetAllReviewItems() ([]ReviewItem, error) {
 		return nil, err
 	}
 
+	hidden, err := r.db.GetHiddenItems(time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
 	var reviewItems []ReviewItem
 
 	for _, section := range sections {
@@ -168,6 +193,7 @@ func (r *OrgRenderer) GetAllReviewItems() ([]ReviewItem, error) {
 		if err != nil {
 			return nil, err
 		}
+		items, _ = visibleItems(section, items, hidden)
 
 		for _, item := range items {
 			reviewItem := r.parseItemToReviewItem(item, section.SectionName, section.Priority)
@@ -178,6 +204,47 @@ func (r *OrgRenderer) GetAllReviewItems() ([]ReviewItem, error) {
 	return reviewItems, nil
 }
 
+// HiddenCounts returns the number of snoozed and muted items in each section, by section name.
+func (r *OrgRenderer) HiddenCounts() (map[string]int, error) {
+	sections, err := r.db.GetAllSections()
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := r.db.GetHiddenItems(time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, section := range sections {
+		if len(hidden[section.ID]) == 0 {
+			continue
+		}
+		items, err := r.db.GetItemsBySection(section.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, count := visibleItems(section, items, hidden); count > 0 {
+			counts[section.SectionName] = count
+		}
+	}
+	return counts, nil
+}
+
+// visibleItems drops the snoozed and muted items of a section and returns how many it dropped.
+func visibleItems(section *database.Section, items []*database.Item, hidden map[int64]map[string]string) ([]*database.Item, int) {
+	sectionHidden := hidden[section.ID]
+	if len(sectionHidden) == 0 {
+		return items, 0
+	}
+	visible := []*database.Item{}
+	for _, item := range items {
+		if _, ok := sectionHidden[item.Identifier]; !ok {
+			visible = append(visible, item)
+		}
+	}
+	return visible, len(items) - len(visible)
+}
+
 // parseItemToReviewItem extracts structured metadata from an item's details
 func (r *OrgRenderer) parseItemToReviewItem(item *database.Item, sectionName string, priority int) ReviewItem {
 	details, err := item.GetDetails()
@@ -188,9 +255,13 @@ func (r *OrgRenderer) parseItemToReviewItem(item *database.Item, sectionName str
 	reviewItem := ReviewItem{
 		Section:  sectionName,
 		Priority: priority,
-		Status:   item.Status,
+		Status:   item.DisplayStatus(),
 		Title:    item.Title,
 	}
+	if item.UserStatus != "" {
+		reviewItem.UserStatus = item.UserStatus
+		reviewItem.SyncedStatus = item.Status
+	}
 
 	for _, line := range details {
 		line = strings.TrimSpace(line)
@@ -204,15 +275,14 @@ func (r *OrgRenderer) parseItemToReviewItem(item *database.Item, sectionName str
 			}
 		}
 
-		// Parse Repo: owner/repo
+		// Parse Repo: owner/repo. GitLab owners are namespaces which can contain slashes.
 		if strings.HasPrefix(line, "Repo:") {
 			repoStr := strings.TrimSpace(strings.TrimPrefix(line, "Repo:"))
-			parts := strings.Split(repoStr, "/")
-			if len(parts) >= 2 {
-				reviewItem.Owner = parts[0]
-				reviewItem.Repo = parts[1]
-			} else if len(parts) == 1 {
-				reviewItem.Repo = parts[0]
+			if slash := strings.LastIndex(repoStr, "/"); slash >= 0 {
+				reviewItem.Owner = repoStr[:slash]
+				reviewItem.Repo = repoStr[slash+1:]
+			} else {
+				reviewItem.Repo = repoStr
 			}
 			continue
 		}
@@ -223,6 +293,15 @@ func (r *OrgRenderer) parseItemToReviewItem(item *database.Item, sectionName str
 			continue
 		}
 
+		if strings.HasPrefix(line, "Reason:") {
+			reviewItem.Reason = strings.TrimSpace(strings.TrimPrefix(line, "Reason:"))
+			continue
+		}
+		if strings.HasPrefix(line, "Notification:") {
+			reviewItem.NotificationID = strings.TrimSpace(strings.TrimPrefix(line, "Notification:"))
+			continue
+		}
+
 		// Parse URL (usually starts with https://github.com)
 		if strings.HasPrefix(line, "https://") {
 			reviewItem.URL = line
@@ -253,10 +332,10 @@ func (r *OrgRenderer) RenderFile(filename, orgFileDir string) error {
 	return os.WriteFile(orgFilePath, []byte(content), 0644)
 }
 
-func (r *OrgRenderer) buildSectionHeader(section *database.Section, items []*database.Item) string {
+func (r *OrgRenderer) buildSectionHeader(section *database.Section, items []*database.Item, hiddenCount int) string {
 	doneCount := 0
 	for _, item := range items {
-		if item.Status == "DONE" || item.Status == "CANCELLED" {
+		if status := item.DisplayStatus(); status == "DONE" || status == "CANCELLED" {
 			doneCount++
 		}
 	}
@@ -269,7 +348,11 @@ func (r *OrgRenderer) buildSectionHeader(section *database.Section, items []*dat
 	indentStars := strings.Repeat("*", section.IndentLevel-1)
 	ratio := fmt.Sprintf("[%d/%d]", doneCount, len(items))
 
-	return fmt.Sprintf("%s %s %s %s", indentStars, status, section.SectionName, ratio)
+	header := fmt.Sprintf("%s %s %s %s", indentStars, status, section.SectionName, ratio)
+	if hiddenCount > 0 {
+		header += fmt.Sprintf(" (%d hidden)", hiddenCount)
+	}
+	return header
 }
 
 func (r *OrgRenderer) buildItemLines(item *database.Item, indentLevel int) []string {
@@ -287,7 +370,7 @@ func (r *OrgRenderer) buildItemLines(item *database.Item, indentLevel int) []str
 
 	// Build the title line
 	indentStars := strings.Repeat("*", indentLevel)
-	titleLine := fmt.Sprintf("%s %s %s", indentStars, item.Status, item.Title)
+	titleLine := fmt.Sprintf("%s %s %s", indentStars, item.DisplayStatus(), item.Title)
 
 	// Add tags
 	if len(tags) > 0 {
@@ -339,6 +422,10 @@ type PRComment interface {
 	GetCreatedAt() time.Time
 	IsOutdated() bool
 	GetCommitID() string
+	GetAnchor() database.CommentAnchor
+	GetOriginalLines() string // Lines a local suggestion replaces, "" when unknown
+	IsResolved() bool         // Whether the comment's review thread was resolved
+	GetResolvedBy() string
 }
 
 type CommentJSON struct {
@@ -350,6 +437,20 @@ type CommentJSON struct {
 	InReplyTo int64     `json:"in_reply_to"`
 	CreatedAt time.Time `json:"created_at"`
 	Outdated  bool      `json:"outdated"`
+	Line      int64     `json:"line,omitempty"`
+	Side      string    `json:"side,omitempty"`
+	StartLine int64     `json:"start_line,omitempty"`
+	StartSide string    `json:"start_side,omitempty"`
+	Orphaned  bool      `json:"orphaned,omitempty"` // Local comment whose line vanished after a push
+	Kind      string    `json:"kind,omitempty"`     // "suggestion" for suggested changes
+	// For local suggestions, the lines the suggestion replaces
+	OriginalLines string `json:"original_lines,omitempty"`
+	// Review thread the comment belongs to, and whether someone resolved it
+	ThreadID   string `json:"thread_id,omitempty"`
+	Resolved   bool   `json:"resolved,omitempty"`
+	ResolvedBy string `json:"resolved_by,omitempty"`
+	// A private note from AddNote, shown inline but never submitted
+	Private bool `json:"private,omitempty"`
 }
 
 type ReviewJSON struct {
@@ -369,41 +470,46 @@ type CommitJSON struct {
 	URL     string `json:"url"`
 }
 
+
 type PRMetadata struct {
-	Number             int      `json:"number"`
-	Title              string   `json:"title"`
-	Author             string   `json:"author"`
-	BaseRef            string   `json:"base_ref"`
-	HeadRef            string   `json:"head_ref"`
-	State              string   `json:"state"`
-	Milestone          string   `json:"milestone"`
-	Labels             []string `json:"labels"`
-	Assignees          []string `json:"assignees"`
-	Reviewers          []string `json:"reviewers"`           // Requested individual reviewers
-	RequestedTeams     []string `json:"requested_teams"`     // Requested team reviewers
-	ApprovedBy         []string `json:"approved_by"`         // Logins of users who approved
-	ChangesRequestedBy []string `json:"changes_requested_by"` // Logins of users who requested changes
-	CommentedBy        []string `json:"commented_by"`         // Logins of users who commented (non-approval/non-request)
-	Draft              bool     `json:"draft"`
-	CIStatus           string   `json:"ci_status"`
-	CIFailures         []string `json:"ci_failures"`
-	Body               string   `json:"body"`
-	URL                string   `json:"url"`
-	WorktreePath       string   `json:"worktree_path"`
+	Number             int                 `json:"number"`
+	Title              string              `json:"title"`
+	Author             string              `json:"author"`
+	BaseRef            string              `json:"base_ref"`
+	HeadRef            string              `json:"head_ref"`
+	State              string              `json:"state"`
+	Milestone          string              `json:"milestone"`
+	Labels             []string            `json:"labels"`
+	Assignees          []string            `json:"assignees"`
+	Reviewers          []string            `json:"reviewers"`            // Requested individual reviewers
+	RequestedTeams     []string            `json:"requested_teams"`      // Requested team reviewers
+	ApprovedBy         []string            `json:"approved_by"`          // Logins of users who approved
+	ChangesRequestedBy []string            `json:"changes_requested_by"` // Logins of users who requested changes
+	CommentedBy        []string            `json:"commented_by"`         // Logins of users who commented (non-approval/non-request)
+	Draft              bool                `json:"draft"`
+	CIStatus           string              `json:"ci_status"`
+	CIFailures         []string            `json:"ci_failures"`
+	Body               string              `json:"body"`
+	URL                string              `json:"url"`
+	WorktreePath       string              `json:"worktree_path"`
+	JiraIssues         []jira.IssueDetails `json:"jira_issues"` // Issues linked by key or by a Jira workflow
 }
 
 type PRDetails struct {
-	Metadata PRMetadata    `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments         []CommentJSON `json:"comments"`
-	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews          []ReviewJSON  `json:"reviews"`
-	Commits          []CommitJSON  `json:"commits"`
+	Metadata         PRMetadata      `json:"metadata"`
+	Diff             string          `json:"diff"`
+	Comments         []CommentJSON   `json:"comments"`
+	OutdatedComments []CommentJSON   `json:"outdated_comments"`
+	Reviews          []ReviewJSON    `json:"reviews"`
+	Commits          []CommitJSON    `json:"commits"`
+	Notes            []NoteJSON      `json:"notes"`
+	Checklists       []ChecklistJSON `json:"checklists"`
 }
 
 // GitHubPRComment wraps *github.PullRequestComment to implement PRComment interface
 type GitHubPRComment struct {
 	*github.PullRequestComment
+	Thread *git_tools.ReviewThread // Review thread of the comment, if known
 }
 
 // GetLogin returns the login of the comment author
@@ -468,6 +574,30 @@ func (c *GitHubPRComment) GetCommitID() string {
 	return ""
 }
 
+func (c *GitHubPRComment) GetAnchor() database.CommentAnchor {
+	return database.CommentAnchor{
+		Line:      int64(c.PullRequestComment.GetLine()),
+		Side:      c.PullRequestComment.GetSide(),
+		StartLine: int64(c.PullRequestComment.GetStartLine()),
+		StartSide: c.PullRequestComment.GetStartSide(),
+	}
+}
+
+func (c *GitHubPRComment) GetOriginalLines() string {
+	return ""
+}
+
+func (c *GitHubPRComment) IsResolved() bool {
+	return c.Thread != nil && c.Thread.IsResolved
+}
+
+func (c *GitHubPRComment) GetResolvedBy() string {
+	if c.Thread != nil {
+		return c.Thread.ResolvedBy
+	}
+	return ""
+}
+
 // LocalPRComment wraps database.LocalComment to implement PRComment interface
 type LocalPRComment struct {
 	*database.LocalComment
@@ -514,11 +644,30 @@ func (c *LocalPRComment) GetCreatedAt() time.Time {
 	return time.Time{}
 }
 
+// IsOutdated reports orphaned comments, whose line disappeared in a later push, so they are
+// listed with the outdated comments instead of rendering at a stale position.
 func (c *LocalPRComment) IsOutdated() bool {
-	return false
+	return c.Orphaned
 }
 
 func (c *LocalPRComment) GetCommitID() string {
+	return c.CommitSHA
+}
+
+func (c *LocalPRComment) GetAnchor() database.CommentAnchor {
+	return c.CommentAnchor
+}
+
+func (c *LocalPRComment) GetOriginalLines() string {
+	return c.OriginalLines
+}
+
+// IsResolved is false for local comments, which are not part of a GitHub thread yet
+func (c *LocalPRComment) IsResolved() bool {
+	return false
+}
+
+func (c *LocalPRComment) GetResolvedBy() string {
 	return ""
 }
 
@@ -526,7 +675,7 @@ func (c *LocalPRComment) GetCommitID() string {
 func convertToPRComments(comments []*github.PullRequestComment) []PRComment {
 	result := make([]PRComment, len(comments))
 	for i, comment := range comments {
-		result[i] = &GitHubPRComment{comment}
+		result[i] = &GitHubPRComment{PullRequestComment: comment}
 	}
 	return result
 }
@@ -541,26 +690,17 @@ func convertLocalCommentsToPRComments(localComments []database.LocalComment) []P
 	return result
 }
 
-// convertIssueCommentToPRComment converts a *github.IssueComment to *github.PullRequestComment
-func convertIssueCommentToPRComment(ic *github.IssueComment) *github.PullRequestComment {
-	return &github.PullRequestComment{
-		ID:        ic.ID,
-		Body:      ic.Body,
-		User:      ic.User,
-		CreatedAt: ic.CreatedAt,
-		UpdatedAt: ic.UpdatedAt,
-		URL:       ic.URL,
-		HTMLURL:   ic.HTMLURL,
-	}
-}
-
 func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDetails, error) {
-	client := git_tools.GetGithubClient()
-	ctx := context.Background()
+	f := forge.For(owner, repo)
+	// Pending reviews, resolvable threads, requested teams and CI checks are GitHub features
+	gh, isGitHub := f.(*forge.GitHub)
 
 	var metadata PRMetadata
 	var headSHA string
 	needsFreshFetch := skipCache
+	// The head and diff we last stored, used to re-anchor local comments when the author pushes
+	var previousSHA, previousDiff string
+	headMoved := false
 
 	// 1. Try to load metadata from cache first (unless skipCache)
 	if !skipCache {
@@ -570,8 +710,10 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 				slog.Debug("Using cached PR metadata", "pr", number, "repo", repo)
 				// We have cached metadata, but we still need SHA for diff lookup
 				// Get it from the PullRequests table
-				_, sha, _ := config.C.DB.GetPullRequest(number, repo)
+				_, sha, _ := config.C.DB.GetPullRequest(number, owner, repo)
 				headSHA = sha
+				// The issues go stale on their own TTL, independent of the PR's metadata
+				metadata.JiraIssues = getLinkedJiraIssues(owner, repo, number, metadata, false)
 			} else {
 				needsFreshFetch = true
 			}
@@ -582,7 +724,7 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 
 	// 2. Fetch fresh PR details from GitHub if needed
 	if needsFreshFetch {
-		pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+		pr, err := f.GetPR(owner, repo, number)
 		if err != nil {
 			// If we have cached data, return that instead of failing
 			if metadata.Number != 0 {
@@ -596,8 +738,24 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 				headSHA = *pr.Head.SHA
 			}
 
+			// Let attached clients know the author pushed since we last looked
+			previousDiff, previousSHA, _ = config.C.DB.GetPullRequest(number, owner, repo)
+			if previousSHA != "" && headSHA != "" && previousSHA != headSHA {
+				headMoved = true
+				events.Publish(events.PRUpdated, events.PRUpdatedParams{
+					Owner:       owner,
+					Repo:        repo,
+					Number:      number,
+					PreviousSHA: previousSHA,
+					SHA:         headSHA,
+				})
+			}
+
 			// Fetch Reviewers (Requested)
-			reviewers, _ := GetRequestedReviewers(owner, repo, number, skipCache)
+			reviewers := &github.Reviewers{Users: pr.RequestedReviewers}
+			if isGitHub {
+				reviewers, _ = GetRequestedReviewers(owner, repo, number, skipCache)
+			}
 			reviewerLogins := []string{}
 			teamLogins := []string{}
 			if reviewers != nil {
@@ -610,7 +768,7 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 			}
 
 			// Fetch actual reviews to see who has approved/commented/etc.
-			ghReviews, _, _ := client.PullRequests.ListReviews(ctx, owner, repo, number, nil)
+			ghReviews, _ := f.ListReviews(owner, repo, number)
 			approvedBy := []string{}
 			changesRequestedBy := []string{}
 			commentedBy := []string{}
@@ -658,7 +816,7 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 			// Fetch CI Status
 			var ciStatus string
 			var ciFailures []string
-			if headSHA != "" {
+			if headSHA != "" && isGitHub {
 				status, err := GetLatestCIStatus(owner, repo, number, headSHA, skipCache)
 				if err == nil && status != nil {
 					total := 0
@@ -737,6 +895,8 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 				metadata.Milestone = pr.Milestone.GetTitle()
 			}
 
+			metadata.JiraIssues = getLinkedJiraIssues(owner, repo, number, metadata, skipCache)
+
 			// Cache the metadata
 			metadataJSON, err := json.Marshal(metadata)
 			if err == nil {
@@ -745,26 +905,37 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 		}
 	}
 
-	// 3. Fetch Diff (with caching)
-	var diff string
-	if !skipCache {
-		cachedDiff, _, err := config.C.DB.GetPullRequest(number, repo)
+	// 3. Fetch Diff (with caching). A cached diff is stale once the head moved.
+	var diff, diffSHA string
+	if !skipCache && !headMoved {
+		cachedDiff, cachedSHA, err := config.C.DB.GetPullRequest(number, owner, repo)
 		if err == nil && cachedDiff != "" {
 			diff = cachedDiff
+			diffSHA = cachedSHA
 		}
 	}
 	if diff == "" {
-		d, _, err := client.PullRequests.GetRaw(ctx, owner, repo, number, github.RawOptions{Type: github.Diff})
+		d, err := f.GetDiff(owner, repo, number)
 		if err != nil {
 			slog.Error("Error getting PR diff", "pr", number, "repo", repo, "error", err)
 		} else {
 			diff = d
+			diffSHA = headSHA
 			// Store in cache
-			config.C.DB.UpsertPullRequest(number, repo, headSHA, diff)
+			config.C.DB.UpsertPullRequest(number, owner, repo, headSHA, diff)
 		}
 	}
 
 	parsedDiff, _ := utils.Parse(diff)
+	if diff != "" && headSHA != "" && diffSHA == headSHA {
+		// Move draft comments written against an older head onto the lines they now live on.
+		// When a sync stored the new head first, the old head's diff was kept alongside it.
+		if !headMoved {
+			previousDiff, previousSHA, _ = config.C.DB.GetPreviousPullRequest(number, owner, repo)
+		}
+		oldParsedDiff, _ := utils.Parse(previousDiff)
+		reanchorLocalComments(f, owner, repo, number, previousSHA, headSHA, oldParsedDiff, parsedDiff)
+	}
 	formattedDiff := diff
 	if parsedDiff != nil {
 		formattedDiff = formatDiff(parsedDiff)
@@ -778,33 +949,49 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 			json.Unmarshal([]byte(cachedCommentsJSON), &githubComments)
 		}
 	}
+	commentsFetched := false
 	if githubComments == nil {
-		opts := github.PullRequestListCommentsOptions{}
-		githubComments, _, _ = client.PullRequests.ListComments(ctx, owner, repo, number, &opts)
-
-		issueComments, _, _ := client.Issues.ListComments(ctx, owner, repo, number, nil)
-		for _, ic := range issueComments {
-			githubComments = append(githubComments, convertIssueCommentToPRComment(ic))
+		commentsFetched = true
+		var err error
+		githubComments, err = f.ListComments(owner, repo, number)
+		if err != nil {
+			slog.Error("Error getting PR comments", "pr", number, "repo", repo, "error", err)
 		}
 
 		if githubComments != nil {
-			// Sort comments by creation date to maintain order
-			sort.Slice(githubComments, func(i, j int) bool {
-				return githubComments[i].CreatedAt.Before(*githubComments[j].CreatedAt)
-			})
-
 			commentsJSON, _ := json.Marshal(githubComments)
 			config.C.DB.UpsertPRComments(number, repo, string(commentsJSON))
 		}
 	}
 
+	if config.C.SyncDraftReviews && isGitHub {
+		// Pull in edits made to the pending review from the web UI or another machine.
+		pending, err := reconcileDraftReview(gh.Client, owner, repo, number)
+		if err != nil {
+			slog.Error("Error reconciling pending review", "pr", number, "repo", repo, "error", err)
+		} else if pending != nil {
+			githubComments = filterPendingReviewComments(githubComments, pending.GetID())
+		}
+	}
+
 	comments := convertToPRComments(githubComments)
 	comments = filterComments(comments)
 
 	localComments, _ := config.C.DB.GetLocalCommentsForPR(owner, repo, number)
 	comments = append(comments, convertLocalCommentsToPRComments(localComments)...)
+	notes, err := config.C.DB.GetNotesForPR(owner, repo, number)
+	if err != nil {
+		slog.Error("Error fetching notes", "pr", number, "repo", repo, "error", err)
+	}
+	comments = append(comments, noteComments(notes, parsedDiff)...)
 
 	commentJSONs, outdatedCommentJSONs := splitComments(comments)
+	if len(githubComments) > 0 && isGitHub {
+		// Freshly fetched comments may belong to threads the cache doesn't know yet.
+		threads := loadReviewThreads(gh.Client, owner, repo, number, skipCache || commentsFetched)
+		applyThreadState(commentJSONs, threads)
+		applyThreadState(outdatedCommentJSONs, threads)
+	}
 
 	// 5. Load Reviews from DB
 	var reviews []ReviewJSON
@@ -816,7 +1003,7 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 	// If not in DB, fetch fresh
 	if reviews == nil {
 
-		ghReviews, _, _ := client.PullRequests.ListReviews(ctx, owner, repo, number, nil)
+		ghReviews, _ := f.ListReviews(owner, repo, number)
 		var formattedReviews []ReviewJSON
 		for _, r := range ghReviews {
 			var submittedAt time.Time
@@ -844,7 +1031,7 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 	// effectively moving the fetch from GetFullPRResponse to here.
 	// If we want to truly "read from cache", we should add DB support for commits, but 
 	// consolidating the fetch here is the first step and avoids the double fetch in GetFullPRResponse.
-	ghCommits, _, err := client.PullRequests.ListCommits(ctx, owner, repo, number, nil)
+	ghCommits, err := f.ListCommits(owner, repo, number)
 	if err != nil {
 		slog.Error("Error fetching commits", "error", err)
 	} else {
@@ -864,12 +1051,14 @@ func GetPRDetails(owner string, repo string, number int, skipCache bool) (*PRDet
 	}
 
 	return &PRDetails{
-		Metadata: metadata,
-		Diff:     formattedDiff,
-		Comments: commentJSONs,
+		Metadata:         metadata,
+		Diff:             formattedDiff,
+		Comments:         commentJSONs,
 		OutdatedComments: outdatedCommentJSONs,
-		Reviews:  reviews,
-		Commits:  commits,
+		Reviews:          reviews,
+		Commits:          commits,
+		Notes:            notesToJSON(notes),
+		Checklists:       prChecklists(owner, repo, number, diffSHA, parsedDiff, metadata.Labels),
 	}, nil
 }
 
@@ -966,6 +1155,9 @@ func GetFullPRResponse(owner string, repo string, number int, skipCache bool, de
 			sb.WriteString(fmt.Sprintf("  - %s\n", failure))
 		}
 	}
+	writeJiraDrawer(&sb, metadata.JiraIssues)
+	writeNotesDrawer(&sb, details.Notes)
+	writeChecklistsDrawer(&sb, details.Checklists)
 	sb.WriteString("\n")
 
 	// Commits
@@ -1248,14 +1440,20 @@ func (c *JSONPRComment) GetPath() string { return c.Path }
 func (c *JSONPRComment) GetCreatedAt() time.Time { return c.CreatedAt }
 func (c *JSONPRComment) IsOutdated() bool { return c.Outdated }
 func (c *JSONPRComment) GetCommitID() string { return "" } // Not in JSON currently
+func (c *JSONPRComment) GetAnchor() database.CommentAnchor {
+	return database.CommentAnchor{Line: c.Line, Side: c.Side, StartLine: c.StartLine, StartSide: c.StartSide}
+}
+func (c *JSONPRComment) GetOriginalLines() string { return c.OriginalLines }
+func (c *JSONPRComment) IsResolved() bool         { return c.Resolved }
+func (c *JSONPRComment) GetResolvedBy() string    { return c.ResolvedBy }
 
 
 func GetPRDiffWithInlineComments(owner string, repo string, number int, skipCache bool, pr *github.PullRequest) (string, int) {
-	client := git_tools.GetGithubClient()
+	client := git_tools.GetGithubClientFor(owner, repo)
 
 	// Check database first - skip API call if cached
 	if !skipCache {
-		cachedBody, cachedSha, err := config.C.DB.GetPullRequest(number, repo)
+		cachedBody, cachedSha, err := config.C.DB.GetPullRequest(number, owner, repo)
 		if err != nil {
 			slog.Error("Error checking database for PR", "pr", number, "repo", repo, "error", err)
 			// Continue to fetch from API
@@ -1358,6 +1556,11 @@ func processPRDiffWithComments(client *github.Client, owner string, repo string,
 		comments = filterComments(comments)
 	}
 
+	if len(githubComments) > 0 {
+		// Resolved threads collapse the same way as in the PR view.
+		markThreadState(comments, loadReviewThreads(client, owner, repo, number, skipCache))
+	}
+
 	// Fetch LocalComments from database for this specific PR and add them to the comments list
 	localComments, err := config.C.DB.GetLocalCommentsForPR(owner, repo, number)
 	if err != nil {
@@ -1433,8 +1636,11 @@ func buildCommentTree(tree []PRComment, filePath string, forceOutdated bool) str
 	rootComment := tree[0]
 	commentIDInt, _ := strconv.ParseInt(rootComment.GetID(), 10, 64)
 	header := "    ┌─ REVIEW COMMENT ─────────────────"
+	resolved := rootComment.IsResolved()
 	if forceOutdated || rootComment.IsOutdated() {
 		header = "    ┌─ REVIEW COMMENT [OUTDATED] ──────"
+	} else if resolved {
+		header = "    ┌─ REVIEW COMMENT [RESOLVED] ──────"
 	} else if rootComment.GetPosition() == "" {
 		header = "    ┌─ FILE COMMENT ───────────────────"
 	}
@@ -1443,8 +1649,25 @@ func buildCommentTree(tree []PRComment, filePath string, forceOutdated bool) str
 	result = append(result, fmt.Sprintf("    │ %s : %d", rootComment.GetCreatedAt().Format(time.DateTime)+" "+treeAuthorsFromList(tree), commentIDInt))
 	result = append(result, "    │")
 
+	if resolved {
+		// Resolved discussions are collapsed to a summary line.
+		result = append(result, fmt.Sprintf("    │ Resolved by %s, %d comment(s) hidden", rootComment.GetResolvedBy(), len(tree)))
+		result = append(result, "    └──────────────────────────────────")
+		result = append(result, "")
+		return strings.Join(result, "\n")
+	}
+
 	for idx, comment := range tree {
-		cleanBody := escapeBodyString(comment.GetBody())
+		body := comment.GetBody()
+		if hasSuggestion(body) {
+			// Show suggested changes as a diff rather than a raw markdown block.
+			var original []string
+			if lines := comment.GetOriginalLines(); lines != "" {
+				original = strings.Split(lines, "\n")
+			}
+			body = renderSuggestion(body, original)
+		}
+		cleanBody := escapeBodyString(body)
 		commentLines := strings.Split(cleanBody, "\n")
 
 		if idx == 0 {
@@ -1654,6 +1877,18 @@ func splitComments(comments []PRComment) ([]CommentJSON, []CommentJSON) {
 			CreatedAt: c.GetCreatedAt(),
 			Outdated:  isOutdated,
 		}
+		anchor := c.GetAnchor()
+		item.Line, item.Side, item.StartLine, item.StartSide = anchor.Line, anchor.Side, anchor.StartLine, anchor.StartSide
+		if hasSuggestion(item.Body) {
+			item.Kind = database.CommentKindSuggestion
+		}
+		item.OriginalLines = c.GetOriginalLines()
+		if local, ok := c.(*LocalPRComment); ok {
+			item.Orphaned = local.Orphaned
+		}
+		if _, ok := c.(*NotePRComment); ok {
+			item.Private = true
+		}
 		if isOutdated {
 			outdated = append(outdated, item)
 		} else {
@@ -1676,7 +1911,7 @@ func filterComments(comments []PRComment) []PRComment {
 }
 
 func GetRequestedReviewers(owner, repo string, number int, skipCache bool) (*github.Reviewers, error) {
-	client := git_tools.GetGithubClient()
+	client := git_tools.GetGithubClientFor(owner, repo)
 
 	if !skipCache {
 		cachedReviewersJSON, err := config.C.DB.GetRequestedReviewers(number, repo)
@@ -1715,7 +1950,7 @@ type CombinedPRStatus struct {
 }
 
 func GetLatestCIStatus(owner, repo string, prNumber int, sha string, skipCache bool) (*CombinedPRStatus, error) {
-	client := git_tools.GetGithubClient()
+	client := git_tools.GetGithubClientFor(owner, repo)
 
 	if !skipCache {
 		cachedStatusJSON, err := config.C.DB.GetCIStatus(prNumber, repo, sha)
@@ -47,7 +47,7 @@ func TestFormatComment(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatComment(&GitHubPRComment{tt.comment})
+			result := formatComment(&GitHubPRComment{PullRequestComment: tt.comment})
 			if result != tt.expected {
 				t.Errorf("formatComment() = %q, want %q", result, tt.expected)
 			}
@@ -3,12 +3,15 @@ package server
 import (
 	"crs/config"
 	"crs/database"
+	"crs/doctor"
+	"crs/forge"
 	"crs/git_tools"
+	"crs/workflows"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/rpc"
-	"net/rpc/jsonrpc"
 	"os"
 	"path/filepath"
 
@@ -22,14 +25,23 @@ import (
 var CurrentCount int
 
 func RunServer(log *slog.Logger) {
-	server := rpc.NewServer()
-	handler := &RPCHandler{Log: log}
-	if err := server.Register(handler); err != nil {
+	server, err := newRPCServer(log)
+	if err != nil {
 		log.Error("Error registering RPC handler", "error", err)
 		return
 	}
 
-	server.ServeCodec(jsonrpc.NewServerCodec(&Stdio{}))
+	server.ServeCodec(NewServerCodec(&Stdio{}))
+}
+
+// newRPCServer builds the rpc.Server shared by the stdio and socket transports.
+func newRPCServer(log *slog.Logger) (*rpc.Server, error) {
+	server := rpc.NewServer()
+	handler := &RPCHandler{Log: log}
+	if err := server.Register(handler); err != nil {
+		return nil, err
+	}
+	return server, nil
 }
 
 type Stdio struct{}
@@ -75,6 +87,8 @@ type GetReviewsArgs struct{}
 type GetReviewsReply struct {
 	Content string       `json:"content"` // Kept for simplicity on org-mode clients
 	Items   []ReviewItem `json:"items"`
+	// Number of snoozed and muted items left out of each section, by section name
+	HiddenCounts map[string]int `json:"hidden_counts"`
 }
 
 func (h *RPCHandler) GetAllReviews(args *GetReviewsArgs, reply *GetReviewsReply) error {
@@ -90,6 +104,11 @@ func (h *RPCHandler) GetAllReviews(args *GetReviewsArgs, reply *GetReviewsReply)
 	} else {
 		reply.Items = items
 	}
+	reply.HiddenCounts, err = renderer.HiddenCounts()
+	if err != nil {
+		h.Log.Error("Error counting hidden items", "error", err)
+		return err
+	}
 	return nil
 }
 
@@ -101,13 +120,15 @@ type GetPRstructArgs struct {
 }
 
 type GetPRReply struct {
-	Okay     bool          `json:"okay"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
-	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Okay             bool            `json:"okay"`
+	Content          string          `json:"content"`
+	Metadata         *PRMetadata     `json:"metadata"`
+	Diff             string          `json:"diff"`
+	Comments         []CommentJSON   `json:"comments"`
+	OutdatedComments []CommentJSON   `json:"outdated_comments"`
+	Reviews          []ReviewJSON    `json:"reviews"`
+	Notes            []NoteJSON      `json:"notes"`
+	Checklists       []ChecklistJSON `json:"checklists"`
 }
 
 func (h *RPCHandler) GetPR(args *GetPRstructArgs, reply *GetPRReply) error {
@@ -122,6 +143,8 @@ func (h *RPCHandler) GetPR(args *GetPRstructArgs, reply *GetPRReply) error {
 	reply.Comments = details.Comments
 	reply.OutdatedComments = details.OutdatedComments
 	reply.Reviews = details.Reviews
+	reply.Notes = details.Notes
+	reply.Checklists = details.Checklists
 	reply.Okay = true
 	return nil
 }
@@ -142,7 +165,7 @@ func (h *RPCHandler) fetchPRAndRunPlugins(owner, repo string, number int, skipCa
 	}
 
 	// Extract SHA from DB
-	_, sha, _ := config.C.DB.GetPullRequest(number, repo)
+	_, sha, _ := config.C.DB.GetPullRequest(number, owner, repo)
 
 	// Run plugins in background
 	metadataJSON, _ := json.Marshal(details.Metadata)
@@ -163,26 +186,58 @@ type AddCommentArgs struct {
 	Position  int64
 	Body      string
 	ReplyToID *int64
+	// Optional: the first diff position of a multi-line comment ending at Position.
+	StartPosition int64
+	// Optional: file lines to anchor to instead of diff positions. Derived from the
+	// positions when omitted.
+	Line      int64
+	Side      string
+	StartLine int64
+	StartSide string
 }
 
 type AddCommentReply struct {
-	ID       int64         `json:"id"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	ID               int64         `json:"id"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
 }
 
 func (h *RPCHandler) AddComment(args *AddCommentArgs, reply *AddCommentReply) error {
-	comment, err := config.C.DB.InsertLocalComment(args.Owner, args.Repo, args.Number, args.Filename, args.Position, &args.Body, args.ReplyToID)
+	position := args.Position
+	anchor := database.CommentAnchor{Line: args.Line, Side: args.Side, StartLine: args.StartLine, StartSide: args.StartSide}
+	diff, commitSHA := loadPRDiff(args.Owner, args.Repo, args.Number)
+	if args.ReplyToID == nil {
+		position, anchor = resolveCommentAnchor(diff, args.Filename, args.Position, args.StartPosition, anchor)
+	}
+
+	comment, err := config.C.DB.InsertLocalComment(database.LocalComment{
+		Owner:         args.Owner,
+		Repo:          args.Repo,
+		Number:        args.Number,
+		Filename:      args.Filename,
+		Position:      position,
+		Body:          &args.Body,
+		ReplyToID:     args.ReplyToID,
+		CommentAnchor: anchor,
+		CommitSHA:     commitSHA,
+	})
 	if err != nil {
 		h.Log.Error("Error inserting local comment", "error", err)
 		return err
 	}
 	reply.ID = comment.ID
 
+	if syncsDraftReview(args.Owner, args.Repo) && args.ReplyToID == nil {
+		// The comment is kept locally either way and is pushed again on the next change or on submit.
+		if err := pushDraftReview(git_tools.GetGithubClientFor(args.Owner, args.Repo), args.Owner, args.Repo, args.Number); err != nil {
+			h.Log.Error("Error syncing comment to pending review", "error", err)
+		}
+	}
+
 	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
 	if err != nil {
 		return err
@@ -206,16 +261,22 @@ type EditCommentArgs struct {
 }
 
 type EditCommentReply struct {
-	Okay     bool          `json:"okay"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
 }
 
 func (h *RPCHandler) EditComment(args *EditCommentArgs, reply *EditCommentReply) error {
+	// GitHub wins when reconciling, so a mirrored comment has to be edited there first.
+	if err := h.mirrorCommentChange(args.Owner, args.Repo, args.ID, &args.Body); err != nil {
+		h.Log.Error("Error editing pending review comment", "error", err)
+		return err
+	}
+
 	err := config.C.DB.UpdateLocalComment(args.ID, args.Body)
 	if err != nil {
 		h.Log.Error("Error updating local comment", "error", err)
@@ -245,16 +306,21 @@ type DeleteCommentArgs struct {
 }
 
 type DeleteCommentReply struct {
-	Okay     bool          `json:"okay"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
 }
 
 func (h *RPCHandler) DeleteComment(args *DeleteCommentArgs, reply *DeleteCommentReply) error {
+	if err := h.mirrorCommentChange(args.Owner, args.Repo, args.ID, nil); err != nil {
+		h.Log.Error("Error deleting pending review comment", "error", err)
+		return err
+	}
+
 	err := config.C.DB.DeleteLocalComment(args.ID)
 	if err != nil {
 		h.Log.Error("Error deleting local comment", "error", err)
@@ -276,6 +342,103 @@ func (h *RPCHandler) DeleteComment(args *DeleteCommentArgs, reply *DeleteComment
 	return nil
 }
 
+// mirrorCommentChange applies an edit (body != nil) or delete (body == nil) of a local comment to
+// its copy in the pending GitHub review. It is a no-op for comments that only exist locally.
+func (h *RPCHandler) mirrorCommentChange(owner, repo string, id int64, body *string) error {
+	if !syncsDraftReview(owner, repo) {
+		return nil
+	}
+	comment, err := config.C.DB.GetLocalComment(id)
+	if err != nil || comment == nil || comment.GithubCommentID == nil {
+		return err
+	}
+	client := git_tools.GetGithubClientFor(owner, repo)
+	if body == nil {
+		return git_tools.DeleteReviewComment(client, owner, repo, *comment.GithubCommentID)
+	}
+	return git_tools.EditReviewComment(client, owner, repo, *comment.GithubCommentID, *body)
+}
+
+type MoveCommentArgs struct {
+	Owner         string `json:"Owner"`
+	Repo          string `json:"Repo"`
+	Number        int    `json:"Number"`
+	ID            int64  `json:"ID"`
+	Filename      string
+	Position      int64
+	StartPosition int64
+	Line          int64
+	Side          string
+	StartLine     int64
+	StartSide     string
+}
+
+type MoveCommentReply struct {
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
+	OutdatedComments []CommentJSON `json:"outdated_comments"`
+	Reviews          []ReviewJSON  `json:"reviews"`
+}
+
+// MoveComment re-places a local comment, typically one orphaned by a push, on the current diff.
+func (h *RPCHandler) MoveComment(args *MoveCommentArgs, reply *MoveCommentReply) error {
+	comment, err := config.C.DB.GetLocalComment(args.ID)
+	if err != nil {
+		h.Log.Error("Error fetching local comment", "error", err)
+		return err
+	}
+	if comment == nil {
+		return fmt.Errorf("local comment %d not found", args.ID)
+	}
+	if comment.ReplyToID != nil {
+		return fmt.Errorf("local comment %d is a reply and has no position", args.ID)
+	}
+
+	diff, commitSHA := loadPRDiff(args.Owner, args.Repo, args.Number)
+	anchor := database.CommentAnchor{Line: args.Line, Side: args.Side, StartLine: args.StartLine, StartSide: args.StartSide}
+	position, anchor := resolveCommentAnchor(diff, args.Filename, args.Position, args.StartPosition, anchor)
+
+	// GitHub cannot move a pending review comment, so drop the mirror and push it again.
+	if err := h.mirrorCommentChange(args.Owner, args.Repo, args.ID, nil); err != nil {
+		h.Log.Error("Error removing pending review comment before move", "error", err)
+		return err
+	}
+	if comment.GithubCommentID != nil {
+		if err := config.C.DB.SetLocalCommentGithubID(args.ID, nil); err != nil {
+			h.Log.Error("Error unlinking local comment from pending review", "error", err)
+		}
+	}
+
+	err = config.C.DB.UpdateLocalCommentAnchor(args.ID, args.Filename, position, anchor, commitSHA, false)
+	if err != nil {
+		h.Log.Error("Error moving local comment", "error", err)
+		return err
+	}
+	reply.Okay = true
+
+	if syncsDraftReview(args.Owner, args.Repo) {
+		if err := pushDraftReview(git_tools.GetGithubClientFor(args.Owner, args.Repo), args.Owner, args.Repo, args.Number); err != nil {
+			h.Log.Error("Error syncing moved comment to pending review", "error", err)
+		}
+	}
+
+	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+	if err != nil {
+		return err
+	}
+
+	reply.Content = content
+	reply.Metadata = &details.Metadata
+	reply.Diff = details.Diff
+	reply.Comments = details.Comments
+	reply.OutdatedComments = details.OutdatedComments
+	reply.Reviews = details.Reviews
+	return nil
+}
+
 type SetFeedbackArgs struct {
 	Owner  string `json:"Owner"`
 	Repo   string `json:"Repo"`
@@ -284,13 +447,13 @@ type SetFeedbackArgs struct {
 }
 
 type SetFeedbackReply struct {
-	ID       int64         `json:"id"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	ID               int64         `json:"id"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
 }
 
 func (h *RPCHandler) SetFeedback(args *SetFeedbackArgs, reply *SetFeedbackReply) error {
@@ -321,16 +484,28 @@ type RemovePRCommentsArgs struct {
 }
 
 type RemovePRCommentsReply struct {
-	Okay     bool          `json:"okay"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
 }
 
 func (h *RPCHandler) RemovePRComments(args *RemovePRCommentsArgs, reply *RemovePRCommentsReply) error {
+	if syncsDraftReview(args.Owner, args.Repo) {
+		client := git_tools.GetGithubClientFor(args.Owner, args.Repo)
+		pending, err := git_tools.GetPendingReview(client, args.Owner, args.Repo, args.Number)
+		if err == nil && pending != nil {
+			err = git_tools.DeletePendingReview(client, args.Owner, args.Repo, args.Number, pending.GetID())
+		}
+		if err != nil {
+			h.Log.Error("Error discarding pending review", "error", err)
+			return err
+		}
+	}
+
 	err := config.C.DB.DeleteLocalCommentsForPR(args.Owner, args.Repo, args.Number)
 	if err != nil {
 		h.Log.Error("Error removing local comments", "error", err)
@@ -361,44 +536,77 @@ type SubmitReviewArgs struct {
 }
 
 type SubmitReviewReply struct {
-	Okay     bool          `json:"okay"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
+	JiraActions      []string      `json:"jira_actions"` // What the JiraRules did for this review
 }
 
 func (h *RPCHandler) SubmitReview(args *SubmitReviewArgs, reply *SubmitReviewReply) error {
-	// 1. Fetch Local Comments
+	if args.Event == "APPROVE" && config.C.ChecklistsBlockApprove {
+		if err := checkRequiredItems(args.Owner, args.Repo, args.Number); err != nil {
+			return err
+		}
+	}
+
+	// 1. Fetch Local Comments, moved onto the current head if it changed since the last view
+	f := forge.For(args.Owner, args.Repo)
+	headSHA, err := anchorToHead(f, args.Owner, args.Repo, args.Number)
+	if err != nil {
+		h.Log.Error("Error anchoring local comments to the PR head", "error", err)
+		return err
+	}
 	comments, err := config.C.DB.GetLocalCommentsForPR(args.Owner, args.Repo, args.Number)
 	if err != nil {
 		h.Log.Error("Error fetching local comments", "error", err)
 		return err
 	}
+	orphaned := 0
+	for _, c := range comments {
+		if c.Orphaned {
+			orphaned++
+		}
+	}
+	if orphaned > 0 {
+		// Submitting would either fail or land them on the wrong lines.
+		return fmt.Errorf("%d local comment(s) lost their line in a later push; move or delete them before submitting", orphaned)
+	}
 
 	// 2. Construct Review Request
-	client := git_tools.GetGithubClient()
+
+	// In draft sync mode the top-level comments already live in the pending review,
+	// so only that review needs submitting. GitLab has no pending reviews.
+	var pending *github.PullRequestReview
+	if gh, isGitHub := f.(*forge.GitHub); config.C.SyncDraftReviews && isGitHub {
+		client := gh.Client
+		if err := pushDraftReview(client, args.Owner, args.Repo, args.Number); err != nil {
+			h.Log.Error("Error syncing pending review before submit", "error", err)
+			return err
+		}
+		pending, err = git_tools.GetPendingReview(client, args.Owner, args.Repo, args.Number)
+		if err != nil {
+			h.Log.Error("Error fetching pending review", "error", err)
+			return err
+		}
+	}
+
 	var reviewComments []*github.DraftReviewComment
 	for _, c := range comments {
 		if c.Body == nil {
 			continue
 		}
 		if c.ReplyToID != nil {
-			err := git_tools.SubmitReply(client, args.Owner, args.Repo, args.Number, *c.Body, *c.ReplyToID)
+			err := f.SubmitReply(args.Owner, args.Repo, args.Number, *c.Body, *c.ReplyToID)
 			if err != nil {
 				h.Log.Error("Error submitting reply", "error", err)
 			}
-		} else {
+		} else if pending == nil {
 			// Top-level comments
-			pos := int(c.Position)
-			body := *c.Body
-			reviewComments = append(reviewComments, &github.DraftReviewComment{
-				Path:     &c.Filename,
-				Position: &pos,
-				Body:     &body,
-			})
+			reviewComments = append(reviewComments, draftReviewComment(c))
 		}
 	}
 
@@ -409,9 +617,16 @@ func (h *RPCHandler) SubmitReview(args *SubmitReviewArgs, reply *SubmitReviewRep
 	if args.Body != "" {
 		reviewRequest.Body = &args.Body
 	}
+	if headSHA != "" {
+		reviewRequest.CommitID = &headSHA
+	}
 
 	// 3. Submit to GitHub
-	err = git_tools.SubmitReview(client, args.Owner, args.Repo, args.Number, reviewRequest)
+	if pending != nil {
+		err = git_tools.SubmitPendingReview(f.(*forge.GitHub).Client, args.Owner, args.Repo, args.Number, pending.GetID(), args.Event, args.Body)
+	} else {
+		err = f.SubmitReview(args.Owner, args.Repo, args.Number, reviewRequest)
+	}
 	if err != nil {
 		h.Log.Error("Error submitting review to GitHub", "error", err)
 		return err
@@ -423,6 +638,9 @@ func (h *RPCHandler) SubmitReview(args *SubmitReviewArgs, reply *SubmitReviewRep
 		h.Log.Error("Error deleting local comments after submission", "error", err)
 	}
 
+	// Read the issue keys of the PR's Jira workflow items before they are removed
+	jiraKeys := storedJiraKeys(args.Owner, args.Repo, args.Number)
+
 	// 5. Remove the item from all sections in the database
 	// The identifier is constructed as RepoName + PRNumber (matching PRToOrgBridge.Identifier)
 	identifier := fmt.Sprintf("%s%d", args.Repo, args.Number)
@@ -435,15 +653,22 @@ func (h *RPCHandler) SubmitReview(args *SubmitReviewArgs, reply *SubmitReviewRep
 
 	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, true)
 	if err != nil {
-		return err
+		// The review is in, so the Jira rules still run, filled in from the cached metadata.
+		// The client keeps its view when no content comes back.
+		h.Log.Error("Error refreshing PR after review", "error", err)
+		details = cachedPRDetails(args.Owner, args.Repo, args.Number)
+		reply.Metadata = &details.Metadata
+	} else {
+		reply.Content = content
+		reply.Metadata = &details.Metadata
+		reply.Diff = details.Diff
+		reply.Comments = details.Comments
+		reply.OutdatedComments = details.OutdatedComments
+		reply.Reviews = details.Reviews
 	}
 
-	reply.Content = content
-	reply.Metadata = &details.Metadata
-	reply.Diff = details.Diff
-	reply.Comments = details.Comments
-	reply.OutdatedComments = details.OutdatedComments
-	reply.Reviews = details.Reviews
+	// 6. Move or comment on the linked Jira issues
+	reply.JiraActions = applyJiraRules(h.Log, args.Event, args.Owner, args.Repo, jiraKeys, details)
 	return nil
 }
 
@@ -454,13 +679,13 @@ type SyncPRArgs struct {
 }
 
 type SyncPRReply struct {
-	Okay     bool          `json:"okay"`
-	Content  string        `json:"content"`
-	Metadata *PRMetadata   `json:"metadata"`
-	Diff     string        `json:"diff"`
-	Comments []CommentJSON `json:"comments"`
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
 	OutdatedComments []CommentJSON `json:"outdated_comments"`
-	Reviews  []ReviewJSON  `json:"reviews"`
+	Reviews          []ReviewJSON  `json:"reviews"`
 }
 
 func (h *RPCHandler) SyncPR(args *SyncPRArgs, reply *SyncPRReply) error {
@@ -489,6 +714,65 @@ func (h *RPCHandler) ListPlugins(args *ListPluginsArgs, reply *ListPluginsReply)
 	return nil
 }
 
+// ConfigReloader re-reads the config file and swaps in its workflows between sync cycles.
+// main sets it; ReloadConfig only validates the file when it is nil.
+var ConfigReloader func() error
+
+type ReloadConfigArgs struct{}
+type ReloadConfigReply struct {
+	Okay   bool     `json:"okay"`
+	Errors []string `json:"errors"` // Why the config was rejected; the running config is kept
+}
+
+func (h *RPCHandler) ReloadConfig(args *ReloadConfigArgs, reply *ReloadConfigReply) error {
+	var err error
+	if ConfigReloader != nil {
+		err = ConfigReloader()
+	} else {
+		var cfg *config.Config
+		if cfg, err = config.Load(); err == nil {
+			err = errors.Join(workflows.ValidateWorkflows(cfg)...)
+		}
+	}
+	if err != nil {
+		// Invalid workflows come back joined, one error each
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				reply.Errors = append(reply.Errors, e.Error())
+			}
+		} else {
+			reply.Errors = []string{err.Error()}
+		}
+		return nil
+	}
+	reply.Okay = true
+	return nil
+}
+
+type DoctorArgs struct{}
+type DoctorReply struct {
+	Okay   bool           `json:"okay"`
+	Checks []doctor.Check `json:"checks"`
+	Report string         `json:"report"` // The checks rendered as text, as -check-config prints them
+}
+
+// Doctor validates the config file as it is on disk and checks the credentials, repos,
+// plugins and database it relies on.
+func (h *RPCHandler) Doctor(args *DoctorArgs, reply *DoctorReply) error {
+	var report doctor.Report
+	cfg, err := config.Load()
+	if err != nil {
+		report = doctor.ConfigFailure(err)
+	} else {
+		cfg.DB = config.C.DB
+		report = doctor.Run(cfg)
+	}
+	reply.Okay = report.OK()
+	reply.Checks = report.Checks
+	reply.Report = report.String()
+	return nil
+}
+
 type CheckRepoExistsArgs struct {
 	Repo string `json:"Repo"`
 }
@@ -549,7 +833,11 @@ func (h *RPCHandler) GetPluginOutput(args *GetPluginOutputArgs, reply *GetPlugin
 	// we call fetchPRAndRunPlugins (which is async for the plugin part).
 	if len(results) == 0 {
 		h.Log.Info("No plugin results found, triggering async run", "pr", args.Number)
-		go h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+		go func() {
+			config.RLock()
+			defer config.RUnlock()
+			h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+		}()
 	}
 
 	reply.Output = results
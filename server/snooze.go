@@ -0,0 +1,272 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/events"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SnoozeItemArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+	// When the item comes back: an RFC 3339 time, a date (2006-01-02, midnight local time) or a
+	// duration like "4h" or "3d". Empty for no time limit.
+	Until string `json:"Until"`
+	// Bring the item back as soon as the PR is updated, such as a push or a new comment
+	UntilActivity bool `json:"UntilActivity"`
+}
+
+type SnoozeItemReply struct {
+	Okay   bool  `json:"okay"`
+	Until  int64 `json:"until"`  // Unix time the item wakes at, 0 for none
+	Hidden int   `json:"hidden"` // Number of sections the item was hidden from
+}
+
+// SnoozeItem hides a PR's items from every section until a time, until the PR has new
+// activity, or whichever comes first when both are given. Syncs keep the items up to date.
+func (h *RPCHandler) SnoozeItem(args *SnoozeItemArgs, reply *SnoozeItemReply) error {
+	now := time.Now()
+	until, err := parseSnoozeUntil(args.Until, now)
+	if err != nil {
+		return err
+	}
+	if until == 0 && !args.UntilActivity {
+		return fmt.Errorf("a snooze needs Until, UntilActivity or both; use MuteItem to hide a PR indefinitely")
+	}
+	identifier := itemIdentifier(args.Owner, args.Repo, args.Number)
+	snooze := database.Snooze{Identifier: identifier, Until: until, UntilActivity: args.UntilActivity, CreatedAt: now.Unix()}
+	if err := config.C.DB.UpsertSnooze(snooze); err != nil {
+		h.Log.Error("Error storing snooze", "identifier", identifier, "error", err)
+		return err
+	}
+	hidden, err := hideItem(identifier, database.HiddenSnoozed)
+	if err != nil {
+		h.Log.Error("Error hiding snoozed item", "identifier", identifier, "error", err)
+		return err
+	}
+	reply.Okay = true
+	reply.Until = until
+	reply.Hidden = hidden
+	return nil
+}
+
+type UnsnoozeItemArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+}
+
+type UnsnoozeItemReply struct {
+	Okay bool `json:"okay"`
+}
+
+func (h *RPCHandler) UnsnoozeItem(args *UnsnoozeItemArgs, reply *UnsnoozeItemReply) error {
+	identifier := itemIdentifier(args.Owner, args.Repo, args.Number)
+	if err := config.C.DB.DeleteSnooze(identifier); err != nil {
+		return err
+	}
+	if err := config.C.DB.UnhideItem(identifier, database.HiddenSnoozed); err != nil {
+		return err
+	}
+	events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{})
+	reply.Okay = true
+	return nil
+}
+
+type MuteItemArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+}
+
+// MuteItem hides a PR until its rule is removed. It is AddMuteRule with Kind "pr".
+func (h *RPCHandler) MuteItem(args *MuteItemArgs, reply *AddMuteRuleReply) error {
+	return h.AddMuteRule(&AddMuteRuleArgs{Kind: database.MuteKindPR, Value: itemIdentifier(args.Owner, args.Repo, args.Number)}, reply)
+}
+
+type AddMuteRuleArgs struct {
+	Kind  string `json:"Kind"`  // pr, author, label or repo
+	Value string `json:"Value"` // owner/repo-number for pr, a login, a label name or owner/repo
+}
+
+type AddMuteRuleReply struct {
+	Okay   bool              `json:"okay"`
+	Rule   database.MuteRule `json:"rule"`
+	Hidden int               `json:"hidden"` // Items hidden right away; label rules apply from the next sync
+}
+
+// AddMuteRule hides every PR matching the rule. PR, author and repo rules apply to the current
+// items immediately; label rules need the PR's labels and take effect on the next sync.
+func (h *RPCHandler) AddMuteRule(args *AddMuteRuleArgs, reply *AddMuteRuleReply) error {
+	value := strings.TrimSpace(args.Value)
+	if !slices.Contains(database.MuteKinds, args.Kind) {
+		return fmt.Errorf("unknown mute rule kind %q (expected one of %s)", args.Kind, strings.Join(database.MuteKinds, ", "))
+	}
+	if value == "" {
+		return fmt.Errorf("a %s mute rule needs a Value", args.Kind)
+	}
+	rule, err := config.C.DB.AddMuteRule(args.Kind, value)
+	if err != nil {
+		h.Log.Error("Error storing mute rule", "kind", args.Kind, "value", value, "error", err)
+		return err
+	}
+	hidden, err := applyMuteRules([]database.MuteRule{rule})
+	if err != nil {
+		h.Log.Error("Error hiding muted items", "rule", rule.ID, "error", err)
+		return err
+	}
+	reply.Okay = true
+	reply.Rule = rule
+	reply.Hidden = hidden
+	return nil
+}
+
+type RemoveMuteRuleArgs struct {
+	ID int64 `json:"ID"`
+}
+
+type RemoveMuteRuleReply struct {
+	Okay bool `json:"okay"`
+}
+
+// RemoveMuteRule deletes a rule and shows the items it hid, unless another rule still matches.
+func (h *RPCHandler) RemoveMuteRule(args *RemoveMuteRuleArgs, reply *RemoveMuteRuleReply) error {
+	if err := config.C.DB.DeleteMuteRule(args.ID); err != nil {
+		return err
+	}
+	rules, err := config.C.DB.GetMuteRules()
+	if err != nil {
+		return err
+	}
+	if err := config.C.DB.UnhideAll(database.HiddenMuted); err != nil {
+		return err
+	}
+	// Label rules are re-applied by the next sync
+	if _, err := applyMuteRules(rules); err != nil {
+		return err
+	}
+	reply.Okay = true
+	return nil
+}
+
+type ListMutesArgs struct{}
+
+type ListMutesReply struct {
+	Rules   []database.MuteRule `json:"rules"`
+	Snoozes []SnoozeJSON        `json:"snoozes"`
+}
+
+type SnoozeJSON struct {
+	Identifier    string `json:"identifier"`
+	Until         int64  `json:"until"`
+	UntilActivity bool   `json:"until_activity"`
+}
+
+func (h *RPCHandler) ListMutes(args *ListMutesArgs, reply *ListMutesReply) error {
+	rules, err := config.C.DB.GetMuteRules()
+	if err != nil {
+		return err
+	}
+	snoozes, err := config.C.DB.GetSnoozes()
+	if err != nil {
+		return err
+	}
+	reply.Rules = rules
+	reply.Snoozes = []SnoozeJSON{}
+	for _, snooze := range snoozes {
+		reply.Snoozes = append(reply.Snoozes, SnoozeJSON{Identifier: snooze.Identifier, Until: snooze.Until, UntilActivity: snooze.UntilActivity})
+	}
+	slices.SortFunc(reply.Snoozes, func(a, b SnoozeJSON) int { return strings.Compare(a.Identifier, b.Identifier) })
+	return nil
+}
+
+// itemIdentifier is the identifier the workflows store a PR's items under.
+func itemIdentifier(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s-%d", owner, repo, number)
+}
+
+// parseSnoozeUntil turns SnoozeItemArgs.Until into a Unix time, 0 for an empty string.
+func parseSnoozeUntil(until string, now time.Time) (int64, error) {
+	until = strings.TrimSpace(until)
+	if until == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, until); err == nil {
+		return t.Unix(), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, until, now.Location()); err == nil {
+		return t.Unix(), nil
+	}
+	if days, found := strings.CutSuffix(until, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n).Unix(), nil
+		}
+	}
+	if d, err := time.ParseDuration(until); err == nil && d > 0 {
+		return now.Add(d).Unix(), nil
+	}
+	return 0, fmt.Errorf("invalid Until %q (expected an RFC 3339 time, a date like 2006-01-02 or a duration like 4h or 3d)", until)
+}
+
+// hideItem hides identifier in every section and tells clients, returning the sections it was in.
+func hideItem(identifier, reason string) (int, error) {
+	items, err := config.C.DB.GetItemsByIdentifier(identifier)
+	if err != nil {
+		return 0, err
+	}
+	if err := config.C.DB.HideItem(identifier, reason); err != nil {
+		return 0, err
+	}
+	if len(items) > 0 {
+		events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{Updated: len(items)})
+	}
+	return len(items), nil
+}
+
+// applyMuteRules hides the current items matched by PR, author and repo rules and returns how
+// many it hid. Items don't record their labels, so label rules wait for the next sync.
+func applyMuteRules(rules []database.MuteRule) (int, error) {
+	items, err := config.C.DB.GetAllItems()
+	if err != nil {
+		return 0, err
+	}
+	renderer := NewOrgRenderer(config.C.DB)
+	muted := map[string]bool{}
+	hidden := 0
+	for _, item := range items {
+		if muted[item.Identifier] {
+			continue
+		}
+		reviewItem := renderer.parseItemToReviewItem(item, "", 0)
+		author, _, _ := strings.Cut(reviewItem.Author, " ")
+		for _, rule := range rules {
+			matches := false
+			switch rule.Kind {
+			case database.MuteKindPR:
+				matches = strings.EqualFold(rule.Value, item.Identifier)
+			case database.MuteKindAuthor:
+				matches = author != "" && strings.EqualFold(rule.Value, author)
+			case database.MuteKindRepo:
+				matches = reviewItem.Repo != "" && strings.EqualFold(rule.Value, reviewItem.Owner+"/"+reviewItem.Repo)
+			}
+			if matches {
+				if err := config.C.DB.HideItem(item.Identifier, database.HiddenMuted); err != nil {
+					return hidden, err
+				}
+				muted[item.Identifier] = true
+				hidden++
+				break
+			}
+		}
+	}
+	if hidden > 0 {
+		events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{Updated: hidden})
+	}
+	return hidden, nil
+}
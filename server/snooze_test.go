@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crs/database"
+	"testing"
+	"time"
+)
+
+func TestParseSnoozeUntil(t *testing.T) {
+	now := time.Date(2024, 5, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		until   string
+		want    time.Time
+		wantErr bool
+	}{
+		{until: "", want: time.Unix(0, 0)},
+		{until: "4h", want: now.Add(4 * time.Hour)},
+		{until: "3d", want: now.AddDate(0, 0, 3)},
+		{until: "2024-05-13", want: time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{until: "2024-05-13T09:30:00+02:00", want: time.Date(2024, 5, 13, 7, 30, 0, 0, time.UTC)},
+		{until: "-1h", wantErr: true},
+		{until: "next week", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSnoozeUntil(tt.until, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSnoozeUntil(%q) error = %v, wantErr %v", tt.until, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want.Unix() {
+			t.Errorf("parseSnoozeUntil(%q) = %v, want %v", tt.until, time.Unix(got, 0).UTC(), tt.want)
+		}
+	}
+}
+
+func TestVisibleItemsAndSectionHeader(t *testing.T) {
+	section := &database.Section{ID: 1, SectionName: "Reviews", IndentLevel: 2}
+	items := []*database.Item{
+		{SectionID: 1, Identifier: "org/api-1", Status: "TODO"},
+		{SectionID: 1, Identifier: "org/api-2", Status: "DONE"},
+		{SectionID: 1, Identifier: "org/api-3", Status: "TODO"},
+	}
+	hidden := map[int64]map[string]string{
+		1: {"org/api-1": database.HiddenSnoozed, "org/api-9": database.HiddenMuted},
+		2: {"org/api-3": database.HiddenMuted},
+	}
+
+	visible, hiddenCount := visibleItems(section, items, hidden)
+	if len(visible) != 2 || hiddenCount != 1 || visible[0].Identifier != "org/api-2" {
+		t.Errorf("visibleItems() = %d items, %d hidden, want org/api-2 and org/api-3 with 1 hidden", len(visible), hiddenCount)
+	}
+
+	renderer := &OrgRenderer{}
+	if got, want := renderer.buildSectionHeader(section, visible, hiddenCount), "* TODO Reviews [1/2] (1 hidden)"; got != want {
+		t.Errorf("buildSectionHeader() = %q, want %q", got, want)
+	}
+	if got, want := renderer.buildSectionHeader(section, items, 0), "* TODO Reviews [1/3]"; got != want {
+		t.Errorf("buildSectionHeader() = %q, want %q", got, want)
+	}
+}
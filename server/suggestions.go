@@ -0,0 +1,350 @@
+package server
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/git_tools"
+	"crs/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v48/github"
+)
+
+const (
+	suggestionFence = "```suggestion"
+	fenceEnd        = "```"
+)
+
+// hasSuggestion reports whether a comment body contains a GitHub suggested-change block.
+func hasSuggestion(body string) bool {
+	_, _, _, ok := parseSuggestion(body)
+	return ok
+}
+
+// buildSuggestionBody wraps the replacement text in a suggestion block, after the optional
+// explanation. An empty replacement suggests deleting the lines.
+func buildSuggestionBody(comment string, replacement string) string {
+	var b strings.Builder
+	if strings.TrimSpace(comment) != "" {
+		b.WriteString(strings.TrimRight(comment, "\n"))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(suggestionFence + "\n")
+	if replacement != "" {
+		b.WriteString(strings.TrimSuffix(replacement, "\n") + "\n")
+	}
+	b.WriteString(fenceEnd)
+	return b.String()
+}
+
+// parseSuggestion splits a comment body into the text before the first suggestion block,
+// the replacement lines inside it, and the text after it.
+func parseSuggestion(body string) (string, []string, string, bool) {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != suggestionFence {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == fenceEnd {
+				before := strings.TrimRight(strings.Join(lines[:i], "\n"), "\n")
+				after := strings.TrimLeft(strings.Join(lines[j+1:], "\n"), "\n")
+				return before, lines[i+1 : j], after, true
+			}
+		}
+		return "", nil, "", false
+	}
+	return "", nil, "", false
+}
+
+// suggestionOriginal returns the new-file lines start..end of a file. Every line must be part
+// of the diff, since GitHub only accepts suggestions on lines it shows.
+func suggestionOriginal(diff *utils.Diff, filename string, start, end int) ([]string, error) {
+	if diff == nil {
+		return nil, fmt.Errorf("no diff available for %s", filename)
+	}
+	file := diff.File(filename)
+	if file == nil {
+		return nil, fmt.Errorf("%s is not part of the diff", filename)
+	}
+	byNumber := make(map[int]string)
+	for _, h := range file.Hunks {
+		for _, l := range h.NewRange.Lines {
+			byNumber[l.Number] = l.Content
+		}
+	}
+	var original []string
+	for n := start; n <= end; n++ {
+		content, ok := byNumber[n]
+		if !ok {
+			return nil, fmt.Errorf("line %d of %s is not part of the diff", n, filename)
+		}
+		original = append(original, content)
+	}
+	return original, nil
+}
+
+// importedSuggestionOriginal recovers the lines replaced by a suggestion written outside this
+// server, such as in the web UI, from the stored diff. It returns "" when the diff belongs to
+// another head or does not show every line.
+func importedSuggestionOriginal(diff *utils.Diff, diffSHA string, c *github.PullRequestComment) string {
+	if diffSHA != "" && c.GetCommitID() != "" && diffSHA != c.GetCommitID() {
+		return ""
+	}
+	if c.GetLine() == 0 || c.GetSide() == sideLeft {
+		return ""
+	}
+	start := c.GetStartLine()
+	if start == 0 {
+		start = c.GetLine()
+	}
+	original, err := suggestionOriginal(diff, c.GetPath(), start, c.GetLine())
+	if err != nil {
+		return ""
+	}
+	return strings.Join(original, "\n")
+}
+
+// renderSuggestion replaces the suggestion block in a body with a mini diff of the replaced
+// and suggested lines. original is empty for suggestions written outside this server, in
+// which case only the suggested lines are shown.
+func renderSuggestion(body string, original []string) string {
+	before, replacement, after, ok := parseSuggestion(body)
+	if !ok {
+		return body
+	}
+	var lines []string
+	if before != "" {
+		lines = append(lines, before, "")
+	}
+	lines = append(lines, "Suggested change:")
+	for _, l := range original {
+		lines = append(lines, "- "+l)
+	}
+	for _, l := range replacement {
+		lines = append(lines, "+ "+l)
+	}
+	if after != "" {
+		lines = append(lines, "", after)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applySuggestion replaces lines start..end (1-based, inclusive) of content with the
+// replacement, refusing if those lines no longer read as they did when the suggestion
+// was written.
+func applySuggestion(content string, start, end int, original, replacement []string) (string, error) {
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if start < 1 || end < start || end > len(lines) {
+		return "", fmt.Errorf("lines %d-%d are outside the file (%d lines)", start, end, len(lines))
+	}
+	current := lines[start-1 : end]
+	if strings.Join(current, "\n") != strings.Join(original, "\n") {
+		return "", fmt.Errorf("lines %d-%d changed since the suggestion was written", start, end)
+	}
+
+	patched := make([]string, 0, len(lines)-len(current)+len(replacement))
+	patched = append(patched, lines[:start-1]...)
+	patched = append(patched, replacement...)
+	patched = append(patched, lines[end:]...)
+	result := strings.Join(patched, "\n")
+	if trailingNewline {
+		result += "\n"
+	}
+	return result, nil
+}
+
+type AddSuggestionArgs struct {
+	Owner       string `json:"Owner"`
+	Repo        string `json:"Repo"`
+	Number      int    `json:"Number"`
+	Filename    string
+	StartLine   int64  // First new-file line to replace; 0 for a single line
+	Line        int64  // Last new-file line to replace
+	Replacement string // Text to put in place of the lines; empty deletes them
+	Body        string // Optional explanation shown above the suggestion
+	// Alternatively, the range as diff positions, as sent by clients that only know positions.
+	Position      int64
+	StartPosition int64
+}
+
+type AddSuggestionReply struct {
+	ID               int64         `json:"id"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
+	OutdatedComments []CommentJSON `json:"outdated_comments"`
+	Reviews          []ReviewJSON  `json:"reviews"`
+}
+
+func (h *RPCHandler) AddSuggestion(args *AddSuggestionArgs, reply *AddSuggestionReply) error {
+	diff, commitSHA := loadPRDiff(args.Owner, args.Repo, args.Number)
+	anchor := database.CommentAnchor{Line: args.Line, Side: sideRight, StartLine: args.StartLine}
+	if args.Line == 0 {
+		anchor.Side = ""
+	}
+	position, anchor := resolveCommentAnchor(diff, args.Filename, args.Position, args.StartPosition, anchor)
+	if anchor.Line == 0 {
+		return fmt.Errorf("could not find the lines to replace in %s", args.Filename)
+	}
+	if anchor.Side != sideRight || (anchor.StartLine != 0 && anchor.StartSide != sideRight) {
+		return fmt.Errorf("suggestions can only replace lines of the new version of %s", args.Filename)
+	}
+
+	start := anchor.StartLine
+	if start == 0 {
+		start = anchor.Line
+	}
+	original, err := suggestionOriginal(diff, args.Filename, int(start), int(anchor.Line))
+	if err != nil {
+		return err
+	}
+
+	body := buildSuggestionBody(args.Body, args.Replacement)
+	comment, err := config.C.DB.InsertLocalComment(database.LocalComment{
+		Owner:         args.Owner,
+		Repo:          args.Repo,
+		Number:        args.Number,
+		Filename:      args.Filename,
+		Position:      position,
+		Body:          &body,
+		CommentAnchor: anchor,
+		CommitSHA:     commitSHA,
+		Kind:          database.CommentKindSuggestion,
+		OriginalLines: strings.Join(original, "\n"),
+	})
+	if err != nil {
+		h.Log.Error("Error inserting suggestion", "error", err)
+		return err
+	}
+	reply.ID = comment.ID
+
+	if syncsDraftReview(args.Owner, args.Repo) {
+		if err := pushDraftReview(git_tools.GetGithubClientFor(args.Owner, args.Repo), args.Owner, args.Repo, args.Number); err != nil {
+			h.Log.Error("Error syncing suggestion to pending review", "error", err)
+		}
+	}
+
+	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+	if err != nil {
+		return err
+	}
+
+	reply.Content = content
+	reply.Metadata = &details.Metadata
+	reply.Diff = details.Diff
+	reply.Comments = details.Comments
+	reply.OutdatedComments = details.OutdatedComments
+	reply.Reviews = details.Reviews
+	return nil
+}
+
+type ApplySuggestionArgs struct {
+	Owner  string `json:"Owner"`
+	Repo   string `json:"Repo"`
+	Number int    `json:"Number"`
+	ID     int64  `json:"ID"` // Local comment ID of the suggestion
+}
+
+type ApplySuggestionReply struct {
+	Okay bool   `json:"okay"`
+	Path string `json:"path"` // File that was patched
+}
+
+// ApplySuggestion patches a local suggestion into the PR's worktree so it can be tried out
+// before the review is posted. The worktree is left uncommitted.
+func (h *RPCHandler) ApplySuggestion(args *ApplySuggestionArgs, reply *ApplySuggestionReply) error {
+	comment, err := config.C.DB.GetLocalComment(args.ID)
+	if err != nil {
+		return err
+	}
+	if comment == nil || comment.Kind != database.CommentKindSuggestion || comment.Body == nil {
+		return fmt.Errorf("local comment %d is not a suggestion", args.ID)
+	}
+	_, replacement, _, ok := parseSuggestion(*comment.Body)
+	if !ok {
+		return fmt.Errorf("local comment %d has no suggestion block", args.ID)
+	}
+
+	worktree, err := config.C.DB.GetWorktree(args.Number, args.Repo, args.Owner)
+	if err != nil {
+		return err
+	}
+	if worktree == "" {
+		return fmt.Errorf("no worktree recorded for %s/%s#%d", args.Owner, args.Repo, args.Number)
+	}
+
+	path, err := worktreeFile(worktree, comment.Filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	start := comment.StartLine
+	if start == 0 {
+		start = comment.Line
+	}
+	original, err := localSuggestionOriginal(comment, int(start))
+	if err != nil {
+		return fmt.Errorf("cannot apply suggestion to %s: %w", path, err)
+	}
+	patched, err := applySuggestion(string(content), int(start), int(comment.Line), original, replacement)
+	if err != nil {
+		return fmt.Errorf("cannot apply suggestion to %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(patched), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	h.Log.Info("Applied suggestion to worktree", "id", args.ID, "path", path)
+	reply.Okay = true
+	reply.Path = path
+	return nil
+}
+
+// localSuggestionOriginal returns the lines a local suggestion replaces: the ones recorded when it
+// was written or, for suggestions stored without them, the ones at its anchor in the stored diff
+// of the head it was written on. Without either the suggestion can't be checked, so it fails.
+func localSuggestionOriginal(c *database.LocalComment, start int) ([]string, error) {
+	if c.OriginalLines != "" {
+		return strings.Split(c.OriginalLines, "\n"), nil
+	}
+	for _, get := range []func(int, string, string) (string, string, error){config.C.DB.GetPullRequest, config.C.DB.GetPreviousPullRequest} {
+		rawDiff, sha, err := get(c.Number, c.Owner, c.Repo)
+		if err != nil || rawDiff == "" || (c.CommitSHA != "" && sha != c.CommitSHA) {
+			continue
+		}
+		diff, err := utils.Parse(rawDiff)
+		if err != nil {
+			return nil, err
+		}
+		return suggestionOriginal(diff, c.Filename, start, int(c.Line))
+	}
+	return nil, fmt.Errorf("the lines it replaces were not recorded and the diff it was written on is not stored")
+}
+
+// worktreeFile resolves a comment's filename inside worktree. Filenames come from the forge,
+// so absolute ones and ones that climb out of the worktree are refused rather than written to.
+func worktreeFile(worktree, filename string) (string, error) {
+	if filepath.IsAbs(filename) {
+		return "", fmt.Errorf("%s is not a path inside the worktree", filename)
+	}
+	path := filepath.Join(worktree, filename)
+	rel, err := filepath.Rel(worktree, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not a path inside the worktree", filename)
+	}
+	return path, nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crs/database"
+	"crs/testutil"
+	"crs/utils"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func TestBuildAndParseSuggestion(t *testing.T) {
+	body := buildSuggestionBody("Use a constant here.", "const limit = 10\n")
+	want := "Use a constant here.\n\n```suggestion\nconst limit = 10\n```"
+	if body != want {
+		t.Fatalf("buildSuggestionBody() = %q, want %q", body, want)
+	}
+
+	before, replacement, after, ok := parseSuggestion(body + "\n\nThanks!")
+	if !ok {
+		t.Fatalf("parseSuggestion() found no suggestion in %q", body)
+	}
+	if before != "Use a constant here." || after != "Thanks!" {
+		t.Errorf("parseSuggestion() before = %q, after = %q", before, after)
+	}
+	if len(replacement) != 1 || replacement[0] != "const limit = 10" {
+		t.Errorf("parseSuggestion() replacement = %q", replacement)
+	}
+
+	// An empty block suggests deleting the lines.
+	_, replacement, _, ok = parseSuggestion(buildSuggestionBody("", ""))
+	if !ok || len(replacement) != 0 {
+		t.Errorf("empty suggestion parsed as %q, ok = %v", replacement, ok)
+	}
+
+	if hasSuggestion("```go\nfmt.Println()\n```") {
+		t.Errorf("hasSuggestion() matched a plain code block")
+	}
+}
+
+func TestRenderSuggestion(t *testing.T) {
+	body := buildSuggestionBody("Rename", "x := 2")
+	got := renderSuggestion(body, []string{"y := 2"})
+	want := "Rename\n\nSuggested change:\n- y := 2\n+ x := 2"
+	if got != want {
+		t.Errorf("renderSuggestion() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildCommentTree_Suggestion(t *testing.T) {
+	// The PR view renders comments from their JSON form
+	tree := []PRComment{
+		&JSONPRComment{CommentJSON{ID: "1", Author: "local", Body: buildSuggestionBody("Rename", "x := 2"), Position: "3", OriginalLines: "y := 2"}},
+	}
+
+	got := buildCommentTree(tree, "main.go", false)
+
+	if !strings.Contains(got, "- y := 2") || !strings.Contains(got, "+ x := 2") {
+		t.Errorf("suggestion not rendered as a diff:\n%s", got)
+	}
+}
+
+func TestSuggestionOriginal(t *testing.T) {
+	diff, err := utils.Parse(anchorTestDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	original, err := suggestionOriginal(diff, "main.go", 2, 3)
+	if err != nil {
+		t.Fatalf("suggestionOriginal() error = %v", err)
+	}
+	if strings.Join(original, "\n") != "var a = 2\nvar z = 0" {
+		t.Errorf("suggestionOriginal() = %q", original)
+	}
+
+	if _, err := suggestionOriginal(diff, "main.go", 4, 9); err == nil {
+		t.Errorf("suggestionOriginal() outside the diff should fail")
+	}
+}
+
+func TestImportedSuggestionOriginal(t *testing.T) {
+	diff, err := utils.Parse(anchorTestDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	c := &github.PullRequestComment{Path: github.String("main.go"), StartLine: github.Int(2), Line: github.Int(3), Side: github.String("RIGHT"), CommitID: github.String("head1")}
+
+	if got := importedSuggestionOriginal(diff, "head1", c); got != "var a = 2\nvar z = 0" {
+		t.Errorf("importedSuggestionOriginal() = %q", got)
+	}
+	if got := importedSuggestionOriginal(diff, "head2", c); got != "" {
+		t.Errorf("importedSuggestionOriginal() against another head = %q, want nothing", got)
+	}
+}
+
+func TestApplySuggestion(t *testing.T) {
+	content := "a\nb\nc\nd\n"
+
+	got, err := applySuggestion(content, 2, 3, []string{"b", "c"}, []string{"B"})
+	if err != nil {
+		t.Fatalf("applySuggestion() error = %v", err)
+	}
+	if got != "a\nB\nd\n" {
+		t.Errorf("applySuggestion() = %q", got)
+	}
+
+	if _, err := applySuggestion(content, 2, 2, []string{"changed"}, []string{"B"}); err == nil {
+		t.Errorf("applySuggestion() should refuse when the lines changed")
+	}
+	if _, err := applySuggestion(content, 4, 5, []string{"d", "e"}, nil); err == nil {
+		t.Errorf("applySuggestion() should refuse lines past the end of the file")
+	}
+}
+
+func TestApplySuggestion_WithoutOriginalLines(t *testing.T) {
+	db := testutil.NewDB(t)
+	worktree := t.TempDir()
+	if err := db.AddWorktree(7, "api", "org", worktree, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	body := buildSuggestionBody("", "B")
+	// Stored without OriginalLines, like suggestions from before they were recorded
+	comment, err := db.InsertLocalComment(database.LocalComment{
+		Owner: "org", Repo: "api", Number: 7, Filename: "main.go", Body: &body,
+		CommentAnchor: database.CommentAnchor{Line: 2, Side: sideRight}, CommitSHA: "head1",
+		Kind: database.CommentKindSuggestion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &RPCHandler{Log: slog.New(slog.DiscardHandler)}
+	apply := func(content string) (string, error) {
+		path := filepath.Join(worktree, "main.go")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var reply ApplySuggestionReply
+		err := h.ApplySuggestion(&ApplySuggestionArgs{Owner: "org", Repo: "api", Number: 7, ID: comment.ID}, &reply)
+		patched, _ := os.ReadFile(path)
+		return string(patched), err
+	}
+
+	// Without a stored diff of its head the replaced lines are unknown
+	if got, err := apply("a\nb\nc\n"); err == nil || got != "a\nb\nc\n" {
+		t.Errorf("ApplySuggestion() without a diff = %q, %v, want a refusal", got, err)
+	}
+
+	if err := db.UpsertPullRequest(7, "org", "api", "head1", diffHeader+"@@ -1,2 +1,3 @@\n a\n+b\n c\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := apply("a\nchanged\nc\n"); err == nil || got != "a\nchanged\nc\n" {
+		t.Errorf("ApplySuggestion() over changed lines = %q, %v, want a refusal", got, err)
+	}
+	if got, err := apply("a\nb\nc\n"); err != nil || got != "a\nB\nc\n" {
+		t.Errorf("ApplySuggestion() = %q, %v, want b replaced", got, err)
+	}
+}
+
+func TestWorktreeFile(t *testing.T) {
+	for filename, ok := range map[string]bool{
+		"main.go":           true,
+		"cmd/../main.go":    true,
+		"..config.go":       true,
+		"../other/main.go":  false,
+		"cmd/../../main.go": false,
+		"/etc/passwd":       false,
+	} {
+		path, err := worktreeFile("/work/api", filename)
+		if (err == nil) != ok {
+			t.Errorf("worktreeFile(%q) = %q, %v, want ok = %v", filename, path, err, ok)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crs/config"
+	"crs/git_tools"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// loadReviewThreads returns the PR's review threads, from the cache unless skipCache is set.
+// Thread state only decorates comments, so failures are logged and yield no threads.
+func loadReviewThreads(client *github.Client, owner, repo string, number int, skipCache bool) []git_tools.ReviewThread {
+	var threads []git_tools.ReviewThread
+	if !skipCache {
+		cachedThreadsJSON, err := config.C.DB.GetPRReviewThreads(number, repo)
+		if err == nil && cachedThreadsJSON != "" {
+			if err := json.Unmarshal([]byte(cachedThreadsJSON), &threads); err == nil {
+				return threads
+			}
+		}
+	}
+
+	threads, err := git_tools.GetReviewThreads(client, owner, repo, number)
+	if err != nil {
+		slog.Warn("Error fetching review threads", "pr", number, "repo", repo, "error", err)
+		return nil
+	}
+	if threadsJSON, err := json.Marshal(threads); err == nil {
+		config.C.DB.UpsertPRReviewThreads(number, repo, string(threadsJSON))
+	}
+	return threads
+}
+
+// findThread returns the thread containing the GitHub comment, or nil.
+func findThread(threads []git_tools.ReviewThread, commentID int64) *git_tools.ReviewThread {
+	for i := range threads {
+		for _, id := range threads[i].CommentIDs {
+			if id == commentID {
+				return &threads[i]
+			}
+		}
+	}
+	return nil
+}
+
+// applyThreadState marks the comments belonging to a review thread with the thread's ID and
+// resolution. Local comments are skipped: their IDs are ours, not GitHub's.
+func applyThreadState(comments []CommentJSON, threads []git_tools.ReviewThread) {
+	for i := range comments {
+		if comments[i].Author == "local" {
+			continue
+		}
+		id, err := strconv.ParseInt(comments[i].ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		if thread := findThread(threads, id); thread != nil {
+			comments[i].ThreadID = thread.ID
+			comments[i].Resolved = thread.IsResolved
+			comments[i].ResolvedBy = thread.ResolvedBy
+		}
+	}
+}
+
+// markThreadState links the GitHub comments among comments to their review thread, for render
+// paths that build comment trees without going through CommentJSON.
+func markThreadState(comments []PRComment, threads []git_tools.ReviewThread) {
+	for _, c := range comments {
+		if gh, ok := c.(*GitHubPRComment); ok {
+			gh.Thread = findThread(threads, gh.PullRequestComment.GetID())
+		}
+	}
+}
+
+type ResolveThreadArgs struct {
+	Owner     string `json:"Owner"`
+	Repo      string `json:"Repo"`
+	Number    int    `json:"Number"`
+	ThreadID  string `json:"ThreadID"`  // GraphQL thread ID, as returned in thread_id
+	CommentID int64  `json:"CommentID"` // Alternatively, the ID of any comment in the thread
+}
+
+type ResolveThreadReply struct {
+	Okay             bool          `json:"okay"`
+	Content          string        `json:"content"`
+	Metadata         *PRMetadata   `json:"metadata"`
+	Diff             string        `json:"diff"`
+	Comments         []CommentJSON `json:"comments"`
+	OutdatedComments []CommentJSON `json:"outdated_comments"`
+	Reviews          []ReviewJSON  `json:"reviews"`
+}
+
+func (h *RPCHandler) ResolveThread(args *ResolveThreadArgs, reply *ResolveThreadReply) error {
+	return h.setThreadResolved(args, true, reply)
+}
+
+func (h *RPCHandler) UnresolveThread(args *ResolveThreadArgs, reply *ResolveThreadReply) error {
+	return h.setThreadResolved(args, false, reply)
+}
+
+func (h *RPCHandler) setThreadResolved(args *ResolveThreadArgs, resolved bool, reply *ResolveThreadReply) error {
+	client := git_tools.GetGithubClientFor(args.Owner, args.Repo)
+
+	threadID := args.ThreadID
+	if threadID == "" {
+		thread := findThread(loadReviewThreads(client, args.Owner, args.Repo, args.Number, true), args.CommentID)
+		if thread == nil {
+			return fmt.Errorf("comment %d is not part of a review thread", args.CommentID)
+		}
+		threadID = thread.ID
+	}
+
+	var err error
+	if resolved {
+		err = git_tools.ResolveReviewThread(client, threadID)
+	} else {
+		err = git_tools.UnresolveReviewThread(client, threadID)
+	}
+	if err != nil {
+		h.Log.Error("Error changing review thread resolution", "thread", threadID, "resolved", resolved, "error", err)
+		return err
+	}
+
+	// Refresh the cached thread state so the re-render shows the change.
+	loadReviewThreads(client, args.Owner, args.Repo, args.Number, true)
+
+	details, content, err := h.fetchPRAndRunPlugins(args.Owner, args.Repo, args.Number, false)
+	if err != nil {
+		return err
+	}
+
+	reply.Okay = true
+	reply.Content = content
+	reply.Metadata = &details.Metadata
+	reply.Diff = details.Diff
+	reply.Comments = details.Comments
+	reply.OutdatedComments = details.OutdatedComments
+	reply.Reviews = details.Reviews
+	return nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"crs/database"
+	"crs/git_tools"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func TestApplyThreadState(t *testing.T) {
+	threads := []git_tools.ReviewThread{
+		{ID: "T1", IsResolved: true, ResolvedBy: "alice", CommentIDs: []int64{10, 11}},
+		{ID: "T2", CommentIDs: []int64{20}},
+	}
+	comments := []CommentJSON{
+		{ID: "11", Author: "bob"},
+		{ID: "20", Author: "carol"},
+		{ID: "10", Author: "local"}, // local IDs can collide with GitHub's
+		{ID: "99", Author: "dave"},  // conversation comment, not in any thread
+	}
+
+	applyThreadState(comments, threads)
+
+	if c := comments[0]; c.ThreadID != "T1" || !c.Resolved || c.ResolvedBy != "alice" {
+		t.Errorf("reply in resolved thread = %+v", c)
+	}
+	if c := comments[1]; c.ThreadID != "T2" || c.Resolved {
+		t.Errorf("comment in open thread = %+v", c)
+	}
+	if comments[2].ThreadID != "" || comments[3].ThreadID != "" {
+		t.Errorf("local and unthreaded comments should be left alone: %+v, %+v", comments[2], comments[3])
+	}
+}
+
+func TestBuildCommentTree_Resolved(t *testing.T) {
+	tree := []PRComment{
+		&JSONPRComment{CommentJSON{ID: "10", Author: "bob", Body: "Typo here", Position: "3", Resolved: true, ResolvedBy: "alice"}},
+		&JSONPRComment{CommentJSON{ID: "11", Author: "alice", Body: "Fixed", Position: "3", InReplyTo: 10, Resolved: true, ResolvedBy: "alice"}},
+	}
+
+	got := buildCommentTree(tree, "main.go", false)
+
+	if !strings.Contains(got, "[RESOLVED]") || !strings.Contains(got, "Resolved by alice, 2 comment(s) hidden") {
+		t.Errorf("resolved thread not collapsed:\n%s", got)
+	}
+	if strings.Contains(got, "Typo here") {
+		t.Errorf("resolved thread should hide its comments:\n%s", got)
+	}
+
+	tree[0].(*JSONPRComment).Resolved = false
+	if got := buildCommentTree(tree, "main.go", false); !strings.Contains(got, "Typo here") {
+		t.Errorf("open thread should show its comments:\n%s", got)
+	}
+}
+
+func TestBuildCommentTree_ResolvedGitHubComments(t *testing.T) {
+	// GetPRDiffWithInlineComments builds trees straight from GitHub and local comments
+	local := database.LocalComment{ID: 10, Filename: "main.go", Position: 3, Body: github.String("Still open")}
+	tree := []PRComment{
+		&GitHubPRComment{PullRequestComment: &github.PullRequestComment{ID: github.Int64(10), Body: github.String("Typo here"), Position: github.Int(3)}},
+		&LocalPRComment{&local},
+	}
+	threads := []git_tools.ReviewThread{{ID: "T1", IsResolved: true, ResolvedBy: "alice", CommentIDs: []int64{10}}}
+
+	markThreadState(tree, threads)
+	got := buildCommentTree(tree, "main.go", false)
+
+	if !strings.Contains(got, "[RESOLVED]") || !strings.Contains(got, "Resolved by alice, 2 comment(s) hidden") {
+		t.Errorf("resolved thread not collapsed:\n%s", got)
+	}
+	if tree[1].IsResolved() {
+		t.Errorf("local comment with a colliding ID was marked resolved")
+	}
+}
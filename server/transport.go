@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+)
+
+// ParseListenAddr splits a -listen value like unix:///tmp/crs.sock or tcp://127.0.0.1:7777
+// into the network and address pair expected by net.Listen.
+func ParseListenAddr(raw string) (string, string, error) {
+	network, address, found := strings.Cut(raw, "://")
+	if !found {
+		return "", "", fmt.Errorf("invalid listen address: %s (expected unix:///path or tcp://host:port)", raw)
+	}
+	switch network {
+	case "unix":
+		if address == "" {
+			return "", "", fmt.Errorf("invalid listen address: %s (missing socket path)", raw)
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", "", fmt.Errorf("invalid listen address: %s: %w", raw, err)
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported listen network: %s (expected unix or tcp)", network)
+	}
+	return network, address, nil
+}
+
+// ListenAndServe runs the RPC server on a unix or tcp socket so that many clients can
+// attach to one long-lived server (and its single background sync) instead of each
+// spawning their own process over stdio.
+func ListenAndServe(log *slog.Logger, listenAddr string) error {
+	network, address, err := ParseListenAddr(listenAddr)
+	if err != nil {
+		return err
+	}
+
+	if network == "unix" {
+		// A socket left behind by a crashed server would make Listen fail with "address in use".
+		if err := removeStaleSocket(address); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listenAddr, err)
+	}
+	defer listener.Close()
+	log.Info("Listening for RPC clients", "network", network, "address", listener.Addr().String())
+
+	server, err := newRPCServer(log)
+	if err != nil {
+		return err
+	}
+	return serveListener(log, server, listener)
+}
+
+func serveListener(log *slog.Logger, server *rpc.Server, listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Error("Error accepting RPC connection", "error", err)
+			continue
+		}
+		log.Info("RPC client connected", "remote", conn.RemoteAddr().String())
+		go func(conn net.Conn) {
+			// ServeCodec blocks until the client hangs up and closes the connection itself.
+			server.ServeCodec(NewServerCodec(conn))
+			log.Info("RPC client disconnected", "remote", conn.RemoteAddr().String())
+		}(conn)
+	}
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("refusing to remove %s: not a socket", path)
+	}
+	// If another server is still accepting on the socket, leave it alone.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is already listening on %s", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/rpc/jsonrpc"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseListenAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		wantNetwork string
+		wantAddress string
+		wantErr     bool
+	}{
+		{
+			name:        "unix socket",
+			raw:         "unix:///tmp/crs.sock",
+			wantNetwork: "unix",
+			wantAddress: "/tmp/crs.sock",
+		},
+		{
+			name:        "tcp loopback",
+			raw:         "tcp://127.0.0.1:7777",
+			wantNetwork: "tcp",
+			wantAddress: "127.0.0.1:7777",
+		},
+		{
+			name:    "missing scheme",
+			raw:     "127.0.0.1:7777",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported scheme",
+			raw:     "udp://127.0.0.1:7777",
+			wantErr: true,
+		},
+		{
+			name:    "tcp without port",
+			raw:     "tcp://127.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "unix without path",
+			raw:     "unix://",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network, address, err := ParseListenAddr(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseListenAddr(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if network != tt.wantNetwork || address != tt.wantAddress {
+				t.Errorf("ParseListenAddr(%q) = (%q, %q), want (%q, %q)", tt.raw, network, address, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestServeListener_ConcurrentClients(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server, err := newRPCServer(log)
+	if err != nil {
+		t.Fatalf("newRPCServer() error = %v", err)
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "crs.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- serveListener(log, server, listener) }()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			t.Fatalf("client %d: Dial() error = %v", i, err)
+		}
+		client := jsonrpc.NewClient(conn)
+		var reply ListPluginsReply
+		if err := client.Call("RPCHandler.ListPlugins", &ListPluginsArgs{}, &reply); err != nil {
+			t.Errorf("client %d: ListPlugins error = %v", i, err)
+		}
+		defer client.Close()
+	}
+
+	listener.Close()
+	if err := <-done; err != nil {
+		t.Errorf("serveListener() returned %v after listener closed, want nil", err)
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "crs.sock")
+	if err := removeStaleSocket(socketPath); err != nil {
+		t.Fatalf("removeStaleSocket() on missing path error = %v", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	if err := removeStaleSocket(socketPath); err == nil {
+		t.Errorf("removeStaleSocket() on a live socket should fail")
+	}
+	listener.Close()
+}
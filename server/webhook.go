@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crs/config"
+	"crs/events"
+	"crs/git_tools"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// webhookDebounce coalesces the burst of events GitHub sends for one action (a submitted
+// review fires pull_request_review and one pull_request_review_comment per comment).
+const webhookDebounce = 2 * time.Second
+
+// WebhookHandler receives GitHub webhooks, drops the cached data of the PRs they mention and
+// asks for the workflows of the repo to be re-run.
+type WebhookHandler struct {
+	Log    *slog.Logger
+	Secret []byte
+	// Refresh re-runs the workflows that watch repo ("owner/repo").
+	Refresh func(repo string)
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+// webhookTarget is what a webhook event changed: PRs by number, or for status events only a commit.
+type webhookTarget struct {
+	Owner   string
+	Repo    string
+	Numbers []int
+	SHA     string
+}
+
+// ListenWebhooks serves GitHub webhooks on addr (e.g. ":8080") until the listener fails.
+func ListenWebhooks(log *slog.Logger, addr string, secret string, refresh func(repo string)) error {
+	handler := &WebhookHandler{Log: log, Secret: []byte(secret), Refresh: refresh}
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	log.Info("Listening for GitHub webhooks", "address", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	config.RLock()
+	defer config.RUnlock()
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := github.ValidatePayload(r, h.Secret)
+	if err != nil {
+		h.Log.Warn("Rejected webhook", "delivery", github.DeliveryID(r), "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	eventType := github.WebHookType(r)
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		h.Log.Warn("Could not parse webhook", "event", eventType, "delivery", github.DeliveryID(r), "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	target, ok := parseWebhookTarget(event)
+	if !ok {
+		h.Log.Debug("Ignoring webhook", "event", eventType, "delivery", github.DeliveryID(r))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.Log.Info("Received webhook", "event", eventType, "owner", target.Owner, "repo", target.Repo, "prs", target.Numbers, "sha", target.SHA)
+	invalidateWebhookTarget(h.Log, target)
+	for _, number := range target.Numbers {
+		events.Publish(events.PRChanged, events.PRChangedParams{Owner: target.Owner, Repo: target.Repo, Number: number, Event: eventType})
+	}
+	h.scheduleRefresh(target.Owner + "/" + target.Repo)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// parseWebhookTarget extracts the PRs an event is about. It returns false for events that
+// don't affect any PR data, like ping.
+func parseWebhookTarget(event interface{}) (webhookTarget, bool) {
+	var repo *github.Repository
+	target := webhookTarget{}
+	switch e := event.(type) {
+	case *github.PullRequestEvent:
+		repo = e.GetRepo()
+		target.Numbers = []int{e.GetNumber()}
+	case *github.PullRequestReviewEvent:
+		repo = e.GetRepo()
+		target.Numbers = []int{e.GetPullRequest().GetNumber()}
+	case *github.PullRequestReviewCommentEvent:
+		repo = e.GetRepo()
+		target.Numbers = []int{e.GetPullRequest().GetNumber()}
+	case *github.CheckSuiteEvent:
+		repo = e.GetRepo()
+		for _, pr := range e.GetCheckSuite().PullRequests {
+			target.Numbers = append(target.Numbers, pr.GetNumber())
+		}
+		target.SHA = e.GetCheckSuite().GetHeadSHA()
+	case *github.StatusEvent:
+		repo = e.GetRepo()
+		target.SHA = e.GetSHA()
+	default:
+		return target, false
+	}
+	target.Owner = repo.GetOwner().GetLogin()
+	target.Repo = repo.GetName()
+	if target.Repo == "" || (len(target.Numbers) == 0 && target.SHA == "") {
+		return target, false
+	}
+	return target, true
+}
+
+// invalidateWebhookTarget drops the cached metadata, comments, reviews and CI status so the
+// next GetPR fetches them fresh, along with the in-memory state the smart filters read.
+func invalidateWebhookTarget(log *slog.Logger, target webhookTarget) {
+	git_tools.ForgetPRState(target.Owner, target.Repo, target.Numbers)
+	db := config.C.DB
+	for _, number := range target.Numbers {
+		if err := db.DeletePRMetadataCache(target.Owner, target.Repo, number); err != nil {
+			log.Error("Error invalidating PR metadata", "repo", target.Repo, "pr", number, "error", err)
+		}
+		if err := db.DeletePRComments(number, target.Repo); err != nil {
+			log.Error("Error invalidating PR comments", "repo", target.Repo, "pr", number, "error", err)
+		}
+		if err := db.DeletePRReviews(number, target.Repo); err != nil {
+			log.Error("Error invalidating PR reviews", "repo", target.Repo, "pr", number, "error", err)
+		}
+		if err := db.DeletePRReviewThreads(number, target.Repo); err != nil {
+			log.Error("Error invalidating PR review threads", "repo", target.Repo, "pr", number, "error", err)
+		}
+		if err := db.DeleteCIStatus(number, target.Repo); err != nil {
+			log.Error("Error invalidating CI status", "repo", target.Repo, "pr", number, "error", err)
+		}
+	}
+	if target.SHA != "" {
+		if err := db.DeleteCIStatusForSHA(target.Repo, target.SHA); err != nil {
+			log.Error("Error invalidating CI status", "repo", target.Repo, "sha", target.SHA, "error", err)
+		}
+	}
+}
+
+// scheduleRefresh runs Refresh for repo once the current burst of events has passed.
+func (h *WebhookHandler) scheduleRefresh(repo string) {
+	if h.Refresh == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pending == nil {
+		h.pending = make(map[string]bool)
+	}
+	if h.pending[repo] {
+		return
+	}
+	h.pending[repo] = true
+	time.AfterFunc(webhookDebounce, func() {
+		h.mu.Lock()
+		delete(h.pending, repo)
+		h.mu.Unlock()
+		h.Refresh(repo)
+	})
+}
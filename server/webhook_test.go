@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crs/git_tools"
+	"crs/testutil"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func signedWebhook(secret, event, body string) *http.Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestWebhookHandlerSignature(t *testing.T) {
+	handler := &WebhookHandler{Log: slog.New(slog.NewTextHandler(io.Discard, nil)), Secret: []byte("s3cret")}
+
+	tests := []struct {
+		name   string
+		secret string
+		want   int
+	}{
+		{"valid signature", "s3cret", http.StatusNoContent},
+		{"wrong secret", "guess", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, signedWebhook(tt.secret, "ping", `{"zen": "Keep it logically awesome."}`))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWebhookTarget(t *testing.T) {
+	repo := &github.Repository{Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}}
+	tests := []struct {
+		name    string
+		event   interface{}
+		want    webhookTarget
+		wantHit bool
+	}{
+		{
+			name:    "pull request",
+			event:   &github.PullRequestEvent{Number: github.Int(7), Repo: repo},
+			want:    webhookTarget{Owner: "owner", Repo: "repo", Numbers: []int{7}},
+			wantHit: true,
+		},
+		{
+			name:    "review comment",
+			event:   &github.PullRequestReviewCommentEvent{PullRequest: &github.PullRequest{Number: github.Int(8)}, Repo: repo},
+			want:    webhookTarget{Owner: "owner", Repo: "repo", Numbers: []int{8}},
+			wantHit: true,
+		},
+		{
+			name: "check suite",
+			event: &github.CheckSuiteEvent{Repo: repo, CheckSuite: &github.CheckSuite{
+				HeadSHA:      github.String("abc"),
+				PullRequests: []*github.PullRequest{{Number: github.Int(1)}, {Number: github.Int(2)}},
+			}},
+			want:    webhookTarget{Owner: "owner", Repo: "repo", Numbers: []int{1, 2}, SHA: "abc"},
+			wantHit: true,
+		},
+		{
+			name:    "status",
+			event:   &github.StatusEvent{SHA: github.String("def"), Repo: repo},
+			want:    webhookTarget{Owner: "owner", Repo: "repo", SHA: "def"},
+			wantHit: true,
+		},
+		{
+			name:  "ping",
+			event: &github.PingEvent{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hit := parseWebhookTarget(tt.event)
+			if hit != tt.wantHit {
+				t.Fatalf("hit = %v, want %v", hit, tt.wantHit)
+			}
+			if !hit {
+				return
+			}
+			if got.Owner != tt.want.Owner || got.Repo != tt.want.Repo || got.SHA != tt.want.SHA || len(got.Numbers) != len(tt.want.Numbers) {
+				t.Fatalf("target = %+v, want %+v", got, tt.want)
+			}
+			for i := range got.Numbers {
+				if got.Numbers[i] != tt.want.Numbers[i] {
+					t.Errorf("target = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidateWebhookTarget_ForgetsFilterState(t *testing.T) {
+	testutil.NewDB(t)
+
+	keys := map[string]bool{
+		"ci_status:owner/repo:someone:feature": false,
+		"ci_status:owner/fork:someone:fix":     false,
+		"interaction_state:owner/repo:7":       false,
+		"interaction_state:owner/repo:70":      true,
+		"ci_status:other/repo:other:main":      true,
+	}
+	for key := range keys {
+		git_tools.GlobalCache.Set(key, "cached", time.Hour)
+	}
+	t.Cleanup(func() {
+		for key := range keys {
+			git_tools.GlobalCache.Delete(key)
+		}
+	})
+
+	invalidateWebhookTarget(slog.New(slog.NewTextHandler(io.Discard, nil)), webhookTarget{Owner: "owner", Repo: "repo", Numbers: []int{7}})
+	for key, kept := range keys {
+		if _, found := git_tools.GlobalCache.Get(key); found != kept {
+			t.Errorf("%s cached = %v, want %v", key, found, kept)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Package testutil holds helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"crs/config"
+	"crs/database"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// NewDB opens a fresh database in the test's temp dir and makes it config.C.DB. The whole
+// config is restored when the test ends, so tests may change other settings too.
+func NewDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	saved := config.C
+	t.Cleanup(func() {
+		config.C = saved
+		db.Close()
+	})
+	config.C.DB = db
+	return db
+}
+
+// NewGithubClient starts a server running handler for the test and returns a github.com style
+// client pointed at it, sending through httpClient (nil for the default, as with github.NewClient).
+func NewGithubClient(t *testing.T, httpClient *http.Client, handler http.Handler) *github.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := github.NewClient(httpClient)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client
+}
@@ -125,6 +125,109 @@ func (d *Diff) Changed() map[string][]int {
 	return dFiles
 }
 
+// File returns the DiffFile for a path, matching either its new or original name.
+func (d *Diff) File(name string) *DiffFile {
+	for _, f := range d.Files {
+		if f.NewName == name || f.OrigName == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// LineAtPosition returns the line at a GitHub diff position (1 = first line after the
+// first hunk header) in the named file, or nil if there is none.
+func (d *Diff) LineAtPosition(name string, position int) *DiffLine {
+	f := d.File(name)
+	if f == nil {
+		return nil
+	}
+	for _, h := range f.Hunks {
+		for _, l := range h.WholeRange.Lines {
+			if l.Position == position {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+// PositionOfLine returns the diff position of a file line. When old is true the number is
+// looked up in the original file (the LEFT side), otherwise in the new file (the RIGHT side).
+// It returns 0 if the line is not part of the diff.
+func (d *Diff) PositionOfLine(name string, number int, old bool) int {
+	f := d.File(name)
+	if f == nil {
+		return 0
+	}
+	for _, h := range f.Hunks {
+		lines := h.NewRange.Lines
+		if old {
+			lines = h.OrigRange.Lines
+		}
+		for _, l := range lines {
+			if l.Number == number {
+				return l.Position
+			}
+		}
+	}
+	return 0
+}
+
+// LinesAtPosition returns the original and new line numbers at a diff position of the named
+// file. An added line has no original number and a removed one no new number, so those are 0.
+func (d *Diff) LinesAtPosition(name string, position int) (old int, new int) {
+	f := d.File(name)
+	if f == nil {
+		return 0, 0
+	}
+	for _, h := range f.Hunks {
+		for _, l := range h.OrigRange.Lines {
+			if l.Position == position {
+				old = l.Number
+			}
+		}
+		for _, l := range h.NewRange.Lines {
+			if l.Position == position {
+				new = l.Number
+			}
+		}
+	}
+	return old, new
+}
+
+// MapLine follows a line of the file's original version into its new version. It returns
+// false when the line was removed or changed by one of the hunks.
+func (f *DiffFile) MapLine(old int) (int, bool) {
+	offset := 0
+	for _, h := range f.Hunks {
+		// Count from the parsed lines rather than the header, which may omit lengths.
+		origLen := len(h.OrigRange.Lines)
+		if old < h.OrigRange.Start || (origLen == 0 && old <= h.OrigRange.Start) {
+			return old + offset, true
+		}
+		if old < h.OrigRange.Start+origLen {
+			for _, l := range h.OrigRange.Lines {
+				if l.Number != old {
+					continue
+				}
+				if l.Mode != UNCHANGED {
+					return 0, false
+				}
+				// Unchanged lines share their diff position between both sides.
+				for _, n := range h.NewRange.Lines {
+					if n.Position == l.Position {
+						return n.Number, true
+					}
+				}
+			}
+			return 0, false
+		}
+		offset += len(h.NewRange.Lines) - origLen
+	}
+	return old + offset, true
+}
+
 func regFind(s string, reg string, group int) string {
 	re := regexp.MustCompile(reg)
 	return re.FindStringSubmatch(s)[group]
@@ -176,3 +176,113 @@ func Test_InsertLinesAtEnd(t *testing.T) {
 		t.Fatalf("Adding Line at the end failed.  Expected END before the newly added line")
 	}
 }
+
+const positionTestDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@
+ package main
+-var a = 1
++var a = 2
+ var b = 3
+ var c = 4
+`
+
+func Test_LineAtPosition(t *testing.T) {
+	diff, err := Parse(positionTestDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		position   int
+		wantMode   DiffLineMode
+		wantNumber int
+	}{
+		{1, UNCHANGED, 1},
+		{2, REMOVED, 2},
+		{3, ADDED, 2},
+		{4, UNCHANGED, 3},
+	}
+	for _, tt := range tests {
+		line := diff.LineAtPosition("main.go", tt.position)
+		if line == nil {
+			t.Fatalf("LineAtPosition(%d) = nil", tt.position)
+		}
+		if line.Mode != tt.wantMode || line.Number != tt.wantNumber {
+			t.Errorf("LineAtPosition(%d) = mode %v line %d, want mode %v line %d", tt.position, line.Mode, line.Number, tt.wantMode, tt.wantNumber)
+		}
+	}
+
+	if line := diff.LineAtPosition("main.go", 99); line != nil {
+		t.Errorf("LineAtPosition(99) = %v, want nil", line)
+	}
+	if line := diff.LineAtPosition("other.go", 1); line != nil {
+		t.Errorf("LineAtPosition on unknown file = %v, want nil", line)
+	}
+}
+
+func Test_PositionOfLine(t *testing.T) {
+	diff, err := Parse(positionTestDiff)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if got := diff.PositionOfLine("main.go", 2, false); got != 3 {
+		t.Errorf("PositionOfLine(new 2) = %d, want 3", got)
+	}
+	if got := diff.PositionOfLine("main.go", 2, true); got != 2 {
+		t.Errorf("PositionOfLine(old 2) = %d, want 2", got)
+	}
+	if got := diff.PositionOfLine("main.go", 40, false); got != 0 {
+		t.Errorf("PositionOfLine(new 40) = %d, want 0", got)
+	}
+
+	if old, new := diff.LinesAtPosition("main.go", 3); old != 0 || new != 2 {
+		t.Errorf("LinesAtPosition(3) = (%d, %d), want (0, 2) for an added line", old, new)
+	}
+	if old, new := diff.LinesAtPosition("main.go", 4); old != 3 || new != 3 {
+		t.Errorf("LinesAtPosition(4) = (%d, %d), want (3, 3) for a context line", old, new)
+	}
+}
+
+func Test_MapLine(t *testing.T) {
+	diff, err := Parse(`diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -2,3 +2,4 @@
+ keep
+-old
++new
++extra
+ keep
+@@ -10,0 +12,2 @@
++added
++added
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	file := diff.File("main.go")
+
+	tests := []struct {
+		old    int
+		want   int
+		wantOK bool
+	}{
+		{1, 1, true},   // before the first hunk
+		{2, 2, true},   // context line inside a hunk
+		{3, 0, false},  // removed line
+		{4, 5, true},   // context after the replacement shifts by one
+		{10, 11, true}, // insertion after line 10 does not move line 10 itself
+		{11, 14, true}, // after both hunks
+	}
+	for _, tt := range tests {
+		got, ok := file.MapLine(tt.old)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("MapLine(%d) = (%d, %v), want (%d, %v)", tt.old, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
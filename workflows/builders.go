@@ -2,8 +2,10 @@ package workflows
 
 import (
 	"log/slog"
+	"slices"
 	"strings"
 	"crs/config"
+	"crs/forge"
 	"crs/git_tools"
 
 	"github.com/google/go-github/v48/github"
@@ -12,6 +14,16 @@ import (
 func MatchWorkflows(workflow_maps []config.RawWorkflow, repos *[]string, jiraDomain string) []Workflow {
 	workflows := []Workflow{}
 	for _, raw_workflow := range workflow_maps {
+		if !slices.Contains(WorkflowTypes, raw_workflow.WorkflowType) {
+			slog.Error("Skipping workflow with an unknown type", "workflow", raw_workflow.Name, "error", raw_workflow.Errorf("WorkflowType", "unknown WorkflowType %q", raw_workflow.WorkflowType))
+			continue
+		}
+		if raw_workflow.Filter != "" {
+			if _, err := CompileFilterExpression(raw_workflow.Filter); err != nil {
+				slog.Error("Skipping workflow with an invalid filter", "workflow", raw_workflow.Name, "error", raw_workflow.FilterError(err))
+				continue
+			}
+		}
 		if raw_workflow.WorkflowType == "SyncReviewRequestsWorkflow" {
 			workflows = append(workflows, BuildSyncReviewRequestWorkflow(&raw_workflow, repos))
 		}
@@ -22,7 +34,13 @@ func MatchWorkflows(workflow_maps []config.RawWorkflow, repos *[]string, jiraDom
 			workflows = append(workflows, BuildListMyPRsWorkflow(&raw_workflow, repos))
 		}
 		if raw_workflow.WorkflowType == "ProjectListWorkflow" {
-			workflows = append(workflows, BuildProjectListWorkflow(&raw_workflow, jiraDomain))
+			workflows = append(workflows, BuildProjectListWorkflow(&raw_workflow, repos, jiraDomain))
+		}
+		if raw_workflow.WorkflowType == "NotificationsWorkflow" {
+			workflows = append(workflows, BuildNotificationsWorkflow(&raw_workflow))
+		}
+		if raw_workflow.WorkflowType == "SearchWorkflow" {
+			workflows = append(workflows, BuildSearchWorkflow(&raw_workflow))
 		}
 	}
 	return workflows
@@ -32,7 +50,7 @@ func BuildSingleRepoReviewWorkflow(raw *config.RawWorkflow, repos *[]string) Wor
 	wf := SingleRepoSyncReviewRequestsWorkflow{
 		Name:                raw.Name,
 		Owner:               raw.Owner,
-		Repo:                raw.Repo,
+		Repo:                config.WithHost(raw.Host, []string{raw.Repo})[0],
 		Filters:             BuildFiltersList(raw),
 		SectionTitle:        raw.SectionTitle,
 		ReleaseCheckCommand: raw.ReleaseCheckCommand,
@@ -51,7 +69,7 @@ func BuildSyncReviewRequestWorkflow(raw *config.RawWorkflow, repos *[]string) Wo
 	wf := SyncReviewRequestsWorkflow{
 		Name:                raw.Name,
 		Owner:               raw.Owner,
-		Repos:               workflowRepos,
+		Repos:               config.WithHost(raw.Host, workflowRepos),
 		Filters:             BuildFiltersList(raw),
 		SectionTitle:        raw.SectionTitle,
 		ReleaseCheckCommand: raw.ReleaseCheckCommand,
@@ -70,7 +88,7 @@ func BuildListMyPRsWorkflow(raw *config.RawWorkflow, repos *[]string) Workflow {
 	wf := ListMyPRsWorkflow{
 		Name:                raw.Name,
 		Owner:               raw.Owner,
-		Repos:               workflowRepos,
+		Repos:               config.WithHost(raw.Host, workflowRepos),
 		Filters:             BuildFiltersList(raw),
 		PRState:             raw.PRState,
 		SectionTitle:        raw.SectionTitle,
@@ -81,13 +99,51 @@ func BuildListMyPRsWorkflow(raw *config.RawWorkflow, repos *[]string) Workflow {
 	return wf
 }
 
-func BuildProjectListWorkflow(raw *config.RawWorkflow, jiraDomain string) Workflow {
+func BuildProjectListWorkflow(raw *config.RawWorkflow, repos *[]string, jiraDomain string) Workflow {
+	// Owner and Repo are the original single repo form
+	workflowRepos := *repos
+	if len(raw.Repos) > 0 {
+		workflowRepos = raw.Repos
+	} else if raw.Repo != "" {
+		workflowRepos = []string{raw.Owner + "/" + raw.Repo}
+	}
+
 	wf := ProjectListWorkflow{
 		Name:                raw.Name,
-		Owner:               raw.Owner,
-		Repo:                raw.Repo,
+		Repos:               config.WithHost(raw.Host, workflowRepos),
 		JiraDomain:          jiraDomain,
 		JiraEpic:            raw.JiraEpic,
+		JQL:                 raw.JQL,
+		Filters:             BuildFiltersList(raw),
+		SectionTitle:        raw.SectionTitle,
+		ReleaseCheckCommand: raw.ReleaseCheckCommand,
+		Prune:               raw.Prune,
+		IncludeDiff:         raw.IncludeDiff,
+	}
+	return wf
+}
+
+func BuildNotificationsWorkflow(raw *config.RawWorkflow) Workflow {
+	reasons := raw.Reasons
+	if len(reasons) == 0 {
+		reasons = git_tools.NotificationReasons
+	}
+	wf := NotificationsWorkflow{
+		Name:         raw.Name,
+		Host:         raw.Host,
+		Repos:        raw.Repos,
+		Reasons:      reasons,
+		SectionTitle: raw.SectionTitle,
+		Prune:        raw.Prune,
+	}
+	return wf
+}
+
+func BuildSearchWorkflow(raw *config.RawWorkflow) Workflow {
+	wf := SearchWorkflow{
+		Name:                raw.Name,
+		Host:                raw.Host,
+		Query:               raw.Query,
 		Filters:             BuildFiltersList(raw),
 		SectionTitle:        raw.SectionTitle,
 		ReleaseCheckCommand: raw.ReleaseCheckCommand,
@@ -103,12 +159,12 @@ var filter_func_map = map[string]func(prs []*github.PullRequest) []*github.PullR
 	"FilterIsDraft":           git_tools.FilterIsDraft,
 	"FilterNotMyPRs":          git_tools.FilterNotMyPRs,
 	"FilterMyPRs":             git_tools.FilterMyPRs,
-	"FilterCIPassing":         git_tools.FilterCIPassing,
-	"FilterCIFailing":         git_tools.FilterCIFailing,
+	"FilterCIPassing":         git_tools.MakeCIPassingFilter(forge.PRState{}),
+	"FilterCIFailing":         git_tools.MakeCIFailingFilter(forge.PRState{}),
 	"FilterStale":             git_tools.FilterStale,
 	"FilterNotStale":          git_tools.FilterNotStale,
-	"FilterWaitingOnMe":       git_tools.FilterWaitingOnMe,
-	"FilterWaitingOnAuthor":    git_tools.FilterWaitingOnAuthor,
+	"FilterWaitingOnMe":       git_tools.MakeWaitingOnMeFilter(forge.PRState{}),
+	"FilterWaitingOnAuthor":   git_tools.MakeWaitingOnAuthorFilter(forge.PRState{}),
 }
 
 func ParseFilterString(raw string) (string, string) {
@@ -164,5 +220,15 @@ func BuildFiltersList(raw *config.RawWorkflow) []git_tools.PRFilter {
 		}
 		filters = append(filters, filter_func)
 	}
+
+	// The Filter expression is ANDed with the list above
+	if raw.Filter != "" {
+		filter, err := CompileFilterExpression(raw.Filter)
+		if err != nil {
+			slog.Warn("Invalid filter expression", "error", raw.FilterError(err))
+		} else {
+			filters = append(filters, filter)
+		}
+	}
 	return filters
 }
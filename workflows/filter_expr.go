@@ -0,0 +1,160 @@
+package workflows
+
+import (
+	"crs/filter_expr"
+	"crs/git_tools"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+type prPredicate func(pr *github.PullRequest) bool
+
+// CompileFilterExpression turns a Filter expression such as
+// "FilterWaitingOnMe or (label:urgent and not FilterIsDraft)" into a single PR filter.
+// Besides the names in filter_func_map it understands label:, author:, author:in(team),
+// age (since the PR was opened) and idle (since it was last updated) compared with >, >=, < or <=.
+func CompileFilterExpression(input string) (git_tools.PRFilter, error) {
+	expr, err := filter_expr.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	predicate, err := compileExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(prs []*github.PullRequest) []*github.PullRequest {
+		filtered := []*github.PullRequest{}
+		for _, pr := range prs {
+			if predicate(pr) {
+				filtered = append(filtered, pr)
+			}
+		}
+		return filtered
+	}, nil
+}
+
+func compileExpr(expr filter_expr.Expr) (prPredicate, error) {
+	switch e := expr.(type) {
+	case filter_expr.And:
+		left, right, err := compilePair(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return func(pr *github.PullRequest) bool { return left(pr) && right(pr) }, nil
+	case filter_expr.Or:
+		left, right, err := compilePair(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return func(pr *github.PullRequest) bool { return left(pr) || right(pr) }, nil
+	case filter_expr.Not:
+		inner, err := compileExpr(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(pr *github.PullRequest) bool { return !inner(pr) }, nil
+	default:
+		return compileTerm(expr.(filter_expr.Term))
+	}
+}
+
+func compilePair(left, right filter_expr.Expr) (prPredicate, prPredicate, error) {
+	l, err := compileExpr(left)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := compileExpr(right)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+// fromFilter adapts a list filter to a single PR.
+func fromFilter(filter git_tools.PRFilter) prPredicate {
+	return func(pr *github.PullRequest) bool {
+		return len(filter([]*github.PullRequest{pr})) == 1
+	}
+}
+
+func compileTerm(t filter_expr.Term) (prPredicate, error) {
+	switch t.Name {
+	case "label", "FilterByLabel":
+		if t.Op != ":" || t.In {
+			return nil, t.Errorf("%s needs a label, e.g. %s:bug", t.Name, t.Name)
+		}
+		return fromFilter(git_tools.MakeLabelFilter(t.Arg)), nil
+	case "author", "FilterByAuthor":
+		if t.Op != ":" {
+			return nil, t.Errorf("%s needs a login or in(team), e.g. %s:alice", t.Name, t.Name)
+		}
+		if t.In {
+			return fromFilter(git_tools.MakeTeamAuthorFilter(t.Arg)), nil
+		}
+		return fromFilter(git_tools.MakeAuthorFilter(t.Arg)), nil
+	case "FilterExcludeAuthor":
+		if t.Op != ":" || t.In {
+			return nil, t.Errorf("FilterExcludeAuthor needs a login, e.g. FilterExcludeAuthor:bot")
+		}
+		return fromFilter(git_tools.MakeExcludeAuthorFilter(t.Arg)), nil
+	case "age", "idle":
+		return compileAgeTerm(t)
+	}
+
+	filter := filter_func_map[t.Name]
+	if filter == nil {
+		return nil, t.Errorf("unknown filter %q", t.Name)
+	}
+	if t.Op != "" {
+		return nil, t.Errorf("%s does not take an argument", t.Name)
+	}
+	return fromFilter(filter), nil
+}
+
+func compileAgeTerm(t filter_expr.Term) (prPredicate, error) {
+	if t.In || (t.Op != ">" && t.Op != ">=" && t.Op != "<" && t.Op != "<=") {
+		return nil, t.Errorf("%s needs a comparison, e.g. %s>3d", t.Name, t.Name)
+	}
+	threshold, err := parseFilterDuration(t.Arg)
+	if err != nil {
+		return nil, t.Errorf("%s: %v", t.Name, err)
+	}
+	return func(pr *github.PullRequest) bool {
+		since := pr.GetCreatedAt()
+		if t.Name == "idle" {
+			since = pr.GetUpdatedAt()
+		}
+		if since.IsZero() {
+			return false
+		}
+		age := time.Since(since)
+		switch t.Op {
+		case ">":
+			return age > threshold
+		case ">=":
+			return age >= threshold
+		case "<":
+			return age < threshold
+		default:
+			return age <= threshold
+		}
+	}, nil
+}
+
+// parseFilterDuration parses durations like 3d and 2w on top of what time.ParseDuration accepts.
+func parseFilterDuration(s string) (time.Duration, error) {
+	days := map[string]int{"d": 1, "w": 7}
+	for suffix, multiplier := range days {
+		if n, found := strings.CutSuffix(s, suffix); found {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count*multiplier) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
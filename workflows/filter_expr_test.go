@@ -0,0 +1,114 @@
+package workflows
+
+import (
+	"crs/config"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func TestCompileFilterExpression(t *testing.T) {
+	makePR := func(number int, author string, draft bool, age time.Duration, labelNames ...string) *github.PullRequest {
+		labels := []*github.Label{}
+		for _, name := range labelNames {
+			labels = append(labels, &github.Label{Name: github.String(name)})
+		}
+		created := time.Now().Add(-age)
+		return &github.PullRequest{
+			Number:    github.Int(number),
+			User:      &github.User{Login: github.String(author)},
+			Draft:     github.Bool(draft),
+			Labels:    labels,
+			CreatedAt: &created,
+			UpdatedAt: &created,
+		}
+	}
+	prs := []*github.PullRequest{
+		makePR(1, "alice", false, time.Hour, "urgent"),
+		makePR(2, "bob", true, 5*24*time.Hour, "urgent"),
+		makePR(3, "carol", false, 10*24*time.Hour, "bug"),
+		makePR(4, "alice", true, 2*time.Hour),
+	}
+
+	tests := []struct {
+		expr string
+		want []int
+	}{
+		{"label:urgent", []int{1, 2}},
+		{"FilterByLabel:urgent and not FilterIsDraft", []int{1}},
+		{"author:carol or (label:urgent and not FilterIsDraft)", []int{1, 3}},
+		{"not (author:alice or label:bug)", []int{2}},
+		{"age>3d", []int{2, 3}},
+		{"idle<=1w and FilterIsDraft", []int{2, 4}},
+		{"age<90m", []int{1}},
+		{"FilterExcludeAuthor:alice and FilterNotDraft", []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := CompileFilterExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileFilterExpression(%q) error: %v", tt.expr, err)
+			}
+			got := []int{}
+			for _, pr := range filter(prs) {
+				got = append(got, pr.GetNumber())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%q kept %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileFilterExpressionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"FilterNope",
+		"FilterIsDraft:yes",
+		"label",
+		"age:3d",
+		"age>soon",
+		"a or",
+	} {
+		if _, err := CompileFilterExpression(expr); err == nil {
+			t.Errorf("CompileFilterExpression(%q) error = nil, want an error", expr)
+		}
+	}
+}
+
+func TestBuildFiltersList_FilterExpression(t *testing.T) {
+	draft := true
+	notDraft := false
+	raw := config.RawWorkflow{
+		Filters: []string{"FilterNotDraft"},
+		Filter:  "label:urgent or label:bug",
+	}
+	filters := BuildFiltersList(&raw)
+	if len(filters) != 2 {
+		t.Fatalf("expected 2 filters, got %d", len(filters))
+	}
+	prs := []*github.PullRequest{
+		{Number: github.Int(1), Draft: &notDraft, Labels: []*github.Label{{Name: github.String("bug")}}},
+		{Number: github.Int(2), Draft: &draft, Labels: []*github.Label{{Name: github.String("urgent")}}},
+		{Number: github.Int(3), Draft: &notDraft},
+	}
+	for _, filter := range filters {
+		prs = filter(prs)
+	}
+	if len(prs) != 1 || prs[0].GetNumber() != 1 {
+		t.Errorf("expected only PR #1 to pass the list and the expression, got %d PRs", len(prs))
+	}
+}
+
+func TestMatchWorkflows_SkipsInvalidFilterExpression(t *testing.T) {
+	repos := []string{"org/api"}
+	raw := []config.RawWorkflow{
+		{WorkflowType: "SyncReviewRequestsWorkflow", Name: "good", Filter: "label:bug"},
+		{WorkflowType: "SyncReviewRequestsWorkflow", Name: "bad", Filter: "FilterNope"},
+	}
+	workflows := MatchWorkflows(raw, &repos, "")
+	if len(workflows) != 1 || workflows[0].GetName() != "good" {
+		t.Errorf("expected only the workflow with a valid filter, got %d workflows", len(workflows))
+	}
+}
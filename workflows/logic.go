@@ -3,10 +3,12 @@ package workflows
 import (
 	"bytes"
 	"crs/config"
+	"crs/events"
+	"crs/forge"
 	"crs/git_tools"
+	"crs/jira"
 	"crs/org"
 	"crs/utils"
-	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -16,6 +18,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/go-github/v48/github"
 )
@@ -24,6 +27,7 @@ type Workflow interface {
 	GetName() string
 	Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error)
 	GetOrgSectionName() string
+	GetRepos() []string // The "owner/repo" entries the workflow watches
 }
 
 type FileChanges struct {
@@ -58,6 +62,8 @@ type PRToOrgBridge struct {
 	// Implement the OrgTODO Interface for PRs
 	PR          *github.PullRequest
 	IncludeDiff bool
+	JiraIssue   *jira.Issue // Set by ProjectListWorkflow for the issue the PR is linked from
+	JiraDomain  string
 }
 
 func (prb PRToOrgBridge) ID() string {
@@ -108,9 +114,34 @@ func (prb PRToOrgBridge) ItemTitle(indent_level int, release_check_command strin
 			line = line + "merged:"
 		}
 	}
+	for _, tag := range prb.jiraTags() {
+		line = line + tag + ":"
+	}
 	return line
 }
 
+// jiraTags are the org tags for the linked Jira issue: its key, status and assignee.
+func (prb PRToOrgBridge) jiraTags() []string {
+	if prb.JiraIssue == nil {
+		return nil
+	}
+	tags := []string{orgTag(prb.JiraIssue.Key), orgTag(prb.JiraIssue.Status())}
+	if assignee := prb.JiraIssue.Assignee(); assignee != "" {
+		tags = append(tags, orgTag(assignee))
+	}
+	return tags
+}
+
+// orgTag replaces the characters which would break a :tag: list, like spaces in "In Progress".
+func orgTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-@#%", r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
 func (prb PRToOrgBridge) Summary() string {
 	return prb.Title()
 }
@@ -146,6 +177,13 @@ func (prb PRToOrgBridge) Details() []string {
 
 	details = append(details, fmt.Sprintf("Branch: %s\n", *prb.PR.Head.Label))
 
+	if prb.JiraIssue != nil {
+		details = append(details, fmt.Sprintf("Jira: %s (%s) %s\n", prb.JiraIssue.Key, prb.JiraIssue.Status(), jira.BrowseURL(prb.JiraDomain, prb.JiraIssue.Key)))
+		if assignee := prb.JiraIssue.Assignee(); assignee != "" {
+			details = append(details, fmt.Sprintf("Jira Assignee: %s\n", assignee))
+		}
+	}
+
 	reviewers := strings.Join(utils.Map(prb.PR.RequestedReviewers, getReviewerName), ", ")
 	if reviewers != "" {
 		details = append(details, fmt.Sprintf("Requested Reviewers: %s\n", reviewers))
@@ -168,7 +206,7 @@ func (prb PRToOrgBridge) Details() []string {
 			details = append(details, "Merged with Empty Merge Commit SHA?")
 		}
 	} else {
-		ciInfo := git_tools.GetCIStatus(*prb.PR.Base.Repo.Owner.Login, *prb.PR.Head.Repo.Name, *prb.PR.Head.Label)
+		ciInfo := git_tools.PRCIStatus(prb.PR, forge.PRState{})
 		if len(ciInfo.Statuses) > 0 {
 			details = append(details, fmt.Sprintf("*** %s CI Status\n", ciInfo.OverallStatus))
 			details = append(details, ciInfo.Statuses...)
@@ -264,7 +302,13 @@ func removePRBodySections(body *string) string {
 }
 
 func getComments(owner string, repo string, number int) (int, []string) {
-	comments, err := git_tools.GetPRComments(git_tools.GetGithubClient(), owner, repo, number)
+	var comments []*github.PullRequestComment
+	var err error
+	if forge.IsGitLab(owner, repo) {
+		comments, err = forge.NewGitLab().ListComments(owner, repo, number)
+	} else {
+		comments, err = git_tools.GetPRComments(git_tools.GetGithubClientFor(owner, repo), owner, repo, number)
+	}
 	comments = filterComments(comments)
 	
 	// Store the result in the database
@@ -292,7 +336,9 @@ func getComments(owner string, repo string, number int) (int, []string) {
 		for i, comment := range tree {
 			if i == 0 {
 				str_comments = append(str_comments, "**** "+comment.CreatedAt.Format(time.DateTime)+" "+treeAuthors(tree))
-				str_comments = append(str_comments, *comment.DiffHunk)
+				if comment.DiffHunk != nil {
+					str_comments = append(str_comments, *comment.DiffHunk)
+				}
 			}
 			clean_body := escapeBody(comment.Body)
 			str_comments = append(str_comments, fmt.Sprintf("***** (%d) %s %s", i, comment.CreatedAt.Format(time.DateTime), *comment.User.Login))
@@ -304,20 +350,50 @@ func getComments(owner string, repo string, number int) (int, []string) {
 }
 
 func ProcessPRsDB(log *slog.Logger, prs []*github.PullRequest, changes_channel chan FileChanges, doc *org.DBOrgDocument, section *org.DBSection, change_wg *sync.WaitGroup, prune_command string, includeDiff bool) RunResult {
+	bridges := []PRToOrgBridge{}
+	for _, pr := range prs {
+		bridges = append(bridges, PRToOrgBridge{PR: pr, IncludeDiff: includeDiff})
+	}
+	return ProcessBridgesDB(log, bridges, changes_channel, doc, section, change_wg, prune_command)
+}
+
+// ProcessBridgesDB is ProcessPRsDB for PRs which carry extra context, like a linked Jira issue.
+func ProcessBridgesDB(log *slog.Logger, bridges []PRToOrgBridge, changes_channel chan FileChanges, doc *org.DBOrgDocument, section *org.DBSection, change_wg *sync.WaitGroup, prune_command string) RunResult {
 	result := RunResult{}
 
-	// the index for both slices should match
-	seen_prs := []*github.PullRequest{}
 	pr_strings := []string{}
 	changes := []FileChanges{}
 	ttl := time.Now().Add(2 * time.Hour).Unix()
 
-	for _, pr := range prs {
-		pr_strings = append(pr_strings, fmt.Sprintf("%s-%v", pr.Base.Repo.GetFullName(), pr.GetNumber()))
-		seen_prs = append(seen_prs, pr)
-		fc := SyncTODOToSectionDB(*doc, pr, *section, includeDiff)
+	// Snoozed and muted PRs are still synced, so they come back up to date, but are hidden
+	hidden := map[string]string{}
+	visible, err := loadVisibility(section.DB)
+	if err != nil {
+		log.Error("Error loading snoozes and mute rules", "error", err)
+	}
+	userStatuses, err := section.DB.GetUserStatuses()
+	if err != nil {
+		log.Error("Error loading user statuses", "error", err)
+	}
+
+	for _, bridge := range bridges {
+		pr_strings = append(pr_strings, fmt.Sprintf("%s-%v", bridge.PR.Base.Repo.GetFullName(), bridge.PR.GetNumber()))
+		fc := syncBridgeToSectionDB(*doc, bridge, *section)
 		fc.TTL = ttl
 		changes = append(changes, fc)
+		if userStatuses != nil {
+			mergeUserStatus(log, section.DB, userStatuses, bridge)
+		}
+		if visible != nil {
+			if reason := visible.hiddenReason(log, section.DB, bridge); reason != "" {
+				hidden[bridge.Identifier()] = reason
+			}
+		}
+	}
+	if visible != nil {
+		if err := section.DB.SetHiddenItems(section.ID, pr_strings, hidden); err != nil {
+			log.Error("Error storing hidden items", "error", err)
+		}
 	}
 
 	if prune_command == "Delete" || prune_command == "Archive" {
@@ -385,14 +461,19 @@ func ProcessPRsDB(log *slog.Logger, prs []*github.PullRequest, changes_channel c
 }
 
 func SyncTODOToSectionDB(doc org.DBOrgDocument, pr *github.PullRequest, section org.DBSection, includeDiff bool) FileChanges {
-	pr_as_org := PRToOrgBridge{PR: pr, IncludeDiff: includeDiff}
-	found, _ := org.CheckTODOInSectionDB(pr_as_org, &section)
+	return syncBridgeToSectionDB(doc, PRToOrgBridge{PR: pr, IncludeDiff: includeDiff}, section)
+}
+
+func syncBridgeToSectionDB(doc org.DBOrgDocument, pr_as_org PRToOrgBridge, section org.DBSection) FileChanges {
+	found, existing := org.CheckTODOInSectionDB(pr_as_org, &section)
 	changeType := "Addition"
 	if found {
 		// After a week we stop updating old ones
 		mergedAt := pr_as_org.PR.GetMergedAt()
 		if !mergedAt.IsZero() && mergedAt.After(time.Now().Add(-7*24*time.Hour)) {
 			changeType = "Update"
+		} else if jiraTagsChanged(pr_as_org, existing) {
+			changeType = "Update"
 		} else {
 			changeType = "No Change"
 		}
@@ -406,28 +487,62 @@ func SyncTODOToSectionDB(doc org.DBOrgDocument, pr *github.PullRequest, section
 	}
 }
 
+// jiraTagsChanged reports whether the linked issue moved (status, assignee) since the item was stored.
+func jiraTagsChanged(pr_as_org PRToOrgBridge, existing org.OrgTODO) bool {
+	if pr_as_org.JiraIssue == nil {
+		return false
+	}
+	item, ok := existing.(*org.DBOrgItem)
+	if !ok {
+		return false
+	}
+	tags, err := item.GetTags()
+	if err != nil {
+		return false
+	}
+	for _, tag := range pr_as_org.jiraTags() {
+		if !slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
 // Assume git_tools.GetGithubClient() and processWorkflowRuns are defined elsewhere
 // and work as intended.
 
 
 func getPRDiff(owner string, repo string, number int) []string {
-	client := git_tools.GetGithubClient()
-	
+	f := forge.For(owner, repo)
+
 	// Get the PR object to get the latest SHA for storage
-	pr, _, err := client.PullRequests.Get(context.Background(), owner, repo, number)
+	pr, err := f.GetPR(owner, repo, number)
 	latestSha := ""
 	if err == nil && pr.Head != nil && pr.Head.SHA != nil {
 		latestSha = *pr.Head.SHA
 	}
 	
-	diff, _, err := client.PullRequests.GetRaw(context.Background(), owner, repo, number, github.RawOptions{Type: github.Diff})
+	diff, err := f.GetDiff(owner, repo, number)
 	if err != nil {
 		slog.Error("Error getting PR diff", "pr", number, "repo", repo, "error", err)
 		return []string{}
 	}
 	
+	// Let attached clients know the author pushed. The PR view won't see the push once the new
+	// head is stored, and re-anchors local comments from the previous diff kept alongside it.
+	_, previousSha, _ := config.C.DB.GetPullRequest(number, owner, repo)
+	if previousSha != "" && latestSha != "" && previousSha != latestSha {
+		events.Publish(events.PRUpdated, events.PRUpdatedParams{
+			Owner:       owner,
+			Repo:        repo,
+			Number:      number,
+			PreviousSHA: previousSha,
+			SHA:         latestSha,
+		})
+	}
+
 	// Store the result in the database
-	if err := config.C.DB.UpsertPullRequest(number, repo, latestSha, diff); err != nil {
+	if err := config.C.DB.UpsertPullRequest(number, owner, repo, latestSha, diff); err != nil {
 		slog.Error("Error storing PR diff in database", "pr", number, "repo", repo, "error", err)
 		// Continue even if storage fails
 	}
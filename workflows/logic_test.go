@@ -1,6 +1,8 @@
 package workflows
 
 import (
+	"crs/jira"
+	"slices"
 	"testing"
 
 	"github.com/google/go-github/v48/github"
@@ -51,3 +53,24 @@ func TestPRToOrgBridge_RepoAndIdentifier(t *testing.T) {
 		t.Errorf("Identifier() = %v, want %v", identifier, expectedIdentifier)
 	}
 }
+
+func TestPRToOrgBridge_JiraTags(t *testing.T) {
+	issue := &jira.Issue{Key: "PROJ-7"}
+	issue.Fields.Status.Name = "In Progress"
+	pr := &github.PullRequest{
+		Title: github.String("Add widgets"),
+		State: github.String("open"),
+		Draft: github.Bool(false),
+		Head:  &github.PullRequestBranch{Repo: &github.Repository{Name: github.String("api")}},
+	}
+
+	bridge := PRToOrgBridge{PR: pr, JiraIssue: issue}
+	if got := bridge.ItemTitle(2, ""); got != "** TODO Add widgets\t\t:api:PROJ-7:In_Progress:" {
+		t.Errorf("ItemTitle() = %q, want the issue key and status as tags", got)
+	}
+
+	issue.Fields.Assignee = &jira.User{DisplayName: "Jane Doe"}
+	if got := bridge.jiraTags(); !slices.Equal(got, []string{"PROJ-7", "In_Progress", "Jane_Doe"}) {
+		t.Errorf("jiraTags() = %v, want key, status and assignee", got)
+	}
+}
@@ -3,12 +3,15 @@ package workflows
 import (
 	"crs/config"
 	"crs/database"
+	"crs/events"
 	"crs/git_tools"
 	"crs/org"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -36,6 +39,7 @@ type ManagerService struct {
 	workflow_chan chan FileChanges
 	sleepTime     time.Duration
 	oneoff        bool
+	mu            *sync.Mutex // Guards Workflows and sleepTime, which Reload swaps between cycles
 }
 
 func deduplicateChanges(log *slog.Logger, changes []SerializedFileChange) []SerializedFileChange {
@@ -225,10 +229,79 @@ func NewManagerService(workflows []Workflow, oneoff bool, sleepTime time.Duratio
 		workflow_chan: make(chan FileChanges),
 		sleepTime:     sleepTime,
 		oneoff:        oneoff,
+		mu:            &sync.Mutex{},
 	}
 }
 
-func (ms ManagerService) runWorkflow(log *slog.Logger, workflow Workflow, workflow_chan chan FileChanges, file_change_wg *sync.WaitGroup) {
+// current returns the workflows and sleep time of the loaded config.
+func (ms *ManagerService) current() ([]Workflow, time.Duration) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.Workflows, ms.sleepTime
+}
+
+// Reload re-reads codereviewserver.toml and, once any running cycle has finished, swaps in the
+// new settings, plugins and workflows. Sections of new workflows are created and sections no
+// workflow uses anymore are removed. An invalid config, including workflows ValidateWorkflows
+// rejects, is returned as an error and the running one is kept.
+func (ms *ManagerService) Reload(log *slog.Logger) error {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Error("Not reloading invalid config", "error", err)
+		return err
+	}
+	if errs := ValidateWorkflows(cfg); len(errs) > 0 {
+		log.Error("Not reloading invalid workflows", "errors", errs)
+		return errors.Join(errs...)
+	}
+
+	cycleMu.Lock()
+	defer cycleMu.Unlock()
+
+	oldWorkflows, _ := ms.current()
+	config.Apply(cfg)
+	workflows := MatchWorkflows(config.C.RawWorkflows, &config.C.Repos, config.C.JiraDomain)
+
+	ms.mu.Lock()
+	ms.Workflows = workflows
+	ms.sleepTime = config.C.SleepDuration
+	ms.mu.Unlock()
+
+	ms.Initialize()
+	retireSections(log, config.C.DB, oldWorkflows, workflows, config.C.RawWorkflows)
+	log.Info("Reloaded config", "workflows", len(workflows))
+	return nil
+}
+
+// retireSections deletes the sections which only removed workflows synced into. Sections still
+// named by a configured workflow are kept, even when MatchWorkflows skipped it as invalid.
+func retireSections(log *slog.Logger, db *database.DB, oldWorkflows []Workflow, workflows []Workflow, raws []config.RawWorkflow) {
+	inUse := map[string]bool{}
+	for _, wf := range workflows {
+		inUse[wf.GetOrgSectionName()] = true
+	}
+	for _, raw := range raws {
+		inUse[raw.SectionTitle] = true
+	}
+	for _, wf := range oldWorkflows {
+		name := wf.GetOrgSectionName()
+		if inUse[name] {
+			continue
+		}
+		inUse[name] = true // Only retire once when several old workflows shared it
+		section, err := db.GetSection(name)
+		if err != nil {
+			continue
+		}
+		if err := db.DeleteSection(section.ID); err != nil {
+			log.Error("Error retiring section", "section", name, "error", err)
+			continue
+		}
+		log.Info("Retired section", "section", name)
+	}
+}
+
+func (ms *ManagerService) runWorkflow(log *slog.Logger, workflow Workflow, workflow_chan chan FileChanges, file_change_wg *sync.WaitGroup) RunResult {
 	// Helper which times the workflow run command.
 	log.Info("Starting Workflow", "workflow", workflow.GetName())
 	start := time.Now()
@@ -238,15 +311,27 @@ func (ms ManagerService) runWorkflow(log *slog.Logger, workflow Workflow, workfl
 		log.Error("Errored in Workflow", "workflow", workflow.GetName(), "after", duration, "error", err)
 	}
 	log.Info("Finishing Workflow", "workflow", workflow.GetName(), "took", duration, "result", result.Report())
+	return result
 }
 
-func (ms ManagerService) RunOnce(log *slog.Logger, file_change_wg *sync.WaitGroup) {
+// RunOnce runs every workflow once and returns the combined result of all of them.
+func (ms *ManagerService) RunOnce(log *slog.Logger, file_change_wg *sync.WaitGroup) RunResult {
+	workflows, _ := ms.current()
+	return ms.runWorkflows(log, workflows, ms.workflow_chan, file_change_wg)
+}
+
+func (ms *ManagerService) runWorkflows(log *slog.Logger, workflows []Workflow, workflow_chan chan FileChanges, file_change_wg *sync.WaitGroup) RunResult {
 	var wg sync.WaitGroup
-	for _, workflow := range ms.Workflows {
+	var mu sync.Mutex
+	total := RunResult{}
+	for _, workflow := range workflows {
 		wg.Add(1)
 		go func(workflow Workflow) {
 			defer wg.Done()
-			ms.runWorkflow(log, workflow, ms.workflow_chan, file_change_wg)
+			result := ms.runWorkflow(log, workflow, workflow_chan, file_change_wg)
+			mu.Lock()
+			total.Add(result)
+			mu.Unlock()
 		}(workflow)
 	}
 	if waitTimeout(&wg, 240*time.Second) {
@@ -254,9 +339,70 @@ func (ms ManagerService) RunOnce(log *slog.Logger, file_change_wg *sync.WaitGrou
 	} else {
 		log.Info("Completed RunOnce Waitgroup")
 	}
+	mu.Lock()
+	defer mu.Unlock()
+	return total
+}
+
+// publishChanges notifies attached clients once a cycle's changes have been applied.
+func publishChanges(result RunResult) {
+	if result.Added+result.Updated+result.Deleted == 0 {
+		return
+	}
+	events.Publish(events.ReviewsChanged, events.ReviewsChangedParams{
+		Added:   result.Added,
+		Updated: result.Updated,
+		Deleted: result.Deleted,
+	})
+}
+
+// cycleMu keeps a webhook-triggered run from overlapping with a polling cycle.
+var cycleMu sync.Mutex
+
+// runCycle runs the workflows against a fresh change listener, waits for their changes to be
+// applied and notifies attached clients.
+func (ms *ManagerService) runCycle(log *slog.Logger, workflows []Workflow) RunResult {
+	cycleMu.Lock()
+	defer cycleMu.Unlock()
+
+	var cycle_wg sync.WaitGroup
+	cycle_wg.Add(1)
+	workflow_chan := make(chan FileChanges)
+
+	go ListenChanges(log, workflow_chan, &cycle_wg)
+	result := ms.runWorkflows(log, workflows, workflow_chan, &cycle_wg)
+	close(workflow_chan)
+	cycle_wg.Done()
+
+	if waitTimeout(&cycle_wg, 240*time.Second) {
+		log.Error("Cycle waitgroup timed out waiting for changes to be applied")
+	}
+	publishChanges(result)
+	return result
+}
+
+// RunForRepo re-runs only the workflows that watch repo ("owner/repo"), e.g. after a webhook
+// reported a change to one of its PRs.
+func (ms *ManagerService) RunForRepo(log *slog.Logger, repo string) RunResult {
+	var matching []Workflow
+	workflows, _ := ms.current()
+	for _, wf := range workflows {
+		if slices.ContainsFunc(wf.GetRepos(), func(r string) bool {
+			_, name := config.SplitHost(r)
+			return strings.EqualFold(name, repo)
+		}) {
+			matching = append(matching, wf)
+		}
+	}
+	if len(matching) == 0 {
+		log.Debug("No workflows watch repo, skipping targeted run", "repo", repo)
+		return RunResult{}
+	}
+	log.Info("Running workflows for repo", "repo", repo, "workflows", len(matching))
+	return ms.runCycle(log, matching)
 }
 
-func (ms ManagerService) Run(log *slog.Logger) {
+func (ms *ManagerService) Run(log *slog.Logger) {
 	log.Info("Starting Service")
 
 	// Advisory lock to prevent multiple concurrent syncs
@@ -286,31 +432,30 @@ func (ms ManagerService) Run(log *slog.Logger) {
 		go ListenChanges(log, ms.workflow_chan, &listener_wg)
 
 		log.Info("Running Once")
-		ms.RunOnce(log, &listener_wg)
+		result := ms.RunOnce(log, &listener_wg)
 		close(ms.workflow_chan)
 		listener_wg.Done()
 		if waitTimeout(&listener_wg, 240*time.Second) {
 			log.Error("Listener waitgroup timed out waiting for changes to be applied")
 		}
+		publishChanges(result)
 	} else {
 		cycle_count := 0
-		log.Info("Starting service mode with sleep duration:" + ms.sleepTime.String())
+		_, sleepTime := ms.current()
+		log.Info("Starting service mode with sleep duration:" + sleepTime.String())
 		for {
-			log.Info("Cycle", "count", cycle_count)
-			var cycle_wg sync.WaitGroup
-			cycle_wg.Add(1)
-			ms.workflow_chan = make(chan FileChanges)
-
-			go ListenChanges(log, ms.workflow_chan, &cycle_wg)
-			ms.RunOnce(log, &cycle_wg)
-			close(ms.workflow_chan)
-			cycle_wg.Done()
-
-			if waitTimeout(&cycle_wg, 240*time.Second) {
-				log.Error("Cycle waitgroup timed out waiting for changes to be applied")
+			config.RLock()
+			reserve := config.C.RateLimitReserve
+			config.RUnlock()
+			if wait := git_tools.RateLimitBackoff(reserve); wait > 0 {
+				log.Warn("GitHub rate limit is low, deferring cycle until it resets", "count", cycle_count, "wait", wait.Round(time.Second))
+				time.Sleep(wait)
 			}
+			log.Info("Cycle", "count", cycle_count)
+			workflows, sleepTime := ms.current()
+			ms.runCycle(log, workflows)
 			// Render org files after each cycle
-			time.Sleep(ms.sleepTime)
+			time.Sleep(sleepTime)
 			cycle_count++
 		}
 	}
@@ -321,7 +466,8 @@ func (ms *ManagerService) Initialize() {
 	// Ensure all required sections exist.
 	// Does this sync since GetSection has creation side effect
 	db := config.C.DB
-	for _, wf := range ms.Workflows {
+	workflows, _ := ms.current()
+	for _, wf := range workflows {
 		// Don't need to check release command here
 		doc := org.NewDBClient(db, org.BaseOrgSerializer{ReleaseCheckCommand: ""})
 		doc.GetSection(wf.GetOrgSectionName())
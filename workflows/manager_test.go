@@ -1,10 +1,15 @@
 package workflows
 
 import (
+	"crs/config"
 	"crs/org"
+	"crs/testutil"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestDeduplicateChanges(t *testing.T) {
@@ -67,3 +72,117 @@ func TestDeduplicateChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestReloadSwapsWorkflowsAndRetiresSections(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	configPath := filepath.Join(configHome, "codereviewserver.toml")
+
+	db := testutil.NewDB(t)
+	config.C = config.Config{DB: db}
+
+	old := []Workflow{
+		SearchWorkflow{Name: "a", SectionTitle: "Kept"},
+		SearchWorkflow{Name: "b", SectionTitle: "Removed"},
+	}
+	ms := NewManagerService(old, false, time.Minute)
+	ms.Initialize()
+
+	write := func(content string) {
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`SleepDuration = 3
+[[Workflows]]
+WorkflowType = "SearchWorkflow"
+Name = "a"
+SectionTitle = "Kept"
+Query = "is:open"
+
+[[Workflows]]
+WorkflowType = "SearchWorkflow"
+Name = "c"
+SectionTitle = "Added"
+Query = "is:open"
+`)
+	if err := ms.Reload(slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	workflows, sleepTime := ms.current()
+	if len(workflows) != 2 || sleepTime != 3*time.Minute {
+		t.Errorf("after Reload got %d workflows sleeping %v, want 2 and 3m", len(workflows), sleepTime)
+	}
+	sections, _ := db.GetAllSections()
+	names := []string{}
+	for _, section := range sections {
+		names = append(names, section.SectionName)
+	}
+	if !slices.Equal(names, []string{"Added", "Kept"}) {
+		t.Errorf("sections = %v, want [Added Kept]", names)
+	}
+	if config.C.DB != db {
+		t.Errorf("Reload replaced the open database")
+	}
+
+	// A broken config is reported and the running one is kept
+	write(`[[Workflows]]
+WorkflowType = "SearchWorkflow"
+Filter = "label:bug and"
+`)
+	if err := ms.Reload(slog.New(slog.DiscardHandler)); err == nil {
+		t.Errorf("Reload() error = nil, want the filter syntax error")
+	}
+	if workflows, _ := ms.current(); len(workflows) != 2 {
+		t.Errorf("invalid config replaced the workflows")
+	}
+}
+
+func TestReloadKeepsSectionsOfInvalidWorkflows(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	configPath := filepath.Join(configHome, "codereviewserver.toml")
+
+	db := testutil.NewDB(t)
+	config.C = config.Config{DB: db}
+
+	ms := NewManagerService([]Workflow{SearchWorkflow{Name: "a", SectionTitle: "Reviews"}}, false, time.Minute)
+	ms.Initialize()
+	section, err := db.GetSection("Reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpsertItem(section.ID, "1", "TODO", "Fix the parser", []string{}, nil, false, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A half-typed save while the file is watched
+	err = os.WriteFile(configPath, []byte(`[[Workflows]]
+WorkflowType = "SearchWorkflo"
+Name = "a"
+SectionTitle = "Reviews"
+Query = "is:open"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.Reload(slog.New(slog.DiscardHandler)); err == nil {
+		t.Errorf("Reload() error = nil, want the unknown WorkflowType")
+	}
+	if workflows, _ := ms.current(); len(workflows) != 1 {
+		t.Errorf("invalid config replaced the workflows")
+	}
+	items, _ := db.GetItemsBySection(section.ID)
+	if _, err := db.GetSection("Reviews"); err != nil || len(items) != 1 {
+		t.Errorf("section lost after an invalid reload: %v, %d items", err, len(items))
+	}
+
+	// Should a skipped workflow get past validation, its section is still not retired
+	retireSections(slog.New(slog.DiscardHandler), db, []Workflow{SearchWorkflow{Name: "a", SectionTitle: "Reviews"}}, nil,
+		[]config.RawWorkflow{{WorkflowType: "SearchWorkflo", Name: "a", SectionTitle: "Reviews"}})
+	items, _ = db.GetItemsBySection(section.ID)
+	if len(items) != 1 {
+		t.Errorf("retireSections() deleted the section of a skipped workflow")
+	}
+}
@@ -0,0 +1,186 @@
+package workflows
+
+import (
+	"crs/config"
+	"crs/git_tools"
+	"crs/org"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// NotificationsWorkflow mirrors the unread GitHub notifications inbox into a section.
+// Items disappear once the notification is read, here or on GitHub.
+type NotificationsWorkflow struct {
+	Name         string
+	Host         string   // GithubHosts entry whose inbox is synced; github.com if empty
+	Repos        []string // Only include notifications from these repos; all repos if empty
+	Reasons      []string // Notification reasons to include, e.g. review_requested or mention
+	SectionTitle string
+	Prune        string
+}
+
+func (w NotificationsWorkflow) GetName() string {
+	return w.Name
+}
+
+func (w NotificationsWorkflow) GetOrgSectionName() string {
+	return w.SectionTitle
+}
+
+func (w NotificationsWorkflow) GetRepos() []string {
+	return w.Repos
+}
+
+func (w NotificationsWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error) {
+	client, err := git_tools.GetHostClient(config.C.Host(w.Host))
+	if err != nil {
+		log.Error("Error creating GitHub client", "host", w.Host, "error", err)
+		return RunResult{}, err
+	}
+	notifications, err := git_tools.GetNotifications(client)
+	if err != nil {
+		log.Error("Error getting notifications", "error", err)
+		return RunResult{}, err
+	}
+	notifications = w.filter(notifications)
+
+	doc := org.NewDBClient(config.C.DB, org.BaseOrgSerializer{})
+	section, err := doc.GetSection(w.SectionTitle)
+	if err != nil {
+		log.Error("Error getting section", "error", err, "section", w.SectionTitle)
+		return RunResult{}, errors.New("Section Not Found")
+	}
+	return ProcessNotificationsDB(log, notifications, c, doc, section, file_change_wg, w.Prune), nil
+}
+
+func (w NotificationsWorkflow) filter(notifications []*github.Notification) []*github.Notification {
+	filtered := []*github.Notification{}
+	for _, notification := range notifications {
+		if !slices.Contains(w.Reasons, notification.GetReason()) {
+			continue
+		}
+		if len(w.Repos) > 0 && !slices.ContainsFunc(w.Repos, func(repo string) bool {
+			_, name := config.SplitHost(repo)
+			return strings.EqualFold(name, notification.GetRepository().GetFullName())
+		}) {
+			continue
+		}
+		filtered = append(filtered, notification)
+	}
+	return filtered
+}
+
+// ProcessNotificationsDB adds new notifications to the section and removes (or archives, with
+// Prune = "Archive") the items of notifications that are no longer unread.
+func ProcessNotificationsDB(log *slog.Logger, notifications []*github.Notification, changes_channel chan FileChanges, doc *org.DBOrgDocument, section *org.DBSection, change_wg *sync.WaitGroup, prune_command string) RunResult {
+	result := RunResult{}
+	seen := []string{}
+	changes := []FileChanges{}
+
+	for _, notification := range notifications {
+		item := NotificationToOrgBridge{Notification: notification}
+		seen = append(seen, item.Identifier())
+		changeType := "Addition"
+		if found, _ := org.CheckTODOInSectionDB(item, section); found {
+			changeType = "No Change"
+		}
+		changes = append(changes, FileChanges{
+			ChangeType:     changeType,
+			Item:           item,
+			Section:        *section,
+			ItemSerializer: doc.Serializer,
+		})
+	}
+
+	if prune_command != "Archive" {
+		prune_command = "Delete"
+	}
+	items, err := section.GetItems()
+	if err != nil {
+		log.Error("Error getting items from section", "error", err)
+	} else {
+		for _, item := range items {
+			if slices.Contains(seen, item.Identifier()) {
+				continue
+			}
+			changes = append(changes, FileChanges{
+				ChangeType:     prune_command,
+				Item:           item,
+				Section:        *section,
+				ItemSerializer: doc.Serializer,
+			})
+		}
+	}
+
+	for _, output := range changes {
+		result.Process(&output, changes_channel, change_wg)
+	}
+	return result
+}
+
+// NotificationToOrgBridge implements the OrgTODO interface for notification threads.
+// Notifications about a PR or issue use its number as the ID, so they open like any review item.
+type NotificationToOrgBridge struct {
+	Notification *github.Notification
+}
+
+func (nb NotificationToOrgBridge) ID() string {
+	if number := git_tools.NotificationNumber(nb.Notification); number != 0 {
+		return fmt.Sprintf("%d", number)
+	}
+	return "thread-" + nb.Notification.GetID()
+}
+
+func (nb NotificationToOrgBridge) Repo() string {
+	return nb.Notification.GetRepository().GetFullName()
+}
+
+func (nb NotificationToOrgBridge) StartLine() int {
+	panic("Called StartLine for NotificationToOrgBridge which shouldn't be done.")
+}
+
+func (nb NotificationToOrgBridge) LinesCount() int {
+	panic("Called LinesCount for NotificationToOrgBridge which shouldn't be done.")
+}
+
+func (nb NotificationToOrgBridge) Identifier() string {
+	return fmt.Sprintf("%s-%s", nb.Repo(), nb.ID())
+}
+
+func (nb NotificationToOrgBridge) ItemTitle(indent_level int, release_check_command string) string {
+	return fmt.Sprintf("%s %s %s\t\t:%s:%s:", strings.Repeat("*", indent_level), nb.GetStatus(), nb.Summary(), nb.Notification.GetRepository().GetName(), nb.Notification.GetReason())
+}
+
+func (nb NotificationToOrgBridge) Summary() string {
+	return nb.Notification.GetSubject().GetTitle()
+}
+
+func (nb NotificationToOrgBridge) CheckDone() bool {
+	return !nb.Notification.GetUnread()
+}
+
+func (nb NotificationToOrgBridge) GetStatus() string {
+	if nb.CheckDone() {
+		return "DONE"
+	}
+	return "TODO"
+}
+
+func (nb NotificationToOrgBridge) Details() []string {
+	return []string{
+		nb.ID(),
+		"Repo: " + nb.Repo(),
+		git_tools.NotificationHTMLURL(nb.Notification) + "\n",
+		fmt.Sprintf("Title: %s\n", nb.Summary()),
+		fmt.Sprintf("Reason: %s\n", nb.Notification.GetReason()),
+		fmt.Sprintf("Type: %s\n", nb.Notification.GetSubject().GetType()),
+		fmt.Sprintf("Notification: %s\n", nb.Notification.GetID()),
+		fmt.Sprintf("Updated: %s\n", nb.Notification.GetUpdatedAt().Format("2006-01-02 15:04")),
+	}
+}
@@ -0,0 +1,65 @@
+package workflows
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func testNotification(id, reason, repo, subjectType, subjectURL string) *github.Notification {
+	owner, name, _ := strings.Cut(repo, "/")
+	return &github.Notification{
+		ID:     github.String(id),
+		Reason: github.String(reason),
+		Unread: github.Bool(true),
+		Repository: &github.Repository{
+			Name:     github.String(name),
+			FullName: github.String(repo),
+			HTMLURL:  github.String("https://github.com/" + owner + "/" + name),
+		},
+		Subject: &github.NotificationSubject{
+			Title: github.String("Fix the thing"),
+			Type:  github.String(subjectType),
+			URL:   github.String(subjectURL),
+		},
+	}
+}
+
+func TestNotificationsWorkflowFilter(t *testing.T) {
+	notifications := []*github.Notification{
+		testNotification("1", "review_requested", "org/api", "PullRequest", ""),
+		testNotification("2", "subscribed", "org/api", "PullRequest", ""),
+		testNotification("3", "mention", "org/web", "Issue", ""),
+		testNotification("4", "ci_activity", "Org/API", "CheckSuite", ""),
+	}
+	wf := NotificationsWorkflow{Reasons: []string{"review_requested", "mention", "ci_activity"}, Repos: []string{"org/api"}}
+
+	got := wf.filter(notifications)
+	if len(got) != 2 || got[0].GetID() != "1" || got[1].GetID() != "4" {
+		ids := []string{}
+		for _, n := range got {
+			ids = append(ids, n.GetID())
+		}
+		t.Errorf("filter() = %v, want [1 4]", ids)
+	}
+}
+
+func TestNotificationToOrgBridge(t *testing.T) {
+	pr := NotificationToOrgBridge{Notification: testNotification("99", "review_requested", "org/api", "PullRequest", "https://api.github.com/repos/org/api/pulls/12")}
+	if pr.Identifier() != "org/api-12" {
+		t.Errorf("Identifier() = %q, want %q", pr.Identifier(), "org/api-12")
+	}
+	details := pr.Details()
+	if details[0] != "12" || details[1] != "Repo: org/api" || details[2] != "https://github.com/org/api/pull/12\n" {
+		t.Errorf("Details() = %q, want the PR number, repo and URL first", details[:3])
+	}
+	if !strings.HasSuffix(pr.ItemTitle(2, ""), ":api:review_requested:") {
+		t.Errorf("ItemTitle() = %q, want repo and reason tags", pr.ItemTitle(2, ""))
+	}
+
+	ci := NotificationToOrgBridge{Notification: testNotification("100", "ci_activity", "org/api", "CheckSuite", "")}
+	if ci.ID() != "thread-100" {
+		t.Errorf("ID() = %q, want %q", ci.ID(), "thread-100")
+	}
+}
@@ -0,0 +1,49 @@
+package workflows
+
+import (
+	"crs/config"
+	"crs/database"
+	"log/slog"
+	"slices"
+)
+
+// userStatusReset returns why your status on the PR no longer holds, or "" if it does.
+// UserStatusResetOn picks the PR changes that clear it; with UserStatusPrecedence = "sync", a
+// change of the synced status, like the PR being merged, clears it too.
+func userStatusReset(status database.UserStatus, bridge PRToOrgBridge, precedence string, resetOn []string) string {
+	headSHA := bridge.PR.GetHead().GetSHA()
+	if slices.Contains(resetOn, "NewCommits") && status.HeadSHA != "" && headSHA != "" && headSHA != status.HeadSHA {
+		return "new commits"
+	}
+	if slices.Contains(resetOn, "NewActivity") && bridge.PR.GetUpdatedAt().Unix() > status.SetAt {
+		return "new activity"
+	}
+	if precedence == "sync" && status.SyncedStatus != "" && bridge.GetStatus() != status.SyncedStatus {
+		return "synced status changed"
+	}
+	return ""
+}
+
+// mergeUserStatus keeps or clears your status on the PR. Statuses set before the PR's head
+// commit was known record it here, so later pushes can be told apart.
+func mergeUserStatus(log *slog.Logger, db *database.DB, statuses map[string]database.UserStatus, bridge PRToOrgBridge) {
+	status, ok := statuses[bridge.Identifier()]
+	if !ok {
+		return
+	}
+	if reason := userStatusReset(status, bridge, config.C.UserStatusPrecedence, config.C.UserStatusResetOn); reason != "" {
+		log.Info("Clearing user status", "identifier", status.Identifier, "status", status.Status, "reason", reason)
+		if err := db.ClearUserStatus(status.Identifier); err != nil {
+			log.Error("Error clearing user status", "identifier", status.Identifier, "error", err)
+		}
+		delete(statuses, status.Identifier)
+		return
+	}
+	if headSHA := bridge.PR.GetHead().GetSHA(); status.HeadSHA == "" && headSHA != "" {
+		if err := db.SetUserStatusSHA(status.Identifier, headSHA); err != nil {
+			log.Error("Error recording user status commit", "identifier", status.Identifier, "error", err)
+		}
+		status.HeadSHA = headSHA
+		statuses[status.Identifier] = status
+	}
+}
@@ -0,0 +1,83 @@
+package workflows
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/testutil"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func TestUserStatusReset(t *testing.T) {
+	setAt := time.Now().Add(-time.Hour)
+	status := database.UserStatus{Identifier: "org/api-1", Status: "DONE", SetAt: setAt.Unix(), HeadSHA: "abc", SyncedStatus: "TODO"}
+	pr := func(sha string, updatedAt time.Time, state string) PRToOrgBridge {
+		p := testPR(1, updatedAt)
+		p.Head.SHA = github.String(sha)
+		p.State = github.String(state)
+		return PRToOrgBridge{PR: p}
+	}
+	tests := []struct {
+		name       string
+		bridge     PRToOrgBridge
+		precedence string
+		resetOn    []string
+		want       string
+	}{
+		{"unchanged", pr("abc", setAt.Add(-time.Minute), "open"), "user", []string{"NewCommits"}, ""},
+		{"new commits", pr("def", setAt.Add(time.Minute), "open"), "user", []string{"NewCommits"}, "new commits"},
+		{"new commits ignored", pr("def", setAt.Add(time.Minute), "open"), "user", nil, ""},
+		{"new activity", pr("abc", setAt.Add(time.Minute), "open"), "user", []string{"NewActivity"}, "new activity"},
+		{"merged with user precedence", pr("abc", setAt.Add(-time.Minute), "closed"), "user", []string{"NewCommits"}, ""},
+		{"merged with sync precedence", pr("abc", setAt.Add(-time.Minute), "closed"), "sync", []string{"NewCommits"}, "synced status changed"},
+	}
+	for _, tt := range tests {
+		if got := userStatusReset(status, tt.bridge, tt.precedence, tt.resetOn); got != tt.want {
+			t.Errorf("%s: userStatusReset() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeUserStatus(t *testing.T) {
+	db := testutil.NewDB(t)
+	config.C = config.Config{DB: db, UserStatusPrecedence: "user", UserStatusResetOn: []string{"NewCommits"}}
+
+	setAt := time.Now().Add(-time.Hour).Unix()
+	for _, status := range []database.UserStatus{
+		{Identifier: "org/api-1", Status: "DONE", SetAt: setAt, HeadSHA: "abc", SyncedStatus: "TODO"},
+		{Identifier: "org/api-2", Status: "WAITING", SetAt: setAt, SyncedStatus: "TODO"},
+	} {
+		if err := db.SetUserStatus(status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statuses, err := db.GetUserStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := slog.New(slog.DiscardHandler)
+	pushed := testPR(1, time.Now())
+	pushed.Head.SHA = github.String("def")
+	unknownHead := testPR(2, time.Now())
+	unknownHead.Head.SHA = github.String("123")
+	mergeUserStatus(log, db, statuses, PRToOrgBridge{PR: pushed})
+	mergeUserStatus(log, db, statuses, PRToOrgBridge{PR: unknownHead})
+
+	stored, err := db.GetUserStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["org/api-1"]; ok {
+		t.Errorf("DONE status kept after new commits: %v", stored["org/api-1"])
+	}
+	if got := stored["org/api-2"]; got.Status != "WAITING" || got.HeadSHA != "123" {
+		t.Errorf("status for org/api-2 = %+v, want WAITING at head 123", got)
+	}
+	if len(statuses) != 1 {
+		t.Errorf("loaded statuses = %v, want the cleared one removed", statuses)
+	}
+}
@@ -0,0 +1,128 @@
+package workflows
+
+import (
+	"crs/config"
+	"crs/forge"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WorkflowTypes are the WorkflowType values MatchWorkflows knows how to build.
+var WorkflowTypes = []string{
+	"SyncReviewRequestsWorkflow",
+	"SingleRepoSyncReviewRequestsWorkflow",
+	"ListMyPRsWorkflow",
+	"ProjectListWorkflow",
+	"NotificationsWorkflow",
+	"SearchWorkflow",
+}
+
+// ValidateWorkflows reports the mistakes in the configured workflows that MatchWorkflows and
+// BuildFiltersList would otherwise only log or skip: unknown workflow types and filter names,
+// invalid Filter expressions and repos, and missing keys a workflow type needs. Each error is
+// placed at its line and column in codereviewserver.toml.
+func ValidateWorkflows(cfg *config.Config) []error {
+	errs := []error{}
+	for i, entry := range cfg.Repos {
+		if _, _, err := forge.ParseRepo(entry); err != nil {
+			errs = append(errs, fmt.Errorf("Repos[%d]: %w", i, err))
+		} else if err := validateHostPrefix(cfg, entry); err != nil {
+			errs = append(errs, fmt.Errorf("Repos[%d]: %w", i, err))
+		}
+	}
+
+	for _, raw := range cfg.RawWorkflows {
+		if raw.WorkflowType == "" {
+			errs = append(errs, raw.Errorf("", "missing WorkflowType"))
+		} else if !slices.Contains(WorkflowTypes, raw.WorkflowType) {
+			errs = append(errs, raw.Errorf("WorkflowType", "unknown WorkflowType %q (expected one of %s)", raw.WorkflowType, strings.Join(WorkflowTypes, ", ")))
+		}
+		if raw.SectionTitle == "" {
+			errs = append(errs, raw.Errorf("", "missing SectionTitle"))
+		}
+
+		for i, name := range raw.Filters {
+			if err := validateFilterName(name); err != nil {
+				errs = append(errs, raw.Errorf(fmt.Sprintf("Filters[%d]", i), "%v", err))
+			}
+		}
+		if raw.Filter != "" {
+			if _, err := CompileFilterExpression(raw.Filter); err != nil {
+				errs = append(errs, raw.FilterError(err))
+			}
+		}
+		for i, entry := range raw.Repos {
+			if _, _, err := forge.ParseRepo(entry); err != nil {
+				errs = append(errs, raw.Errorf(fmt.Sprintf("Repos[%d]", i), "%v", err))
+			} else if err := validateHostPrefix(cfg, entry); err != nil {
+				errs = append(errs, raw.Errorf(fmt.Sprintf("Repos[%d]", i), "%v", err))
+			}
+		}
+		if err := validateHostPrefix(cfg, raw.Repo); err != nil {
+			errs = append(errs, raw.Errorf("Repo", "%v", err))
+		}
+		if raw.Host != "" && cfg.Host(raw.Host) == nil && !strings.EqualFold(raw.Host, config.DefaultGithubHost) {
+			errs = append(errs, raw.Errorf("Host", "unknown Host %q (expected one of the GithubHosts)", raw.Host))
+		}
+
+		switch raw.Prune {
+		case "", "Delete", "Archive", "Keep":
+		default:
+			errs = append(errs, raw.Errorf("Prune", "unknown Prune %q (expected Delete, Archive or Keep)", raw.Prune))
+		}
+
+		switch raw.WorkflowType {
+		case "SingleRepoSyncReviewRequestsWorkflow":
+			if raw.Owner == "" || raw.Repo == "" {
+				errs = append(errs, raw.Errorf("", "%s needs an Owner and a Repo", raw.WorkflowType))
+			}
+		case "ListMyPRsWorkflow":
+			switch raw.PRState {
+			case "", "open", "closed", "all":
+			default:
+				errs = append(errs, raw.Errorf("PRState", "unknown PRState %q (expected open, closed or all)", raw.PRState))
+			}
+		case "ProjectListWorkflow":
+			if raw.JQL == "" && raw.JiraEpic == "" {
+				errs = append(errs, raw.Errorf("", "%s needs a JQL query or a JiraEpic", raw.WorkflowType))
+			}
+			if cfg.JiraDomain == "" {
+				errs = append(errs, raw.Errorf("", "%s needs the top-level JiraDomain", raw.WorkflowType))
+			}
+		case "SearchWorkflow":
+			if raw.Query == "" {
+				errs = append(errs, raw.Errorf("", "%s needs a Query", raw.WorkflowType))
+			}
+		}
+	}
+	return errs
+}
+
+// validateHostPrefix checks that a repo entry's host prefix, if any, is one of the GithubHosts.
+func validateHostPrefix(cfg *config.Config, entry string) error {
+	host, _ := config.SplitHost(entry)
+	if host == "" || cfg.Host(host) != nil || strings.EqualFold(host, config.DefaultGithubHost) {
+		return nil
+	}
+	return fmt.Errorf("unknown host %q in %q (expected one of the GithubHosts)", host, entry)
+}
+
+// validateFilterName checks an entry of a workflow's Filters list the way BuildFiltersList reads it.
+func validateFilterName(name string) error {
+	filterName, filterArg := ParseFilterString(name)
+	switch filterName {
+	case "FilterByLabel", "FilterByAuthor", "FilterExcludeAuthor":
+		if filterArg == "" {
+			return fmt.Errorf("%s requires an argument (e.g. %s:value)", filterName, filterName)
+		}
+		return nil
+	}
+	if filter_func_map[filterName] == nil {
+		return fmt.Errorf("unknown filter %q", name)
+	}
+	if filterArg != "" {
+		return fmt.Errorf("%s does not take an argument", filterName)
+	}
+	return nil
+}
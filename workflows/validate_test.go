@@ -0,0 +1,78 @@
+package workflows
+
+import (
+	"crs/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateWorkflows(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	content := `Repos = ["org/api"]
+
+[[Workflows]]
+WorkflowType = "SyncReviewRequestsWorkflow"
+Name = "ok"
+SectionTitle = "Reviews"
+Filters = ["FilterNotDraft", "FilterByLabel:bug"]
+Filter = "FilterWaitingOnMe or label:urgent"
+
+[[Workflows]]
+WorkflowType = "SyncReviewRequestWorkflow"
+Name = "typo"
+SectionTitle = "Typo"
+Filters = [
+    "FilterNotDraft",
+    "FilterNotDrafts",
+    "FilterByAuthor",
+]
+Filter = "FilterWaitingOnMe or FilterNope"
+Repos = ["org"]
+
+[[Workflows]]
+WorkflowType = "SearchWorkflow"
+Name = "search"
+SectionTitle = "Search"
+Prune = "Remove"
+`
+	if err := os.WriteFile(filepath.Join(configHome, "codereviewserver.toml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []string{
+		`codereviewserver.toml:11:17: workflow "typo": unknown WorkflowType "SyncReviewRequestWorkflow" (expected one of SyncReviewRequestsWorkflow, SingleRepoSyncReviewRequestsWorkflow, ListMyPRsWorkflow, ProjectListWorkflow, NotificationsWorkflow, SearchWorkflow)`,
+		`codereviewserver.toml:16:6: workflow "typo": unknown filter "FilterNotDrafts"`,
+		`codereviewserver.toml:17:6: workflow "typo": FilterByAuthor requires an argument (e.g. FilterByAuthor:value)`,
+		`codereviewserver.toml:19:32: workflow "typo": Filter: unknown filter "FilterNope"`,
+		`codereviewserver.toml:20:11: workflow "typo": invalid repo entry: org (expected 'owner/repo', 'host/owner/repo' or 'gitlab:group/project')`,
+		`codereviewserver.toml:26:10: workflow "search": unknown Prune "Remove" (expected Delete, Archive or Keep)`,
+		`codereviewserver.toml:22:1: workflow "search": SearchWorkflow needs a Query`,
+	}
+	errs := ValidateWorkflows(cfg)
+	if len(errs) != len(want) {
+		t.Fatalf("ValidateWorkflows() returned %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q\nwant %q", i, err.Error(), want[i])
+		}
+	}
+}
+
+func TestMatchWorkflows_SkipsUnknownWorkflowType(t *testing.T) {
+	repos := []string{"org/api"}
+	raw := []config.RawWorkflow{
+		{WorkflowType: "SearchWorkflow", Name: "good", Query: "is:pr"},
+		{WorkflowType: "SerchWorkflow", Name: "typo", Query: "is:pr"},
+	}
+	workflows := MatchWorkflows(raw, &repos, "")
+	if len(workflows) != 1 || workflows[0].GetName() != "good" {
+		t.Errorf("expected only the workflow with a known type, got %d workflows", len(workflows))
+	}
+}
@@ -0,0 +1,77 @@
+package workflows
+
+import (
+	"crs/database"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// visibility decides which synced PRs are snoozed or muted.
+type visibility struct {
+	snoozes map[string]database.Snooze
+	rules   []database.MuteRule
+	now     time.Time
+}
+
+func loadVisibility(db *database.DB) (*visibility, error) {
+	snoozes, err := db.GetSnoozes()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := db.GetMuteRules()
+	if err != nil {
+		return nil, err
+	}
+	return &visibility{snoozes: snoozes, rules: rules, now: time.Now()}, nil
+}
+
+// muted reports whether a mute rule matches the PR.
+func (v *visibility) muted(bridge PRToOrgBridge) bool {
+	for _, rule := range v.rules {
+		switch rule.Kind {
+		case database.MuteKindPR:
+			if strings.EqualFold(rule.Value, bridge.Identifier()) {
+				return true
+			}
+		case database.MuteKindAuthor:
+			if strings.EqualFold(rule.Value, bridge.PR.GetUser().GetLogin()) {
+				return true
+			}
+		case database.MuteKindRepo:
+			if strings.EqualFold(rule.Value, bridge.Repo()) {
+				return true
+			}
+		case database.MuteKindLabel:
+			for _, label := range bridge.PR.Labels {
+				if strings.EqualFold(rule.Value, label.GetName()) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hiddenReason returns why the PR is hidden, or "" if it is shown. A snooze that has run out,
+// or whose PR was updated since it was snoozed, is deleted and the PR shows again.
+func (v *visibility) hiddenReason(log *slog.Logger, db *database.DB, bridge PRToOrgBridge) string {
+	if v.muted(bridge) {
+		return database.HiddenMuted
+	}
+	snooze, ok := v.snoozes[bridge.Identifier()]
+	if !ok {
+		return ""
+	}
+	expired := snooze.Until > 0 && v.now.Unix() >= snooze.Until
+	active := snooze.UntilActivity && bridge.PR.GetUpdatedAt().Unix() > snooze.CreatedAt
+	if !expired && !active {
+		return database.HiddenSnoozed
+	}
+	log.Info("Waking snoozed item", "identifier", snooze.Identifier, "new_activity", active)
+	if err := db.DeleteSnooze(snooze.Identifier); err != nil {
+		log.Error("Error deleting snooze", "identifier", snooze.Identifier, "error", err)
+	}
+	delete(v.snoozes, snooze.Identifier)
+	return ""
+}
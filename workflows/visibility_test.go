@@ -0,0 +1,107 @@
+package workflows
+
+import (
+	"crs/config"
+	"crs/database"
+	"crs/org"
+	"crs/testutil"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+func testPR(number int, updatedAt time.Time, labels ...string) *github.PullRequest {
+	repo := &github.Repository{Name: github.String("api"), FullName: github.String("org/api")}
+	pr := &github.PullRequest{
+		Number:    github.Int(number),
+		Title:     github.String("Change"),
+		State:     github.String("open"),
+		Draft:     github.Bool(false),
+		User:      &github.User{Login: github.String("alice")},
+		UpdatedAt: &updatedAt,
+		Head:      &github.PullRequestBranch{Repo: repo, Label: github.String("alice:change")},
+		Base:      &github.PullRequestBranch{Repo: repo},
+	}
+	for _, label := range labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(label)})
+	}
+	return pr
+}
+
+func TestProcessPRsDB_HidesSnoozedAndMutedPRs(t *testing.T) {
+	db := testutil.NewDB(t)
+	config.C = config.Config{DB: db}
+
+	now := time.Now()
+	snoozedAt := now.Add(-time.Hour).Unix()
+	for _, snooze := range []database.Snooze{
+		{Identifier: "org/api-1", UntilActivity: true, CreatedAt: snoozedAt},
+		{Identifier: "org/api-2", UntilActivity: true, CreatedAt: snoozedAt},
+		{Identifier: "org/api-3", Until: now.Add(time.Hour).Unix(), CreatedAt: snoozedAt},
+		{Identifier: "org/api-4", Until: now.Add(-time.Minute).Unix(), CreatedAt: snoozedAt},
+	} {
+		if err := db.UpsertSnooze(snooze); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.AddMuteRule(database.MuteKindLabel, "WIP"); err != nil {
+		t.Fatal(err)
+	}
+
+	prs := []*github.PullRequest{
+		testPR(1, now),                   // updated since it was snoozed: wakes
+		testPR(2, now.Add(-2*time.Hour)), // no activity: stays snoozed
+		testPR(3, now.Add(-2*time.Hour)), // snoozed for another hour
+		testPR(4, now.Add(-2*time.Hour)), // snooze ran out: wakes
+		testPR(5, now, "wip"),            // muted by label
+	}
+
+	doc := org.NewDBClient(db, org.BaseOrgSerializer{})
+	section, err := doc.GetSection("Reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.DiscardHandler)
+	changes := make(chan FileChanges)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	synced := 0
+	go func() {
+		// Rendering the items needs the API, so only count the changes
+		for range changes {
+			synced++
+			wg.Done()
+		}
+		close(done)
+	}()
+	ProcessPRsDB(log, prs, changes, doc, section, &wg, "Keep", false)
+	close(changes)
+	<-done
+	wg.Wait()
+	if synced != len(prs) {
+		t.Errorf("synced %d PRs, want hidden PRs synced too", synced)
+	}
+
+	hidden, err := db.GetHiddenItems(now.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"org/api-2": database.HiddenSnoozed, "org/api-3": database.HiddenSnoozed, "org/api-5": database.HiddenMuted}
+	got := hidden[section.ID]
+	if len(got) != len(want) {
+		t.Errorf("hidden items = %v, want %v", got, want)
+	}
+	for identifier, reason := range want {
+		if got[identifier] != reason {
+			t.Errorf("hidden[%s] = %q, want %q", identifier, got[identifier], reason)
+		}
+	}
+
+	snoozes, _ := db.GetSnoozes()
+	if _, ok := snoozes["org/api-1"]; ok || len(snoozes) != 2 {
+		t.Errorf("snoozes after sync = %v, want the woken ones deleted", snoozes)
+	}
+}
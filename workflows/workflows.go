@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"crs/config"
+	"crs/forge"
 	"crs/git_tools"
 	"crs/jira"
 	"crs/org"
@@ -9,7 +10,10 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 	"sync"
+
+	"github.com/google/go-github/v48/github"
 )
 
 type RunResult struct {
@@ -35,6 +39,13 @@ func (rr *RunResult) Process(output *FileChanges, c chan FileChanges, wg *sync.W
 	}
 }
 
+func (rr *RunResult) Add(other RunResult) {
+	rr.Added += other.Added
+	rr.Updated += other.Updated
+	rr.Deleted += other.Deleted
+	rr.Skipped += other.Skipped
+}
+
 func (rr *RunResult) Report() string {
 	return fmt.Sprintf("A: %d; U: %d; R: %d; S: %d", rr.Added, rr.Updated, rr.Deleted, rr.Skipped)
 }
@@ -58,19 +69,17 @@ func (w SingleRepoSyncReviewRequestsWorkflow) GetOrgSectionName() string {
 	return w.SectionTitle
 }
 
+func (w SingleRepoSyncReviewRequestsWorkflow) GetRepos() []string {
+	return []string{w.Repo}
+}
+
 func (w SingleRepoSyncReviewRequestsWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error) {
-	owner, repo, err := git_tools.ParseRepoName(w.Repo)
-	if err != nil {
+	if _, _, err := forge.ParseRepo(w.Repo); err != nil {
 		log.Error("Error parsing repo name", "repo", w.Repo, "error", err)
 		return RunResult{}, err
 	}
 
-	prs, err := git_tools.GetPRs(
-		git_tools.GetGithubClient(),
-		"open",
-		owner,
-		repo,
-	)
+	prs, complete, err := fetchPRs(log, "open", []string{w.Repo})
 	if err != nil {
 		log.Error("Error getting PRs", "error", err)
 		return RunResult{}, err
@@ -87,7 +96,7 @@ func (w SingleRepoSyncReviewRequestsWorkflow) Run(log *slog.Logger, c chan FileC
 
 	beforeCount, _ := db.GetItemCount()
 	log.Info("Starting workflow", "items_before", beforeCount)
-	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, w.Prune, w.IncludeDiff)
+	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, pruneMode(log, w.Prune, complete), w.IncludeDiff)
 	afterCount, _ := db.GetItemCount()
 	log.Info("Finished workflow", "items_after", afterCount)
 	return result, nil
@@ -108,8 +117,7 @@ type SyncReviewRequestsWorkflow struct {
 }
 
 func (w SyncReviewRequestsWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error) {
-	client := git_tools.GetGithubClient()
-	prs, err := git_tools.GetManyRepoPRs(client, "open", w.Repos)
+	prs, complete, err := fetchPRs(log, "open", w.Repos)
 	if err != nil {
 		log.Error("Error getting PRs", "error", err)
 		return RunResult{}, err
@@ -126,7 +134,7 @@ func (w SyncReviewRequestsWorkflow) Run(log *slog.Logger, c chan FileChanges, fi
 	
 	beforeCount, _ := db.GetItemCount()
 	log.Info("Starting workflow", "items_before", beforeCount)
-	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, w.Prune, w.IncludeDiff)
+	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, pruneMode(log, w.Prune, complete), w.IncludeDiff)
 	afterCount, _ := db.GetItemCount()
 	log.Info("Finished workflow", "items_after", afterCount)
 	return result, nil
@@ -140,6 +148,10 @@ func (w SyncReviewRequestsWorkflow) GetOrgSectionName() string {
 	return w.SectionTitle
 }
 
+func (w SyncReviewRequestsWorkflow) GetRepos() []string {
+	return w.Repos
+}
+
 type ListMyPRsWorkflow struct {
 	Name                string
 	Owner               string
@@ -160,9 +172,12 @@ func (w ListMyPRsWorkflow) GetOrgSectionName() string {
 	return w.SectionTitle
 }
 
+func (w ListMyPRsWorkflow) GetRepos() []string {
+	return w.Repos
+}
+
 func (w ListMyPRsWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error) {
-	client := git_tools.GetGithubClient()
-	prs, err := git_tools.GetManyRepoPRs(client, w.PRState, w.Repos)
+	prs, complete, err := fetchPRs(log, w.PRState, w.Repos)
 	if err != nil {
 		log.Error("Error getting PRs", "error", err)
 		return RunResult{}, err
@@ -180,20 +195,99 @@ func (w ListMyPRsWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change
 	
 	beforeCount, _ := db.GetItemCount()
 	log.Info("Starting workflow", "items_before", beforeCount)
-	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, w.Prune, w.IncludeDiff)
+	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, pruneMode(log, w.Prune, complete), w.IncludeDiff)
 	afterCount, _ := db.GetItemCount()
 	log.Info("Finished workflow", "items_after", afterCount)
 	return result, nil
 }
 
+// fetchPRs lists the PRs of several repos. GitHub repos go through the GraphQL bulk fetcher, which
+// also caches what the smart filters need, and fall back to the REST API if GraphQL is unavailable.
+// Repos on other GitHub hosts are fetched with their host's client, and gitlab: repos are listed
+// through the GitLab forge. A host or project that fails is logged and skipped, and complete is
+// false so the caller doesn't prune its items; only when every one fails is an error returned.
+func fetchPRs(log *slog.Logger, state string, repos []string) (prs []*github.PullRequest, complete bool, err error) {
+	githubRepos := map[string][]string{}
+	hosts := []string{}
+	gitlabPRs := []*github.PullRequest{}
+	sources, failed := 0, 0
+	for _, entry := range repos {
+		if !forge.IsGitLabEntry(entry) {
+			host, name := config.SplitHost(entry)
+			if host == "" {
+				// Unprefixed repos may still belong to a host through its Owners
+				owner, repo, _ := strings.Cut(name, "/")
+				if h := config.C.HostFor(owner, repo); h != nil {
+					host = h.Host
+				}
+			}
+			if _, ok := githubRepos[host]; !ok {
+				hosts = append(hosts, host)
+			}
+			githubRepos[host] = append(githubRepos[host], name)
+			continue
+		}
+		owner, repo, parseErr := forge.ParseRepo(entry)
+		if parseErr != nil {
+			log.Error("Skipping invalid repo entry", "entry", entry, "error", parseErr)
+			continue
+		}
+		sources++
+		mrs, listErr := forge.NewGitLab().ListPRs(owner, repo, state)
+		if listErr != nil {
+			log.Error("Error getting merge requests, skipping project", "entry", entry, "error", listErr)
+			failed, err = failed+1, listErr
+			continue
+		}
+		gitlabPRs = append(gitlabPRs, mrs...)
+	}
+
+	for _, host := range hosts {
+		sources++
+		client, clientErr := git_tools.GetHostClient(config.C.Host(host))
+		if clientErr != nil {
+			log.Error("Error creating GitHub client, skipping host", "host", host, "error", clientErr)
+			failed, err = failed+1, clientErr
+			continue
+		}
+		hostPRs, listErr := git_tools.GetManyRepoPRsGraphQL(client, state, githubRepos[host])
+		if listErr != nil {
+			log.Warn("GraphQL PR fetch failed, falling back to REST", "host", host, "error", listErr)
+			hostPRs, listErr = git_tools.GetManyRepoPRs(client, state, githubRepos[host])
+			if listErr != nil {
+				log.Error("Error getting PRs, skipping host", "host", host, "error", listErr)
+				failed, err = failed+1, listErr
+				continue
+			}
+		}
+		prs = append(prs, hostPRs...)
+	}
+	if sources > 0 && failed == sources {
+		return nil, false, err
+	}
+	return append(prs, gitlabPRs...), failed == 0, nil
+}
+
+// pruneMode returns the Prune of a workflow, or Keep when some of its repos or PRs could not be
+// fetched, so their items aren't taken for closed PRs.
+func pruneMode(log *slog.Logger, prune string, complete bool) string {
+	if complete || (prune != "Delete" && prune != "Archive") {
+		return prune
+	}
+	log.Warn("Not pruning, some PRs could not be fetched", "prune", prune)
+	return "Keep"
+}
+
+// ProjectListWorkflow syncs the PRs linked to the Jira issues matching a JQL query (or the
+// children of an epic), tagging each item with its issue's key, status and assignee.
 type ProjectListWorkflow struct {
 	Name                string
-	Owner               string
-	Repo                string
+	Repos               []string // "owner/repo" entries the linked PRs must belong to
 	Filters             []git_tools.PRFilter
 	SectionTitle        string
 	JiraDomain          string
 	JiraEpic            string
+	JQL                 string // Takes precedence over JiraEpic
 	ReleaseCheckCommand string
 	Prune               string
 	IncludeDiff         bool
@@ -207,8 +301,21 @@ func (w ProjectListWorkflow) GetOrgSectionName() string {
 	return w.SectionTitle
 }
 
+func (w ProjectListWorkflow) GetRepos() []string {
+	return w.Repos
+}
+
+func (w ProjectListWorkflow) query() string {
+	if w.JQL != "" {
+		return w.JQL
+	}
+	if w.JiraEpic != "" {
+		return jira.EpicJQL(w.JiraEpic)
+	}
+	return ""
+}
+
 func (w ProjectListWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error) {
-	client := git_tools.GetGithubClient()
 	db := config.C.DB
 	doc := org.NewDBClient(db, org.BaseOrgSerializer{ReleaseCheckCommand: w.ReleaseCheckCommand})
 
@@ -216,18 +323,105 @@ func (w ProjectListWorkflow) Run(log *slog.Logger, c chan FileChanges, file_chan
 	if err != nil {
 		return RunResult{}, errors.New("Section Not Found")
 	}
-	if w.JiraEpic == "" {
+	jql := w.query()
+	if jql == "" {
 		// I used to let just define []int for PR #s in config, could easily bring that back
-		return RunResult{}, errors.New("ProjectList requires Jira Epic")
+		return RunResult{}, errors.New("ProjectList requires a JQL query or Jira Epic")
+	}
+	issues, err := jira.SearchIssues(w.JiraDomain, jql)
+	if err != nil {
+		log.Error("Error searching Jira", "error", err, "jql", jql)
+		return RunResult{}, err
 	}
-	projectPRs := jira.GetProjectPRKeys(w.JiraDomain, w.JiraEpic, w.Repo)
 
-	prs, err := git_tools.GetSpecificPRs(client, w.Owner, w.Repo, projectPRs)
+	bridges := []PRToOrgBridge{}
+	complete := true
+	seen := map[string]bool{}
+	repos := []string{}
+	for _, entry := range w.Repos {
+		_, name := config.SplitHost(entry)
+		repos = append(repos, name)
+	}
+	for _, link := range jira.GetLinkedPRs(w.JiraDomain, issues, repos) {
+		// A PR linked from several issues is tagged with the first one
+		id := fmt.Sprintf("%s/%s-%d", link.Owner, link.Repo, link.Number)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		client := git_tools.GetGithubClientFor(link.Owner, link.Repo)
+		prs, err := git_tools.GetSpecificPRs(client, link.Owner, link.Repo, []int{link.Number})
+		if err != nil {
+			log.Error("Error getting linked PR", "issue", link.Issue.Key, "error", err)
+			complete = false
+			continue
+		}
+		if len(git_tools.ApplyPRFilters(prs, w.Filters)) == 0 {
+			continue
+		}
+		issue := link.Issue
+		bridges = append(bridges, PRToOrgBridge{PR: prs[0], IncludeDiff: w.IncludeDiff, JiraIssue: &issue, JiraDomain: w.JiraDomain})
+	}
+
+	beforeCount, _ := db.GetItemCount()
+	log.Info("Starting workflow", "items_before", beforeCount)
+	result := ProcessBridgesDB(log, bridges, c, doc, section, file_change_wg, pruneMode(log, w.Prune, complete))
+	afterCount, _ := db.GetItemCount()
+	log.Info("Finished workflow", "items_after", afterCount)
+	return result, nil
+}
+
+// SearchWorkflow syncs the PRs matching a GitHub search query, so a section can cover a whole
+// org without listing its repos.
+type SearchWorkflow struct {
+	Name                string
+	Host                string // GithubHosts entry to search; github.com if empty
+	Query               string // e.g. "is:pr is:open review-requested:@me org:acme"
+	Filters             []git_tools.PRFilter
+	SectionTitle        string
+	ReleaseCheckCommand string
+	Prune               string
+	IncludeDiff         bool
+}
+
+func (w SearchWorkflow) GetName() string {
+	return w.Name
+}
+
+func (w SearchWorkflow) GetOrgSectionName() string {
+	return w.SectionTitle
+}
+
+// GetRepos returns the repos named with repo: in the query; org-wide searches only run on the sync cycle.
+func (w SearchWorkflow) GetRepos() []string {
+	return git_tools.SearchQueryRepos(w.Query)
+}
+
+func (w SearchWorkflow) Run(log *slog.Logger, c chan FileChanges, file_change_wg *sync.WaitGroup) (RunResult, error) {
+	client, err := git_tools.GetHostClient(config.C.Host(w.Host))
 	if err != nil {
-		log.Error("Error getting specific PRs", "error", err)
+		log.Error("Error creating GitHub client", "host", w.Host, "error", err)
 		return RunResult{}, err
 	}
-	
+	prs, err := git_tools.SearchPRsGraphQL(client, w.Query)
+	if err != nil {
+		log.Warn("GraphQL search failed, falling back to REST", "error", err)
+		prs, err = git_tools.SearchPRs(client, w.Query)
+	}
+	if err != nil {
+		log.Error("Error searching PRs", "error", err, "query", w.Query)
+		return RunResult{}, err
+	}
+
+	prs = git_tools.ApplyPRFilters(prs, w.Filters)
+	db := config.C.DB
+	doc := org.NewDBClient(db, org.BaseOrgSerializer{ReleaseCheckCommand: w.ReleaseCheckCommand})
+	section, err := doc.GetSection(w.SectionTitle)
+	if err != nil {
+		log.Error("Error getting section", "error", err, "section", w.SectionTitle)
+		return RunResult{}, errors.New("Section Not Found")
+	}
+
 	beforeCount, _ := db.GetItemCount()
 	log.Info("Starting workflow", "items_before", beforeCount)
 	result := ProcessPRsDB(log, prs, c, doc, section, file_change_wg, w.Prune, w.IncludeDiff)
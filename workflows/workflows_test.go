@@ -0,0 +1,57 @@
+package workflows
+
+import (
+	"crs/config"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchPRs_RoutesByOwnerAndSkipsFailingHosts(t *testing.T) {
+	ghe := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/repos/acme/api/pulls"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"number":1,"base":{"repo":{"full_name":"acme/api"}}}]`))
+		default:
+			// GraphQL is unavailable, so the REST fallback lists the PRs
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		}
+	}))
+	defer ghe.Close()
+	gitlab := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer gitlab.Close()
+
+	saved := config.C
+	t.Cleanup(func() { config.C = saved })
+	t.Setenv("CRS_GHE_TOKEN", "token")
+	t.Setenv("CRS_GITLAB_TOKEN", "token")
+	config.C = config.Config{
+		GitlabURL:   gitlab.URL,
+		GithubHosts: []config.GithubHost{{Host: "ghe.acme.com", BaseURL: ghe.URL + "/api/v3/", TokenEnv: "CRS_GHE_TOKEN", Owners: []string{"acme"}}},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	prs, complete, err := fetchPRs(log, "open", []string{"acme/api", "gitlab:org/broken"})
+	if err != nil {
+		t.Fatalf("fetchPRs() error = %v", err)
+	}
+	if len(prs) != 1 || prs[0].GetNumber() != 1 {
+		t.Fatalf("fetchPRs() = %v, want acme/api#1 from ghe.acme.com", prs)
+	}
+	if complete {
+		t.Error("fetchPRs() complete = true with a failing GitLab project")
+	}
+	if got := pruneMode(log, "Archive", complete); got != "Keep" {
+		t.Errorf("pruneMode() = %q after a partial fetch, want Keep", got)
+	}
+
+	if _, _, err := fetchPRs(log, "open", []string{"gitlab:org/broken"}); err == nil {
+		t.Error("fetchPRs() error = nil when every repo failed")
+	}
+}